@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// account, challenge, game and event are the parts of the bot API's
+// responses the bot uses.
+type account struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+}
+
+type challenge struct {
+	ID          string `json:"id"`
+	Challenger  string `json:"challenger"`
+	Game        string `json:"game"`
+	TimeControl string `json:"time_control"`
+	Rated       bool   `json:"rated"`
+}
+
+type move struct {
+	Move string `json:"move"`
+}
+
+type game struct {
+	ID      string `json:"id"`
+	Game    string `json:"game"`
+	FEN     string `json:"fen"`
+	Board   string `json:"board"`
+	Colour  string `json:"colour"`
+	Active  string `json:"active"`
+	Ended   bool   `json:"ended"`
+	Status  string `json:"status"`
+	History []move `json:"history"`
+}
+
+type event struct {
+	Type      string     `json:"type"`
+	Challenge *challenge `json:"challenge"`
+	Game      *game      `json:"game"`
+}
+
+// client makes requests to the bot API as one bot.
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func (c *client) do(method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.server+"/api/v1/bot/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		failure := struct {
+			Error string `json:"error"`
+		}{}
+		json.NewDecoder(res.Body).Decode(&failure)
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, res.Status, failure.Error)
+	}
+
+	return res, nil
+}
+
+// post sends a request that's only answered with success or an error.
+func (c *client) post(path string) error {
+	res, err := c.do(http.MethodPost, path)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, res.Body)
+
+	return res.Body.Close()
+}
+
+func (c *client) account() (account, error) {
+	res, err := c.do(http.MethodGet, "account")
+	if err != nil {
+		return account{}, err
+	}
+	defer res.Body.Close()
+
+	a := account{}
+	err = json.NewDecoder(res.Body).Decode(&a)
+
+	return a, err
+}
+
+// stream reads a newline-delimited JSON stream, handing each event to handle
+// until the stream ends or handle asks to stop.
+func (c *client) stream(path string, handle func(event) bool) error {
+	res, err := c.do(http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// empty lines keep the stream alive
+		if line == "" {
+			continue
+		}
+
+		e := event{}
+		err := json.Unmarshal([]byte(line), &e)
+		if err != nil {
+			return err
+		}
+		if !handle(e) {
+			return nil
+		}
+	}
+
+	err = scanner.Err()
+	if err == nil {
+		err = errors.New("stream closed")
+	}
+	return err
+}
+
+func (c *client) accept(id string) error {
+	return c.post("challenge/" + url.PathEscape(id) + "/accept")
+}
+
+func (c *client) decline(id string) error {
+	return c.post("challenge/" + url.PathEscape(id) + "/decline")
+}
+
+func (c *client) move(id, m string) error {
+	return c.post("game/" + url.PathEscape(id) + "/move/" + url.PathEscape(m))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+// engine picks moves with the built-in engines, from the position the server
+// sends.
+type engine struct {
+	depth      int
+	searchTime time.Duration
+}
+
+func (e engine) move(g game) (string, error) {
+	switch g.Game {
+	case "chess":
+		position := chess.NewGame()
+		err := position.SetStateFromFEN(g.FEN)
+		if err != nil {
+			return "", err
+		}
+
+		position.MaxSearchDepth = e.depth
+		position.SearchTime = e.searchTime
+		return position.BestMove().UCI(), nil
+	case "tictactoe":
+		position := tictactoe.NewGame()
+		err := position.FromString(g.Board)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(position.BestMove()), nil
+	}
+
+	return "", fmt.Errorf("unknown game %q", g.Game)
+}
@@ -0,0 +1,166 @@
+// Bot plays on a gomes server through the bot API, with the same engines
+// the server's own bot uses. It accepts challenges to the bot account its
+// token belongs to, and plays every game the account is seated in.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retry is how long to wait before reconnecting to the event stream.
+const retry = 5 * time.Second
+
+type bot struct {
+	client   *client
+	engine   engine
+	games    map[string]bool
+	accept   []string
+	maxGames int
+	mu       sync.Mutex
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "URL of the gomes server")
+	token := flag.String("token", os.Getenv("GOMES_BOT_TOKEN"), "the bot account's token, defaults to $GOMES_BOT_TOKEN")
+	depth := flag.Int("depth", 4, "maximum chess search depth in plies")
+	searchTime := flag.Duration("time", 2*time.Second, "maximum time to think about a chess move")
+	games := flag.String("games", "chess,tictactoe", "comma separated games to accept challenges for")
+	maxGames := flag.Int("concurrency", 4, "most games to play at once, further challenges are declined")
+	flag.Parse()
+
+	if *token == "" {
+		fmt.Println("a bot token is needed, pass -token or set GOMES_BOT_TOKEN")
+		os.Exit(2)
+	}
+
+	b := &bot{
+		client: &client{
+			server: strings.TrimRight(*server, "/"),
+			token:  *token,
+			http:   &http.Client{},
+		},
+		engine:   engine{depth: *depth, searchTime: *searchTime},
+		games:    make(map[string]bool),
+		accept:   strings.Split(*games, ","),
+		maxGames: *maxGames,
+	}
+
+	account, err := b.client.account()
+	if err != nil {
+		fmt.Println("error signing in:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("playing as %s, run by %s\n", account.Name, account.Owner)
+
+	for {
+		err := b.client.stream("stream/event", b.handle)
+		fmt.Printf("event stream ended: %s, reconnecting in %s\n", err, retry)
+		time.Sleep(retry)
+	}
+}
+
+// handle answers an event from the bot's event stream.
+func (b *bot) handle(e event) bool {
+	switch {
+	case e.Type == "challenge" && e.Challenge != nil:
+		b.answer(*e.Challenge)
+	case e.Type == "gameStart" && e.Game != nil:
+		b.start(e.Game.ID)
+	case e.Type == "gameFinish" && e.Game != nil:
+		fmt.Printf("game %s is over: %s\n", e.Game.ID, e.Game.Status)
+	}
+
+	return true
+}
+
+// answer accepts a challenge for a game the bot plays while it has room for
+// another game, and declines it otherwise.
+func (b *bot) answer(c challenge) {
+	b.mu.Lock()
+	busy := len(b.games) >= b.maxGames
+	b.mu.Unlock()
+
+	supported := false
+	for _, name := range b.accept {
+		supported = supported || strings.TrimSpace(name) == c.Game
+	}
+
+	if busy || !supported {
+		fmt.Printf("declining %s's challenge to %s\n", c.Challenger, c.Game)
+		err := b.client.decline(c.ID)
+		if err != nil {
+			fmt.Println("error declining challenge:", err)
+		}
+		return
+	}
+
+	fmt.Printf("accepting %s's challenge to %s\n", c.Challenger, c.Game)
+	err := b.client.accept(c.ID)
+	if err != nil {
+		fmt.Println("error accepting challenge:", err)
+	}
+}
+
+// start plays a game in the background, unless it's already being played.
+func (b *bot) start(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.games[id] {
+		return
+	}
+	b.games[id] = true
+
+	go func() {
+		err := b.play(id)
+		if err != nil {
+			fmt.Printf("error playing game %s: %s\n", id, err)
+		}
+
+		b.mu.Lock()
+		delete(b.games, id)
+		b.mu.Unlock()
+	}()
+}
+
+// play follows a game's stream, moving whenever it's the bot's turn, until
+// the game is over.
+func (b *bot) play(id string) error {
+	// the stream repeats positions when only the clock or spectators change,
+	// so each position is only answered once
+	answered := -1
+	var failure error
+
+	err := b.client.stream("game/stream/"+id, func(e event) bool {
+		g := e.Game
+		if g == nil || g.Ended {
+			return false
+		}
+		if g.Active != g.Colour || len(g.History) == answered {
+			return true
+		}
+		answered = len(g.History)
+
+		m, err := b.engine.move(*g)
+		if err == nil {
+			err = b.client.move(id, m)
+		}
+		if err != nil {
+			failure = err
+			return false
+		}
+
+		return true
+	})
+	if failure != nil {
+		return failure
+	}
+
+	return err
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+type result struct {
+	id       string
+	solved   bool
+	played   string
+	expected string
+	nodes    int
+	elapsed  time.Duration
+	solvedAt time.Duration
+}
+
+func main() {
+	searchTime := flag.Duration("time", 5*time.Second, "search time per position")
+	depth := flag.Int("depth", 0, "max search depth in ply per position, 0 searches until the time runs out")
+	limit := flag.Int("n", 0, "only run the first n positions of each file, 0 runs them all")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: epdtest [flags] <suite.epd>...\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *depth > 0 && !isFlagSet("time") {
+		// a pure depth budget shouldn't be cut short by the default time
+		*searchTime = 24 * time.Hour
+	}
+
+	chess.InitLookups()
+
+	results := []result{}
+	for _, path := range flag.Args() {
+		suite, err := loadSuite(path)
+		if err != nil {
+			fmt.Println("error loading suite:", err)
+			os.Exit(1)
+		}
+
+		if *limit > 0 && len(suite) > *limit {
+			suite = suite[:*limit]
+		}
+
+		fmt.Printf("running %d positions from %s\n", len(suite), path)
+		for i, epd := range suite {
+			res, err := runPosition(epd, *depth, *searchTime)
+			if err != nil {
+				fmt.Printf("skipping position %d of %s: %s\n", i+1, path, err)
+				continue
+			}
+			if res.id == "" {
+				res.id = fmt.Sprintf("%s#%d", path, i+1)
+			}
+
+			results = append(results, res)
+			printResult(res)
+		}
+	}
+
+	printSummary(results)
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+func loadSuite(path string) ([]chess.EPD, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return chess.ReadEPD(f)
+}
+
+func runPosition(epd chess.EPD, depth int, searchTime time.Duration) (result, error) {
+	res := result{
+		id:       epd.ID(),
+		solvedAt: -1,
+	}
+
+	if len(epd.BestMoves()) == 0 && len(epd.AvoidMoves()) == 0 {
+		return res, fmt.Errorf("no bm or am opcode")
+	}
+
+	game := chess.NewGame()
+	err := game.SetStateFromFEN(epd.FEN)
+	if err != nil {
+		return res, err
+	}
+	game.MaxSearchDepth = depth
+	game.SearchTime = searchTime
+
+	// the solution time is the start of the final run of iterations whose best
+	// move solves the position, so a lucky early iteration doesn't count
+	game.OnSearchInfo = func(info chess.SearchInfo) {
+		res.nodes = info.Nodes
+		if !epd.Solved(game.MoveToSAN(info.Best)) {
+			res.solvedAt = -1
+		} else if res.solvedAt == -1 {
+			res.solvedAt = info.Elapsed
+		}
+	}
+
+	start := time.Now()
+	options, _ := game.Search()
+	res.elapsed = time.Since(start)
+
+	if len(options) == 0 {
+		return res, fmt.Errorf("no legal moves in %s", epd.FEN)
+	}
+
+	// Search sorts ascending, so white's best move is the last option
+	best := options[0]
+	if game.EBE.Active<<3 == chess.WHITE {
+		best = options[len(options)-1]
+	}
+
+	res.played = game.MoveToSAN(best)
+	res.solved = epd.Solved(res.played)
+	if !res.solved {
+		res.solvedAt = -1
+	}
+
+	if len(epd.BestMoves()) > 0 {
+		res.expected = fmt.Sprintf("bm %v", epd.BestMoves())
+	} else {
+		res.expected = fmt.Sprintf("am %v", epd.AvoidMoves())
+	}
+
+	return res, nil
+}
+
+func nodesPerSecond(nodes int, elapsed time.Duration) int {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return int(float64(nodes) / elapsed.Seconds())
+}
+
+func printResult(res result) {
+	status := "FAIL"
+	solvedAt := "-"
+	if res.solved {
+		status = "ok"
+		solvedAt = fmt.Sprintf("%dms", res.solvedAt.Milliseconds())
+	}
+
+	fmt.Printf("%-4s %-20s played %-8s %-20s solved at %-8s %10d nodes %8d nps\n", status, res.id, res.played, res.expected, solvedAt, res.nodes, nodesPerSecond(res.nodes, res.elapsed))
+}
+
+func printSummary(results []result) {
+	if len(results) == 0 {
+		fmt.Println("no positions were run")
+		return
+	}
+
+	solved := 0
+	nodes := 0
+	elapsed := time.Duration(0)
+	solveTime := time.Duration(0)
+	for _, res := range results {
+		nodes += res.nodes
+		elapsed += res.elapsed
+		if res.solved {
+			solved++
+			solveTime += res.solvedAt
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("solved:              %d/%d (%.1f%%)\n", solved, len(results), 100*float64(solved)/float64(len(results)))
+	fmt.Printf("total nodes:         %d\n", nodes)
+	fmt.Printf("total time:          %dms\n", elapsed.Milliseconds())
+	fmt.Printf("nodes per second:    %d\n", nodesPerSecond(nodes, elapsed))
+	if solved > 0 {
+		fmt.Printf("avg time-to-solve:   %dms\n", (solveTime / time.Duration(solved)).Milliseconds())
+	}
+}
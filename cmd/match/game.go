@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+type engine struct {
+	name     string
+	depth    int
+	time     time.Duration
+	weights  chess.EvalWeights
+	ordering bool
+}
+
+func (e engine) configure(game *chess.ChessGame) {
+	game.MaxSearchDepth = e.depth
+	game.SearchTime = e.time
+	game.Weights = e.weights
+	game.MoveOrdering = e.ordering
+}
+
+type adjudication struct {
+	maxMoves int
+
+	resignScore float64
+	resignMoves int
+
+	drawScore float64
+	drawMoves int
+	drawStart int
+}
+
+type outcome struct {
+	result      string
+	termination string
+	moves       []string
+}
+
+func positionKey(game *chess.ChessGame) string {
+	fields := strings.Fields(game.EBE.ToFEN())
+	return strings.Join(fields[:4], " ")
+}
+
+func insufficientMaterial(game *chess.ChessGame) bool {
+	minors := 0
+	for _, piece := range game.EBE.Board {
+		switch piece & 0b0111 {
+		case chess.PAWN, chess.ROOK, chess.QUEEN:
+			return false
+		case chess.KNIGHT, chess.BISHOP:
+			minors++
+		}
+	}
+
+	return minors <= 1
+}
+
+func winFor(active int) string {
+	if active<<3 == chess.WHITE {
+		return "1-0"
+	}
+
+	return "0-1"
+}
+
+func playGame(white, black engine, fen string, adj adjudication) outcome {
+	// openings are validated when they're loaded
+	game := chess.NewGame()
+	game.SetStateFromFEN(fen)
+
+	out := outcome{}
+	repetitions := map[string]int{}
+
+	resignStreak := 0
+	resignSide := 0
+	drawStreak := 0
+
+	for ply := 0; ; ply++ {
+		key := positionKey(game)
+		repetitions[key]++
+
+		active := game.EBE.Active
+		switch {
+		case len(game.GetLegalMoves()) == 0:
+			if game.Bitboard.InCheck(active << 3) {
+				out.result, out.termination = winFor(^active&0b1), "checkmate"
+			} else {
+				out.result, out.termination = "1/2-1/2", "stalemate"
+			}
+		case repetitions[key] >= 3:
+			out.result, out.termination = "1/2-1/2", "threefold repetition"
+		case game.EBE.Halfmoves >= 100:
+			out.result, out.termination = "1/2-1/2", "fifty move rule"
+		case insufficientMaterial(game):
+			out.result, out.termination = "1/2-1/2", "insufficient material"
+		case adj.maxMoves > 0 && ply >= 2*adj.maxMoves:
+			out.result, out.termination = "1/2-1/2", "adjudication: move limit"
+		}
+		if out.result != "" {
+			return out
+		}
+
+		eng := white
+		if active<<3 == chess.BLACK {
+			eng = black
+		}
+		eng.configure(game)
+
+		score := 0.0
+		game.OnSearchInfo = func(info chess.SearchInfo) {
+			score = info.Value
+		}
+
+		move := game.BestMove()
+		out.moves = append(out.moves, game.MoveToSAN(move))
+		game.MakeMove(move)
+
+		// scores are from white's point of view, so both engines agreeing on
+		// a large score in the same direction means the game is decided
+		side := 0
+		if score >= adj.resignScore {
+			side = 1
+		} else if score <= -adj.resignScore {
+			side = -1
+		}
+		if adj.resignMoves > 0 && side != 0 && side == resignSide {
+			resignStreak++
+		} else {
+			resignStreak = 1
+			resignSide = side
+		}
+		if adj.resignMoves > 0 && resignSide != 0 && resignStreak >= 2*adj.resignMoves {
+			out.termination = "adjudication: score"
+			out.result = "1-0"
+			if resignSide < 0 {
+				out.result = "0-1"
+			}
+			return out
+		}
+
+		if adj.drawMoves > 0 && game.EBE.Moves >= adj.drawStart && math.Abs(score) <= adj.drawScore {
+			drawStreak++
+		} else {
+			drawStreak = 0
+		}
+		if adj.drawMoves > 0 && drawStreak >= 2*adj.drawMoves {
+			out.result, out.termination = "1/2-1/2", "adjudication: draw score"
+			return out
+		}
+	}
+}
+
+func describe(e engine) string {
+	budget := e.time.String()
+	if e.depth > 0 {
+		budget = fmt.Sprintf("depth %d, %s", e.depth, e.time)
+	}
+
+	return fmt.Sprintf("%s (%s, ordering %v)", e.name, budget, e.ordering)
+}
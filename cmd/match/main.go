@@ -0,0 +1,263 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+type job struct {
+	round        int
+	fen          string
+	engine1White bool
+}
+
+type finished struct {
+	job
+	outcome
+}
+
+func main() {
+	engine1Spec := flag.String("engine1", "name=engine1", "first engine, as comma separated key=value pairs (name, depth, time, weights, ordering)")
+	engine2Spec := flag.String("engine2", "name=engine2", "second engine, same format as -engine1")
+	games := flag.Int("games", 100, "maximum number of games, played in pairs with colours swapped")
+	concurrency := flag.Int("concurrency", 2, "number of games played in parallel")
+	openingsPath := flag.String("openings", "", "EPD or FEN file of opening positions, defaults to the starting position")
+	pgnPath := flag.String("pgn", "match.pgn", "file the games are written to")
+
+	maxMoves := flag.Int("maxmoves", 200, "adjudicate a draw after this many moves, 0 disables")
+	resignScore := flag.Float64("resign-score", 100, "score both engines must agree on to adjudicate a win")
+	resignMoves := flag.Int("resign-moves", 4, "consecutive moves per side beyond the resign score, 0 disables")
+	drawScore := flag.Float64("draw-score", 5, "score both engines must stay within to adjudicate a draw")
+	drawMoves := flag.Int("draw-moves", 8, "consecutive moves per side within the draw score, 0 disables")
+	drawStart := flag.Int("draw-start", 40, "first move number at which draw adjudication applies")
+
+	sprt := flag.Bool("sprt", false, "stop as soon as the SPRT accepts either hypothesis")
+	elo0 := flag.Float64("elo0", 0, "SPRT null hypothesis Elo difference")
+	elo1 := flag.Float64("elo1", 10, "SPRT alternative hypothesis Elo difference")
+	alpha := flag.Float64("alpha", 0.05, "SPRT false positive rate")
+	beta := flag.Float64("beta", 0.05, "SPRT false negative rate")
+	flag.Parse()
+
+	engine1, err := parseEngine(*engine1Spec)
+	if err != nil {
+		fmt.Println("error parsing -engine1:", err)
+		os.Exit(2)
+	}
+	engine2, err := parseEngine(*engine2Spec)
+	if err != nil {
+		fmt.Println("error parsing -engine2:", err)
+		os.Exit(2)
+	}
+
+	// openings are checked for a side in check, which needs the lookups
+	chess.InitLookups()
+
+	openings := []string{chess.StartingFEN}
+	if *openingsPath != "" {
+		openings, err = loadOpenings(*openingsPath)
+		if err != nil {
+			fmt.Println("error loading openings:", err)
+			os.Exit(1)
+		}
+	}
+
+	pgnFile, err := os.Create(*pgnPath)
+	if err != nil {
+		fmt.Println("error creating pgn file:", err)
+		os.Exit(1)
+	}
+	defer pgnFile.Close()
+
+	adj := adjudication{
+		maxMoves:    *maxMoves,
+		resignScore: *resignScore,
+		resignMoves: *resignMoves,
+		drawScore:   *drawScore,
+		drawMoves:   *drawMoves,
+		drawStart:   *drawStart,
+	}
+
+	fmt.Printf("engine1: %s\nengine2: %s\n", describe(engine1), describe(engine2))
+	fmt.Printf("playing up to %d games from %d openings, %d at a time\n", *games, len(openings), *concurrency)
+
+	jobs := make(chan job)
+	results := make(chan finished)
+	stop := make(chan struct{})
+
+	go func() {
+		defer close(jobs)
+		for round := 0; round < *games; round++ {
+			j := job{
+				round:        round + 1,
+				fen:          openings[(round/2)%len(openings)],
+				engine1White: round%2 == 0,
+			}
+
+			select {
+			case jobs <- j:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for range max(1, *concurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				white, black := engine1, engine2
+				if !j.engine1White {
+					white, black = engine2, engine1
+				}
+
+				results <- finished{j, playGame(white, black, j.fen, adj)}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	lower, upper := sprtBounds(*alpha, *beta)
+	t := tally{}
+	decision := ""
+	for res := range results {
+		score := 0.5
+		switch {
+		case res.result == "1-0" && res.engine1White, res.result == "0-1" && !res.engine1White:
+			score = 1
+		case res.result == "1-0", res.result == "0-1":
+			score = 0
+		}
+		t.add(score)
+
+		white, black := engine1.name, engine2.name
+		if !res.engine1White {
+			white, black = black, white
+		}
+
+		tags := map[string]string{
+			"Event":       fmt.Sprintf("%s vs %s", engine1.name, engine2.name),
+			"Site":        "gomes match",
+			"Date":        time.Now().Format("2006.01.02"),
+			"Round":       strconv.Itoa(res.round),
+			"White":       white,
+			"Black":       black,
+			"Termination": res.termination,
+		}
+		if res.fen != chess.StartingFEN {
+			tags["FEN"] = res.fen
+			tags["SetUp"] = "1"
+		}
+		fmt.Fprintln(pgnFile, chess.PGN{Tags: tags, Moves: res.moves, Result: res.result})
+
+		diff, margin := t.elo()
+		llr := t.llr(*elo0, *elo1)
+		fmt.Printf("game %d: %s vs %s %s (%s) | +%d -%d =%d | elo %+.1f ± %.1f | llr %.2f (%.2f, %.2f)\n", res.round, white, black, res.result, res.termination, t.wins, t.losses, t.draws, diff, margin, llr, lower, upper)
+
+		if *sprt && decision == "" {
+			switch {
+			case llr >= upper:
+				decision = fmt.Sprintf("H1 accepted: %s is at least %.1f Elo stronger", engine1.name, *elo1)
+			case llr <= lower:
+				decision = fmt.Sprintf("H0 accepted: %s is not %.1f Elo stronger", engine1.name, *elo1)
+			}
+			if decision != "" {
+				fmt.Printf("SPRT finished, waiting for games in progress: %s\n", decision)
+				close(stop)
+			}
+		}
+	}
+
+	diff, margin := t.elo()
+	fmt.Println()
+	fmt.Printf("games:  %d (+%d -%d =%d)\n", t.games(), t.wins, t.losses, t.draws)
+	fmt.Printf("score:  %.1f%%\n", 100*t.score())
+	fmt.Printf("elo:    %+.1f ± %.1f (95%%)\n", diff, margin)
+	if *sprt {
+		if decision == "" {
+			decision = "inconclusive"
+		}
+		fmt.Printf("sprt:   llr %.2f (%.2f, %.2f), %s\n", t.llr(*elo0, *elo1), lower, upper, decision)
+	}
+}
+
+func parseEngine(spec string) (engine, error) {
+	e := engine{
+		name:     "engine",
+		time:     100 * time.Millisecond,
+		weights:  chess.DefaultWeights,
+		ordering: true,
+	}
+
+	for _, option := range strings.Split(spec, ",") {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return e, fmt.Errorf("option %q is not a key=value pair", option)
+		}
+
+		var err error
+		switch strings.TrimSpace(key) {
+		case "name":
+			e.name = value
+		case "depth":
+			e.depth, err = strconv.Atoi(value)
+		case "time":
+			e.time, err = time.ParseDuration(value)
+		case "weights":
+			e.weights, err = chess.ReadWeights(value)
+		case "ordering":
+			e.ordering, err = strconv.ParseBool(value)
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return e, err
+		}
+	}
+
+	return e, nil
+}
+
+func loadOpenings(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := chess.ReadEPD(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s contains no positions", path)
+	}
+
+	openings := []string{}
+	for _, record := range records {
+		opening := chess.EBE{}
+		err := opening.FromFEN(record.FEN)
+		if err != nil {
+			return nil, err
+		}
+		openings = append(openings, record.FEN)
+	}
+
+	return openings, nil
+}
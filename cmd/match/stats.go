@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+)
+
+// tally counts results from the first engine's point of view.
+type tally struct {
+	wins   int
+	losses int
+	draws  int
+}
+
+func (t *tally) add(score float64) {
+	switch score {
+	case 1:
+		t.wins++
+	case 0:
+		t.losses++
+	default:
+		t.draws++
+	}
+}
+
+func (t tally) games() int {
+	return t.wins + t.losses + t.draws
+}
+
+func (t tally) score() float64 {
+	if t.games() == 0 {
+		return 0.5
+	}
+
+	return (float64(t.wins) + float64(t.draws)/2) / float64(t.games())
+}
+
+// variance is the per-game variance of the score.
+func (t tally) variance() float64 {
+	n := float64(t.games())
+	if n == 0 {
+		return 0
+	}
+
+	s := t.score()
+	return (float64(t.wins)*math.Pow(1-s, 2) + float64(t.draws)*math.Pow(0.5-s, 2) + float64(t.losses)*math.Pow(s, 2)) / n
+}
+
+// elo returns the estimated Elo difference and the half width of its 95%
+// confidence interval.
+func (t tally) elo() (float64, float64) {
+	n := float64(t.games())
+	if n == 0 {
+		return 0, math.Inf(1)
+	}
+
+	s := t.score()
+	stderr := math.Sqrt(t.variance() / n)
+	low := eloFromScore(s - 1.96*stderr)
+	high := eloFromScore(s + 1.96*stderr)
+
+	return eloFromScore(s), (high - low) / 2
+}
+
+// llr is the generalised SPRT log-likelihood ratio of H1 (elo1) against H0
+// (elo0), using the normal approximation of the score distribution.
+func (t tally) llr(elo0, elo1 float64) float64 {
+	variance := t.variance()
+	if t.games() == 0 || variance == 0 {
+		return 0
+	}
+
+	s0 := scoreFromElo(elo0)
+	s1 := scoreFromElo(elo1)
+
+	return float64(t.games()) * (s1 - s0) * (2*t.score() - s0 - s1) / (2 * variance)
+}
+
+func sprtBounds(alpha, beta float64) (float64, float64) {
+	return math.Log(beta / (1 - alpha)), math.Log((1 - beta) / alpha)
+}
+
+func scoreFromElo(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+func eloFromScore(score float64) float64 {
+	if score <= 0 {
+		return math.Inf(-1)
+	}
+	if score >= 1 {
+		return math.Inf(1)
+	}
+	if score == 0.5 {
+		return 0
+	}
+
+	return -400 * math.Log10(1/score-1)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEloFromScore(t *testing.T) {
+	for _, elo := range []float64{-200, -50, 0, 35, 400} {
+		actual := eloFromScore(scoreFromElo(elo))
+		if math.Abs(actual-elo) > 1e-9 {
+			t.Errorf("Expected elo (%f) != round tripped elo (%f)", elo, actual)
+		}
+	}
+}
+
+func TestTallyElo(t *testing.T) {
+	even := tally{wins: 30, losses: 30, draws: 40}
+	diff, margin := even.elo()
+	if diff != 0 {
+		t.Errorf("Expected an even tally to give 0 elo, got %f", diff)
+	}
+	if margin <= 0 || math.IsInf(margin, 0) {
+		t.Errorf("Expected a finite positive margin, got %f", margin)
+	}
+
+	ahead := tally{wins: 60, losses: 20, draws: 20}
+	diff, _ = ahead.elo()
+	if diff <= 0 {
+		t.Errorf("Expected a winning tally to give positive elo, got %f", diff)
+	}
+}
+
+func TestTallyLLR(t *testing.T) {
+	lower, upper := sprtBounds(0.05, 0.05)
+	if math.Abs(lower+2.944) > 1e-3 || math.Abs(upper-2.944) > 1e-3 {
+		t.Errorf("Expected bounds of about ±2.944, got (%f, %f)", lower, upper)
+	}
+
+	strong := tally{wins: 400, losses: 200, draws: 400}
+	if llr := strong.llr(0, 10); llr < upper {
+		t.Errorf("Expected a +70 elo tally to accept H1, llr was %f", llr)
+	}
+
+	weak := tally{wins: 200, losses: 400, draws: 400}
+	if llr := weak.llr(0, 10); llr > lower {
+		t.Errorf("Expected a -70 elo tally to accept H0, llr was %f", llr)
+	}
+
+	if llr := (tally{}).llr(0, 10); llr != 0 {
+		t.Errorf("Expected an empty tally to have llr 0, got %f", llr)
+	}
+}
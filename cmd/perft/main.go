@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+type suiteEntry struct {
+	fen    string
+	counts map[int]int
+}
+
+func main() {
+	fen := flag.String("fen", chess.StartingFEN, "position to run perft on")
+	depth := flag.Int("depth", 4, "perft depth")
+	divide := flag.Bool("divide", false, "print the node count below each root move")
+	suitePath := flag.String("suite", "", "perft suite file (FEN ;D1 20 ;D2 400 ...) to check instead of a single position")
+	maxDepth := flag.Int("maxdepth", 0, "skip suite depths beyond this, 0 runs every depth in the file")
+	flag.Parse()
+
+	chess.InitLookups()
+
+	if *suitePath != "" {
+		if !runSuite(*suitePath, *maxDepth) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	game := chess.NewGame()
+	err := game.SetStateFromFEN(*fen)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	entries, nodes := game.Divide(*depth)
+	elapsed := time.Since(start)
+
+	if *divide {
+		for _, entry := range entries {
+			fmt.Printf("%s: %d\n", entry.Move, entry.Nodes)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Nodes searched: %d\n", nodes)
+	fmt.Printf("Time: %dms (%d nodes/second)\n", elapsed.Milliseconds(), int(float64(nodes)/max(elapsed.Seconds(), 1e-9)))
+}
+
+func runSuite(path string, maxDepth int) bool {
+	suite, err := loadSuite(path)
+	if err != nil {
+		fmt.Println("error loading suite:", err)
+		return false
+	}
+
+	passed, failed := 0, 0
+	for i, entry := range suite {
+		// suites may skip depths, such as ;D1 20 ;D3 8902
+		depths := make([]int, 0, len(entry.counts))
+		for depth := range entry.counts {
+			depths = append(depths, depth)
+		}
+		slices.Sort(depths)
+
+		for _, depth := range depths {
+			expected := entry.counts[depth]
+			if maxDepth > 0 && depth > maxDepth {
+				continue
+			}
+
+			game := chess.NewGame()
+			err := game.SetStateFromFEN(entry.fen)
+			if err != nil {
+				fmt.Printf("FAIL #%d %s\n", i+1, err)
+				failed++
+				break
+			}
+
+			start := time.Now()
+			_, actual := game.Divide(depth)
+			elapsed := time.Since(start)
+
+			status := "ok"
+			if actual != expected {
+				status = "FAIL"
+				failed++
+			} else {
+				passed++
+			}
+
+			fmt.Printf("%-4s #%d D%d %d (expected %d) %dms %s\n", status, i+1, depth, actual, expected, elapsed.Milliseconds(), entry.fen)
+		}
+	}
+
+	fmt.Printf("\npassed %d, failed %d\n", passed, failed)
+
+	return failed == 0
+}
+
+func loadSuite(path string) ([]suiteEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	suite := []suiteEntry{}
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		entry := suiteEntry{
+			fen:    strings.TrimSpace(parts[0]),
+			counts: map[int]int{},
+		}
+
+		for _, part := range parts[1:] {
+			fields := strings.Fields(part)
+			if len(fields) != 2 || !strings.HasPrefix(fields[0], "D") {
+				return nil, fmt.Errorf("line %d: can't parse %q as a depth and node count", lineNumber, part)
+			}
+
+			depth, err := strconv.Atoi(fields[0][1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			count, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			entry.counts[depth] = count
+		}
+
+		suite = append(suite, entry)
+	}
+
+	return suite, scanner.Err()
+}
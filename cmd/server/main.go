@@ -1,40 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/jfosburgh/gomes/internal/config"
 	"github.com/jfosburgh/gomes/internal/routes"
 )
 
 func main() {
 
-	handleSigTerm()
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, printConfig, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	// the configuration is printed even when it's invalid, to help find out
+	// why
+	if printConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
 	}
+	if printConfig {
+		return
+	}
+
+	slog.SetDefault(cfg.Log.Logger(os.Stderr))
 
-	router := routes.NewRouter()
+	server := routes.NewServer(cfg)
+	stopped := shutdownOnSignal(server, cfg.Shutdown.Timeout)
 
-	fmt.Printf("Starting server at http://localhost:%s\n", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		fmt.Println("ListenAndServe err:", err)
+	slog.Info("starting web server", "addr", cfg.HTTP.Addr, "tls", cfg.HTTP.TLS)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("can't serve the web server", "err", err)
 		os.Exit(1)
 	}
+
+	if err := <-stopped; err != nil {
+		slog.Error("error shutting down", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
 }
 
-func handleSigTerm() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+// shutdownOnSignal shuts the server down gracefully on SIGINT or SIGTERM,
+// giving it timeout to do so, and sends the result on the returned channel.
+// A second signal stops the server straight away.
+func shutdownOnSignal(server *routes.Server, timeout time.Duration) <-chan error {
+	stopped := make(chan error, 1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		<-c
-		fmt.Println("\nreceived sigterm, exiting")
-		os.Exit(1)
+		<-ctx.Done()
+		stop()
+		slog.Info("received signal, shutting down", "timeout", timeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		stopped <- server.Shutdown(ctx)
 	}()
+
+	return stopped
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+var results = map[string]float64{
+	"1-0":     1,
+	"0-1":     0,
+	"1/2-1/2": 0.5,
+}
+
+func main() {
+	pgnPaths := flag.String("pgn", "", "comma separated PGN files to extract labelled positions from, e.g. our own games or match runner output")
+	epdPaths := flag.String("epd", "", "comma separated EPD files of positions labelled with a c9 result opcode")
+	startPath := flag.String("start", "", "weights file to start from, defaults to the engine's current weights")
+	outPath := flag.String("out", "weights.json", "file the tuned weights are written to")
+	skip := flag.Int("skip", 8, "opening moves to skip in each game")
+	limit := flag.Int("positions", 0, "maximum number of positions to use, 0 uses them all")
+	iterations := flag.Int("iterations", 0, "maximum local search iterations, 0 runs until no weight improves")
+	flag.Parse()
+
+	if *pgnPaths == "" && *epdPaths == "" {
+		fmt.Println("at least one of -pgn or -epd is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	chess.InitLookups()
+
+	start := chess.DefaultWeights
+	if *startPath != "" {
+		var err error
+		start, err = chess.ReadWeights(*startPath)
+		if err != nil {
+			fmt.Println("error reading starting weights:", err)
+			os.Exit(1)
+		}
+	}
+
+	samples := []sample{}
+	for _, path := range splitPaths(*pgnPaths) {
+		extracted, err := samplesFromPGN(path, *skip)
+		if err != nil {
+			fmt.Println("error reading pgn:", err)
+			os.Exit(1)
+		}
+		samples = append(samples, extracted...)
+	}
+	for _, path := range splitPaths(*epdPaths) {
+		extracted, err := samplesFromEPD(path)
+		if err != nil {
+			fmt.Println("error reading epd:", err)
+			os.Exit(1)
+		}
+		samples = append(samples, extracted...)
+	}
+
+	if *limit > 0 && len(samples) > *limit {
+		samples = samples[:*limit]
+	}
+	if len(samples) == 0 {
+		fmt.Println("no quiet labelled positions found")
+		os.Exit(1)
+	}
+
+	weights := toVector(start)
+	k := fitScale(samples, weights)
+	fmt.Printf("tuning %d weights on %d positions, scale %.3f, starting error %.6f\n", len(parameters), len(samples), k, meanError(samples, weights, k))
+
+	tuned := localSearch(samples, weights, k, *iterations)
+	result := fromVector(start, tuned)
+
+	for i, p := range parameters {
+		fmt.Printf("%-14s %5.0f -> %5.0f\n", p.name, weights[i], tuned[i])
+	}
+
+	err := chess.WriteWeights(*outPath, result)
+	if err != nil {
+		fmt.Println("error writing weights:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote tuned weights to %s\n", *outPath)
+}
+
+func splitPaths(paths string) []string {
+	split := []string{}
+	for _, path := range strings.Split(paths, ",") {
+		if strings.TrimSpace(path) != "" {
+			split = append(split, strings.TrimSpace(path))
+		}
+	}
+
+	return split
+}
+
+// quiet positions are ones where the static evaluation is meaningful: the
+// side to move isn't in check and neither the last move nor the next one
+// changes the material balance.
+func quiet(game *chess.ChessGame, next chess.Move) bool {
+	if game.Bitboard.InCheck(game.EBE.Active << 3) {
+		return false
+	}
+
+	if next.Capture != chess.EMPTY || next.Promotion != chess.EMPTY {
+		return false
+	}
+
+	if len(game.Moves) > 0 {
+		last := game.Moves[len(game.Moves)-1]
+		if last.Capture != chess.EMPTY || last.Promotion != chess.EMPTY {
+			return false
+		}
+	}
+
+	return true
+}
+
+func samplesFromPGN(path string, skip int) ([]sample, error) {
+	samples := []sample{}
+
+	err := chess.ReadPGNGames(path, func(game *chess.ChessGame, ply int, move chess.Move, tags map[string]string) bool {
+		result, ok := results[tags["Result"]]
+		if !ok {
+			return false
+		}
+
+		if ply >= 2*skip && quiet(game, move) {
+			weights := game.Weights
+			samples = append(samples, sample{
+				features: extractFeatures(game),
+				result:   result,
+			})
+			game.Weights = weights
+		}
+
+		return true
+	})
+
+	return samples, err
+}
+
+func samplesFromEPD(path string) ([]sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := chess.ReadEPD(f)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []sample{}
+	for _, record := range records {
+		c9 := record.Operations["c9"]
+		if len(c9) == 0 {
+			continue
+		}
+
+		result, ok := results[c9[0]]
+		if !ok {
+			continue
+		}
+
+		game := chess.NewGame()
+		err := game.SetStateFromFEN(record.FEN)
+		if err != nil {
+			fmt.Println("skipping position:", err)
+			continue
+		}
+		samples = append(samples, sample{
+			features: extractFeatures(game),
+			result:   result,
+		})
+	}
+
+	return samples, nil
+}
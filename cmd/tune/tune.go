@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+// parameter is a single tunable weight. Material is linear in the weights,
+// so each position is reduced to one feature value per parameter and the
+// evaluation becomes a dot product.
+type parameter struct {
+	name  string
+	field func(w *chess.EvalWeights) *int
+}
+
+var parameters = []parameter{
+	{"queen", func(w *chess.EvalWeights) *int { return &w.Queen }},
+	{"rook", func(w *chess.EvalWeights) *int { return &w.Rook }},
+	{"bishop", func(w *chess.EvalWeights) *int { return &w.Bishop }},
+	{"knight", func(w *chess.EvalWeights) *int { return &w.Knight }},
+	{"pawn", func(w *chess.EvalWeights) *int { return &w.Pawn }},
+	{"doubled_pawn", func(w *chess.EvalWeights) *int { return &w.DoubledPawn }},
+	{"blocked_pawn", func(w *chess.EvalWeights) *int { return &w.BlockedPawn }},
+	{"isolated_pawn", func(w *chess.EvalWeights) *int { return &w.IsolatedPawn }},
+	{"mobility", func(w *chess.EvalWeights) *int { return &w.Mobility }},
+}
+
+type sample struct {
+	features []float64
+	result   float64
+}
+
+// extractFeatures evaluates the position once per parameter with only that
+// weight set, giving the white-relative contribution of a unit weight.
+func extractFeatures(game *chess.ChessGame) []float64 {
+	features := make([]float64, len(parameters))
+	for i, p := range parameters {
+		game.Weights = chess.EvalWeights{}
+		*p.field(&game.Weights) = 1
+		features[i] = float64(game.Material(chess.WHITE) - game.Material(chess.BLACK))
+	}
+
+	return features
+}
+
+func toVector(weights chess.EvalWeights) []float64 {
+	vector := make([]float64, len(parameters))
+	for i, p := range parameters {
+		vector[i] = float64(*p.field(&weights))
+	}
+
+	return vector
+}
+
+func fromVector(base chess.EvalWeights, vector []float64) chess.EvalWeights {
+	weights := base
+	for i, p := range parameters {
+		*p.field(&weights) = int(math.Round(vector[i]))
+	}
+
+	return weights
+}
+
+func evaluate(features, weights []float64) float64 {
+	score := 0.0
+	for i := range features {
+		score += features[i] * weights[i]
+	}
+
+	return score
+}
+
+func sigmoid(score, k float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*score/400))
+}
+
+// meanError is the mean squared difference between each game result and the
+// win probability predicted from the evaluation.
+func meanError(samples []sample, weights []float64, k float64) float64 {
+	total := 0.0
+	for _, s := range samples {
+		total += math.Pow(s.result-sigmoid(evaluate(s.features, weights), k), 2)
+	}
+
+	return total / float64(len(samples))
+}
+
+// fitScale finds the sigmoid scaling constant that best fits the starting
+// weights, so the weights are tuned against a fixed score to probability map.
+func fitScale(samples []sample, weights []float64) float64 {
+	best, bestError := 1.0, meanError(samples, weights, 1.0)
+	for step := 1.0; step >= 0.001; step /= 10 {
+		improved := true
+		for improved {
+			improved = false
+			for _, k := range []float64{best - step, best + step} {
+				if k <= 0 {
+					continue
+				}
+				if e := meanError(samples, weights, k); e < bestError {
+					best, bestError, improved = k, e, true
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// localSearch is the classic Texel tuning loop: nudge each weight up or down
+// by one and keep any change that lowers the error, until nothing improves.
+func localSearch(samples []sample, weights []float64, k float64, maxIterations int) []float64 {
+	weights = append([]float64{}, weights...)
+	bestError := meanError(samples, weights, k)
+
+	for iteration := 1; maxIterations <= 0 || iteration <= maxIterations; iteration++ {
+		improved := false
+		for i := range weights {
+			for _, delta := range []float64{1, -1} {
+				weights[i] += delta
+				e := meanError(samples, weights, k)
+				if e < bestError {
+					bestError = e
+					improved = true
+					break
+				}
+				weights[i] -= delta
+			}
+		}
+
+		fmt.Printf("iteration %d: error %.6f\n", iteration, bestError)
+		if !improved {
+			break
+		}
+	}
+
+	return weights
+}
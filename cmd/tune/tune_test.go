@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+func TestFeaturesMatchMaterial(t *testing.T) {
+	game := chess.NewGame()
+	game.SetStateFromFEN("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+
+	features := extractFeatures(game)
+
+	game.Weights = chess.DefaultWeights
+	expected := float64(game.Material(chess.WHITE) - game.Material(chess.BLACK))
+	actual := evaluate(features, toVector(chess.DefaultWeights))
+	if expected != actual {
+		t.Errorf("Expected evaluation from features (%f) != evaluation from Material (%f)", actual, expected)
+	}
+}
+
+func TestLocalSearchReducesError(t *testing.T) {
+	// white wins every game where it has more of the first feature, so the
+	// first weight should grow
+	samples := []sample{
+		{features: []float64{1, 0, 0, 0, 0, 0, 0, 0, 0}, result: 1},
+		{features: []float64{2, 0, 0, 0, 0, 0, 0, 0, 0}, result: 1},
+		{features: []float64{-1, 0, 0, 0, 0, 0, 0, 0, 0}, result: 0},
+		{features: []float64{0, 0, 0, 0, 0, 0, 0, 0, 0}, result: 0.5},
+	}
+	start := make([]float64, len(parameters))
+
+	tuned := localSearch(samples, start, 1, 20)
+	if meanError(samples, tuned, 1) >= meanError(samples, start, 1) {
+		t.Errorf("Expected tuning to reduce the error, weights went from %+v to %+v", start, tuned)
+	}
+	if tuned[0] <= 0 {
+		t.Errorf("Expected the first weight to increase, got %f", tuned[0])
+	}
+}
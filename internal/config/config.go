@@ -0,0 +1,535 @@
+// Package config gathers the server's settings from, in increasing order of
+// precedence, their defaults, a YAML file, GOMES_ environment variables and
+// command line flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the name of every setting's environment variable.
+const envPrefix = "GOMES_"
+
+// Config is every setting the server reads at startup.
+type Config struct {
+	HTTP     HTTP     `yaml:"http"`
+	SSH      SSH      `yaml:"ssh"`
+	API      API      `yaml:"api"`
+	Limits   Limits   `yaml:"limits"`
+	Admin    Admin    `yaml:"admin"`
+	Metrics  Metrics  `yaml:"metrics"`
+	Paths    Paths    `yaml:"paths"`
+	Chess    Chess    `yaml:"chess"`
+	Games    Games    `yaml:"games"`
+	Lobby    Lobby    `yaml:"lobby"`
+	Bots     Bots     `yaml:"bots"`
+	Shutdown Shutdown `yaml:"shutdown"`
+	Log      Log      `yaml:"log"`
+}
+
+// HTTP is the web server, for browsers and the JSON API. With TLS it serves
+// HTTPS and HTTP/2 on Addr, with the PEM certificate and key at Cert and Key,
+// or a self-signed certificate made at startup if they aren't set. Plain
+// HTTP on RedirectAddr, if it's set, is redirected to HTTPS.
+type HTTP struct {
+	Addr         string `yaml:"addr"`
+	TLS          bool   `yaml:"tls"`
+	Cert         string `yaml:"cert"`
+	Key          string `yaml:"key"`
+	RedirectAddr string `yaml:"redirect_addr"`
+}
+
+// SSH is the server for TUI players. Its host key is created at HostKey if
+// it doesn't exist yet. Connections past MaxSessions, or MaxSessionsPerIP
+// from one address, are turned away, and sessions are closed once they've
+// been idle for IdleTimeout or open for MaxTimeout, where zero means never.
+type SSH struct {
+	Enabled          bool          `yaml:"enabled"`
+	Addr             string        `yaml:"addr"`
+	HostKey          string        `yaml:"host_key"`
+	MaxSessions      int           `yaml:"max_sessions"`
+	MaxSessionsPerIP int           `yaml:"max_sessions_per_ip"`
+	IdleTimeout      time.Duration `yaml:"idle_timeout"`
+	MaxTimeout       time.Duration `yaml:"max_timeout"`
+}
+
+// API switches the JSON API and its bot endpoints on and off.
+type API struct {
+	Enabled bool `yaml:"enabled"`
+	Bots    bool `yaml:"bots"`
+}
+
+// Limits are how many games each client may start, moves they may make,
+// times they may have the bot search and sign-in codes they may try, per
+// minute, with zero for no limit. Clients are limited by their address and by
+// their player, and behind a proxy their address is read from IPHeader.
+// MaxBody is the most bytes a request's body may have.
+type Limits struct {
+	Games    int    `yaml:"games"`
+	Moves    int    `yaml:"moves"`
+	Bot      int    `yaml:"bot"`
+	Links    int    `yaml:"links"`
+	MaxBody  int    `yaml:"max_body"`
+	IPHeader string `yaml:"ip_header"`
+}
+
+// Admin is who may use the admin console: players whose SSH keys, by their
+// SHA256 fingerprints, are in Keys. Players an admin kicks are refused for
+// KickFor afterwards, or not at all if it's zero.
+type Admin struct {
+	Keys    []string      `yaml:"keys"`
+	KickFor time.Duration `yaml:"kick_for"`
+}
+
+// Metrics switches the Prometheus metrics at /metrics on and off. The health
+// checks at /healthz and /readyz are always served.
+type Metrics struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// Paths are where the server finds its templates and keeps its data.
+type Paths struct {
+	Templates string `yaml:"templates"`
+	Games     string `yaml:"games"`
+	Accounts  string `yaml:"accounts"`
+	Archive   string `yaml:"archive"`
+}
+
+// Chess is the chess engine's opening book, read from PGN files, and its
+// evaluation weights, which are the built in ones if the file is missing.
+type Chess struct {
+	Book    bool     `yaml:"book"`
+	Books   []string `yaml:"books"`
+	Weights string   `yaml:"weights"`
+}
+
+// Games is how many games can be in progress, how long an idle one is kept
+// and how often idle games are looked for.
+type Games struct {
+	TTL      time.Duration `yaml:"ttl"`
+	MaxGames int           `yaml:"max_games"`
+	Sweep    time.Duration `yaml:"sweep"`
+}
+
+// Lobby is how long seeks stay open.
+type Lobby struct {
+	SeekTTL time.Duration `yaml:"seek_ttl"`
+}
+
+// Bots caps how hard the server's bot may be asked to think, whatever a
+// player or API client asks for, and how much of the machine its searches
+// share. Chess depths are in plies, and zero means no limit.
+type Bots struct {
+	MaxDepth      int           `yaml:"max_depth"`
+	MaxSearchTime time.Duration `yaml:"max_search_time"`
+
+	// Workers is how many searches run at once, with the rest waiting their
+	// turn, and Threads how many moves each chess search looks at in
+	// parallel. Zero threads searches every move at once.
+	Workers int `yaml:"workers"`
+	Threads int `yaml:"threads"`
+}
+
+// Shutdown is how long the server waits, once it's told to stop, for players
+// to be let go and the bot's searches to finish before it stops regardless.
+type Shutdown struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Log is what the server logs and how. Level is one of debug, info, warn
+// and error, and Format is text or json. The bot's searches are only logged
+// at debug.
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Logger logs to w as configured. The configuration must be valid.
+func (l Log) Logger(w io.Writer) *slog.Logger {
+	level := slog.LevelInfo
+	level.UnmarshalText([]byte(l.Level))
+
+	options := &slog.HandlerOptions{Level: level}
+	if l.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// Default is the configuration the server runs with when nothing is set,
+// from the root of the repository.
+func Default() Config {
+	// the workers share the CPUs between them
+	workers := max(runtime.NumCPU()/2, 1)
+
+	return Config{
+		HTTP: HTTP{Addr: ":8080"},
+		SSH: SSH{
+			Enabled:          true,
+			Addr:             "localhost:23234",
+			HostKey:          ".ssh/id_ed25519",
+			MaxSessions:      200,
+			MaxSessionsPerIP: 10,
+			IdleTimeout:      30 * time.Minute,
+		},
+		API: API{Enabled: true, Bots: true},
+		Limits: Limits{
+			Games:   20,
+			Moves:   120,
+			Bot:     60,
+			Links:   10,
+			MaxBody: 64 << 10,
+		},
+		Admin:   Admin{Keys: []string{}, KickFor: 10 * time.Minute},
+		Metrics: Metrics{Enabled: true},
+		Paths: Paths{
+			Templates: "internal/routes/templates",
+			Games:     "data/games.jsonl",
+			Accounts:  "data/accounts.json",
+			Archive:   "data/archive.jsonl",
+		},
+		Chess: Chess{
+			Book:    true,
+			Books:   []string{"pkg/chess/Carlsen.pgn", "pkg/chess/twic1555.pgn"},
+			Weights: "pkg/chess/weights.json",
+		},
+		Games: Games{
+			TTL:      30 * time.Minute,
+			MaxGames: 1000,
+			Sweep:    time.Minute,
+		},
+		Lobby: Lobby{SeekTTL: 30 * time.Minute},
+		Bots: Bots{
+			MaxDepth:      12,
+			MaxSearchTime: 10 * time.Second,
+			Workers:       workers,
+			Threads:       max(runtime.NumCPU()/workers, 1),
+		},
+		Shutdown: Shutdown{Timeout: 15 * time.Second},
+		Log:      Log{Level: "info", Format: "text"},
+	}
+}
+
+// setting is one configurable value, named by its dotted YAML key. Its flag
+// is the key with dashes, such as -http-addr, and its environment variable
+// the key in capitals with underscores, such as GOMES_HTTP_ADDR.
+type setting struct {
+	key   string
+	usage string
+	value flag.Value
+}
+
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_").Replace(s.key))
+}
+
+// settings binds every setting to its field in c.
+func (c *Config) settings() []setting {
+	return []setting{
+		{"http.addr", "address the web server listens on", (*stringValue)(&c.HTTP.Addr)},
+		{"http.tls", "serve HTTPS and HTTP/2", (*boolValue)(&c.HTTP.TLS)},
+		{"http.cert", "PEM certificate file for HTTPS, self-signed if it and the key aren't set", (*stringValue)(&c.HTTP.Cert)},
+		{"http.key", "PEM private key file for HTTPS", (*stringValue)(&c.HTTP.Key)},
+		{"http.redirect_addr", "address redirecting plain HTTP to HTTPS, such as :80", (*stringValue)(&c.HTTP.RedirectAddr)},
+		{"ssh.enabled", "serve the TUI over SSH", (*boolValue)(&c.SSH.Enabled)},
+		{"ssh.addr", "address the SSH server listens on", (*stringValue)(&c.SSH.Addr)},
+		{"ssh.host_key", "path of the SSH host key, created if missing", (*stringValue)(&c.SSH.HostKey)},
+		{"ssh.max_sessions", "most SSH sessions open at once, 0 for no limit", (*intValue)(&c.SSH.MaxSessions)},
+		{"ssh.max_sessions_per_ip", "most SSH sessions open at once from one address, 0 for no limit", (*intValue)(&c.SSH.MaxSessionsPerIP)},
+		{"ssh.idle_timeout", "how long an SSH session may be idle before it's closed, 0 for no limit", (*durationValue)(&c.SSH.IdleTimeout)},
+		{"ssh.max_timeout", "how long an SSH session may be open, 0 for no limit", (*durationValue)(&c.SSH.MaxTimeout)},
+		{"api.enabled", "serve the JSON API under /api/v1", (*boolValue)(&c.API.Enabled)},
+		{"api.bots", "serve the bot API under /api/v1/bot", (*boolValue)(&c.API.Bots)},
+		{"limits.games", "games each client may start a minute, 0 for no limit", (*intValue)(&c.Limits.Games)},
+		{"limits.moves", "moves each client may make a minute, 0 for no limit", (*intValue)(&c.Limits.Moves)},
+		{"limits.bot", "times each client may have the bot search a minute, 0 for no limit", (*intValue)(&c.Limits.Bot)},
+		{"limits.links", "sign-in codes each client may try a minute, 0 for no limit", (*intValue)(&c.Limits.Links)},
+		{"limits.max_body", "most bytes in a request's body", (*intValue)(&c.Limits.MaxBody)},
+		{"limits.ip_header", "header with the client's address behind a proxy, such as X-Forwarded-For", (*stringValue)(&c.Limits.IPHeader)},
+		{"admin.keys", "comma separated SHA256 fingerprints of the admins' SSH keys", (*listValue)(&c.Admin.Keys)},
+		{"admin.kick_for", "how long a kicked player is refused, 0 to let them straight back", (*durationValue)(&c.Admin.KickFor)},
+		{"metrics.enabled", "serve Prometheus metrics at /metrics", (*boolValue)(&c.Metrics.Enabled)},
+		{"paths.templates", "directory of the browser's HTML templates", (*stringValue)(&c.Paths.Templates)},
+		{"paths.games", "journal of games in progress", (*stringValue)(&c.Paths.Games)},
+		{"paths.accounts", "file player accounts are saved to", (*stringValue)(&c.Paths.Accounts)},
+		{"paths.archive", "file finished games are archived to", (*stringValue)(&c.Paths.Archive)},
+		{"chess.book", "play book moves from the opening book", (*boolValue)(&c.Chess.Book)},
+		{"chess.books", "comma separated PGN files the opening book is read from", (*listValue)(&c.Chess.Books)},
+		{"chess.weights", "evaluation weights file, the built in weights are used if it's missing", (*stringValue)(&c.Chess.Weights)},
+		{"games.ttl", "how long an idle game is kept, 0 keeps them forever", (*durationValue)(&c.Games.TTL)},
+		{"games.max_games", "most games in progress at once, 0 for no limit", (*intValue)(&c.Games.MaxGames)},
+		{"games.sweep", "how often idle games are removed", (*durationValue)(&c.Games.Sweep)},
+		{"lobby.seek_ttl", "how long a seek stays open", (*durationValue)(&c.Lobby.SeekTTL)},
+		{"bots.max_depth", "deepest the bot may search, in plies for chess, 0 for no limit", (*intValue)(&c.Bots.MaxDepth)},
+		{"bots.max_search_time", "longest the chess bot may think about a move, 0 for no limit", (*durationValue)(&c.Bots.MaxSearchTime)},
+		{"bots.workers", "how many of the bot's searches run at once, the rest wait their turn", (*intValue)(&c.Bots.Workers)},
+		{"bots.threads", "how many moves each chess search looks at in parallel, 0 for all of them", (*intValue)(&c.Bots.Threads)},
+		{"shutdown.timeout", "how long to wait for players and searches when shutting down", (*durationValue)(&c.Shutdown.Timeout)},
+		{"log.level", "the least important messages logged: debug, info, warn or error", (*stringValue)(&c.Log.Level)},
+		{"log.format", "how messages are logged: text or json", (*stringValue)(&c.Log.Format)},
+	}
+}
+
+// Load reads the configuration from the command line arguments, the
+// environment and the YAML file named by -config or GOMES_CONFIG, and
+// validates it. It reports whether -print-config was given, which it only
+// does once the settings have been read, so an invalid configuration can
+// still be printed. It returns flag.ErrHelp after printing usage for -help.
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (Config, bool, error) {
+	flags := flag.NewFlagSet("gomes", flag.ContinueOnError)
+	flags.SetOutput(output)
+
+	path, _ := lookupEnv(envPrefix + "CONFIG")
+	flags.StringVar(&path, "config", path, "YAML file to read settings from, also set with "+envPrefix+"CONFIG")
+	printConfig := flags.Bool("print-config", false, "print the configuration in effect as YAML and exit")
+
+	// flags are parsed into a scratch config, and only applied once the
+	// file and environment have been read
+	scratch := Default()
+	for _, s := range scratch.settings() {
+		flags.Var(s.value, s.flag(), fmt.Sprintf("%s (%s)", s.usage, s.env()))
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return Config{}, false, err
+	}
+	if flags.NArg() > 0 {
+		return Config{}, false, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	config := Default()
+	if path != "" {
+		err = config.readFile(path)
+		if err != nil {
+			return Config{}, false, err
+		}
+	}
+
+	settings := config.settings()
+	for _, s := range settings {
+		value, ok := lookupEnv(s.env())
+		if !ok {
+			continue
+		}
+		err = s.value.Set(value)
+		if err != nil {
+			return Config{}, false, fmt.Errorf("%s: %w", s.env(), err)
+		}
+	}
+
+	// PORT is what hosting platforms set, and what the server has always
+	// listened on
+	if port, ok := lookupEnv("PORT"); ok && port != "" {
+		if _, set := lookupEnv(envPrefix + "HTTP_ADDR"); !set {
+			config.HTTP.Addr = ":" + port
+		}
+	}
+
+	byFlag := make(map[string]setting)
+	for _, s := range settings {
+		byFlag[s.flag()] = s
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if s, ok := byFlag[f.Name]; ok && err == nil {
+			err = s.value.Set(f.Value.String())
+		}
+	})
+	if err != nil {
+		return Config{}, false, err
+	}
+
+	return config, *printConfig, config.Validate()
+}
+
+// readFile reads settings from a YAML file over the ones already set.
+// Unknown keys are an error, so typos don't go unnoticed.
+func (c *Config) readFile(path string) error {
+	f, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(f))
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate checks every setting, returning all the problems it finds.
+func (c Config) Validate() error {
+	errs := []error{}
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validAddr(c.HTTP.Addr), "http.addr: %q isn't a host:port address", c.HTTP.Addr)
+	if c.HTTP.TLS {
+		check((c.HTTP.Cert == "") == (c.HTTP.Key == ""), "http.cert: a certificate and key are needed together, or neither for a self-signed certificate")
+		if c.HTTP.Cert != "" {
+			_, err := os.Stat(c.HTTP.Cert)
+			check(err == nil, "http.cert: can't read %q", c.HTTP.Cert)
+		}
+		if c.HTTP.Key != "" {
+			_, err := os.Stat(c.HTTP.Key)
+			check(err == nil, "http.key: can't read %q", c.HTTP.Key)
+		}
+		check(c.HTTP.RedirectAddr == "" || validAddr(c.HTTP.RedirectAddr), "http.redirect_addr: %q isn't a host:port address", c.HTTP.RedirectAddr)
+	} else {
+		check(c.HTTP.RedirectAddr == "", "http.redirect_addr: redirects to HTTPS, which needs http.tls")
+	}
+	if c.SSH.Enabled {
+		check(validAddr(c.SSH.Addr), "ssh.addr: %q isn't a host:port address", c.SSH.Addr)
+		check(c.SSH.HostKey != "", "ssh.host_key: a path is needed")
+		check(c.SSH.MaxSessions >= 0, "ssh.max_sessions: can't be negative")
+		check(c.SSH.MaxSessionsPerIP >= 0, "ssh.max_sessions_per_ip: can't be negative")
+		check(c.SSH.IdleTimeout >= 0, "ssh.idle_timeout: can't be negative")
+		check(c.SSH.MaxTimeout >= 0, "ssh.max_timeout: can't be negative")
+	}
+
+	check(c.API.Enabled || !c.API.Bots, "api.bots: the bot API is part of the JSON API, which is disabled")
+	check(c.Limits.Games >= 0, "limits.games: can't be negative")
+	check(c.Limits.Moves >= 0, "limits.moves: can't be negative")
+	check(c.Limits.Bot >= 0, "limits.bot: can't be negative")
+	check(c.Limits.Links >= 0, "limits.links: can't be negative")
+	check(c.Limits.MaxBody > 0, "limits.max_body: must be positive")
+	for _, key := range c.Admin.Keys {
+		check(strings.HasPrefix(key, "SHA256:"), "admin.keys: %q isn't a SHA256 fingerprint, such as ssh-keygen -lf prints", key)
+	}
+	check(c.Admin.KickFor >= 0, "admin.kick_for: can't be negative")
+
+	info, err := os.Stat(c.Paths.Templates)
+	check(err == nil && info.IsDir(), "paths.templates: %q isn't a directory", c.Paths.Templates)
+	check(c.Paths.Games != "", "paths.games: a path is needed")
+	check(c.Paths.Accounts != "", "paths.accounts: a path is needed")
+	check(c.Paths.Archive != "", "paths.archive: a path is needed")
+
+	if c.Chess.Book {
+		for _, book := range c.Chess.Books {
+			_, err := os.Stat(book)
+			check(err == nil, "chess.books: can't read %q, set chess.book to false to play without the opening book", book)
+		}
+	}
+
+	check(c.Games.TTL >= 0, "games.ttl: can't be negative")
+	check(c.Games.MaxGames >= 0, "games.max_games: can't be negative")
+	check(c.Games.Sweep > 0, "games.sweep: must be positive")
+	check(c.Lobby.SeekTTL > 0, "lobby.seek_ttl: must be positive")
+	check(c.Bots.MaxDepth >= 0, "bots.max_depth: can't be negative")
+	check(c.Bots.MaxSearchTime >= 0, "bots.max_search_time: can't be negative")
+	check(c.Bots.Workers > 0, "bots.workers: must be at least 1")
+	check(c.Bots.Threads >= 0, "bots.threads: can't be negative")
+	check(c.Shutdown.Timeout > 0, "shutdown.timeout: must be positive")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level: %q isn't debug, info, warn or error", c.Log.Level)
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: %q isn't text or json", c.Log.Format)
+
+	return errors.Join(errs...)
+}
+
+// validAddr reports whether addr is a host and port to listen on. The host
+// may be empty to listen on every interface.
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+// Write writes the configuration as YAML, in the format the config file is
+// read in.
+func (c Config) Write(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err := encoder.Encode(c)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+type stringValue string
+
+func (v *stringValue) String() string     { return string(*v) }
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+
+type boolValue bool
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%q isn't true or false", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be switched on with a bare flag.
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q isn't a whole number", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%q isn't a duration such as 30s or 10m", s)
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+// listValue is a comma separated list, with an empty string for an empty
+// list.
+type listValue []string
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+func (v *listValue) Set(s string) error {
+	*v = []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
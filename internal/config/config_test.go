@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env is an environment to load settings from.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+// testArgs points the settings that are checked on disk at a temporary
+// directory, since the defaults are relative to the root of the repository.
+func testArgs(t *testing.T, args ...string) []string {
+	return append([]string{"-paths-templates", t.TempDir(), "-chess-book=false"}, args...)
+}
+
+func writeFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "gomes.yaml")
+	err := os.WriteFile(path, []byte(contents), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeFile(t, `
+http:
+  addr: ":9000"
+ssh:
+  addr: "0.0.0.0:2222"
+games:
+  ttl: 1h
+lobby:
+  seek_ttl: 5m
+`)
+
+	config, printConfig, err := Load(
+		testArgs(t, "-config", path, "-lobby-seek-ttl", "10m", "-print-config"),
+		env(map[string]string{
+			"GOMES_SSH_ADDR":       ":2200",
+			"GOMES_LOBBY_SEEK_TTL": "2m",
+			"PORT":                 "7000",
+		}),
+		&bytes.Buffer{},
+	)
+	if err != nil {
+		t.Fatalf("Expected no error loading, got %s", err)
+	}
+	if !printConfig {
+		t.Errorf("Expected -print-config to be reported")
+	}
+
+	tests := []struct {
+		name     string
+		expected any
+		actual   any
+	}{
+		// PORT is only a fallback for GOMES_HTTP_ADDR, and beats the file
+		{"http.addr", ":7000", config.HTTP.Addr},
+		{"ssh.addr", ":2200", config.SSH.Addr},
+		{"games.ttl", time.Hour, config.Games.TTL},
+		{"games.sweep", time.Minute, config.Games.Sweep},
+		{"lobby.seek_ttl", 10 * time.Minute, config.Lobby.SeekTTL},
+		{"chess.book", false, config.Chess.Book},
+	}
+
+	for _, test := range tests {
+		if test.expected != test.actual {
+			t.Errorf("%s: Expected value (%v) != actual value (%v)", test.name, test.expected, test.actual)
+		}
+	}
+
+	config, _, err = Load(testArgs(t), env(map[string]string{"GOMES_CONFIG": path, "GOMES_HTTP_ADDR": ":8000", "PORT": "7000"}), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Expected no error loading, got %s", err)
+	}
+	if config.HTTP.Addr != ":8000" {
+		t.Errorf("Expected http.addr (%s) != actual http.addr (%s)", ":8000", config.HTTP.Addr)
+	}
+	if config.SSH.Addr != "0.0.0.0:2222" {
+		t.Errorf("Expected ssh.addr (%s) != actual ssh.addr (%s)", "0.0.0.0:2222", config.SSH.Addr)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		err  string
+	}{
+		{"unknown key", []string{"-config", writeFile(t, "http:\n  address: \":80\"\n")}, nil, "field address not found"},
+		{"missing file", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, nil, "no such file"},
+		{"bad duration", []string{"-games-ttl", "soon"}, nil, "isn't a duration"},
+		{"bad env", nil, map[string]string{"GOMES_SSH_ENABLED": "maybe"}, "GOMES_SSH_ENABLED"},
+		{"bad addr", []string{"-http-addr", "8080"}, nil, "http.addr"},
+		{"bad port", []string{"-ssh-addr", "localhost:99999"}, nil, "ssh.addr"},
+		{"cert without key", []string{"-http-tls", "-http-cert", "cert.pem"}, nil, "http.cert"},
+		{"missing cert", []string{"-http-tls", "-http-cert", "cert.pem", "-http-key", "key.pem"}, nil, "cert.pem"},
+		{"redirect without tls", []string{"-http-redirect-addr", ":80"}, nil, "http.redirect_addr"},
+		{"bad admin key", []string{"-admin-keys", "ssh-ed25519 AAAA"}, nil, "admin.keys"},
+		{"bots without api", []string{"-api-enabled=false"}, nil, "api.bots"},
+		{"negative depth", []string{"-bots-max-depth", "-1"}, nil, "bots.max_depth"},
+		{"no sweep", []string{"-games-sweep", "0s"}, nil, "games.sweep"},
+		{"bad log level", []string{"-log-level", "loud"}, nil, "log.level"},
+		{"bad log format", nil, map[string]string{"GOMES_LOG_FORMAT": "xml"}, "log.format"},
+		{"missing book", []string{"-chess-book", "-chess-books", "missing.pgn"}, nil, "missing.pgn"},
+		{"argument", []string{"serve"}, nil, "unexpected argument"},
+	}
+
+	for _, test := range tests {
+		_, _, err := Load(testArgs(t, test.args...), env(test.env), &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: Expected error containing (%s) != actual error (%v)", test.name, test.err, err)
+		}
+	}
+
+	// every problem is reported at once
+	_, _, err := Load(testArgs(t, "-http-addr", "nowhere", "-lobby-seek-ttl", "0s"), env(nil), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "http.addr") || !strings.Contains(err.Error(), "lobby.seek_ttl") {
+		t.Errorf("Expected both problems to be reported, got %v", err)
+	}
+
+	// the SSH settings don't matter with SSH off
+	_, _, err = Load(testArgs(t, "-ssh-enabled=false", "-ssh-addr", ""), env(nil), &bytes.Buffer{})
+	if err != nil {
+		t.Errorf("Expected no error with SSH disabled, got %s", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	config, _, err := Load(testArgs(t, "-chess-books", "a.pgn, b.pgn", "-bots-max-search-time", "3s"), env(nil), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Expected no error loading, got %s", err)
+	}
+
+	out := &bytes.Buffer{}
+	err = config.Write(out)
+	if err != nil {
+		t.Fatalf("Expected no error writing, got %s", err)
+	}
+
+	// what's printed reads back as the same configuration
+	path := writeFile(t, out.String())
+	read, _, err := Load([]string{"-config", path}, env(nil), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Expected no error reading the printed config, got %s", err)
+	}
+	if read.Bots.MaxSearchTime != 3*time.Second {
+		t.Errorf("Expected max search time (%s) != actual max search time (%s)", 3*time.Second, read.Bots.MaxSearchTime)
+	}
+	if strings.Join(read.Chess.Books, ",") != "a.pgn,b.pgn" {
+		t.Errorf("Expected books (%s) != actual books (%v)", "a.pgn,b.pgn", read.Chess.Books)
+	}
+	if read.Paths.Templates != config.Paths.Templates {
+		t.Errorf("Expected templates (%s) != actual templates (%s)", config.Paths.Templates, read.Paths.Templates)
+	}
+}
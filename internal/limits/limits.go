@@ -0,0 +1,245 @@
+// Package limits rate limits what clients can ask the server to do, such as
+// starting games and moving, so that no one client can use up the server.
+// Each limit is a token bucket per client, and clients are known both by
+// their address and by who they are, so that neither a new session nor a new
+// address gets around a limit.
+package limits
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/metrics"
+)
+
+// sweepInterval is how often buckets that have filled back up are forgotten.
+const sweepInterval = time.Minute
+
+var limited = metrics.Default.Counter(
+	"gomes_rate_limited_total",
+	"Requests and TUI actions turned away by a rate limit, by what the client was doing too often.",
+	"limit",
+)
+
+// Error is a limit having been hit, saying when to try again.
+type Error struct {
+	// What is what the client was doing too often, such as "starting games".
+	What  string
+	Retry time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("You're %s too quickly. Try again in %s.", e.What, RetryAfter(e.Retry))
+}
+
+// RetryAfter is how long to wait rounded up to a whole second, which is what
+// the Retry-After header takes.
+func RetryAfter(wait time.Duration) time.Duration {
+	return time.Duration(math.Ceil(wait.Seconds())) * time.Second
+}
+
+// made counts the limiters made, to order them by.
+var made atomic.Uint64
+
+// Limiter allows Count of something per Per for each client, with up to
+// Count at once. A nil Limiter allows everything.
+type Limiter struct {
+	what  string
+	rate  float64
+	burst float64
+	// order is when the limiter was made, which is the order limiters are
+	// locked in
+	order uint64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is one client's tokens, as of last.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter allows count of what per per, or everything if count isn't
+// positive.
+func NewLimiter(what string, count int, per time.Duration) *Limiter {
+	if count <= 0 || per <= 0 {
+		return nil
+	}
+
+	return &Limiter{
+		what:      what,
+		rate:      float64(count) / per.Seconds(),
+		burst:     float64(count),
+		order:     made.Add(1),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from each key's bucket if all of them have one, and
+// otherwise returns an *Error saying how long until they will. Empty keys
+// are left out, so a client that isn't known by one of them is only limited
+// by the rest.
+func (l *Limiter) Allow(keys ...string) error {
+	return Allow(keys, l)
+}
+
+// Allow is Limiter.Allow for several limiters at once. Tokens are only taken
+// if every limiter allows the keys, so a client turned away by one limit
+// isn't charged by the others, and the *Error is for the first limiter that
+// turned it away. Nil limiters allow everything.
+func Allow(keys []string, limiters ...*Limiter) error {
+	checked := []*Limiter{}
+	for _, l := range limiters {
+		if l != nil && !slices.Contains(checked, l) {
+			checked = append(checked, l)
+		}
+	}
+
+	// limiters are locked in the order they were made, so that two clients
+	// checking the same limiters can't each hold one the other is waiting on
+	locked := slices.Clone(checked)
+	slices.SortFunc(locked, func(a, b *Limiter) int { return cmp.Compare(a.order, b.order) })
+	for _, l := range locked {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+
+	now := time.Now()
+	buckets := []*bucket{}
+	for _, l := range checked {
+		l.sweep(now)
+
+		taken, wait := l.check(keys, now)
+		if wait > 0 {
+			limited.Inc(l.what)
+			return &Error{What: l.what, Retry: wait}
+		}
+		buckets = append(buckets, taken...)
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+
+	return nil
+}
+
+// check returns the buckets a token would be taken from for the keys, and
+// how long until they all have one. The limiter must be locked.
+func (l *Limiter) check(keys []string, now time.Time) ([]*bucket, time.Duration) {
+	buckets := []*bucket{}
+	wait := time.Duration(0)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: l.burst, last: now}
+			l.buckets[key] = b
+		}
+		l.refill(b, now)
+
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/l.rate*float64(time.Second)))
+		}
+		buckets = append(buckets, b)
+	}
+
+	return buckets, wait
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*l.rate, l.burst)
+	b.last = now
+}
+
+// sweep forgets buckets that are full again, since a new one would be the
+// same. The limiter must be locked.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Limits are the limits every front end applies to its clients. Keys are
+// made with IP and Identity, so the same client is limited the same way
+// however it plays.
+type Limits struct {
+	// Games limits starting games and posting seeks.
+	Games *Limiter
+	// Moves limits moves, in every kind of game.
+	Moves *Limiter
+	// Bot limits starting games against the bot and moving in them, each
+	// of which has the bot search for its reply.
+	Bot *Limiter
+	// Links limits tries at signing a browser in with a one-time code, so
+	// that codes can't be guessed.
+	Links *Limiter
+
+	// IPHeader is the request header holding a client's address when the
+	// server is behind a proxy, such as X-Forwarded-For or X-Real-IP. Without
+	// one, the address the request came from is used.
+	IPHeader string
+}
+
+// IP is the key for a client's address.
+func IP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err == nil {
+		addr = host
+	}
+	if addr == "" {
+		return ""
+	}
+
+	return "ip:" + addr
+}
+
+// Identity is the key for who a client is, such as the seat they play from.
+func Identity(seat string) string {
+	if seat == "" {
+		return ""
+	}
+
+	return "seat:" + seat
+}
+
+// RequestIP is the key for the address an HTTP request came from.
+func (l *Limits) RequestIP(r *http.Request) string {
+	return IP(l.RequestAddr(r))
+}
+
+// RequestAddr is the address an HTTP request came from. A proxy adds the
+// address it saw to the end of X-Forwarded-For, so the last one is the only
+// one it vouches for.
+func (l *Limits) RequestAddr(r *http.Request) string {
+	if l.IPHeader != "" {
+		addresses := strings.Split(r.Header.Get(l.IPHeader), ",")
+		if addr := strings.TrimSpace(addresses[len(addresses)-1]); addr != "" {
+			return addr
+		}
+	}
+
+	return r.RemoteAddr
+}
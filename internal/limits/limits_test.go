@@ -0,0 +1,108 @@
+package limits
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	l := NewLimiter("starting games", 2, 200*time.Millisecond)
+
+	for i := range 2 {
+		if err := l.Allow("ip:a", "seat:x"); err != nil {
+			t.Fatalf("Expected game %d to be allowed, got %s", i+1, err)
+		}
+	}
+
+	err := l.Allow("ip:a", "seat:x")
+	limited := &Error{}
+	if !errors.As(err, &limited) {
+		t.Fatalf("Expected a limit error, got %v", err)
+	}
+	if limited.Retry <= 0 || limited.Retry > 100*time.Millisecond {
+		t.Errorf("Expected to retry within a token's time, got %s", limited.Retry)
+	}
+
+	// a new session from the same address is still limited, and the same
+	// player from a new address too
+	if l.Allow("ip:a", "seat:y") == nil {
+		t.Errorf("Expected the address to be limited")
+	}
+	if l.Allow("ip:b", "seat:x") == nil {
+		t.Errorf("Expected the player to be limited")
+	}
+	if err := l.Allow("ip:b", "seat:y"); err != nil {
+		t.Errorf("Expected another client to be allowed, got %s", err)
+	}
+
+	time.Sleep(limited.Retry)
+	if err := l.Allow("ip:a", "", "seat:x"); err != nil {
+		t.Errorf("Expected a game to be allowed once a token is back, got %s", err)
+	}
+}
+
+func TestAllowAll(t *testing.T) {
+	games := NewLimiter("starting games", 3, time.Minute)
+	bot := NewLimiter("asking the bot to move", 1, time.Minute)
+	keys := []string{"ip:a", "seat:x"}
+
+	if err := Allow(keys, games, bot, nil); err != nil {
+		t.Fatalf("Expected the first game to be allowed, got %s", err)
+	}
+
+	// turned away by the bot's limit, the game isn't counted against the
+	// client's games
+	err := Allow(keys, games, bot)
+	limited := &Error{}
+	if !errors.As(err, &limited) || limited.What != "asking the bot to move" {
+		t.Fatalf("Expected the bot's limit, got %v", err)
+	}
+	for i := range 2 {
+		if err := games.Allow(keys...); err != nil {
+			t.Errorf("Expected game %d to be allowed, got %s", i+2, err)
+		}
+	}
+	if err := Allow(keys, games, games); err == nil {
+		t.Errorf("Expected the games limit to be hit")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	l := NewLimiter("moving", 0, time.Minute)
+	for range 100 {
+		if err := l.Allow("ip:a"); err != nil {
+			t.Fatalf("Expected no limit, got %s", err)
+		}
+	}
+}
+
+func TestRequestIP(t *testing.T) {
+	tests := []struct {
+		header   string
+		remote   string
+		value    string
+		expected string
+	}{
+		{"", "1.2.3.4:5678", "9.9.9.9", "ip:1.2.3.4"},
+		{"X-Forwarded-For", "10.0.0.1:5678", "6.6.6.6, 9.9.9.9", "ip:9.9.9.9"},
+		{"X-Real-IP", "10.0.0.1:5678", "", "ip:10.0.0.1"},
+		{"", "[::1]:5678", "", "ip:::1"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		// only the configured header is trusted
+		r.Header.Set("X-Forwarded-For", test.value)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+
+		actual := (&Limits{IPHeader: test.header}).RequestIP(r)
+		if actual != test.expected {
+			t.Errorf("Expected IP (%s) != actual IP (%s)", test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package metrics keeps counters, gauges and histograms, and serves them in
+// the Prometheus text format, described at
+// https://prometheus.io/docs/instrumenting/exposition_formats/. It only does
+// what the server needs: metrics are registered once, usually as package
+// variables, and every sample of a metric has the same labels.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are histogram buckets for durations in seconds, from a
+// millisecond up to a minute.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Default is the registry the server's metrics are kept in and served from.
+var Default = NewRegistry()
+
+// Registry is a set of metrics, served by ServeHTTP. Registering a name
+// again replaces the metric that had it.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// family is a metric with every sample that has been recorded for it.
+type family interface {
+	write(w io.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.families[name] = f
+}
+
+// ServeHTTP writes every metric, sorted by name.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]family, 0, len(names))
+	slices.Sort(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+// desc is what every kind of metric has: its name, help text, type and the
+// names of its labels.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) header(w io.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, help, d.name, d.kind)
+}
+
+// key joins label values into a map key.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// sample writes one line, with extra label pairs such as a histogram's le
+// after the metric's own.
+func (d desc) sample(w io.Writer, suffix string, values []string, value float64, extra ...string) {
+	pairs := []string{}
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escape(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+
+	labels := ""
+	if len(pairs) > 0 {
+		labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s%s%s %s\n", d.name, suffix, labels, formatFloat(value))
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// series keeps a metric's samples by their label values, and writes them in
+// order.
+type series[T any] struct {
+	mu          sync.Mutex
+	values      map[string]T
+	labelValues map[string][]string
+}
+
+func newSeries[T any]() series[T] {
+	return series[T]{values: make(map[string]T), labelValues: make(map[string][]string)}
+}
+
+// each calls fn for every sample, sorted by label values, with the series
+// locked.
+func (s *series[T]) each(fn func(values []string, value T)) {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fn(s.labelValues[key], s.values[key])
+	}
+}
+
+// Counter is a total that only goes up, such as moves played.
+type Counter struct {
+	desc
+	series[float64]
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}, series: newSeries[float64]()}
+	r.register(name, c)
+
+	return c
+}
+
+// Add adds a positive amount to the sample with the given label values.
+func (c *Counter) Add(amount float64, values ...string) {
+	if amount < 0 {
+		panic(fmt.Sprintf("metrics: %s can't go down", c.name))
+	}
+
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] += amount
+	c.labelValues[key] = values
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.each(func(values []string, value float64) {
+		c.sample(w, "", values, value)
+	})
+}
+
+// Gauge is a value that goes up and down, such as sessions open.
+type Gauge struct {
+	desc
+	series[float64]
+}
+
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name, help, "gauge", labels}, series: newSeries[float64]()}
+	r.register(name, g)
+
+	return g
+}
+
+func (g *Gauge) Set(value float64, values ...string) {
+	key := g.key(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[key] = value
+	g.labelValues[key] = values
+}
+
+func (g *Gauge) Add(amount float64, values ...string) {
+	key := g.key(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[key] += amount
+	g.labelValues[key] = values
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.header(w)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.each(func(values []string, value float64) {
+		g.sample(w, "", values, value)
+	})
+}
+
+// GaugeFunc is a gauge worked out when the metrics are served, for values
+// that are already kept somewhere else, such as how many games are in the
+// store. collect calls set once for each sample.
+type GaugeFunc struct {
+	desc
+	collect func(set func(value float64, values ...string))
+}
+
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func(set func(value float64, values ...string))) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, "gauge", labels}, collect: collect}
+	r.register(name, g)
+
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	samples := newSeries[float64]()
+	g.collect(func(value float64, values ...string) {
+		key := g.key(values)
+		samples.values[key] = value
+		samples.labelValues[key] = values
+	})
+
+	g.header(w)
+	samples.each(func(values []string, value float64) {
+		g.sample(w, "", values, value)
+	})
+}
+
+// Histogram counts observations, such as how long requests took, into
+// buckets by size.
+type Histogram struct {
+	desc
+	series[*buckets]
+	bounds []float64
+}
+
+// buckets are one sample's counts, each counting the observations no bigger
+// than its bound, with the last for those bigger than every bound.
+type buckets struct {
+	counts []uint64
+	sum    float64
+}
+
+func (r *Registry) Histogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: desc{name, help, "histogram", labels}, series: newSeries[*buckets](), bounds: slices.Clone(bounds)}
+	slices.Sort(h.bounds)
+	r.register(name, h)
+
+	return h
+}
+
+func (h *Histogram) Observe(value float64, values ...string) {
+	key := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, ok := h.values[key]
+	if !ok {
+		b = &buckets{counts: make([]uint64, len(h.bounds)+1)}
+		h.values[key] = b
+		h.labelValues[key] = values
+	}
+
+	i, _ := slices.BinarySearch(h.bounds, value)
+	b.counts[i]++
+	b.sum += value
+}
+
+// Since observes the seconds gone by since start.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.header(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.each(func(values []string, b *buckets) {
+		count := uint64(0)
+		for i, bound := range h.bounds {
+			count += b.counts[i]
+			h.sample(w, "_bucket", values, float64(count), "le", formatFloat(bound))
+		}
+		count += b.counts[len(h.bounds)]
+		h.sample(w, "_bucket", values, float64(count), "le", "+Inf")
+		h.sample(w, "_sum", values, b.sum)
+		h.sample(w, "_count", values, float64(count))
+	})
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	r := NewRegistry()
+
+	moves := r.Counter("moves_total", "Moves played.", "game")
+	moves.Inc("chess")
+	moves.Add(2, "chess")
+	moves.Inc(`tic"tac"toe`)
+
+	sessions := r.Gauge("sessions", "Sessions open.")
+	sessions.Add(2)
+	sessions.Add(-1)
+
+	r.GaugeFunc("games", "Games going.", []string{"game"}, func(set func(float64, ...string)) {
+		set(3, "tictactoe")
+		set(1, "chess")
+	})
+
+	latency := r.Histogram("latency_seconds", "How long it took,\nin seconds.", []float64{1, 0.1}, "route")
+	latency.Observe(0.05, "/")
+	latency.Observe(0.1, "/")
+	latency.Observe(5, "/")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	expected := `# HELP games Games going.
+# TYPE games gauge
+games{game="chess"} 1
+games{game="tictactoe"} 3
+# HELP latency_seconds How long it took,\nin seconds.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/",le="0.1"} 2
+latency_seconds_bucket{route="/",le="1"} 2
+latency_seconds_bucket{route="/",le="+Inf"} 3
+latency_seconds_sum{route="/"} 5.15
+latency_seconds_count{route="/"} 3
+# HELP moves_total Moves played.
+# TYPE moves_total counter
+moves_total{game="chess"} 3
+moves_total{game="tic\"tac\"toe"} 1
+# HELP sessions Sessions open.
+# TYPE sessions gauge
+sessions 1
+`
+	if w.Body.String() != expected {
+		t.Errorf("Expected metrics (\n%s) != actual metrics (\n%s)", expected, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format, got %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestLabels(t *testing.T) {
+	r := NewRegistry()
+	moves := r.Counter("moves_total", "Moves played.", "game")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic for the wrong number of label values")
+		}
+	}()
+	moves.Inc()
+}
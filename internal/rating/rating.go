@@ -0,0 +1,202 @@
+// Package rating rates players with the Glicko-2 system, described at
+// http://www.glicko.net/glicko/glicko2.pdf. Every rated game is its own rating
+// period, so ratings move as soon as a game ends.
+package rating
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	DefaultRating     = 1500
+	DefaultDeviation  = 350
+	DefaultVolatility = 0.06
+
+	// Tau limits how quickly volatility can change.
+	Tau = 0.5
+
+	// ProvisionalDeviation is the deviation above which a rating is still
+	// too uncertain to be trusted.
+	ProvisionalDeviation = 110
+
+	// scale converts between the Glicko and Glicko-2 scales.
+	scale = 173.7178
+
+	// epsilon is how precisely the new volatility is found.
+	epsilon = 0.000001
+)
+
+// Rating is a player's strength, with the deviation saying how sure it is and
+// the volatility how erratic their results have been.
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// New is the rating of a player who hasn't played yet.
+func New() Rating {
+	return Rating{
+		Rating:     DefaultRating,
+		Deviation:  DefaultDeviation,
+		Volatility: DefaultVolatility,
+	}
+}
+
+// Provisional reports whether the rating is still settling.
+func (r Rating) Provisional() bool {
+	return r.Deviation > ProvisionalDeviation
+}
+
+// Result is one game in a rating period: who it was against, and the score,
+// 1 for a win, 0.5 for a draw and 0 for a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Update returns the rating after a rating period with the given results. A
+// period without any games only makes the rating less certain.
+func (r Rating) Update(results []Result) Rating {
+	mu := (r.Rating - DefaultRating) / scale
+	phi := r.Deviation / scale
+	sigma := r.Volatility
+
+	if len(results) == 0 {
+		phi = math.Sqrt(phi*phi + sigma*sigma)
+		return Rating{r.Rating, math.Min(phi*scale, DefaultDeviation), sigma}
+	}
+
+	var vInv, improvement float64
+	for _, result := range results {
+		muJ := (result.Opponent.Rating - DefaultRating) / scale
+		g := g(result.Opponent.Deviation / scale)
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+
+		vInv += g * g * e * (1 - e)
+		improvement += g * (result.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * improvement
+
+	sigma = volatility(sigma, phi, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * improvement
+
+	return Rating{mu*scale + DefaultRating, phi * scale, sigma}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// volatility finds the new volatility with the Illinois algorithm, step 5 of
+// the Glicko-2 paper.
+func volatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		return ex*(delta*delta-phi*phi-v-ex)/(2*math.Pow(phi*phi+v+ex, 2)) - (x-a)/(Tau*Tau)
+	}
+
+	A := a
+	B := 0.0
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*Tau) < 0 {
+			k++
+		}
+		B = a - k*Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
+
+// Categories are the pools players are rated in, in the order they're shown.
+// Chess is split by how long a game takes, and every other game is a pool
+// of its own.
+var Categories = []string{
+	"chess-bullet",
+	"chess-blitz",
+	"chess-rapid",
+	"chess-classical",
+	"chess-untimed",
+	"tictactoe",
+}
+
+var titles = map[string]string{
+	"chess-bullet":    "Chess (Bullet)",
+	"chess-blitz":     "Chess (Blitz)",
+	"chess-rapid":     "Chess (Rapid)",
+	"chess-classical": "Chess (Classical)",
+	"chess-untimed":   "Chess (Untimed)",
+	"tictactoe":       "Tic-Tac-Toe",
+}
+
+// Title is a category's name as shown to players.
+func Title(category string) string {
+	title, ok := titles[category]
+	if !ok {
+		return category
+	}
+
+	return title
+}
+
+// Category is the pool a game is rated in. Chess games are bucketed by their
+// estimated length, the initial time plus forty moves' increments, and
+// untimed games have no initial time.
+func Category(game string, initial, increment time.Duration) string {
+	if game != "chess" {
+		return game
+	}
+
+	estimate := initial + 40*increment
+	switch {
+	case initial == 0:
+		return "chess-untimed"
+	case estimate < 3*time.Minute:
+		return "chess-bullet"
+	case estimate < 8*time.Minute:
+		return "chess-blitz"
+	case estimate < 25*time.Minute:
+		return "chess-rapid"
+	}
+
+	return "chess-classical"
+}
+
+// Bot is the fixed anchor rating of the bot searching to the given depth.
+// Anchors never change, so they tie players' ratings to something even when
+// only a few people play.
+func Bot(game string, depth int) Rating {
+	rating := DefaultRating
+	switch game {
+	case "chess":
+		rating = 800 + 150*(min(max(depth, 1), 12)-1)
+	case "tictactoe":
+		rating = 900 + 125*(min(max(depth, 1), 9)-1)
+	}
+
+	return Rating{
+		Rating:     float64(rating),
+		Deviation:  50,
+		Volatility: DefaultVolatility,
+	}
+}
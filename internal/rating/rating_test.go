@@ -0,0 +1,99 @@
+package rating
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// TestUpdate checks the worked example from the Glicko-2 paper.
+func TestUpdate(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	results := []Result{
+		{Rating{1400, 30, 0.06}, 1},
+		{Rating{1550, 100, 0.06}, 0},
+		{Rating{1700, 300, 0.06}, 0},
+	}
+
+	actual := player.Update(results)
+	if !near(actual.Rating, 1464.06, 0.01) {
+		t.Errorf("Expected rating (%.2f) != actual rating (%.2f)", 1464.06, actual.Rating)
+	}
+	if !near(actual.Deviation, 151.52, 0.01) {
+		t.Errorf("Expected deviation (%.2f) != actual deviation (%.2f)", 151.52, actual.Deviation)
+	}
+	if !near(actual.Volatility, 0.05999, 0.00001) {
+		t.Errorf("Expected volatility (%.5f) != actual volatility (%.5f)", 0.05999, actual.Volatility)
+	}
+}
+
+func TestUpdateWithoutGames(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+
+	actual := player.Update(nil)
+	if actual.Rating != 1500 || !near(actual.Deviation, 200.27, 0.01) {
+		t.Errorf("Expected only the deviation to grow, got %+v", actual)
+	}
+
+	if New().Update(nil).Deviation != DefaultDeviation {
+		t.Errorf("Expected the deviation to stay capped at %d", DefaultDeviation)
+	}
+}
+
+func TestUpdateDirection(t *testing.T) {
+	player := New()
+	opponent := New()
+
+	won := player.Update([]Result{{opponent, 1}})
+	lost := player.Update([]Result{{opponent, 0}})
+	drew := player.Update([]Result{{opponent, 0.5}})
+
+	if won.Rating <= player.Rating || lost.Rating >= player.Rating || drew.Rating != player.Rating {
+		t.Errorf("Expected a win to gain, a loss to lose and a draw to hold, got %.1f, %.1f and %.1f", won.Rating, lost.Rating, drew.Rating)
+	}
+	if won.Deviation >= player.Deviation {
+		t.Errorf("Expected a game to make the rating more certain")
+	}
+}
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		game      string
+		initial   time.Duration
+		increment time.Duration
+		expected  string
+	}{
+		{"chess", 0, 0, "chess-untimed"},
+		{"chess", time.Minute, 0, "chess-bullet"},
+		{"chess", 3 * time.Minute, 2 * time.Second, "chess-blitz"},
+		{"chess", 5 * time.Minute, 0, "chess-blitz"},
+		{"chess", 10 * time.Minute, 5 * time.Second, "chess-rapid"},
+		{"chess", 15 * time.Minute, 10 * time.Second, "chess-rapid"},
+		{"chess", 30 * time.Minute, 0, "chess-classical"},
+		{"tictactoe", 5 * time.Minute, 0, "tictactoe"},
+	}
+
+	for _, test := range tests {
+		actual := Category(test.game, test.initial, test.increment)
+		if actual != test.expected {
+			t.Errorf("%s %s+%s: Expected category (%s) != actual category (%s)", test.game, test.initial, test.increment, test.expected, actual)
+		}
+	}
+}
+
+func TestBot(t *testing.T) {
+	for _, game := range []string{"chess", "tictactoe"} {
+		previous := 0.0
+		for depth := 1; depth <= 12; depth++ {
+			anchor := Bot(game, depth)
+			if anchor.Rating < previous {
+				t.Errorf("%s: Expected depth %d to be rated at least as high as depth %d", game, depth, depth-1)
+			}
+			previous = anchor.Rating
+		}
+	}
+}
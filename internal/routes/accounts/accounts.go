@@ -0,0 +1,727 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/rating"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+const (
+	// CodeTTL is how long a code for linking a browser stays valid.
+	CodeTTL = 10 * time.Minute
+	// codeBytes is how many random bytes a code has, written as twice as many
+	// hex digits: enough that codes can't be guessed while they're valid.
+	codeBytes = 8
+
+	// MaxBots is how many bot accounts one player can own.
+	MaxBots = 5
+
+	// DefaultRating is the rating of players who haven't played a rated game.
+	DefaultRating = rating.DefaultRating
+
+	// seatPrefix marks the seats of players with an account, which are the
+	// same whichever front end they play from.
+	seatPrefix = "user:"
+)
+
+var (
+	ErrNotFound    = errors.New("account not found")
+	ErrInvalidName = errors.New("names are 3 to 20 letters, numbers, '-' or '_'")
+	ErrNameTaken   = errors.New("that name is taken")
+	ErrKeyTaken    = errors.New("that key already belongs to an account")
+	ErrBadCode     = errors.New("that code is wrong or has expired")
+	ErrTooManyBots = fmt.Errorf("players can own at most %d bots", MaxBots)
+	ErrNotBot      = errors.New("that account isn't one of your bots")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{3,20}$`)
+
+// Game is a finished game in a player's history, from their side of the
+// board: an online game, or one against the bot. Rated games record the
+// category they were rated in, the player's rating afterwards and how much
+// it changed, which makes the history their rating history too.
+type Game struct {
+	ID       string    `json:"id"`
+	Game     string    `json:"game"`
+	Colour   string    `json:"colour"`
+	Opponent string    `json:"opponent"`
+	Result   string    `json:"result"`
+	Rated    bool      `json:"rated,omitempty"`
+	Category string    `json:"category,omitempty"`
+	Rating   int       `json:"rating,omitempty"`
+	Change   int       `json:"change,omitempty"`
+	Ended    time.Time `json:"ended"`
+}
+
+// Rating is an account's Glicko-2 rating in one category, and how many rated
+// games it's based on.
+type Rating struct {
+	rating.Rating
+	Games int `json:"games"`
+}
+
+// String is the rating rounded for showing, marked with a question mark
+// while it's still provisional.
+func (r Rating) String() string {
+	s := strconv.Itoa(int(math.Round(r.Rating.Rating)))
+	if r.Provisional() {
+		s += "?"
+	}
+
+	return s
+}
+
+// Account is a player who is recognised across front ends: by their SSH
+// public keys in the TUI, and by the browser sessions they've linked. Bot
+// accounts belong to the player who registered them and play through the bot
+// API, identified by a token. Only a hash of the token is kept.
+type Account struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Bot      bool              `json:"bot,omitempty"`
+	Owner    string            `json:"owner,omitempty"`
+	Keys     []string          `json:"keys"`
+	Sessions []string          `json:"sessions,omitempty"`
+	Tokens   []string          `json:"tokens,omitempty"`
+	Ratings  map[string]Rating `json:"ratings,omitempty"`
+	History  []Game            `json:"history,omitempty"`
+	Created  time.Time         `json:"created"`
+}
+
+// Seat is the seat ID the account plays from.
+func (a Account) Seat() string {
+	return seatPrefix + a.ID
+}
+
+// Rating is the account's rating in a category, which starts out at the
+// default until they've played a rated game in it.
+func (a Account) Rating(category string) Rating {
+	r, ok := a.Ratings[category]
+	if !ok {
+		return Rating{Rating: rating.New()}
+	}
+
+	return r
+}
+
+func (a Account) clone() Account {
+	a.Keys = slices.Clone(a.Keys)
+	a.Sessions = slices.Clone(a.Sessions)
+	a.Tokens = slices.Clone(a.Tokens)
+	a.History = slices.Clone(a.History)
+	ratings := make(map[string]Rating, len(a.Ratings))
+	for category, rating := range a.Ratings {
+		ratings[category] = rating
+	}
+	a.Ratings = ratings
+
+	return a
+}
+
+type linkCode struct {
+	account string
+	expires time.Time
+}
+
+// Accounts holds every player account, saving them to a JSON file whenever
+// one changes. Accounts are found by ID, SSH key fingerprint, linked browser
+// session, bot token or seat, and are returned as copies.
+type Accounts struct {
+	mu       sync.Mutex
+	path     string
+	accounts map[string]*Account
+	keys     map[string]string
+	sessions map[string]string
+	tokens   map[string]string
+	names    map[string]string
+	codes    map[string]linkCode
+}
+
+// Open loads the accounts saved at path, which is created on the first save
+// if it doesn't exist yet.
+func Open(path string) (*Accounts, error) {
+	a := &Accounts{
+		path:     path,
+		accounts: make(map[string]*Account),
+		keys:     make(map[string]string),
+		sessions: make(map[string]string),
+		tokens:   make(map[string]string),
+		names:    make(map[string]string),
+		codes:    make(map[string]linkCode),
+	}
+
+	f, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	saved := []*Account{}
+	err = json.Unmarshal(f, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, account := range saved {
+		a.index(account)
+	}
+
+	return a, nil
+}
+
+// index makes an account findable. The accounts must be locked.
+func (a *Accounts) index(account *Account) {
+	a.accounts[account.ID] = account
+	a.names[strings.ToLower(account.Name)] = account.ID
+	for _, key := range account.Keys {
+		a.keys[key] = account.ID
+	}
+	for _, session := range account.Sessions {
+		a.sessions[session] = account.ID
+	}
+	for _, token := range account.Tokens {
+		a.tokens[token] = account.ID
+	}
+}
+
+// save writes every account to the file, replacing it in one step so a crash
+// can't leave it half written. The accounts must be locked.
+func (a *Accounts) save() error {
+	if a.path == "" {
+		return nil
+	}
+
+	saved := make([]*Account, 0, len(a.accounts))
+	for _, account := range a.accounts {
+		saved = append(saved, account)
+	}
+	slices.SortFunc(saved, func(x, y *Account) int { return x.Created.Compare(y.Created) })
+
+	b, err := json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(a.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp := a.path + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, a.path)
+}
+
+func generateID(n int) string {
+	bytes := make([]byte, n)
+	rand.Read(bytes)
+
+	return fmt.Sprintf("%x", bytes)
+}
+
+// hashToken is how a bot token is stored, so the accounts file can't be used
+// to play as a bot.
+func hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+// newID picks an unused account ID. The accounts must be locked.
+func (a *Accounts) newID() string {
+	id := generateID(8)
+	for a.accounts[id] != nil {
+		id = generateID(8)
+	}
+
+	return id
+}
+
+// nameTaken reports whether a name can't be used for a new account. The
+// accounts must be locked.
+func (a *Accounts) nameTaken(name string) bool {
+	// players without an account are shown as Anonymous
+	_, taken := a.names[strings.ToLower(name)]
+	return taken || strings.EqualFold(name, "Anonymous")
+}
+
+// Register creates an account for a new player, identified by the
+// fingerprint of their SSH public key.
+func (a *Accounts) Register(name, key string) (Account, error) {
+	if !validName.MatchString(name) {
+		return Account{}, ErrInvalidName
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.nameTaken(name) {
+		return Account{}, ErrNameTaken
+	}
+	if _, taken := a.keys[key]; taken {
+		return Account{}, ErrKeyTaken
+	}
+
+	account := &Account{
+		ID:      a.newID(),
+		Name:    name,
+		Keys:    []string{key},
+		Created: time.Now(),
+	}
+	a.index(account)
+
+	return account.clone(), a.save()
+}
+
+// RegisterBot creates a bot account owned by a player, returning it with the
+// token it plays with. The token isn't kept, so it can only be shown now.
+func (a *Accounts) RegisterBot(name, owner string) (Account, string, error) {
+	if !validName.MatchString(name) {
+		return Account{}, "", ErrInvalidName
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if player, ok := a.accounts[owner]; !ok || player.Bot {
+		return Account{}, "", ErrNotFound
+	}
+	if a.nameTaken(name) {
+		return Account{}, "", ErrNameTaken
+	}
+	if len(a.bots(owner)) >= MaxBots {
+		return Account{}, "", ErrTooManyBots
+	}
+
+	token := generateID(20)
+	account := &Account{
+		ID:      a.newID(),
+		Name:    name,
+		Bot:     true,
+		Owner:   owner,
+		Keys:    []string{},
+		Tokens:  []string{hashToken(token)},
+		Created: time.Now(),
+	}
+	a.index(account)
+
+	return account.clone(), token, a.save()
+}
+
+// NewToken replaces the token of one of a player's bots, so the old one stops
+// working.
+func (a *Accounts) NewToken(id, owner string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	account, ok := a.accounts[id]
+	if !ok || !account.Bot || account.Owner != owner {
+		return "", ErrNotBot
+	}
+
+	for _, old := range account.Tokens {
+		delete(a.tokens, old)
+	}
+	token := generateID(20)
+	account.Tokens = []string{hashToken(token)}
+	a.tokens[account.Tokens[0]] = account.ID
+
+	return token, a.save()
+}
+
+// Bots lists the bot accounts a player owns, oldest first.
+func (a *Accounts) Bots(owner string) []Account {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	bots := []Account{}
+	for _, bot := range a.bots(owner) {
+		bots = append(bots, bot.clone())
+	}
+
+	return bots
+}
+
+// bots lists the bot accounts a player owns. The accounts must be locked.
+func (a *Accounts) bots(owner string) []*Account {
+	bots := []*Account{}
+	for _, account := range a.accounts {
+		if account.Bot && account.Owner == owner {
+			bots = append(bots, account)
+		}
+	}
+	slices.SortFunc(bots, func(x, y *Account) int { return x.Created.Compare(y.Created) })
+
+	return bots
+}
+
+func (a *Accounts) Get(id string) (Account, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	account, ok := a.accounts[id]
+	if !ok {
+		return Account{}, false
+	}
+
+	return account.clone(), true
+}
+
+// ForKey finds the account an SSH key fingerprint belongs to.
+func (a *Accounts) ForKey(key string) (Account, bool) {
+	a.mu.Lock()
+	id, ok := a.keys[key]
+	a.mu.Unlock()
+	if !ok {
+		return Account{}, false
+	}
+
+	return a.Get(id)
+}
+
+// ForSession finds the account a browser session has been linked to.
+func (a *Accounts) ForSession(session string) (Account, bool) {
+	a.mu.Lock()
+	id, ok := a.sessions[session]
+	a.mu.Unlock()
+	if !ok {
+		return Account{}, false
+	}
+
+	return a.Get(id)
+}
+
+// ForToken finds the bot account a token belongs to.
+func (a *Accounts) ForToken(token string) (Account, bool) {
+	a.mu.Lock()
+	id, ok := a.tokens[hashToken(token)]
+	a.mu.Unlock()
+	if !ok || token == "" {
+		return Account{}, false
+	}
+
+	return a.Get(id)
+}
+
+// ForSeat finds the account playing from a seat.
+func (a *Accounts) ForSeat(seat string) (Account, bool) {
+	id, ok := strings.CutPrefix(seat, seatPrefix)
+	if !ok {
+		return Account{}, false
+	}
+
+	return a.Get(id)
+}
+
+// ByName finds an account by its display name, ignoring case.
+func (a *Accounts) ByName(name string) (Account, bool) {
+	a.mu.Lock()
+	id, ok := a.names[strings.ToLower(name)]
+	a.mu.Unlock()
+	if !ok {
+		return Account{}, false
+	}
+
+	return a.Get(id)
+}
+
+// NewCode returns a one-time code that links a browser session to the
+// account when entered within CodeTTL. Any earlier code for the account
+// stops working.
+func (a *Accounts) NewCode(id string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.accounts[id]; !ok {
+		return "", ErrNotFound
+	}
+
+	now := time.Now()
+	for code, link := range a.codes {
+		if link.account == id || now.After(link.expires) {
+			delete(a.codes, code)
+		}
+	}
+
+	code := strings.ToUpper(generateID(codeBytes))
+	for _, taken := a.codes[code]; taken; _, taken = a.codes[code] {
+		code = strings.ToUpper(generateID(codeBytes))
+	}
+	a.codes[code] = linkCode{account: id, expires: now.Add(CodeTTL)}
+
+	return code, nil
+}
+
+// Link signs a browser session in to the account a code was made for. Codes
+// can only be used once.
+func (a *Accounts) Link(code, session string) (Account, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	link, ok := a.codes[code]
+	delete(a.codes, code)
+	if !ok || time.Now().After(link.expires) {
+		return Account{}, ErrBadCode
+	}
+
+	account, ok := a.accounts[link.account]
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+
+	if previous, linked := a.sessions[session]; linked {
+		a.accounts[previous].Sessions = slices.DeleteFunc(a.accounts[previous].Sessions, func(s string) bool { return s == session })
+	}
+	account.Sessions = append(account.Sessions, session)
+	a.sessions[session] = account.ID
+
+	return account.clone(), a.save()
+}
+
+// Unlink signs a browser session out of its account.
+func (a *Accounts) Unlink(session string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id, ok := a.sessions[session]
+	if !ok {
+		return nil
+	}
+	delete(a.sessions, session)
+
+	account := a.accounts[id]
+	account.Sessions = slices.DeleteFunc(account.Sessions, func(s string) bool { return s == session })
+
+	return a.save()
+}
+
+// Name is the display name of the player in a seat, or Anonymous for players
+// without an account.
+func (a *Accounts) Name(seat string) string {
+	account, ok := a.ForSeat(seat)
+	if !ok {
+		return "Anonymous"
+	}
+
+	return account.Name
+}
+
+// Rating is the rating of the player in a seat in a category, rounded for
+// showing and matching players.
+func (a *Accounts) Rating(seat, category string) int {
+	account, ok := a.ForSeat(seat)
+	if !ok {
+		return DefaultRating
+	}
+
+	return int(math.Round(account.Rating(category).Rating.Rating))
+}
+
+// Finished adds a finished game to the history of each player in it with an
+// account, and updates their ratings if it was rated. Online games are rated
+// when they were matched as rated in the lobby, and games against the bot are
+// always rated, against the bot's fixed anchor rating. Games played by one
+// person against themselves aren't recorded.
+func (a *Accounts) Finished(entry *store.Entry) {
+	data := entry.Data
+	result := store.Result(entry.Game, data)
+	colours := service.SeatNames(entry.Game)
+	if result == "" || len(colours) != 2 {
+		return
+	}
+
+	name := service.GameName(entry.Game)
+	// Player is the person's colour against the bot, or "neither" when the
+	// bot plays itself
+	bot := !data.Online && data.Player != "" && data.Player != "neither"
+	if !data.Online && !bot {
+		return
+	}
+
+	var initial, increment time.Duration
+	if data.Clock != nil {
+		initial, increment = data.Clock.Initial, data.Clock.Increment
+	}
+	category := rating.Category(name, initial, increment)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// each side's account, if they have one, and their name and rating going
+	// into the game
+	var players [2]*Account
+	var names [2]string
+	var before [2]rating.Rating
+	for side, colour := range colours {
+		names[side] = data.Names[colour]
+		before[side] = rating.New()
+
+		seat := data.Seats[colour]
+		if bot {
+			seat = ""
+			if colour == data.Player {
+				seat = data.Owner
+			}
+		}
+
+		if id, ok := strings.CutPrefix(seat, seatPrefix); ok && a.accounts[id] != nil {
+			players[side] = a.accounts[id]
+			names[side] = players[side].Name
+			before[side] = players[side].Rating(category).Rating
+		}
+
+		if bot && colour != data.Player {
+			names[side] = service.BotName(entry)
+			before[side] = rating.Bot(name, service.BotDepth(entry))
+		}
+	}
+
+	rated := data.Rated || bot
+	changed := false
+	for side, account := range players {
+		if account == nil {
+			continue
+		}
+
+		if slices.ContainsFunc(account.History, func(g Game) bool { return g.ID == entry.ID }) {
+			continue
+		}
+
+		game := Game{
+			ID:       entry.ID,
+			Game:     name,
+			Colour:   colours[side],
+			Opponent: names[1-side],
+			Result:   outcome(result, side),
+			Rated:    rated,
+			Ended:    time.Now(),
+		}
+
+		if rated {
+			previous := account.Rating(category)
+			updated := Rating{
+				Rating: previous.Update([]rating.Result{{Opponent: before[1-side], Score: score(game.Result)}}),
+				Games:  previous.Games + 1,
+			}
+			if account.Ratings == nil {
+				account.Ratings = make(map[string]Rating)
+			}
+			account.Ratings[category] = updated
+
+			game.Category = category
+			game.Rating = int(math.Round(updated.Rating.Rating))
+			game.Change = game.Rating - int(math.Round(previous.Rating.Rating))
+		}
+
+		account.History = append(account.History, game)
+		changed = true
+	}
+
+	if changed {
+		err := a.save()
+		if err != nil {
+			slog.Error("error saving accounts", "err", err)
+		}
+	}
+}
+
+// Standing is a player's place on a leaderboard.
+type Standing struct {
+	Rank        int
+	Name        string
+	Rating      int
+	Deviation   int
+	Games       int
+	Provisional bool
+}
+
+// Leaderboard ranks the players who have played rated games in a category by
+// their rating, best first, returning at most limit of them. Players whose
+// ratings are still provisional are ranked after the rest.
+func (a *Accounts) Leaderboard(category string, limit int) []Standing {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	standings := []Standing{}
+	for _, account := range a.accounts {
+		r, ok := account.Ratings[category]
+		if !ok || r.Games == 0 {
+			continue
+		}
+
+		standings = append(standings, Standing{
+			Name:        account.Name,
+			Rating:      int(math.Round(r.Rating.Rating)),
+			Deviation:   int(math.Round(r.Deviation)),
+			Games:       r.Games,
+			Provisional: r.Provisional(),
+		})
+	}
+
+	slices.SortFunc(standings, func(x, y Standing) int {
+		switch {
+		case x.Provisional != y.Provisional && x.Provisional:
+			return 1
+		case x.Provisional != y.Provisional:
+			return -1
+		case x.Rating != y.Rating:
+			return y.Rating - x.Rating
+		}
+
+		return strings.Compare(strings.ToLower(x.Name), strings.ToLower(y.Name))
+	})
+
+	if limit > 0 && len(standings) > limit {
+		standings = standings[:limit]
+	}
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+
+	return standings
+}
+
+// score is a result as Glicko-2 counts it.
+func score(result string) float64 {
+	switch result {
+	case "Win":
+		return 1
+	case "Draw":
+		return 0.5
+	}
+
+	return 0
+}
+
+// outcome is how a result went for the side that moved first (0) or second
+// (1).
+func outcome(result string, side int) string {
+	switch {
+	case result == "1/2-1/2":
+		return "Draw"
+	case (result == "1-0") == (side == 0):
+		return "Win"
+	}
+
+	return "Loss"
+}
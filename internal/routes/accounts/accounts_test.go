@@ -0,0 +1,309 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+func TestRegister(t *testing.T) {
+	a, _ := Open("")
+
+	alice, err := a.Register("alice", "SHA256:alice")
+	if err != nil {
+		t.Fatalf("Expected no error registering, got %s", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		err  error
+	}{
+		{"al", "SHA256:other", ErrInvalidName},
+		{"bob smith", "SHA256:other", ErrInvalidName},
+		{"Alice", "SHA256:other", ErrNameTaken},
+		{"bob", "SHA256:alice", ErrKeyTaken},
+		{"bob", "SHA256:bob", nil},
+	}
+
+	for _, test := range tests {
+		_, err := a.Register(test.name, test.key)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: Expected error (%v) != actual error (%v)", test.name, test.err, err)
+		}
+	}
+
+	found, ok := a.ForKey("SHA256:alice")
+	if !ok || found.ID != alice.ID {
+		t.Errorf("Expected to find alice by her key")
+	}
+	if name := a.Name(alice.Seat()); name != "alice" {
+		t.Errorf("Expected name (%s) != actual name (%s)", "alice", name)
+	}
+	if name := a.Name("some-session"); name != "Anonymous" {
+		t.Errorf("Expected name (%s) != actual name (%s)", "Anonymous", name)
+	}
+	if rating := a.Rating(alice.Seat(), "chess"); rating != DefaultRating {
+		t.Errorf("Expected rating (%d) != actual rating (%d)", DefaultRating, rating)
+	}
+}
+
+func TestLink(t *testing.T) {
+	a, _ := Open("")
+	alice, _ := a.Register("alice", "SHA256:alice")
+
+	code, err := a.NewCode(alice.ID)
+	if err != nil {
+		t.Fatalf("Expected no error making a code, got %s", err)
+	}
+	if len(code) != 2*codeBytes {
+		t.Errorf("Expected code length (%d) != actual code length (%d)", 2*codeBytes, len(code))
+	}
+
+	if _, err := a.Link("nope", "browser"); !errors.Is(err, ErrBadCode) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrBadCode, err)
+	}
+
+	linked, err := a.Link(code, "browser")
+	if err != nil || linked.ID != alice.ID {
+		t.Fatalf("Expected the code to link alice, got error %v", err)
+	}
+	if found, ok := a.ForSession("browser"); !ok || found.ID != alice.ID {
+		t.Errorf("Expected the session to belong to alice")
+	}
+
+	if _, err := a.Link(code, "another browser"); !errors.Is(err, ErrBadCode) {
+		t.Errorf("Expected a code to only work once, got error %v", err)
+	}
+
+	code, _ = a.NewCode(alice.ID)
+	a.mu.Lock()
+	link := a.codes[code]
+	link.expires = time.Now().Add(-time.Second)
+	a.codes[code] = link
+	a.mu.Unlock()
+	if _, err := a.Link(code, "another browser"); !errors.Is(err, ErrBadCode) {
+		t.Errorf("Expected an expired code to fail, got error %v", err)
+	}
+
+	a.Unlink("browser")
+	if _, ok := a.ForSession("browser"); ok {
+		t.Errorf("Expected the session to be signed out")
+	}
+}
+
+func TestRegisterBot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	a, _ := Open(path)
+	alice, _ := a.Register("alice", "SHA256:alice")
+
+	bot, token, err := a.RegisterBot("alicebot", alice.ID)
+	if err != nil {
+		t.Fatalf("Expected no error registering a bot, got %s", err)
+	}
+	if !bot.Bot || bot.Owner != alice.ID {
+		t.Errorf("Expected the bot to belong to alice")
+	}
+
+	tests := []struct {
+		name  string
+		owner string
+		err   error
+	}{
+		{"bo", alice.ID, ErrInvalidName},
+		{"ALICEBOT", alice.ID, ErrNameTaken},
+		{"otherbot", "nobody", ErrNotFound},
+		{"botbot", bot.ID, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		_, _, err := a.RegisterBot(test.name, test.owner)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: Expected error (%v) != actual error (%v)", test.name, test.err, err)
+		}
+	}
+
+	if found, ok := a.ForToken(token); !ok || found.ID != bot.ID {
+		t.Errorf("Expected to find the bot by its token")
+	}
+	if _, ok := a.ForToken(""); ok {
+		t.Errorf("Expected an empty token not to find a bot")
+	}
+
+	if _, err := a.NewToken(alice.ID, alice.ID); !errors.Is(err, ErrNotBot) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotBot, err)
+	}
+	newToken, err := a.NewToken(bot.ID, alice.ID)
+	if err != nil {
+		t.Fatalf("Expected no error making a new token, got %s", err)
+	}
+	if _, ok := a.ForToken(token); ok {
+		t.Errorf("Expected the old token to stop working")
+	}
+
+	reopened, _ := Open(path)
+	if found, ok := reopened.ForToken(newToken); !ok || found.ID != bot.ID {
+		t.Errorf("Expected the new token to be restored")
+	}
+
+	for i := len(reopened.Bots(alice.ID)); i < MaxBots; i++ {
+		reopened.RegisterBot(fmt.Sprintf("alicebot%d", i), alice.ID)
+	}
+	if _, _, err := reopened.RegisterBot("onetoomany", alice.ID); !errors.Is(err, ErrTooManyBots) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrTooManyBots, err)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening accounts, got %s", err)
+	}
+
+	alice, _ := a.Register("alice", "SHA256:alice")
+	code, _ := a.NewCode(alice.ID)
+	a.Link(code, "browser")
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error reopening accounts, got %s", err)
+	}
+	if found, ok := reopened.ForSession("browser"); !ok || found.Name != "alice" {
+		t.Errorf("Expected alice's linked session to be restored")
+	}
+	if found, ok := reopened.ByName("ALICE"); !ok || found.ID != alice.ID {
+		t.Errorf("Expected to find alice by name")
+	}
+}
+
+func TestFinished(t *testing.T) {
+	a, _ := Open("")
+	alice, _ := a.Register("alice", "SHA256:alice")
+
+	s := service.New(store.New(time.Hour, 0))
+	s.Players = a
+
+	game := tictactoe.NewGame()
+	data := &utils.TwoPlayerGame{Active: "X", Started: true}
+	entry, _ := s.Games.Add(game, data)
+	entry.Lock()
+	defer entry.Unlock()
+
+	service.Host(entry, "X", alice.Seat(), nil)
+	s.Join(entry, "browser")
+	if data.Names["X"] != "alice" || data.Names["O"] != "Anonymous" {
+		t.Errorf("Expected the players' names, got %v", data.Names)
+	}
+
+	for _, move := range []int{0, 3, 1, 4, 2} {
+		s.PlayTTT(entry, move)
+	}
+
+	alice, _ = a.Get(alice.ID)
+	if len(alice.History) != 1 {
+		t.Fatalf("Expected one game in alice's history, got %d", len(alice.History))
+	}
+	played := alice.History[0]
+	if played.Result != "Win" || played.Colour != "X" || played.Opponent != "Anonymous" || played.Game != "tictactoe" {
+		t.Errorf("Expected a win as X against Anonymous, got %+v", played)
+	}
+}
+
+func TestFinishedRated(t *testing.T) {
+	a, _ := Open("")
+	alice, _ := a.Register("alice", "SHA256:alice")
+	bob, _ := a.Register("bob", "SHA256:bob")
+
+	s := service.New(store.New(time.Hour, 0))
+	s.Players = a
+
+	entry, _ := s.Pair("tictactoe", [2]string{alice.Seat(), bob.Seat()}, nil, true)
+	entry.Lock()
+	for _, move := range []int{0, 3, 1, 4, 2} {
+		s.PlayTTT(entry, move)
+	}
+	entry.Unlock()
+
+	alice, _ = a.Get(alice.ID)
+	bob, _ = a.Get(bob.ID)
+	won, lost := alice.Rating("tictactoe"), bob.Rating("tictactoe")
+	if won.Games != 1 || lost.Games != 1 {
+		t.Fatalf("Expected both players to have one rated game, got %d and %d", won.Games, lost.Games)
+	}
+	if won.Rating.Rating <= DefaultRating || lost.Rating.Rating >= DefaultRating {
+		t.Errorf("Expected the winner to gain and the loser to lose, got %s and %s", won, lost)
+	}
+
+	played := alice.History[0]
+	if played.Category != "tictactoe" || played.Change <= 0 || played.Rating != a.Rating(alice.Seat(), "tictactoe") {
+		t.Errorf("Expected the game to record alice's new rating, got %+v", played)
+	}
+
+	standings := a.Leaderboard("tictactoe", 10)
+	if len(standings) != 2 || standings[0].Name != "alice" || standings[0].Rank != 1 || standings[1].Name != "bob" {
+		t.Errorf("Expected alice to lead bob, got %+v", standings)
+	}
+	if standings := a.Leaderboard("chess-blitz", 10); len(standings) != 0 {
+		t.Errorf("Expected nobody on the blitz leaderboard, got %+v", standings)
+	}
+}
+
+func TestFinishedAgainstBot(t *testing.T) {
+	a, _ := Open("")
+	alice, _ := a.Register("alice", "SHA256:alice")
+
+	s := service.New(store.New(time.Hour, 0))
+	s.Players = a
+
+	game := tictactoe.NewGame()
+	game.SearchDepth = 1
+	data := &utils.TwoPlayerGame{Active: "X", Player: "O", Owner: alice.Seat(), Started: true}
+	entry, _ := s.Games.Add(game, data)
+	entry.Lock()
+	defer entry.Unlock()
+
+	// the moves are played straight on the board, so the bot doesn't start
+	// searching in the background
+	for _, move := range []int{0, 3, 1, 4, 2} {
+		game.MakeMove(move)
+	}
+	data.Ended = true
+	s.Players.Finished(entry)
+
+	alice, _ = a.Get(alice.ID)
+	if len(alice.History) != 1 {
+		t.Fatalf("Expected one game in alice's history, got %d", len(alice.History))
+	}
+	played := alice.History[0]
+	if played.Result != "Loss" || played.Opponent != "Bot (depth 1)" || !played.Rated || played.Change >= 0 {
+		t.Errorf("Expected a rated loss to the bot, got %+v", played)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		result   string
+		side     int
+		expected string
+	}{
+		{"1-0", 0, "Win"},
+		{"1-0", 1, "Loss"},
+		{"0-1", 0, "Loss"},
+		{"0-1", 1, "Win"},
+		{"1/2-1/2", 1, "Draw"},
+	}
+
+	for _, test := range tests {
+		if actual := outcome(test.result, test.side); actual != test.expected {
+			t.Errorf("%s for side %d: Expected outcome (%s) != actual outcome (%s)", test.result, test.side, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+)
+
+// kickedNotice tells players an admin has kicked them, in the browser and
+// the TUI, and refusedNotice turns them away until they're let back in.
+const (
+	kickedNotice  = "You've been disconnected by an admin."
+	refusedNotice = "An admin has disconnected you. Try again in a little while."
+)
+
+// adminView is the admin page, with a notice of what the last action did.
+type adminView struct {
+	Name     string
+	Notice   string
+	Load     admin.Load
+	Games    []admin.Game
+	Sessions []admin.Session
+}
+
+// adminName returns the name of the admin making the request. Anyone else is
+// told there's no such page.
+func (cfg *configdata) adminName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	seat := cfg.seat(w, r)
+	if !cfg.Admin.AllowedSeat(seat) {
+		http.NotFound(w, r)
+		return "", false
+	}
+
+	return cfg.Accounts.Name(seat), true
+}
+
+// respondWithAdmin sends the admin back to the admin page once they've acted,
+// with a notice of what happened.
+func respondWithAdmin(w http.ResponseWriter, r *http.Request, notice string) {
+	http.Redirect(w, r, "/admin?notice="+url.QueryEscape(notice), http.StatusSeeOther)
+}
+
+func (cfg *configdata) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	name, ok := cfg.adminName(w, r)
+	if !ok {
+		return
+	}
+
+	view := adminView{
+		Name:     name,
+		Notice:   r.URL.Query().Get("notice"),
+		Load:     cfg.Admin.Load(),
+		Games:    cfg.Admin.Games(),
+		Sessions: cfg.Admin.Sessions.List(),
+	}
+	err := cfg.Pages["admin"].ExecuteTemplate(w, "base.html", view)
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", "admin", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (cfg *configdata) handleAdminAbort(w http.ResponseWriter, r *http.Request) {
+	name, ok := cfg.adminName(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	err := cfg.Admin.Abort(id, name)
+	if err != nil {
+		respondWithAdmin(w, r, fmt.Sprintf("Can't abort game %s, %s", id, err))
+		return
+	}
+	respondWithAdmin(w, r, fmt.Sprintf("Aborted game %s", id))
+}
+
+// handleAdminAdjudicate ends a game with a win for the winner side from the
+// form, or a draw if it's empty.
+func (cfg *configdata) handleAdminAdjudicate(w http.ResponseWriter, r *http.Request) {
+	name, ok := cfg.adminName(w, r)
+	if !ok {
+		return
+	}
+
+	id, winner := r.PathValue("id"), r.FormValue("winner")
+	err := cfg.Admin.Adjudicate(id, winner, name)
+	switch {
+	case err != nil:
+		respondWithAdmin(w, r, fmt.Sprintf("Can't adjudicate game %s, %s", id, err))
+	case winner == "":
+		respondWithAdmin(w, r, fmt.Sprintf("Adjudicated game %s as a draw", id))
+	default:
+		respondWithAdmin(w, r, fmt.Sprintf("Adjudicated game %s as a win for %s", id, winner))
+	}
+}
+
+func (cfg *configdata) handleAdminKick(w http.ResponseWriter, r *http.Request) {
+	name, ok := cfg.adminName(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("session"))
+	if err != nil {
+		http.Error(w, admin.ErrNoSession.Error(), http.StatusBadRequest)
+		return
+	}
+	kicked, err := cfg.Admin.Kick(id, name)
+	if err != nil {
+		respondWithAdmin(w, r, fmt.Sprintf("Can't kick session %d, %s", id, err))
+		return
+	}
+	respondWithAdmin(w, r, fmt.Sprintf("Kicked the player, closing %d sessions", kicked))
+}
+
+// handleAdminSettings changes the bot's limits, with the search time written
+// as a duration such as 5s.
+func (cfg *configdata) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
+	name, ok := cfg.adminName(w, r)
+	if !ok {
+		return
+	}
+
+	depth, err := strconv.Atoi(r.FormValue("max_depth"))
+	if err != nil {
+		respondWithAdmin(w, r, fmt.Sprintf("%q isn't a depth", r.FormValue("max_depth")))
+		return
+	}
+	searchTime, err := time.ParseDuration(r.FormValue("max_search_time"))
+	if err != nil {
+		respondWithAdmin(w, r, fmt.Sprintf("%q isn't a duration, such as 5s", r.FormValue("max_search_time")))
+		return
+	}
+
+	err = cfg.Admin.SetBotLimits(depth, searchTime, name)
+	if err != nil {
+		respondWithAdmin(w, r, fmt.Sprintf("Can't change the bot's limits, %s", err))
+		return
+	}
+	respondWithAdmin(w, r, "Changed the bot's limits")
+}
+
+// openSession lists a browser's event stream with the sessions admins can
+// see until it's closed, with the game it's showing if it's showing one. The
+// returned channel is closed if an admin kicks the player.
+func (cfg *configdata) openSession(r *http.Request, seat, game string) (<-chan struct{}, func()) {
+	kicked := make(chan struct{})
+	closed := cfg.Admin.Sessions.Open(admin.Session{
+		Front: "browser",
+		Seat:  seat,
+		Name:  cfg.Accounts.Name(seat),
+		Addr:  cfg.Limits.RequestAddr(r),
+		Game:  game,
+	}, func() { close(kicked) })
+
+	return kicked, closed
+}
+
+// refuseKicked turns away browsers whose players an admin has kicked, until
+// they're let back in.
+func refuseKicked(sessions *admin.Sessions, players *accounts.Accounts, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err == nil && cookie.Value != "" {
+			seat := cookie.Value
+			if account, ok := players.ForSession(seat); ok {
+				seat = account.Seat()
+			}
+			if sessions.Kicked(seat) {
+				http.Error(w, refusedNotice, http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
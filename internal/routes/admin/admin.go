@@ -0,0 +1,228 @@
+// Package admin is what operators can see and do while the server runs, from
+// the browser's admin page or the TUI's admin mode: the games going and the
+// players connected, the bot's load and its limits. Admins are players whose
+// SSH keys are on an allowlist, and in the browser, players who have linked
+// their session to such an account.
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+// ErrNegative is returned for bot limits below zero.
+var ErrNegative = errors.New("limits can't be negative")
+
+// Admin is the admin console shared by both front ends.
+type Admin struct {
+	Service  *service.Service
+	Players  *accounts.Accounts
+	Sessions *Sessions
+
+	keys []string
+}
+
+// New makes a console for the admins with the given SSH key fingerprints,
+// such as SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s.
+func New(games *service.Service, players *accounts.Accounts, sessions *Sessions, keys []string) *Admin {
+	return &Admin{Service: games, Players: players, Sessions: sessions, keys: keys}
+}
+
+// Allowed reports whether an SSH key, by its fingerprint, is an admin's. It's
+// only to be asked of the key a session signed in with, never of one a client
+// merely offered.
+func (a *Admin) Allowed(key string) bool {
+	return key != "" && slices.Contains(a.keys, key)
+}
+
+// AllowedSeat reports whether the player in a seat has an account with an
+// admin's key, which is how a browser session linked to it is let in.
+func (a *Admin) AllowedSeat(seat string) bool {
+	account, ok := a.Players.ForSeat(seat)
+	if !ok || account.Bot {
+		return false
+	}
+
+	return slices.ContainsFunc(account.Keys, a.Allowed)
+}
+
+// Game is a game going, as admins see it.
+type Game struct {
+	ID      string
+	Game    string
+	Title   string
+	Mode    string
+	Players string
+	Sides   []string
+	Status  string
+	Moves   int
+	Rated   bool
+
+	Spectators int
+	// Queued is the bot's place in line for a worker, if it's waiting for
+	// one.
+	Queued  int
+	Created time.Time
+	Active  time.Time
+}
+
+// Games is every game that hasn't ended, newest first.
+func (a *Admin) Games() []Game {
+	games := []Game{}
+	for _, entry := range a.Service.Games.Entries() {
+		entry.Lock()
+		if !entry.Data.Ended {
+			games = append(games, a.game(entry))
+		}
+		entry.Unlock()
+	}
+	slices.SortFunc(games, func(a, b Game) int { return b.Created.Compare(a.Created) })
+
+	return games
+}
+
+// game describes a game. The entry must be locked.
+func (a *Admin) game(entry *store.Entry) Game {
+	data := entry.Data
+	game := Game{
+		ID:         entry.ID,
+		Game:       service.GameName(entry.Game),
+		Title:      lobby.Titles[service.GameName(entry.Game)],
+		Sides:      service.SeatNames(entry.Game),
+		Status:     data.Status,
+		Rated:      data.Rated,
+		Spectators: entry.Watchers(),
+		Queued:     entry.Queued(),
+		Created:    entry.Created,
+		Active:     entry.LastActive(),
+	}
+
+	switch board := entry.Game.(type) {
+	case *chess.ChessGame:
+		game.Moves = len(board.Moves)
+	case *tictactoe.TicTacToeGame:
+		game.Moves = len(board.Moves)
+	}
+
+	names := []string{}
+	switch {
+	case data.Online:
+		game.Mode = service.ModeOnline
+		for _, side := range game.Sides {
+			name := "waiting"
+			if seat, ok := data.Seats[side]; ok {
+				name = a.Players.Name(seat)
+			}
+			names = append(names, fmt.Sprintf("%s (%s)", name, side))
+		}
+	case data.Player != "":
+		game.Mode = service.ModeBot
+		for _, side := range game.Sides {
+			name := service.BotName(entry)
+			if side == data.Player {
+				name = a.Players.Name(data.Owner)
+			}
+			names = append(names, fmt.Sprintf("%s (%s)", name, side))
+		}
+	default:
+		game.Mode = service.ModeLocal
+		names = append(names, a.Players.Name(data.Owner)+" (both sides)")
+	}
+	game.Players = strings.Join(names, " vs ")
+
+	return game
+}
+
+// Abort ends a game without a result.
+func (a *Admin) Abort(id, by string) error {
+	entry, err := a.Service.Games.Get(id)
+	if err != nil {
+		return err
+	}
+	entry.Lock()
+	defer entry.Unlock()
+
+	err = a.Service.Abort(entry)
+	if err == nil {
+		slog.Info("admin aborted game", "game", id, "admin", by)
+	}
+
+	return err
+}
+
+// Adjudicate ends a game with a win for the winner's side, or a draw if the
+// winner is empty.
+func (a *Admin) Adjudicate(id, winner, by string) error {
+	entry, err := a.Service.Games.Get(id)
+	if err != nil {
+		return err
+	}
+	entry.Lock()
+	defer entry.Unlock()
+
+	err = a.Service.Adjudicate(entry, winner)
+	if err == nil {
+		slog.Info("admin adjudicated game", "game", id, "winner", winner, "admin", by)
+	}
+
+	return err
+}
+
+// Kick ends every session of the player in the session with the given ID,
+// returning how many were ended. Players are kicked by their sessions, so
+// that admins never see the browser sessions anonymous players are seated
+// by.
+func (a *Admin) Kick(id int, by string) (int, error) {
+	session, ok := a.Sessions.Get(id)
+	if !ok {
+		return 0, ErrNoSession
+	}
+
+	kicked := a.Sessions.Kick(session.Seat)
+	slog.Info("admin kicked player", "player", session.Name, "sessions", kicked, "for", a.Sessions.KickFor, "admin", by)
+
+	return kicked, nil
+}
+
+// Load is how busy the bot is, and the limits it's searching under.
+type Load struct {
+	Workers int
+	Queued  int
+	Running int
+
+	MaxDepth      int
+	MaxSearchTime time.Duration
+}
+
+// Load is the bot's load now.
+func (a *Admin) Load() Load {
+	load := Load{Workers: a.Service.BotWorkers()}
+	load.Queued, load.Running = a.Service.BotSearches()
+	load.MaxDepth, load.MaxSearchTime = a.Service.BotLimits()
+
+	return load
+}
+
+// SetBotLimits changes the bot's limits from its next search, until the
+// server restarts.
+func (a *Admin) SetBotLimits(depth int, searchTime time.Duration, by string) error {
+	if depth < 0 || searchTime < 0 {
+		return ErrNegative
+	}
+
+	a.Service.SetBotLimits(depth, searchTime)
+	slog.Info("admin changed the bot's limits", "max_depth", depth, "max_search_time", searchTime, "admin", by)
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+func newAdmin(t *testing.T) (*Admin, accounts.Account) {
+	players, _ := accounts.Open("")
+	alice, err := players.Register("alice", "SHA256:alice")
+	if err != nil {
+		t.Fatalf("Expected no error registering, got %s", err)
+	}
+	players.Register("bob", "SHA256:bob")
+
+	games := service.New(store.New(time.Hour, 0))
+	games.Players = players
+
+	return New(games, players, NewSessions(time.Minute), []string{"SHA256:alice"}), alice
+}
+
+func TestAllowed(t *testing.T) {
+	a, alice := newAdmin(t)
+	bob, _ := a.Players.ByName("bob")
+
+	code, _ := a.Players.NewCode(alice.ID)
+	a.Players.Link(code, "alice-browser")
+
+	tests := []struct {
+		name    string
+		allowed bool
+	}{
+		{alice.Seat(), true},
+		{bob.Seat(), false},
+		{"some-session", false},
+	}
+
+	for _, test := range tests {
+		if allowed := a.AllowedSeat(test.name); allowed != test.allowed {
+			t.Errorf("%s: Expected allowed (%t) != actual allowed (%t)", test.name, test.allowed, allowed)
+		}
+	}
+
+	if !a.Allowed("SHA256:alice") || a.Allowed("SHA256:bob") || a.Allowed("") {
+		t.Errorf("Expected only alice's key to be allowed")
+	}
+	if account, ok := a.Players.ForSession("alice-browser"); !ok || !a.AllowedSeat(account.Seat()) {
+		t.Errorf("Expected alice's linked browser to be allowed")
+	}
+}
+
+func TestGames(t *testing.T) {
+	a, alice := newAdmin(t)
+
+	entry, _ := a.Service.Games.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{Active: "X", Started: true})
+	entry.Lock()
+	service.Host(entry, "X", alice.Seat(), nil)
+	a.Service.Join(entry, "browser")
+	a.Service.PlayTTT(entry, 4)
+	entry.Unlock()
+
+	games := a.Games()
+	if len(games) != 1 {
+		t.Fatalf("Expected one game, got %d", len(games))
+	}
+	game := games[0]
+	if game.Mode != service.ModeOnline || game.Players != "alice (X) vs Anonymous (O)" || game.Moves != 1 {
+		t.Errorf("Expected alice's online game with one move, got %+v", game)
+	}
+
+	if err := a.Adjudicate(entry.ID, "Y", "alice"); !errors.Is(err, service.ErrUnknownSide) {
+		t.Errorf("Expected error (%v) != actual error (%v)", service.ErrUnknownSide, err)
+	}
+	if err := a.Abort(entry.ID, "alice"); err != nil {
+		t.Errorf("Expected no error aborting, got %s", err)
+	}
+	if games := a.Games(); len(games) != 0 {
+		t.Errorf("Expected aborted games not to be listed, got %+v", games)
+	}
+}
+
+func TestKick(t *testing.T) {
+	a, alice := newAdmin(t)
+
+	kicked := 0
+	a.Sessions.Open(Session{Front: "ssh", Seat: alice.Seat()}, func() { kicked++ })
+	a.Sessions.Open(Session{Front: "browser", Seat: alice.Seat()}, func() { kicked++ })
+	closed := a.Sessions.Open(Session{Front: "browser", Seat: "some-session"}, func() { kicked++ })
+
+	sessions := a.Sessions.List()
+	if len(sessions) != 3 || sessions[0].Front != "ssh" {
+		t.Fatalf("Expected three sessions, oldest first, got %+v", sessions)
+	}
+	closed()
+
+	if _, err := a.Kick(sessions[2].ID, "bob"); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNoSession, err)
+	}
+	if n, _ := a.Kick(sessions[1].ID, "bob"); n != 2 || kicked != 2 {
+		t.Errorf("Expected both of alice's sessions to be kicked, got %d", n)
+	}
+	if sessions := a.Sessions.List(); len(sessions) != 0 {
+		t.Errorf("Expected no sessions left, got %+v", sessions)
+	}
+	if !a.Sessions.Kicked(alice.Seat()) || a.Sessions.Kicked("some-session") {
+		t.Errorf("Expected only alice to be refused")
+	}
+
+	a.Sessions.KickFor = 0
+	a.Sessions.Kick("some-session")
+	if a.Sessions.Kicked("some-session") {
+		t.Errorf("Expected players to be let back in without a ban")
+	}
+}
+
+func TestSetBotLimits(t *testing.T) {
+	a, _ := newAdmin(t)
+
+	if err := a.SetBotLimits(-1, time.Second, "alice"); !errors.Is(err, ErrNegative) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNegative, err)
+	}
+	if err := a.SetBotLimits(6, 2*time.Second, "alice"); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	load := a.Load()
+	if load.MaxDepth != 6 || load.MaxSearchTime != 2*time.Second || load.Workers < 1 {
+		t.Errorf("Expected the new limits, got %+v", load)
+	}
+}
@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrNoSession is returned for sessions that have closed, or never opened.
+var ErrNoSession = errors.New("no such session")
+
+// Session is a player connected to the server: a browser page with its event
+// stream open, or an SSH session.
+type Session struct {
+	ID int
+	// Front is browser or ssh.
+	Front string
+	Seat  string
+	Name  string
+	// Addr is the address the player connected from, without its port.
+	Addr string
+	// Game is the ID of the game a browser page is showing, if it's showing
+	// one.
+	Game   string
+	Opened time.Time
+}
+
+// Sessions keeps track of the sessions open on both front ends, so that
+// admins can see who's connected and kick them. A kicked player is refused
+// for KickFor afterwards, so that they can't simply reconnect.
+type Sessions struct {
+	KickFor time.Duration
+
+	mu     sync.Mutex
+	nextID int
+	open   map[int]*openSession
+	kicked map[string]time.Time
+}
+
+type openSession struct {
+	Session
+	kick func()
+}
+
+// NewSessions keeps track of sessions, refusing kicked players for kickFor,
+// or not at all if it's zero.
+func NewSessions(kickFor time.Duration) *Sessions {
+	return &Sessions{
+		KickFor: kickFor,
+		open:    make(map[int]*openSession),
+		kicked:  make(map[string]time.Time),
+	}
+}
+
+// Open adds a session, returning a function to call once it closes. kick is
+// called, at most once, if an admin kicks the session's player, and must end
+// the session without waiting for it to close.
+func (s *Sessions) Open(session Session, kick func()) (closed func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	session.ID = s.nextID
+	if host, _, err := net.SplitHostPort(session.Addr); err == nil {
+		session.Addr = host
+	}
+	if session.Opened.IsZero() {
+		session.Opened = time.Now()
+	}
+	s.open[session.ID] = &openSession{Session: session, kick: kick}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.open, session.ID)
+	}
+}
+
+// List is every open session, oldest first.
+func (s *Sessions) List() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]Session, 0, len(s.open))
+	for _, open := range s.open {
+		sessions = append(sessions, open.Session)
+	}
+	slices.SortFunc(sessions, func(a, b Session) int { return a.ID - b.ID })
+
+	return sessions
+}
+
+// Get is an open session.
+func (s *Sessions) Get(id int) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open, ok := s.open[id]
+	if !ok {
+		return Session{}, false
+	}
+
+	return open.Session, true
+}
+
+// Kick ends every session of the player in a seat and refuses them for
+// KickFor, returning how many sessions were ended.
+func (s *Sessions) Kick(seat string) int {
+	s.mu.Lock()
+	kicks := []func(){}
+	for id, open := range s.open {
+		if open.Seat == seat {
+			kicks = append(kicks, open.kick)
+			delete(s.open, id)
+		}
+	}
+	if s.KickFor > 0 {
+		s.kicked[seat] = time.Now().Add(s.KickFor)
+	}
+	s.mu.Unlock()
+
+	for _, kick := range kicks {
+		kick()
+	}
+
+	return len(kicks)
+}
+
+// Kicked reports whether the player in a seat was kicked too recently to be
+// let back in.
+func (s *Sessions) Kicked(seat string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.kicked[seat]
+	if ok && time.Now().After(until) {
+		delete(s.kicked, seat)
+		return false
+	}
+
+	return ok
+}
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+	"github.com/jfosburgh/gomes/internal/routes/archive"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+// newTestBrowser serves the browser's pages as the server does, with alice
+// as an admin and bob as a player, each signed in to a browser session named
+// after them.
+func newTestBrowser(t *testing.T) (http.Handler, *admin.Admin, accounts.Account) {
+	players, _ := accounts.Open("")
+	finished, _ := archive.Open("")
+	games := service.New(store.New(time.Hour, 0))
+	games.Players = players
+	console := admin.New(games, players, admin.NewSessions(time.Minute), []string{"SHA256:alice"})
+
+	alice, err := players.Register("alice", "SHA256:alice")
+	if err != nil {
+		t.Fatalf("Expected no error registering, got %s", err)
+	}
+	bob, _ := players.Register("bob", "SHA256:bob")
+	for session, account := range map[string]accounts.Account{"alice-browser": alice, "bob-browser": bob} {
+		code, _ := players.NewCode(account.ID)
+		if _, err := players.Link(code, session); err != nil {
+			t.Fatalf("Expected no error linking %s, got %s", session, err)
+		}
+	}
+
+	browser := newBrowserRouter("templates", games, lobby.New(games), players, finished, nil, console)
+	return refuseKicked(console.Sessions, players, browser), console, alice
+}
+
+func TestAdminOnly(t *testing.T) {
+	browser, console, _ := newTestBrowser(t)
+
+	routes := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/admin", ""},
+		{http.MethodPost, "/admin/games/nope/abort", ""},
+		{http.MethodPost, "/admin/games/nope/adjudicate", "winner=X"},
+		{http.MethodPost, "/admin/sessions/1/kick", ""},
+		{http.MethodPost, "/admin/settings", "max_depth=1&max_search_time=1s"},
+	}
+
+	for _, session := range []string{"", "some-session", "bob-browser"} {
+		for _, route := range routes {
+			r := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if session != "" {
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+			}
+			w := httptest.NewRecorder()
+			browser.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s %s as %q: Expected status (%d) != actual status (%d)", route.method, route.path, session, http.StatusNotFound, w.Code)
+			}
+		}
+	}
+	if depth, _ := console.Service.BotLimits(); depth != 0 {
+		t.Errorf("Expected the bot's limits to be left alone, got a max depth of %d", depth)
+	}
+
+	// alice's key is an admin's, so the browser she's linked gets in
+	w := request(browser, http.MethodGet, "/admin", "alice-browser", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<h2>Admin</h2>") {
+		t.Errorf("Expected the admin page, got %d", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader("max_depth=6&max_search_time=2s"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "alice-browser"})
+	w = httptest.NewRecorder()
+	browser.ServeHTTP(w, r)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("Expected status (%d) != actual status (%d)", http.StatusSeeOther, w.Code)
+	}
+	if depth, searchTime := console.Service.BotLimits(); depth != 6 || searchTime != 2*time.Second {
+		t.Errorf("Expected the bot's new limits, got %d and %s", depth, searchTime)
+	}
+}
+
+func TestRefuseKicked(t *testing.T) {
+	browser, console, alice := newTestBrowser(t)
+
+	console.Sessions.Open(admin.Session{Front: "ssh", Seat: alice.Seat()}, func() {})
+	console.Sessions.Open(admin.Session{Front: "browser", Seat: "some-session"}, func() {})
+	console.Sessions.Kick(alice.Seat())
+	console.Sessions.Kick("some-session")
+
+	tests := []struct {
+		session string
+		status  int
+	}{
+		// alice is refused in the browser she's linked, though she was
+		// kicked over ssh
+		{"alice-browser", http.StatusForbidden},
+		{"some-session", http.StatusForbidden},
+		{"bob-browser", http.StatusOK},
+		{"", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := request(browser, http.MethodGet, "/leaderboard", test.session, "")
+		if w.Code != test.status {
+			t.Errorf("%q: Expected status (%d) != actual status (%d)", test.session, test.status, w.Code)
+		}
+		if test.status == http.StatusForbidden && !strings.Contains(w.Body.String(), refusedNotice) {
+			t.Errorf("%q: Expected the refused notice, got %s", test.session, w.Body)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package routes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/limits"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+//go:embed openapi.yaml
+var openAPI []byte
+
+// apiGame is a game as the JSON API describes it, to the player making the
+// request. Lists of games leave out the legal moves and history.
+type apiGame struct {
+	ID          string            `json:"id"`
+	Game        string            `json:"game"`
+	Mode        string            `json:"mode"`
+	FEN         string            `json:"fen,omitempty"`
+	Board       string            `json:"board,omitempty"`
+	Colours     []string          `json:"colours"`
+	Colour      string            `json:"colour,omitempty"`
+	Players     map[string]string `json:"players,omitempty"`
+	Active      string            `json:"active"`
+	Started     bool              `json:"started"`
+	Waiting     bool              `json:"waiting"`
+	Ended       bool              `json:"ended"`
+	Rated       bool              `json:"rated"`
+	Status      string            `json:"status"`
+	Result      string            `json:"result,omitempty"`
+	Termination string            `json:"termination,omitempty"`
+	Queued      int               `json:"queued,omitempty"`
+	Clock       *apiClock         `json:"clock,omitempty"`
+	LegalMoves  []service.Move    `json:"legal_moves,omitempty"`
+	History     []service.Move    `json:"history,omitempty"`
+	Created     time.Time         `json:"created"`
+}
+
+// apiClock is a game clock, with the time each side has left in
+// milliseconds.
+type apiClock struct {
+	Initial   int64            `json:"initial"`
+	Increment int64            `json:"increment"`
+	Remaining map[string]int64 `json:"remaining"`
+	Running   string           `json:"running,omitempty"`
+}
+
+type apiMoves struct {
+	History    []service.Move `json:"history"`
+	LegalMoves []service.Move `json:"legal_moves"`
+}
+
+type apiNewGame struct {
+	Game        string `json:"game"`
+	Mode        string `json:"mode"`
+	Colour      string `json:"colour"`
+	Depth       int    `json:"depth"`
+	SearchTime  int    `json:"search_time"`
+	TimeControl string `json:"time_control"`
+	Opponent    string `json:"opponent"`
+}
+
+type apiMove struct {
+	Move string `json:"move"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// newAPIGame describes a game to the player in a seat, with its moves when
+// full is set. The entry must be locked.
+func newAPIGame(entry *store.Entry, seat string, full bool) apiGame {
+	view := service.View(entry, seat)
+	data := entry.Data
+
+	game := apiGame{
+		ID:      entry.ID,
+		Game:    service.GameName(entry.Game),
+		Mode:    service.ModeLocal,
+		Colours: service.SeatNames(entry.Game),
+		Active:  data.Active,
+		Started: data.Started,
+		Waiting: data.Waiting(),
+		Ended:   data.Ended,
+		Rated:   data.Rated,
+		Status:  data.Status,
+		Result:  store.Result(entry.Game, data),
+		Queued:  view.Queued,
+		Created: entry.Created,
+	}
+
+	switch {
+	case data.Online:
+		game.Mode = service.ModeOnline
+		game.Players = data.Names
+	case data.Player != "":
+		game.Mode = service.ModeBot
+	}
+	if slices.Contains(game.Colours, view.Player) {
+		game.Colour = view.Player
+	}
+
+	switch g := entry.Game.(type) {
+	case *chess.ChessGame:
+		game.FEN = g.EBE.ToFEN()
+	case *tictactoe.TicTacToeGame:
+		game.Board = g.ToGameString()
+	}
+
+	if data.Ended {
+		game.Termination = store.Termination(entry.Game, data)
+	}
+
+	if clock := data.Clock; clock != nil {
+		now := time.Now()
+		game.Clock = &apiClock{
+			Initial:   clock.Initial.Milliseconds(),
+			Increment: clock.Increment.Milliseconds(),
+			Remaining: make(map[string]int64),
+		}
+		for side, name := range clock.Sides {
+			game.Clock.Remaining[name] = clock.Left(side, now).Milliseconds()
+		}
+		if clock.Running != -1 {
+			game.Clock.Running = clock.Sides[clock.Running]
+		}
+	}
+
+	if full {
+		game.LegalMoves = service.LegalMoves(entry)
+		game.History = service.History(entry)
+	}
+
+	return game
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("error marshalling JSON", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func respondWithJSONError(w http.ResponseWriter, status int, err error) {
+	respondWithJSON(w, status, apiError{err.Error()})
+}
+
+// respondWithDecodeError explains why a request's parameters couldn't be
+// read, which is usually that they weren't JSON, but may be that there were
+// too many of them.
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	tooLarge := &http.MaxBytesError{}
+	if errors.As(err, &tooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	respondWithJSONError(w, status, fmt.Errorf("couldn't decode parameters: %w", err))
+}
+
+// respondWithAPIGameError is respondWithGameError for the JSON API.
+func respondWithAPIGameError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, store.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	respondWithJSONError(w, status, err)
+}
+
+func (cfg *configdata) handleAPIListGames(w http.ResponseWriter, r *http.Request) {
+	seat := cfg.seat(w, r)
+	name := r.URL.Query().Get("game")
+	status := r.URL.Query().Get("status")
+
+	// other players' games are only found by their invite links, as in the
+	// browser, so each player sees just their own
+	games := []apiGame{}
+	for _, entry := range cfg.Games.Entries() {
+		entry.Lock()
+		if !entry.Data.Involves(seat) {
+			entry.Unlock()
+			continue
+		}
+		game := newAPIGame(entry, seat, false)
+		entry.Unlock()
+		if name != "" && game.Game != name {
+			continue
+		}
+		switch status {
+		case "waiting":
+			if !game.Waiting {
+				continue
+			}
+		case "playing":
+			if !game.Started || game.Waiting || game.Ended {
+				continue
+			}
+		case "ended":
+			if !game.Ended {
+				continue
+			}
+		}
+
+		games = append(games, game)
+	}
+
+	slices.SortFunc(games, func(a, b apiGame) int { return b.Created.Compare(a.Created) })
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Games []apiGame `json:"games"`
+	}{games})
+}
+
+func (cfg *configdata) handleAPICreateGame(w http.ResponseWriter, r *http.Request) {
+	params := apiNewGame{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	game, err := service.NewGame(params.Game)
+	if err != nil {
+		respondWithJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	settings := service.Settings{
+		Mode:       params.Mode,
+		Colour:     params.Colour,
+		Depth:      params.Depth,
+		SearchTime: time.Duration(params.SearchTime) * time.Second,
+	}
+	if settings.Mode == "" {
+		settings.Mode = service.ModeLocal
+	}
+	if initial, increment, timed := utils.ParseTimeControl(params.TimeControl); timed {
+		sides := service.SeatNames(game)
+		settings.Clock = utils.NewClock([2]string{sides[0], sides[1]}, initial, increment)
+	}
+
+	seat := cfg.seat(w, r)
+	if params.Opponent != "" {
+		opponent, ok := cfg.Accounts.ByName(params.Opponent)
+		switch {
+		case settings.Mode != service.ModeOnline:
+			respondWithJSONError(w, http.StatusBadRequest, errors.New("only online games can be offered to an opponent"))
+			return
+		case !ok:
+			respondWithJSONError(w, http.StatusBadRequest, fmt.Errorf("no player is called %q", params.Opponent))
+			return
+		case opponent.Seat() == seat:
+			respondWithJSONError(w, http.StatusBadRequest, errors.New("you can't be your own opponent"))
+			return
+		}
+		settings.Invitee = opponent.Seat()
+	}
+
+	if limited := cfg.limitGame(r, seat, settings.Mode == service.ModeBot); limited != nil {
+		respondWithAPILimit(w, limited)
+		return
+	}
+
+	entry, err := cfg.Games.Add(game, &utils.TwoPlayerGame{Active: service.SeatNames(game)[0]})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrFull) || errors.Is(err, store.ErrClosed) {
+			status = http.StatusServiceUnavailable
+		}
+		respondWithJSONError(w, status, err)
+		return
+	}
+	entry.Lock()
+	defer entry.Unlock()
+
+	err = cfg.Service.Start(entry, seat, settings)
+	if err != nil {
+		cfg.Games.Remove(entry.ID)
+		respondWithJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/games/"+entry.ID)
+	respondWithJSON(w, http.StatusCreated, newAPIGame(entry, seat, true))
+}
+
+func (cfg *configdata) handleAPIGetGame(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		respondWithAPIGameError(w, err)
+		return
+	}
+	defer entry.Unlock()
+
+	respondWithJSON(w, http.StatusOK, newAPIGame(entry, cfg.seat(w, r), true))
+}
+
+func (cfg *configdata) handleAPIJoinGame(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		respondWithAPIGameError(w, err)
+		return
+	}
+	defer entry.Unlock()
+
+	seat := cfg.seat(w, r)
+	_, err = cfg.Service.Join(entry, seat)
+	if err != nil {
+		status := http.StatusConflict
+		switch {
+		case errors.Is(err, service.ErrNotOnline):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrNotInvited):
+			status = http.StatusForbidden
+		}
+		respondWithJSONError(w, status, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAPIGame(entry, seat, true))
+}
+
+func (cfg *configdata) handleAPIGetMoves(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		respondWithAPIGameError(w, err)
+		return
+	}
+	defer entry.Unlock()
+
+	respondWithJSON(w, http.StatusOK, apiMoves{
+		History:    service.History(entry),
+		LegalMoves: service.LegalMoves(entry),
+	})
+}
+
+func (cfg *configdata) handleAPIPlayMove(w http.ResponseWriter, r *http.Request) {
+	params := apiMove{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		respondWithAPIGameError(w, err)
+		return
+	}
+	defer entry.Unlock()
+
+	seat := cfg.seat(w, r)
+	if limited := cfg.limitMove(r, seat, entry); limited != nil {
+		respondWithAPILimit(w, limited)
+		return
+	}
+	err = cfg.Service.Play(entry, seat, params.Move)
+	if err != nil {
+		respondWithJSONError(w, playErrorStatus(err), err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAPIGame(entry, seat, true))
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPI)
+}
+
+// newAPIRouter serves the versioned JSON API, for scripts and bots. Players
+// are identified by the same session cookie as in the browser, and bot
+// accounts by their token.
+func newAPIRouter(games *service.Service, seeks *lobby.Lobby, players *accounts.Accounts, limit *limits.Limits, bots bool) *http.ServeMux {
+	config := &configdata{
+		Games:    games.Games,
+		Service:  games,
+		Lobby:    seeks,
+		Accounts: players,
+		Limits:   limit,
+	}
+
+	apiRouter := http.NewServeMux()
+	apiRouter.HandleFunc("GET /api/v1/openapi.yaml", handleOpenAPI)
+	apiRouter.HandleFunc("GET /api/v1/games", config.handleAPIListGames)
+	apiRouter.HandleFunc("POST /api/v1/games", config.handleAPICreateGame)
+	apiRouter.HandleFunc("GET /api/v1/games/{id}", config.handleAPIGetGame)
+	apiRouter.HandleFunc("POST /api/v1/games/{id}/join", config.handleAPIJoinGame)
+	apiRouter.HandleFunc("GET /api/v1/games/{id}/moves", config.handleAPIGetMoves)
+	apiRouter.HandleFunc("POST /api/v1/games/{id}/moves", config.handleAPIPlayMove)
+	if bots {
+		apiRouter.HandleFunc("GET /api/v1/bot/account", config.withBot(config.handleBotAccount))
+		apiRouter.HandleFunc("GET /api/v1/bot/stream/event", config.withBot(config.handleBotEvents))
+		apiRouter.HandleFunc("GET /api/v1/bot/game/stream/{id}", config.withBot(config.handleBotGameStream))
+		apiRouter.HandleFunc("POST /api/v1/bot/game/{id}/move/{move}", config.withBot(config.handleBotMove))
+		apiRouter.HandleFunc("POST /api/v1/bot/challenge/{id}/accept", config.withBot(config.handleBotAccept))
+		apiRouter.HandleFunc("POST /api/v1/bot/challenge/{id}/decline", config.withBot(config.handleBotDecline))
+	}
+	apiRouter.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSONError(w, http.StatusNotFound, errors.New("no such endpoint"))
+	})
+
+	return apiRouter
+}
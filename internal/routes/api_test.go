@@ -0,0 +1,258 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/limits"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+// newTestAPI serves the JSON API, with the bot API, over in-memory games and
+// accounts.
+func newTestAPI(t *testing.T, limit *limits.Limits) (http.Handler, *service.Service, *accounts.Accounts) {
+	players, err := accounts.Open("")
+	if err != nil {
+		t.Fatalf("Expected no error opening accounts, got %s", err)
+	}
+	games := service.New(store.New(time.Hour, 0))
+	games.Players = players
+
+	return newAPIRouter(games, lobby.New(games), players, limit, true), games, players
+}
+
+// request makes a request as the browser with the given session, returning
+// the recorded response.
+func request(handler http.Handler, method, path, session, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if session != "" {
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func decodeGame(t *testing.T, w *httptest.ResponseRecorder) apiGame {
+	game := apiGame{}
+	if err := json.NewDecoder(w.Body).Decode(&game); err != nil {
+		t.Fatalf("Expected a game, got error %s", err)
+	}
+
+	return game
+}
+
+func TestAPIGame(t *testing.T) {
+	api, _, _ := newTestAPI(t, nil)
+
+	w := request(api, http.MethodPost, "/api/v1/games", "alice", `{"game": "chess", "mode": "local"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status (%d) != actual status (%d): %s", http.StatusCreated, w.Code, w.Body)
+	}
+	created := decodeGame(t, w)
+	if location := w.Header().Get("Location"); location != "/api/v1/games/"+created.ID {
+		t.Errorf("Expected location (%s) != actual location (%s)", "/api/v1/games/"+created.ID, location)
+	}
+
+	w = request(api, http.MethodGet, "/api/v1/games/"+created.ID, "alice", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status (%d) != actual status (%d)", http.StatusOK, w.Code)
+	}
+	if game := decodeGame(t, w); len(game.LegalMoves) != 20 || !game.Started || game.Mode != service.ModeLocal {
+		t.Errorf("Expected a started local game with 20 legal moves, got %+v", game)
+	}
+
+	tests := []struct {
+		name   string
+		move   string
+		status int
+		fen    string
+	}{
+		{"UCI", "e2e4", http.StatusOK, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"},
+		{"SAN", "e5", http.StatusOK, "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"},
+		{"illegal", "e4e5", http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		w := request(api, http.MethodPost, "/api/v1/games/"+created.ID+"/moves", "alice", `{"move": "`+test.move+`"}`)
+		if w.Code != test.status {
+			t.Errorf("%s: Expected status (%d) != actual status (%d): %s", test.name, test.status, w.Code, w.Body)
+			continue
+		}
+		if test.fen == "" {
+			continue
+		}
+		if game := decodeGame(t, w); game.FEN != test.fen {
+			t.Errorf("%s: Expected FEN (%s) != actual FEN (%s)", test.name, test.fen, game.FEN)
+		}
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	api, _, _ := newTestAPI(t, nil)
+
+	w := request(api, http.MethodPost, "/api/v1/games", "alice", `{"game": "chess", "mode": "online"}`)
+	online := decodeGame(t, w)
+	w = request(api, http.MethodPost, "/api/v1/games", "alice", `{"game": "tictactoe", "mode": "local"}`)
+	local := decodeGame(t, w)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		session string
+		body    string
+		status  int
+	}{
+		{"unknown game", http.MethodGet, "/api/v1/games/nope", "alice", "", http.StatusNotFound},
+		{"move in unknown game", http.MethodPost, "/api/v1/games/nope/moves", "alice", `{"move": "e2e4"}`, http.StatusNotFound},
+		{"unknown endpoint", http.MethodGet, "/api/v1/nope", "alice", "", http.StatusNotFound},
+		{"bad JSON", http.MethodPost, "/api/v1/games", "alice", `{"game": `, http.StatusBadRequest},
+		{"unknown kind of game", http.MethodPost, "/api/v1/games", "alice", `{"game": "go"}`, http.StatusBadRequest},
+		{"second player joins", http.MethodPost, "/api/v1/games/" + online.ID + "/join", "bob", "", http.StatusOK},
+		{"both seats taken", http.MethodPost, "/api/v1/games/" + online.ID + "/join", "carol", "", http.StatusConflict},
+		{"join a local game", http.MethodPost, "/api/v1/games/" + local.ID + "/join", "bob", "", http.StatusBadRequest},
+		{"move in someone else's game", http.MethodPost, "/api/v1/games/" + local.ID + "/moves", "bob", `{"move": "4"}`, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		w := request(api, test.method, test.path, test.session, test.body)
+		if w.Code != test.status {
+			t.Errorf("%s: Expected status (%d) != actual status (%d): %s", test.name, test.status, w.Code, w.Body)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("%s: Expected content type (%s) != actual content type (%s)", test.name, "application/json", contentType)
+		}
+	}
+}
+
+func TestAPIBodyLimit(t *testing.T) {
+	api, _, _ := newTestAPI(t, nil)
+	limited := limitBody(api, 64)
+
+	body := `{"game": "chess", "mode": "local", "colour": "` + strings.Repeat("x", 64) + `"}`
+	w := request(limited, http.MethodPost, "/api/v1/games", "alice", body)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status (%d) != actual status (%d): %s", http.StatusRequestEntityTooLarge, w.Code, w.Body)
+	}
+
+	w = request(limited, http.MethodPost, "/api/v1/games", "alice", `{"game": "chess"}`)
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status (%d) != actual status (%d): %s", http.StatusCreated, w.Code, w.Body)
+	}
+}
+
+func TestAPILimits(t *testing.T) {
+	api, _, _ := newTestAPI(t, &limits.Limits{
+		Games: limits.NewLimiter("starting games", 1, time.Minute),
+		Moves: limits.NewLimiter("moving", 1, time.Minute),
+	})
+
+	w := request(api, http.MethodPost, "/api/v1/games", "alice", `{"game": "tictactoe"}`)
+	game := decodeGame(t, w)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"second game", "/api/v1/games", `{"game": "tictactoe"}`, http.StatusTooManyRequests},
+		{"first move", "/api/v1/games/" + game.ID + "/moves", `{"move": "4"}`, http.StatusOK},
+		{"second move", "/api/v1/games/" + game.ID + "/moves", `{"move": "0"}`, http.StatusTooManyRequests},
+	}
+
+	for _, test := range tests {
+		w := request(api, http.MethodPost, test.path, "alice", test.body)
+		if w.Code != test.status {
+			t.Errorf("%s: Expected status (%d) != actual status (%d): %s", test.name, test.status, w.Code, w.Body)
+		}
+		if test.status == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: Expected a Retry-After header", test.name)
+		}
+	}
+
+	// another client, from another address, isn't held up by alice's limits
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/games", strings.NewReader(`{"game": "tictactoe"}`))
+	r.RemoteAddr = "198.51.100.1:1234"
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status (%d) != actual status (%d): %s", http.StatusCreated, w.Code, w.Body)
+	}
+}
+
+func TestAPIInvite(t *testing.T) {
+	api, _, players := newTestAPI(t, nil)
+	bob, _ := players.Register("bob", "SHA256:bob")
+	code, _ := players.NewCode(bob.ID)
+	players.Link(code, "bob-browser")
+
+	w := request(api, http.MethodPost, "/api/v1/games", "alice", `{"game": "chess", "mode": "online", "opponent": "bob"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status (%d) != actual status (%d): %s", http.StatusCreated, w.Code, w.Body)
+	}
+	invite := decodeGame(t, w)
+	w = request(api, http.MethodPost, "/api/v1/games", "carol", `{"game": "tictactoe", "mode": "online"}`)
+	open := decodeGame(t, w)
+
+	// each player lists only the games they started, play in or are
+	// invited to, though carol's is waiting for anyone with its link
+	lists := []struct {
+		session string
+		games   []string
+	}{
+		{"alice", []string{invite.ID}},
+		{"bob-browser", []string{invite.ID}},
+		{"carol", []string{open.ID}},
+		{"dave", []string{}},
+	}
+
+	for _, list := range lists {
+		w := request(api, http.MethodGet, "/api/v1/games", list.session, "")
+		listed := struct {
+			Games []apiGame `json:"games"`
+		}{}
+		if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+			t.Fatalf("%s: Expected a list of games, got error %s", list.session, err)
+		}
+		ids := []string{}
+		for _, game := range listed.Games {
+			ids = append(ids, game.ID)
+		}
+		if !slices.Equal(ids, list.games) {
+			t.Errorf("%s: Expected games (%v) != actual games (%v)", list.session, list.games, ids)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		session string
+		body    string
+		status  int
+	}{
+		{"unknown opponent", "/api/v1/games", "alice", `{"game": "chess", "mode": "online", "opponent": "nope"}`, http.StatusBadRequest},
+		{"opponent in a local game", "/api/v1/games", "alice", `{"game": "chess", "opponent": "bob"}`, http.StatusBadRequest},
+		{"own opponent", "/api/v1/games", "bob-browser", `{"game": "chess", "mode": "online", "opponent": "bob"}`, http.StatusBadRequest},
+		{"someone else joins", "/api/v1/games/" + invite.ID + "/join", "carol", "", http.StatusForbidden},
+		{"invitee joins", "/api/v1/games/" + invite.ID + "/join", "bob-browser", "", http.StatusOK},
+		{"anyone joins an open game", "/api/v1/games/" + open.ID + "/join", "dave", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := request(api, http.MethodPost, test.path, test.session, test.body)
+		if w.Code != test.status {
+			t.Errorf("%s: Expected status (%d) != actual status (%d): %s", test.name, test.status, w.Code, w.Body)
+		}
+	}
+}
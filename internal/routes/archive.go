@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jfosburgh/gomes/internal/routes/archive"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+// archiveSize is how many games the archive page lists.
+const archiveSize = 50
+
+type archiveView struct {
+	Player string
+	Games  []archivedView
+}
+
+type archivedView struct {
+	archive.Game
+	Title string
+}
+
+// handleArchive lists the most recently finished games, or only those of one
+// player.
+func (cfg *configdata) handleArchive(w http.ResponseWriter, r *http.Request) {
+	player := r.URL.Query().Get("player")
+
+	var match func(archive.Game) bool
+	if player != "" {
+		match = func(g archive.Game) bool { return g.Named(player) }
+	}
+
+	view := archiveView{Player: player}
+	for _, game := range cfg.Archive.Recent(archiveSize, match) {
+		view.Games = append(view.Games, archivedView{game, lobby.Titles[game.Game]})
+	}
+
+	err := cfg.Pages["archive"].ExecuteTemplate(w, "base.html", view)
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", "archive", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// replayView is an archived game part of the way through, with its board
+// after Ply of its Plies moves. Prev and Next are the plies either side, and
+// PGN links to the game's PGN when it's chess.
+type replayView struct {
+	archive.Game
+	Title  string
+	Ply    int
+	Plies  int
+	Prev   int
+	Next   int
+	Board  string
+	PGN    string
+	Cells  []utils.Cell
+	Moves  []moveView
+	Status string
+}
+
+type moveView struct {
+	Ply     int
+	Number  string
+	Text    string
+	Current bool
+}
+
+func newReplayView(game archive.Game, ply int) (replayView, error) {
+	frame, err := game.Replay(ply)
+	if err != nil {
+		return replayView{}, err
+	}
+
+	view := replayView{
+		Game:   game,
+		Title:  lobby.Titles[game.Game],
+		Ply:    frame.Ply,
+		Plies:  len(game.Moves),
+		Status: game.Progress(frame.Ply),
+	}
+	view.Prev = max(view.Ply-1, 0)
+	view.Next = min(view.Ply+1, view.Plies)
+
+	// a finished game, so nothing on the board can be clicked
+	data := &utils.TwoPlayerGame{Started: true, Ended: true}
+	switch board := frame.Game.(type) {
+	case *chess.ChessGame:
+		view.Board = "chess-game-board"
+		view.PGN = fmt.Sprintf("/archive/%s/pgn", game.ID)
+		view.Cells = utils.FillChessCells(board, data, -1, false)
+		for _, square := range frame.Last {
+			view.Cells[utils.FlipRank(square)].Classes += " last-move"
+		}
+	case *tictactoe.TicTacToeGame:
+		view.Board = "ttt-game-board"
+		view.Cells = utils.FillTTTCells(board, data)
+		for _, cell := range frame.Last {
+			view.Cells[cell].Classes += " last-move"
+		}
+	}
+
+	for i, text := range game.Notation() {
+		move := moveView{Ply: i + 1, Text: text, Current: i+1 == view.Ply}
+		if i%2 == 0 {
+			move.Number = fmt.Sprintf("%d.", i/2+1)
+		}
+		view.Moves = append(view.Moves, move)
+	}
+
+	return view, nil
+}
+
+// handleReplay shows an archived game at a move, the last one unless ply
+// asks for another. Stepping through the game from the page only swaps in
+// the replay itself.
+func (cfg *configdata) handleReplay(w http.ResponseWriter, r *http.Request) {
+	game, ok := cfg.Archive.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "game not found in the archive", http.StatusNotFound)
+		return
+	}
+
+	ply, err := strconv.Atoi(r.URL.Query().Get("ply"))
+	if err != nil {
+		ply = len(game.Moves)
+	}
+
+	view, err := newReplayView(game, ply)
+	if err != nil {
+		requestLog(r).Error("error replaying game", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	name := "base.html"
+	if r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-History-Restore-Request") != "true" {
+		name = "replay"
+	}
+
+	err = cfg.Pages["replay"].ExecuteTemplate(w, name, view)
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", "replay", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// handleArchivePGN downloads an archived chess game as PGN.
+func (cfg *configdata) handleArchivePGN(w http.ResponseWriter, r *http.Request) {
+	game, ok := cfg.Archive.Get(r.PathValue("id"))
+	if !ok || game.Game != "chess" {
+		http.Error(w, "chess game not found in the archive", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"gomes-%s.pgn\"", game.ID))
+	fmt.Fprint(w, game.PGN())
+}
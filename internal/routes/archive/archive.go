@@ -0,0 +1,252 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+// The ways a game can be played.
+const (
+	ModeOnline = "Online"
+	ModeBot    = "Bot"
+	ModeLocal  = "Local"
+)
+
+// Archive keeps every finished game, from either front end, so it can be
+// replayed after it's gone from the store. Games are appended to a JSON-lines
+// file as they finish and read back when the archive is opened.
+type Archive struct {
+	// Players names the players in each seat, and is nil when everyone is
+	// anonymous
+	Players service.Players
+
+	mu    sync.Mutex
+	file  *os.File
+	games []Game
+	index map[string]int
+}
+
+// Open loads the games archived at path and appends new ones to it. An empty
+// path keeps the archive in memory only.
+func Open(path string) (*Archive, error) {
+	a := &Archive{index: make(map[string]int)}
+	if path == "" {
+		return a, nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Archive) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		game := Game{}
+		err := json.Unmarshal(scanner.Bytes(), &game)
+		if err != nil {
+			// a crash can leave the last line half written
+			slog.Warn("skipping unreadable line", "path", path, "line", lineNumber, "err", err)
+			continue
+		}
+		a.add(game)
+	}
+
+	return scanner.Err()
+}
+
+// add keeps a game, replacing any earlier copy of it. The archive must be
+// locked.
+func (a *Archive) add(game Game) {
+	if i, ok := a.index[game.ID]; ok {
+		a.games[i] = game
+		return
+	}
+
+	a.index[game.ID] = len(a.games)
+	a.games = append(a.games, game)
+}
+
+// Close flushes the archive to disk and closes it. Games finished afterwards
+// are only kept in memory.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.file.Sync()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	a.file = nil
+
+	return err
+}
+
+// Finished archives a finished game. Games are only archived once, however
+// many times they're reported.
+func (a *Archive) Finished(entry *store.Entry) {
+	game, err := a.newGame(entry)
+	if err != nil {
+		slog.Error("can't archive game", "game", entry.ID, "err", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.index[game.ID]; ok {
+		return
+	}
+	a.add(game)
+
+	if a.file == nil {
+		return
+	}
+
+	line, _ := json.Marshal(game)
+	_, err = a.file.Write(append(line, '\n'))
+	if err != nil {
+		slog.Error("error archiving game", "game", game.ID, "err", err)
+	}
+}
+
+// newGame describes a stored game for the archive. The entry must be locked.
+func (a *Archive) newGame(entry *store.Entry) (Game, error) {
+	data := entry.Data
+	game := Game{
+		ID:          entry.ID,
+		Game:        service.GameName(entry.Game),
+		Start:       entry.Start(),
+		Colours:     service.SeatNames(entry.Game),
+		Players:     make(map[string]string),
+		Seats:       make(map[string]string),
+		Mode:        ModeLocal,
+		Rated:       data.Rated,
+		Result:      store.Result(entry.Game, data),
+		Termination: store.Termination(entry.Game, data),
+		Started:     entry.Created,
+		Ended:       time.Now(),
+	}
+
+	switch g := entry.Game.(type) {
+	case *chess.ChessGame:
+		for _, move := range g.Moves {
+			game.Moves = append(game.Moves, move.String())
+		}
+	case *tictactoe.TicTacToeGame:
+		for _, move := range g.Moves {
+			game.Moves = append(game.Moves, strconv.Itoa(move))
+		}
+	default:
+		return game, fmt.Errorf("can't archive game of type %T", entry.Game)
+	}
+
+	if clock := data.Clock; clock != nil {
+		game.TimeControl = fmt.Sprintf("%d+%d", int(clock.Initial.Minutes()), int(clock.Increment.Seconds()))
+	}
+
+	switch {
+	case data.Online:
+		game.Mode = ModeOnline
+	case data.Player != "":
+		game.Mode = ModeBot
+	}
+
+	for _, colour := range game.Colours {
+		seat := data.Owner
+		switch {
+		case data.Online:
+			seat = data.Seats[colour]
+		case data.Player != "" && colour != data.Player:
+			game.Players[colour] = service.BotName(entry)
+			continue
+		}
+
+		game.Seats[colour] = seat
+		game.Players[colour] = a.name(seat)
+		if name, ok := data.Names[colour]; ok && data.Online {
+			game.Players[colour] = name
+		}
+	}
+
+	return game, nil
+}
+
+func (a *Archive) name(seat string) string {
+	if a.Players == nil {
+		return "Anonymous"
+	}
+
+	return a.Players.Name(seat)
+}
+
+// Get returns an archived game by its ID.
+func (a *Archive) Get(id string) (Game, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i, ok := a.index[id]
+	if !ok {
+		return Game{}, false
+	}
+
+	return a.games[i].clone(), true
+}
+
+// Recent returns up to limit archived games that match, newest first. A nil
+// match matches every game, and a limit of zero returns all of them.
+func (a *Archive) Recent(limit int, match func(Game) bool) []Game {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	games := []Game{}
+	for i := len(a.games) - 1; i >= 0 && (limit <= 0 || len(games) < limit); i-- {
+		if match == nil || match(a.games[i]) {
+			games = append(games, a.games[i].clone())
+		}
+	}
+
+	return games
+}
@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+func newService(t *testing.T, path string) (*service.Service, *Archive) {
+	a, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening the archive, got %s", err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	s := service.New(store.New(time.Hour, 0))
+	s.Archive = a
+
+	return s, a
+}
+
+func playChess(t *testing.T, s *service.Service, entry *store.Entry, moves ...string) {
+	game := entry.Game.(*chess.ChessGame)
+	for _, played := range moves {
+		move, err := chessMove(game, played)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.PlayChess(entry, move)
+	}
+}
+
+func TestFinished(t *testing.T) {
+	s, a := newService(t, "")
+
+	entry, _ := s.Pair("chess", [2]string{"a", "b"}, utils.NewClock([2]string{"White", "Black"}, 5*time.Minute, 3*time.Second), true)
+	entry.Lock()
+	playChess(t, s, entry, "f2f3", "e7e5", "g2g4", "d8h4")
+	entry.Unlock()
+
+	game, ok := a.Get(entry.ID)
+	if !ok {
+		t.Fatalf("Expected the finished game to be archived")
+	}
+	if game.Result != "0-1" || game.Termination != "Checkmate" || game.Outcome() != "Black won by Checkmate" {
+		t.Errorf("Expected a win for black by checkmate, got %s, %s", game.Result, game.Termination)
+	}
+	if game.Mode != ModeOnline || !game.Rated || game.TimeControl != "5+3" || len(game.Moves) != 4 {
+		t.Errorf("Expected a rated 5+3 online game of 4 moves, got %+v", game)
+	}
+	if !game.Played("a") || !game.Played("b") || game.Played("c") {
+		t.Errorf("Expected the game to be played from seats a and b, got %v", game.Seats)
+	}
+	if game.Started.IsZero() || game.Ended.Before(game.Started) {
+		t.Errorf("Expected the game to start (%s) before it ended (%s)", game.Started, game.Ended)
+	}
+
+	unfinished, _ := s.Pair("chess", [2]string{"a", "b"}, nil, false)
+	if _, ok := a.Get(unfinished.ID); ok {
+		t.Errorf("Expected a game in progress not to be archived")
+	}
+}
+
+func TestFinishedAgainstBot(t *testing.T) {
+	s, a := newService(t, "")
+
+	game := tictactoe.NewGame()
+	game.SearchDepth = 2
+	data := &utils.TwoPlayerGame{Active: "X", Player: "O", Owner: "me", Started: true}
+	entry, _ := s.Games.Add(game, data)
+	entry.Lock()
+	defer entry.Unlock()
+
+	// the moves are played straight on the board, so the bot doesn't start
+	// searching in the background
+	for _, move := range []int{0, 3, 1, 4} {
+		game.MakeMove(move)
+	}
+	s.PlayTTT(entry, 2)
+
+	archived, ok := a.Get(entry.ID)
+	if !ok {
+		t.Fatalf("Expected the finished game to be archived")
+	}
+	if archived.Mode != ModeBot || archived.Players["X"] != "Bot (depth 2)" || archived.Players["O"] != "Anonymous" {
+		t.Errorf("Expected Anonymous to play the bot, got %s", archived.Matchup())
+	}
+	if archived.Termination != "Three in a row" || archived.Result != "1-0" {
+		t.Errorf("Expected X to win with three in a row, got %s", archived.Outcome())
+	}
+	if !archived.Played("me") {
+		t.Errorf("Expected the game to be played from seat me")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	game := Game{
+		Game:    "chess",
+		Start:   "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		Moves:   []string{"e2e4", "e7e5", "g1f3"},
+		Colours: []string{"White", "Black"},
+		Players: map[string]string{"White": "alice", "Black": "bob"},
+		Result:  "1/2-1/2",
+	}
+
+	tests := []struct {
+		ply      int
+		expected string
+		last     []int
+	}{
+		{0, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", nil},
+		{2, "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2", []int{52, 36}},
+		{9, "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2", []int{6, 21}},
+	}
+
+	for _, test := range tests {
+		frame, err := game.Replay(test.ply)
+		if err != nil {
+			t.Fatalf("ply %d: %s", test.ply, err)
+		}
+
+		actual := frame.Game.(*chess.ChessGame).EBE.ToFEN()
+		if actual != test.expected {
+			t.Errorf("ply %d: Expected FEN (%s) != actual FEN (%s)", test.ply, test.expected, actual)
+		}
+		if len(frame.Last) != len(test.last) || (len(test.last) > 0 && (frame.Last[0] != test.last[0] || frame.Last[1] != test.last[1])) {
+			t.Errorf("ply %d: Expected last move (%v) != actual last move (%v)", test.ply, test.last, frame.Last)
+		}
+	}
+
+	notation := strings.Join(game.Notation(), " ")
+	if notation != "e4 e5 Nf3" {
+		t.Errorf("Expected notation (%s) != actual notation (%s)", "e4 e5 Nf3", notation)
+	}
+
+	pgn := game.PGN()
+	if !strings.Contains(pgn, `[White "alice"]`) || !strings.Contains(pgn, "1. e4 e5 2. Nf3 1/2-1/2") || strings.Contains(pgn, "FEN") {
+		t.Errorf("Expected a PGN of the game, got\n%s", pgn)
+	}
+}
+
+func TestNotationTicTacToe(t *testing.T) {
+	game := Game{Game: "tictactoe", Start: "         ,X", Moves: []string{"4", "0", "8"}}
+
+	notation := strings.Join(game.Notation(), ", ")
+	if notation != "X b2, O a3, X c1" {
+		t.Errorf("Expected notation (%s) != actual notation (%s)", "X b2, O a3, X c1", notation)
+	}
+
+	frame, err := game.Replay(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := frame.Game.(*tictactoe.TicTacToeGame).ToGameString(); state != "O   X    ,X" {
+		t.Errorf("Expected state (%s) != actual state (%s)", "O   X    ,X", state)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	s, a := newService(t, path)
+
+	ids := []string{}
+	for range 3 {
+		entry, _ := s.Pair("tictactoe", [2]string{"a", "b"}, nil, false)
+		entry.Lock()
+		for _, move := range []int{0, 3, 1, 4, 2} {
+			s.PlayTTT(entry, move)
+		}
+		entry.Unlock()
+		ids = append(ids, entry.ID)
+	}
+	a.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error reopening the archive, got %s", err)
+	}
+	defer reopened.Close()
+
+	recent := reopened.Recent(2, nil)
+	if len(recent) != 2 || recent[0].ID != ids[2] || recent[1].ID != ids[1] {
+		t.Errorf("Expected the two newest games, newest first, got %d games", len(recent))
+	}
+	if mine := reopened.Recent(0, func(g Game) bool { return g.Played("a") }); len(mine) != 3 {
+		t.Errorf("Expected all 3 games to be found by seat, got %d", len(mine))
+	}
+	if game, ok := reopened.Get(ids[0]); !ok || len(game.Moves) != 5 {
+		t.Errorf("Expected the first game's 5 moves to be restored")
+	}
+}
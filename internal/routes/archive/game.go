@@ -0,0 +1,236 @@
+package archive
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+// Game is a finished game as the archive keeps it. Moves are played from
+// Start, in the same notation as the store's records: from and to squares for
+// chess and cell numbers for tic-tac-toe. Players and Seats are keyed by
+// colour, with no seat for the bot's side.
+type Game struct {
+	ID          string            `json:"id"`
+	Game        string            `json:"game"`
+	Start       string            `json:"start"`
+	Moves       []string          `json:"moves,omitempty"`
+	Colours     []string          `json:"colours"`
+	Players     map[string]string `json:"players"`
+	Seats       map[string]string `json:"seats,omitempty"`
+	Mode        string            `json:"mode"`
+	Rated       bool              `json:"rated,omitempty"`
+	TimeControl string            `json:"time_control,omitempty"`
+	Result      string            `json:"result"`
+	Termination string            `json:"termination"`
+	Started     time.Time         `json:"started"`
+	Ended       time.Time         `json:"ended"`
+}
+
+func (g Game) clone() Game {
+	g.Moves = slices.Clone(g.Moves)
+	g.Colours = slices.Clone(g.Colours)
+	g.Players = maps.Clone(g.Players)
+	g.Seats = maps.Clone(g.Seats)
+
+	return g
+}
+
+// Matchup names the players and their colours, in the order they move.
+func (g Game) Matchup() string {
+	sides := []string{}
+	for _, colour := range g.Colours {
+		sides = append(sides, fmt.Sprintf("%s (%s)", g.Players[colour], colour))
+	}
+
+	return strings.Join(sides, " vs. ")
+}
+
+// Outcome describes how the game ended, such as "White won by Checkmate".
+func (g Game) Outcome() string {
+	if len(g.Colours) != 2 {
+		return g.Result
+	}
+
+	switch g.Result {
+	case "1-0":
+		return fmt.Sprintf("%s won by %s", g.Colours[0], g.Termination)
+	case "0-1":
+		return fmt.Sprintf("%s won by %s", g.Colours[1], g.Termination)
+	case "1/2-1/2":
+		return fmt.Sprintf("Draw by %s", g.Termination)
+	}
+
+	return g.Termination
+}
+
+// Progress describes how far into the game a replay after ply moves is.
+func (g Game) Progress(ply int) string {
+	switch ply {
+	case 0:
+		return "Start of the game"
+	case len(g.Moves):
+		return g.Outcome()
+	}
+
+	return fmt.Sprintf("Move %d of %d", ply, len(g.Moves))
+}
+
+// Played reports whether the player in a seat took part in the game.
+func (g Game) Played(seat string) bool {
+	for _, played := range g.Seats {
+		if played == seat {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Named reports whether a player of the given name took part in the game,
+// ignoring case.
+func (g Game) Named(name string) bool {
+	for colour, player := range g.Players {
+		if _, seated := g.Seats[colour]; seated && strings.EqualFold(player, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Frame is the board part of the way through a game: Game is a
+// *chess.ChessGame or a *tictactoe.TicTacToeGame after the first Ply moves,
+// and Last holds the squares or cells the last of them touched.
+type Frame struct {
+	Ply  int
+	Game interface{}
+	Last []int
+}
+
+// Replay sets up the board after the first ply moves, which is clamped to
+// the length of the game.
+func (g Game) Replay(ply int) (Frame, error) {
+	frame := Frame{Ply: min(max(ply, 0), len(g.Moves))}
+
+	switch g.Game {
+	case "chess":
+		game := chess.NewGame()
+		err := game.SetStateFromFEN(g.Start)
+		if err != nil {
+			return frame, err
+		}
+
+		for _, played := range g.Moves[:frame.Ply] {
+			move, err := chessMove(game, played)
+			if err != nil {
+				return frame, err
+			}
+			game.MakeMove(move)
+			frame.Last = []int{move.Start, move.End}
+		}
+
+		frame.Game = game
+	case "tictactoe":
+		game := tictactoe.NewGame()
+		err := game.FromString(g.Start)
+		if err != nil {
+			return frame, err
+		}
+
+		for _, played := range g.Moves[:frame.Ply] {
+			cell, err := strconv.Atoi(played)
+			if err != nil || !slices.Contains(game.GenerateMoves(), cell) {
+				return frame, fmt.Errorf("%s is not a legal move in %s", played, game.ToGameString())
+			}
+			game.MakeMove(cell)
+			frame.Last = []int{cell}
+		}
+
+		frame.Game = game
+	default:
+		return frame, fmt.Errorf("unknown game %q", g.Game)
+	}
+
+	return frame, nil
+}
+
+func chessMove(game *chess.ChessGame, played string) (chess.Move, error) {
+	for _, move := range game.GetLegalMoves() {
+		if move.String() == played {
+			return move, nil
+		}
+	}
+
+	return chess.Move{}, fmt.Errorf("%s is not a legal move in %s", played, game.EBE.ToFEN())
+}
+
+// Notation lists the game's moves for players to read: SAN for chess, and
+// the piece and cell, such as X b2, for tic-tac-toe with columns a to c from
+// the left and rows 1 to 3 from the bottom.
+func (g Game) Notation() []string {
+	notation := []string{}
+
+	switch g.Game {
+	case "chess":
+		game := chess.NewGame()
+		if game.SetStateFromFEN(g.Start) != nil {
+			return g.Moves
+		}
+
+		for _, played := range g.Moves {
+			move, err := chessMove(game, played)
+			if err != nil {
+				return append(notation, g.Moves[len(notation):]...)
+			}
+			notation = append(notation, game.MoveToSAN(move))
+			game.MakeMove(move)
+		}
+	case "tictactoe":
+		for i, played := range g.Moves {
+			cell, _ := strconv.Atoi(played)
+			notation = append(notation, fmt.Sprintf("%s %s", []string{"X", "O"}[i%2], service.TTTSquare(cell)))
+		}
+	default:
+		return g.Moves
+	}
+
+	return notation
+}
+
+// PGN writes out a chess game in portable game notation.
+func (g Game) PGN() string {
+	event := "casual"
+	if g.Rated {
+		event = "rated"
+	}
+
+	tags := map[string]string{
+		"Event":       fmt.Sprintf("%s %s game", g.Mode, event),
+		"Site":        "gomes",
+		"Date":        g.Started.Format("2006.01.02"),
+		"Termination": g.Termination,
+	}
+	if len(g.Colours) == 2 {
+		tags["White"] = g.Players[g.Colours[0]]
+		tags["Black"] = g.Players[g.Colours[1]]
+	}
+	// PGN gives time controls in seconds
+	if initial, increment, timed := utils.ParseTimeControl(g.TimeControl); timed {
+		tags["TimeControl"] = fmt.Sprintf("%d+%d", int(initial.Seconds()), int(increment.Seconds()))
+	}
+	if start := chess.DefaultBoard(); g.Start != start.ToFEN() {
+		tags["FEN"] = g.Start
+		tags["SetUp"] = "1"
+	}
+
+	return chess.PGN{Tags: tags, Moves: g.Notation(), Result: g.Result}.String()
+}
@@ -0,0 +1,498 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+var (
+	errNoToken     = errors.New("missing or unknown bot token")
+	errNotSeated   = errors.New("this isn't one of your games")
+	errNoStreaming = errors.New("event streams aren't supported by this connection")
+)
+
+// botAccount is a bot account as the bot API describes it.
+type botAccount struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Owner   string         `json:"owner"`
+	Ratings map[string]int `json:"ratings"`
+	Created time.Time      `json:"created"`
+}
+
+// botChallenge is a challenge to a bot, from the bot's side: Colour is the
+// colour the bot would play, or empty if it's decided when the game starts.
+type botChallenge struct {
+	ID          string `json:"id"`
+	Challenger  string `json:"challenger"`
+	Rating      int    `json:"rating"`
+	Game        string `json:"game"`
+	TimeControl string `json:"time_control,omitempty"`
+	Colour      string `json:"colour,omitempty"`
+	Rated       bool   `json:"rated"`
+}
+
+// botEvent is one event of a bot API stream: a challenge for the account
+// stream's challenge events, and the game for the rest.
+type botEvent struct {
+	Type      string        `json:"type"`
+	Challenge *botChallenge `json:"challenge,omitempty"`
+	Game      *apiGame      `json:"game,omitempty"`
+}
+
+func newBotChallenge(seek lobby.Seek) *botChallenge {
+	challenge := &botChallenge{
+		ID:          seek.ID,
+		Challenger:  seek.Name,
+		Rating:      seek.Rating,
+		Game:        seek.Game,
+		TimeControl: seek.Clock,
+		Rated:       seek.Rated,
+	}
+
+	colours := lobby.Colours[seek.Game]
+	switch seek.Colour {
+	case colours[0]:
+		challenge.Colour = colours[1]
+	case colours[1]:
+		challenge.Colour = colours[0]
+	}
+
+	return challenge
+}
+
+// botStream writes a bot API stream as newline-delimited JSON, or as
+// server-sent events when the client asks for them.
+type botStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+}
+
+func newBotStream(w http.ResponseWriter, r *http.Request) (*botStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errNoStreaming
+	}
+
+	stream := &botStream{
+		w:       w,
+		flusher: flusher,
+		sse:     strings.Contains(r.Header.Get("Accept"), "text/event-stream"),
+	}
+
+	contentType := "application/x-ndjson"
+	if stream.sse {
+		contentType = "text/event-stream"
+	}
+	liftWriteDeadline(w)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return stream, nil
+}
+
+// send writes an event, as a line of JSON or an event named after its type.
+func (s *botStream) send(event botEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if s.sse {
+		err = writeEvent(s.w, event.Type, string(data))
+	} else {
+		_, err = s.w.Write(append(data, '\n'))
+	}
+	if err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// keepAlive writes an empty line, or a comment for server-sent events, so
+// proxies don't close an idle stream.
+func (s *botStream) keepAlive() error {
+	keepAlive := "\n"
+	if s.sse {
+		keepAlive = ": keep-alive\n\n"
+	}
+
+	_, err := fmt.Fprint(s.w, keepAlive)
+	if err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// withBot authenticates a bot API request by the bot token in its
+// Authorization header.
+func (cfg *configdata) withBot(handler func(http.ResponseWriter, *http.Request, accounts.Account)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		bot, found := cfg.Accounts.ForToken(strings.TrimSpace(token))
+		if !ok || !found || !bot.Bot {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gomes bots"`)
+			respondWithJSONError(w, http.StatusUnauthorized, errNoToken)
+			return
+		}
+
+		handler(w, r, bot)
+	}
+}
+
+// seated reports whether the player in a seat is playing in an online game.
+// The entry must be locked.
+func seated(entry *store.Entry, seat string) bool {
+	if !entry.Data.Online {
+		return false
+	}
+
+	for _, player := range entry.Data.Seats {
+		if player == seat {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cfg *configdata) handleBotAccount(w http.ResponseWriter, r *http.Request, bot accounts.Account) {
+	account := botAccount{
+		ID:      bot.ID,
+		Name:    bot.Name,
+		Ratings: make(map[string]int),
+		Created: bot.Created,
+	}
+	if owner, ok := cfg.Accounts.Get(bot.Owner); ok {
+		account.Owner = owner.Name
+	}
+	for category := range bot.Ratings {
+		account.Ratings[category] = cfg.Accounts.Rating(bot.Seat(), category)
+	}
+
+	respondWithJSON(w, http.StatusOK, account)
+}
+
+// botWatch is what a bot's event stream has told it about, so it only sends
+// what's changed: the challenges still open and the games still in play. The
+// stream follows each game it has started until the game finishes or leaves
+// the store, and the games pass their updates on to changed.
+type botWatch struct {
+	seat       string
+	challenges map[string]*botChallenge
+	games      map[string]func()
+	changed    chan *store.Entry
+	done       <-chan struct{}
+}
+
+// follow passes a game's updates on to the watch until it stops following the
+// game or the stream closes, and once more when the game leaves the store.
+func (watch *botWatch) follow(entry *store.Entry) {
+	updates, unsubscribe := entry.Subscribe()
+	watch.games[entry.ID] = unsubscribe
+
+	go func() {
+		for open := true; open; {
+			_, open = <-updates
+			select {
+			case watch.changed <- entry:
+			case <-watch.done:
+				return
+			}
+		}
+	}()
+}
+
+// unfollow stops following a game.
+func (watch *botWatch) unfollow(id string) {
+	if unsubscribe, ok := watch.games[id]; ok {
+		unsubscribe()
+		delete(watch.games, id)
+	}
+}
+
+// handleBotEvents streams a bot's challenges and the starts and ends of its
+// games, for as long as it stays connected. The bot is listed in the lobby
+// as listening while it does.
+func (cfg *configdata) handleBotEvents(w http.ResponseWriter, r *http.Request, bot accounts.Account) {
+	stream, err := newBotStream(w, r)
+	if err != nil {
+		requestLog(r).Error("can't stream to bot", "bot", bot.Name, "err", err)
+		respondWithJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	stop := cfg.Lobby.Listen(lobby.Bot{Seat: bot.Seat(), Name: bot.Name})
+	defer stop()
+
+	updates, unsubscribe := cfg.Lobby.Subscribe()
+	defer unsubscribe()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	watch := &botWatch{
+		seat:       bot.Seat(),
+		challenges: make(map[string]*botChallenge),
+		games:      make(map[string]func()),
+		changed:    make(chan *store.Entry),
+		done:       r.Context().Done(),
+	}
+	defer func() {
+		for id := range watch.games {
+			watch.unfollow(id)
+		}
+	}()
+
+	err = cfg.sendBotEvents(stream, watch, true)
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.Service.Closing():
+			return
+		case _, open := <-updates:
+			if !open {
+				return
+			}
+			err = cfg.sendBotEvents(stream, watch, false)
+		case entry := <-watch.changed:
+			err = cfg.sendGameFinish(stream, watch, entry)
+		case <-ticker.C:
+			err = stream.keepAlive()
+		}
+	}
+
+	requestLog(r).Info("stopped streaming events to bot", "bot", bot.Name, "err", err)
+}
+
+// sendBotEvents sends a "challenge" event for each new challenge to the bot
+// and "challengeClosed" for each one that was accepted, declined, cancelled
+// or expired. Games only start for a bot from its challenges, so the store is
+// only searched for games it has started playing, to send "gameStart" for
+// and follow, when one closes or scan is set.
+func (cfg *configdata) sendBotEvents(stream *botStream, watch *botWatch, scan bool) error {
+	open := make(map[string]bool)
+	for _, seek := range cfg.Lobby.Seeks() {
+		if seek.Opponent != watch.seat {
+			continue
+		}
+
+		open[seek.ID] = true
+		if watch.challenges[seek.ID] != nil {
+			continue
+		}
+
+		watch.challenges[seek.ID] = newBotChallenge(seek)
+		err := stream.send(botEvent{Type: "challenge", Challenge: watch.challenges[seek.ID]})
+		if err != nil {
+			return err
+		}
+	}
+	for id, challenge := range watch.challenges {
+		if open[id] {
+			continue
+		}
+
+		scan = true
+		delete(watch.challenges, id)
+		err := stream.send(botEvent{Type: "challengeClosed", Challenge: challenge})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !scan {
+		return nil
+	}
+
+	for _, entry := range cfg.Games.Entries() {
+		if _, ok := watch.games[entry.ID]; ok {
+			continue
+		}
+
+		entry.Lock()
+		if !seated(entry, watch.seat) || !entry.Data.Started || entry.Data.Ended {
+			entry.Unlock()
+			continue
+		}
+		watch.follow(entry)
+		game := newAPIGame(entry, watch.seat, false)
+		entry.Unlock()
+
+		err := stream.send(botEvent{Type: "gameStart", Game: &game})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendGameFinish sends "gameFinish" once a game the bot is following is over,
+// and stops following it then or once it has left the store.
+func (cfg *configdata) sendGameFinish(stream *botStream, watch *botWatch, entry *store.Entry) error {
+	if _, ok := watch.games[entry.ID]; !ok {
+		return nil
+	}
+
+	entry.Lock()
+	game := newAPIGame(entry, watch.seat, false)
+	entry.Unlock()
+
+	if !game.Ended {
+		if _, err := cfg.Games.Get(entry.ID); err == nil {
+			return nil
+		}
+	}
+
+	watch.unfollow(entry.ID)
+	if !game.Ended {
+		return nil
+	}
+
+	return stream.send(botEvent{Type: "gameFinish", Game: &game})
+}
+
+// handleBotGameStream streams one of the bot's games: "gameFull" with its
+// moves straight away, then "gameState" whenever it changes, until it's over.
+func (cfg *configdata) handleBotGameStream(w http.ResponseWriter, r *http.Request, bot accounts.Account) {
+	seat := bot.Seat()
+	entry, err := cfg.Games.Get(r.PathValue("id"))
+	if err != nil {
+		respondWithAPIGameError(w, err)
+		return
+	}
+
+	entry.Lock()
+	ok := seated(entry, seat)
+	entry.Unlock()
+	if !ok {
+		respondWithJSONError(w, http.StatusForbidden, errNotSeated)
+		return
+	}
+
+	updates, unsubscribe := entry.Subscribe()
+	defer unsubscribe()
+
+	stream, err := newBotStream(w, r)
+	if err != nil {
+		requestLog(r).Error("can't stream to bot", "bot", bot.Name, "err", err)
+		respondWithJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	send := func(event string) (bool, error) {
+		entry.Lock()
+		game := newAPIGame(entry, seat, true)
+		entry.Unlock()
+
+		return game.Ended, stream.send(botEvent{Type: event, Game: &game})
+	}
+
+	ended, err := send("gameFull")
+	for err == nil && !ended {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.Service.Closing():
+			return
+		case _, open := <-updates:
+			if !open {
+				return
+			}
+			ended, err = send("gameState")
+		case <-ticker.C:
+			err = stream.keepAlive()
+		}
+	}
+
+	if err != nil {
+		requestLog(r).Info("stopped streaming game to bot", "bot", bot.Name, "err", err)
+	}
+}
+
+func (cfg *configdata) handleBotMove(w http.ResponseWriter, r *http.Request, bot accounts.Account) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		respondWithAPIGameError(w, err)
+		return
+	}
+	defer entry.Unlock()
+
+	seat := bot.Seat()
+	if !seated(entry, seat) {
+		respondWithJSONError(w, http.StatusForbidden, errNotSeated)
+		return
+	}
+
+	if limited := cfg.limitMove(r, seat, entry); limited != nil {
+		respondWithAPILimit(w, limited)
+		return
+	}
+	err = cfg.Service.Play(entry, seat, r.PathValue("move"))
+	if err != nil {
+		respondWithJSONError(w, playErrorStatus(err), err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAPIGame(entry, seat, true))
+}
+
+func (cfg *configdata) handleBotAccept(w http.ResponseWriter, r *http.Request, bot accounts.Account) {
+	id, err := cfg.Lobby.Accept(r.PathValue("id"), bot.Seat(), bot.Name)
+	if err != nil {
+		respondWithJSONError(w, challengeErrorStatus(err), err)
+		return
+	}
+
+	entry, err := cfg.Games.Get(id)
+	if err != nil {
+		respondWithAPIGameError(w, err)
+		return
+	}
+	entry.Lock()
+	defer entry.Unlock()
+
+	respondWithJSON(w, http.StatusOK, newAPIGame(entry, bot.Seat(), true))
+}
+
+func (cfg *configdata) handleBotDecline(w http.ResponseWriter, r *http.Request, bot accounts.Account) {
+	err := cfg.Lobby.Decline(r.PathValue("id"), bot.Seat())
+	if err != nil {
+		respondWithJSONError(w, challengeErrorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func challengeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, lobby.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, lobby.ErrOwnSeek), errors.Is(err, lobby.ErrOutOfRange), errors.Is(err, lobby.ErrNotYours):
+		return http.StatusForbidden
+	}
+
+	return http.StatusServiceUnavailable
+}
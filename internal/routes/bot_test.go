@@ -0,0 +1,225 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+// newBotGame registers alice and her bot, and starts an online game of
+// tic-tac-toe between them with alice to move, returning the game and the
+// bot's token.
+func newBotGame(t *testing.T, games *service.Service, players *accounts.Accounts) (*store.Entry, accounts.Account, string) {
+	alice, err := players.Register("alice", "SHA256:alice")
+	if err != nil {
+		t.Fatalf("Expected no error registering, got %s", err)
+	}
+	bot, token, err := players.RegisterBot("alicebot", alice.ID)
+	if err != nil {
+		t.Fatalf("Expected no error registering a bot, got %s", err)
+	}
+
+	entry, _ := games.Games.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{Active: "X", Started: true})
+	entry.Lock()
+	defer entry.Unlock()
+	service.Host(entry, "X", alice.Seat(), nil)
+	if _, err := games.Join(entry, bot.Seat()); err != nil {
+		t.Fatalf("Expected the bot to join, got %s", err)
+	}
+
+	return entry, alice, token
+}
+
+func TestBotAuth(t *testing.T) {
+	api, games, players := newTestAPI(t, nil)
+	entry, alice, token := newBotGame(t, games, players)
+
+	// a player's browser, signed in to their account, isn't a bot
+	code, _ := players.NewCode(alice.ID)
+	players.Link(code, "alice-browser")
+
+	paths := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/api/v1/bot/account"},
+		{http.MethodGet, "/api/v1/bot/stream/event"},
+		{http.MethodGet, "/api/v1/bot/game/stream/" + entry.ID},
+		{http.MethodPost, "/api/v1/bot/game/" + entry.ID + "/move/4"},
+	}
+	headers := []struct {
+		name          string
+		authorization string
+	}{
+		{"no header", ""},
+		{"empty token", "Bearer "},
+		{"missing Bearer prefix", token},
+		{"unknown token", "Bearer nope"},
+		{"player's session", "Bearer alice-browser"},
+	}
+
+	for _, path := range paths {
+		for _, header := range headers {
+			r := httptest.NewRequest(path.method, path.path, nil)
+			if header.authorization != "" {
+				r.Header.Set("Authorization", header.authorization)
+			}
+			w := httptest.NewRecorder()
+			api.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s with %s: Expected status (%d) != actual status (%d)", path.path, header.name, http.StatusUnauthorized, w.Code)
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%s with %s: Expected a WWW-Authenticate header", path.path, header.name)
+			}
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/bot/account", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"alicebot"`) {
+		t.Errorf("Expected the bot's account, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestBotMove(t *testing.T) {
+	api, games, players := newTestAPI(t, nil)
+	entry, alice, token := newBotGame(t, games, players)
+	other, _ := games.Games.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{Active: "X"})
+
+	move := func(id, move string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/bot/game/"+id+"/move/"+move, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		return w
+	}
+
+	if w := move(entry.ID, "0"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status (%d) != actual status (%d) moving out of turn", http.StatusForbidden, w.Code)
+	}
+
+	entry.Lock()
+	err := games.Play(entry, alice.Seat(), "4")
+	entry.Unlock()
+	if err != nil {
+		t.Fatalf("Expected alice's move to be played, got %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		move   string
+		status int
+	}{
+		{"unknown game", "nope", "0", http.StatusNotFound},
+		{"game it isn't in", other.ID, "0", http.StatusForbidden},
+		{"illegal move", entry.ID, "4", http.StatusBadRequest},
+		{"legal move", entry.ID, "0", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if w := move(test.id, test.move); w.Code != test.status {
+			t.Errorf("%s: Expected status (%d) != actual status (%d): %s", test.name, test.status, w.Code, w.Body)
+		}
+	}
+}
+
+// readStream opens a bot API stream and reads lines from it until done says
+// it has enough, returning the response and the lines read.
+func readStream(t *testing.T, server *httptest.Server, path, token, accept string, done func(lines []string) bool) (*http.Response, []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	resp, err := server.Client().Do(r)
+	if err != nil {
+		t.Fatalf("Expected no error opening %s, got %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(resp.Body)
+	for !done(lines) && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return resp, lines
+}
+
+func TestBotStreams(t *testing.T) {
+	api, games, players := newTestAPI(t, nil)
+	entry, _, token := newBotGame(t, games, players)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	// newline-delimited JSON has one event on each line
+	resp, lines := readStream(t, server, "/api/v1/bot/game/stream/"+entry.ID, token, "", func(lines []string) bool {
+		return len(lines) == 1
+	})
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Expected content type (%s) != actual content type (%s)", "application/x-ndjson", contentType)
+	}
+	event := botEvent{}
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &event) != nil {
+		t.Fatalf("Expected a line of JSON, got %q", lines)
+	}
+	if event.Type != "gameFull" || event.Game == nil || event.Game.ID != entry.ID || len(event.Game.LegalMoves) != 9 {
+		t.Errorf("Expected the full game, got %+v", event)
+	}
+
+	// server-sent events are named after their type, with the JSON as
+	// their data and a blank line after each
+	resp, lines = readStream(t, server, "/api/v1/bot/stream/event", token, "text/event-stream", func(lines []string) bool {
+		return len(lines) == 3
+	})
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected content type (%s) != actual content type (%s)", "text/event-stream", contentType)
+	}
+	if len(lines) != 3 || lines[0] != "event: gameStart" || lines[2] != "" {
+		t.Fatalf("Expected a gameStart event, got %q", lines)
+	}
+	data, _ := strings.CutPrefix(lines[1], "data: ")
+	event = botEvent{}
+	if err := json.Unmarshal([]byte(data), &event); err != nil || event.Type != "gameStart" || event.Game == nil || event.Game.ID != entry.ID {
+		t.Errorf("Expected the game that started as the event's data, got %q", lines[1])
+	}
+
+	// the stream follows the game, and tells the bot as soon as it's over
+	adjudicated := false
+	_, lines = readStream(t, server, "/api/v1/bot/stream/event", token, "", func(lines []string) bool {
+		if len(lines) == 1 && !adjudicated {
+			adjudicated = true
+			entry.Lock()
+			games.Adjudicate(entry, "X")
+			entry.Unlock()
+		}
+		return len(lines) == 2
+	})
+	event = botEvent{}
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &event) != nil {
+		t.Fatalf("Expected two lines of JSON, got %q", lines)
+	}
+	if event.Type != "gameFinish" || event.Game == nil || event.Game.ID != entry.ID || !event.Game.Ended {
+		t.Errorf("Expected the finished game, got %+v", event)
+	}
+}
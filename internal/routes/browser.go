@@ -1,17 +1,24 @@
 package routes
 
 import (
-	"crypto/rand"
 	"embed"
 	"errors"
 	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jfosburgh/gomes/internal/limits"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+	"github.com/jfosburgh/gomes/internal/routes/archive"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
 	"github.com/jfosburgh/gomes/internal/routes/utils"
 	"github.com/jfosburgh/gomes/pkg/chess"
 	"github.com/jfosburgh/gomes/pkg/tictactoe"
@@ -25,42 +32,64 @@ var (
 type configdata struct {
 	Components map[string]*template.Template
 	Pages      map[string]*template.Template
-	Games      map[string]interface{}
-	GameData   map[string]*utils.TwoPlayerGame
+	Games      *store.GameStore
+	Service    *service.Service
+	Lobby      *lobby.Lobby
+	Accounts   *accounts.Accounts
+	Archive    *archive.Archive
+	Limits     *limits.Limits
+	Admin      *admin.Admin
 }
 
 type chessdata struct {
 }
 
 func (cfg *configdata) handleIndex(w http.ResponseWriter, r *http.Request) {
-	err := cfg.Pages["index"].Execute(w, nil)
+	seat := cfg.seat(w, r)
+	player := ""
+	if account, ok := cfg.Accounts.ForSeat(seat); ok {
+		player = account.Name
+	}
+
+	err := cfg.Pages["index"].Execute(w, struct {
+		Player string
+		Admin  bool
+		Lobby  lobbyView
+	}{player, cfg.Admin.AllowedSeat(seat), cfg.lobbyView(seat)})
 	if err != nil {
 		w.WriteHeader(500)
-		fmt.Println("Error parsing index:", err)
+		requestLog(r).Error("error executing template", "template", "index", "err", err)
 	}
 }
 
-func generateID() string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
+func (cfg *configdata) handleGamePage(w http.ResponseWriter, r *http.Request) {
+	gameName := r.PathValue("game")
 
-	return fmt.Sprintf("%x", bytes)
-}
+	// the session is needed before the game starts, so that an online game's
+	// creator can be seated as soon as they start it
+	session(w, r)
 
-func (cfg *configdata) handleNewPage(w http.ResponseWriter, r *http.Request) {
-	gameName := r.PathValue("game")
+	if gameName != "chess" && gameName != "tictactoe" {
+		cfg.handleExistingGame(w, r, gameName)
+		return
+	}
+
+	if limited := cfg.limitGame(r, cfg.seat(w, r), false); limited != nil {
+		cfg.respondWithLimit(w, r, limited)
+		return
+	}
 
 	game := utils.TwoPlayerGame{}
-	game.ID = generateID()
 	game.Player = ""
 
 	game.Started = false
 	game.Ended = false
 
+	var gameInterface interface{}
 	switch gameName {
 	case "chess":
 		chessGame := chess.NewGame()
-		cfg.Games[game.ID] = chessGame
+		gameInterface = chessGame
 
 		game.Active = "White"
 
@@ -68,100 +97,327 @@ func (cfg *configdata) handleNewPage(w http.ResponseWriter, r *http.Request) {
 		game.Cells = utils.FillChessCells(chessGame, &game, -1, false)
 	case "tictactoe":
 		ticTacToeGame := tictactoe.NewGame()
-		cfg.Games[game.ID] = ticTacToeGame
+		gameInterface = ticTacToeGame
 
 		game.Active = "X"
 
 		game.State = ticTacToeGame.ToGameString()
 		game.Cells = utils.FillTTTCells(ticTacToeGame, &game)
 	default:
-		fmt.Printf("unhandled game: %s\n", gameName)
+		requestLog(r).Info("unknown game", "name", gameName)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	cfg.GameData[game.ID] = &game
+	_, err := cfg.Games.Add(gameInterface, &game)
+	if err != nil {
+		requestLog(r).Warn("can't add game", "err", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	err = cfg.Pages[gameName].ExecuteTemplate(w, "base.html", game)
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", gameName, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// handleExistingGame renders the full page for a game that's already been
+// created, which is where invite links and page reloads end up.
+func (cfg *configdata) handleExistingGame(w http.ResponseWriter, r *http.Request, id string) {
+	entry, err := cfg.Games.Get(id)
+	if err != nil {
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	entry.Lock()
+	defer entry.Unlock()
+
+	name := service.GameName(entry.Game)
+	err = cfg.Pages[name].ExecuteTemplate(w, "base.html", cfg.view(w, r, entry))
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", name, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// handleWatch renders a game for a spectator, who sees it live but can't move
+// in it, even if they happen to be one of its players.
+func (cfg *configdata) handleWatch(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
 
-	err := cfg.Pages[gameName].ExecuteTemplate(w, "base.html", game)
+	name := service.GameName(entry.Game)
+	err = cfg.Pages[name].ExecuteTemplate(w, "base.html", service.Watch(entry))
 	if err != nil {
-		fmt.Printf("error executing template:\n%s\n", err.Error())
+		requestLog(r).Error("error executing template", "template", name, "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
-func (cfg *configdata) respondWithComponent(w http.ResponseWriter, t string, data any) {
-	// fmt.Printf("generating template %s with \n%+v\n", t, data)
+// view is the game as seen by whoever made the request, identified by their
+// session.
+func (cfg *configdata) view(w http.ResponseWriter, r *http.Request, entry *store.Entry) utils.TwoPlayerGame {
+	view := service.View(entry, cfg.seat(w, r))
+	if view.Waiting() {
+		view.Invite = inviteLink(r, entry.ID)
+	}
+
+	return view
+}
+
+// inviteLink is the absolute link to join a game by, on the host the request
+// was made to, so that it still works once it's been sent to someone.
+func inviteLink(r *http.Request, id string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s/games/%s/join", scheme, r.Host, id)
+}
+
+// joinView is the page asking an invited player to confirm they're joining
+// a game, or telling them why they can't.
+type joinView struct {
+	ID     string
+	Title  string
+	Host   string
+	Colour string
+	Clock  string
+	Rated  bool
+	Error  string
+}
+
+// newJoinView describes a game to a player invited to it. The entry must be
+// locked.
+func (cfg *configdata) newJoinView(entry *store.Entry) joinView {
+	data := entry.Data
+	view := joinView{ID: entry.ID, Title: lobby.Titles[service.GameName(entry.Game)], Clock: "Untimed", Rated: data.Rated}
+
+	for _, colour := range service.SeatNames(entry.Game) {
+		seat, taken := data.Seats[colour]
+		if taken && view.Host == "" {
+			view.Host = cfg.Accounts.Name(seat)
+		} else if !taken && view.Colour == "" {
+			view.Colour = colour
+		}
+	}
+	if clock := data.Clock; clock != nil {
+		view.Clock = fmt.Sprintf("%d+%d", int(clock.Initial.Minutes()), int(clock.Increment.Seconds()))
+	}
+
+	return view
+}
+
+func (cfg *configdata) renderJoin(w http.ResponseWriter, r *http.Request, view joinView) {
+	err := cfg.Pages["join"].ExecuteTemplate(w, "base.html", view)
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", "join", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// handleJoinPage asks an invited player to confirm they're joining a game.
+// Joining takes a seat, so it's only done by the page's form: link previews
+// and prefetching load invite links before the invited player gets there.
+func (cfg *configdata) handleJoinPage(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
+	data := entry.Data
+
+	seat := cfg.seat(w, r)
+	for _, id := range data.Seats {
+		if id == seat {
+			http.Redirect(w, r, "/games/"+data.ID, http.StatusSeeOther)
+			return
+		}
+	}
+
+	view := cfg.newJoinView(entry)
+	switch {
+	case !data.Online:
+		view.Error = service.ErrNotOnline.Error()
+	case !data.Waiting():
+		view.Error = service.ErrSeatsTaken.Error()
+	}
+	cfg.renderJoin(w, r, view)
+}
+
+func (cfg *configdata) handleJoin(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
+	data := entry.Data
+
+	_, err = cfg.Service.Join(entry, cfg.seat(w, r))
+	if err != nil {
+		requestLog(r).Info("can't join game", "err", err)
+		status := http.StatusConflict
+		if errors.Is(err, service.ErrNotOnline) {
+			status = http.StatusBadRequest
+		}
+		view := cfg.newJoinView(entry)
+		view.Error = err.Error()
+		w.WriteHeader(status)
+		cfg.renderJoin(w, r, view)
+		return
+	}
+
+	http.Redirect(w, r, "/games/"+data.ID, http.StatusSeeOther)
+}
+
+// handleBoard returns the board as the requester sees it.
+func (cfg *configdata) handleBoard(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
+
+	cfg.respondWithComponent(w, r, service.GameName(entry.Game)+"_gameboard.html", cfg.view(w, r, entry))
+}
+
+func (cfg *configdata) respondWithComponent(w http.ResponseWriter, r *http.Request, t string, data any) {
 	err := cfg.Components[t].Execute(w, data)
 	if err != nil {
-		fmt.Printf("error executing template:\n%s\n", err.Error())
+		requestLog(r).Error("error executing template", "template", t, "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
-func (cfg *configdata) getGameFromRequest(r *http.Request) (interface{}, *utils.TwoPlayerGame, error) {
+// getGameFromRequest returns the game named by the request path, locked for
+// the rest of the request. Callers must unlock it once they're done.
+func (cfg *configdata) getGameFromRequest(r *http.Request) (*store.Entry, error) {
 	gameID := r.PathValue("id")
 
-	data, ok := cfg.GameData[gameID]
-	if !ok {
-		return nil, nil, errors.New(fmt.Sprintf("no game data for %s\n", gameID))
+	entry, err := cfg.Games.Get(gameID)
+	if err != nil {
+		return nil, err
 	}
-	data.Started = true
 
-	game, ok := cfg.Games[gameID]
-	if !ok {
-		return nil, nil, errors.New(fmt.Sprintf("no game for %s\n", gameID))
+	entry.Lock()
+
+	return entry, nil
+}
+
+func (cfg *configdata) respondWithGameError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLog(r).Info("can't get game", "err", err)
+	if errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
+	w.WriteHeader(http.StatusBadRequest)
+}
 
-	return game, data, nil
+func respondWithTurnError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLog(r).Info("rejected move", "err", err)
+	http.Error(w, err.Error(), http.StatusForbidden)
 }
 
-func (cfg *configdata) handleStartGame(w http.ResponseWriter, r *http.Request) {
-	gameInterface, data, err := cfg.getGameFromRequest(r)
-	if err != nil {
-		fmt.Printf("error retrieving game from id:\n%s\n", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+// respondWithPlayError explains why a move wasn't played: it wasn't the
+// player's to make, or it couldn't be made.
+func respondWithPlayError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLog(r).Info("rejected move", "err", err)
+	http.Error(w, err.Error(), playErrorStatus(err))
+}
+
+func playErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrIllegalMove):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotStarted), errors.Is(err, service.ErrGameOver), errors.Is(err, service.ErrWaiting):
+		return http.StatusConflict
 	}
 
-	mode := r.FormValue("gamemode")
+	return http.StatusForbidden
+}
 
-	var compName string
-	switch gameInterface.(type) {
-	case *tictactoe.TicTacToeGame:
-		game := gameInterface.(*tictactoe.TicTacToeGame)
-		if mode == "pvb" {
-			data.Player = r.FormValue("playerID")
-			game.SearchDepth, _ = strconv.Atoi(r.FormValue("depth"))
-			// fmt.Printf("setting game parameters:\nPlayer: %s\nSearch Depth: %d\n", data.Player, game.SearchDepth)
+func (cfg *configdata) handleStartGame(w http.ResponseWriter, r *http.Request) {
+	entry, err := cfg.getGameFromRequest(r)
+	if err != nil {
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
+
+	settings := service.Settings{Colour: r.FormValue("playerID")}
+	switch r.FormValue("gamemode") {
+	case "pvb":
+		settings.Mode = service.ModeBot
+		depth, _ := strconv.Atoi(r.FormValue("depth"))
+		searchTime, _ := strconv.Atoi(r.FormValue("time"))
+		settings.Depth = depth
+		// the browser offers chess depths in moves rather than plies
+		if _, ok := entry.Game.(*chess.ChessGame); ok {
+			settings.Depth = depth * 2
 		}
-		data.Cells = utils.FillTTTCells(game, data)
-		data.Status = "X makes the first move!"
-		compName = "tictactoe_gameboard.html"
-	case *chess.ChessGame:
-		game := gameInterface.(*chess.ChessGame)
-		if mode == "pvb" {
-			data.Player = r.FormValue("playerID")
-			depth, _ := strconv.Atoi(r.FormValue("depth"))
-			searchTime, _ := strconv.Atoi(r.FormValue("time"))
-			game.MaxSearchDepth = depth * 2
-			game.SearchTime = time.Duration(searchTime) * time.Second
+		settings.SearchTime = time.Duration(searchTime) * time.Second
+	case "online":
+		settings.Mode = service.ModeOnline
+		settings.Colour = r.FormValue("seat")
+		initial, increment, timed := utils.ParseTimeControl(r.FormValue("clock"))
+		if timed {
+			sides := service.SeatNames(entry.Game)
+			settings.Clock = utils.NewClock([2]string{sides[0], sides[1]}, initial, increment)
 		}
-		data.Cells = utils.FillChessCells(game, data, -1, false)
-		data.Status = "White makes the first move!"
-		compName = "chess_gameboard.html"
 	default:
-		fmt.Printf("unhandled game type: %t\n", gameInterface)
-		w.WriteHeader(http.StatusBadRequest)
+		settings.Mode = service.ModeLocal
+	}
+
+	// the game was counted when its page was opened, so only the bot's
+	// searches are limited here
+	if settings.Mode == service.ModeBot && cfg.Limits != nil {
+		if limited := cfg.limit(r, cfg.seat(w, r), cfg.Limits.Bot); limited != nil {
+			cfg.respondWithLimit(w, r, limited)
+			return
+		}
+	}
+
+	err = cfg.Service.Start(entry, cfg.seat(w, r), settings)
+	if err != nil {
+		requestLog(r).Info("can't start game", "err", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrStarted) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	cfg.respondWithComponent(w, compName, *data)
+	// started games have their own URL, so reloading the page or sharing it
+	// returns to this game instead of creating a new one
+	w.Header().Set("HX-Push-Url", "/games/"+entry.ID)
+
+	cfg.respondWithComponent(w, r, service.GameName(entry.Game)+"_gameboard.html", cfg.view(w, r, entry))
 }
 
 func (cfg *configdata) handleSelect(w http.ResponseWriter, r *http.Request) {
-	gameInterface, data, err := cfg.getGameFromRequest(r)
+	entry, err := cfg.getGameFromRequest(r)
 	if err != nil {
-		fmt.Printf("error retrieving game from id:\n%s\n", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
+	gameInterface, data := entry.Game, entry.Data
+
+	err = cfg.Service.CheckTurn(entry, cfg.seat(w, r))
+	if err != nil {
+		respondWithTurnError(w, r, err)
+		return
 	}
 
 	queries := r.URL.Query()
@@ -180,46 +436,53 @@ func (cfg *configdata) handleSelect(w http.ResponseWriter, r *http.Request) {
 	var compName string
 	switch gameInterface.(type) {
 	case *tictactoe.TicTacToeGame:
-		fmt.Println("select not implemented for tictactoe")
+		requestLog(r).Info("can't select a tic-tac-toe cell")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	case *chess.ChessGame:
 		game := gameInterface.(*chess.ChessGame)
-		fmt.Printf("returning chess board with piece %d selected\n", location)
 		data.Cells = utils.FillChessCells(game, data, location, false)
 		compName = "chess_gameboard.html"
 	default:
-		fmt.Printf("unhandled game type: %t\n", gameInterface)
+		requestLog(r).Error("unknown game type", "type", fmt.Sprintf("%T", gameInterface))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	cfg.respondWithComponent(w, compName, *data)
+	cfg.respondWithComponent(w, r, compName, cfg.view(w, r, entry))
 }
 
 func (cfg *configdata) handlePromotion(w http.ResponseWriter, r *http.Request) {
-	gameInterface, data, err := cfg.getGameFromRequest(r)
+	entry, err := cfg.getGameFromRequest(r)
 	if err != nil {
-		fmt.Printf("error retrieving game from id:\n%s\n", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
+	gameInterface := entry.Game
+
+	err = cfg.Service.CheckTurn(entry, cfg.seat(w, r))
+	if err != nil {
+		respondWithTurnError(w, r, err)
+		return
 	}
 
 	queries := r.URL.Query()
 	start, err := strconv.Atoi(queries.Get("start"))
 	if err != nil {
-		fmt.Println("couldn't parse start")
+		requestLog(r).Info("bad move request", "start", queries.Get("start"))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	end, err := strconv.Atoi(queries.Get("end"))
 	if err != nil {
-		fmt.Println("couldn't parse end")
+		requestLog(r).Info("bad move request", "end", queries.Get("end"))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	promote, err := strconv.Atoi(queries.Get("promote"))
 	if err != nil {
-		fmt.Println("couldn't parse promote")
+		requestLog(r).Info("bad move request", "promote", queries.Get("promote"))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -228,32 +491,37 @@ func (cfg *configdata) handlePromotion(w http.ResponseWriter, r *http.Request) {
 
 	gameMove, valid := game.MoveFromLocations(start, end)
 	if !valid {
-		fmt.Printf("requested move is invalid: %d->%d\n", start, end)
+		requestLog(r).Info("rejected move", "start", start, "end", end)
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 	gameMove.Promotion = promote
-	game.MakeMove(gameMove)
-	data.Active = utils.ChessNames[game.EBE.Active]
-
-	data.Cells = utils.FillChessCells(game, data, -1, false)
-	data.Ended = len(game.GetLegalMoves()) == 0
-	if data.Ended {
-		data.Status = fmt.Sprintf("%s Wins!", utils.ChessNames[^(game.EBE.Active)&0b1])
-
-		delete(cfg.GameData, data.ID)
-		delete(cfg.Games, data.ID)
-	} else {
-		data.Status = fmt.Sprintf("%s played %s, %s's Turn!", utils.ChessNames[^game.EBE.Active&0b1], gameMove, data.Active)
+	if limited := cfg.limitMove(r, cfg.seat(w, r), entry); limited != nil {
+		cfg.respondWithLimit(w, r, limited)
+		return
+	}
+	err = cfg.Service.Play(entry, cfg.seat(w, r), gameMove.UCI())
+	if err != nil {
+		respondWithPlayError(w, r, err)
+		return
 	}
 
-	cfg.respondWithComponent(w, "chess_gameboard.html", *data)
+	cfg.respondWithComponent(w, r, "chess_gameboard.html", cfg.view(w, r, entry))
 }
 
 func (cfg *configdata) handleMove(w http.ResponseWriter, r *http.Request) {
-	gameInterface, data, err := cfg.getGameFromRequest(r)
+	entry, err := cfg.getGameFromRequest(r)
 	if err != nil {
-		fmt.Printf("error retrieving game from id:\n%s\n", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+	defer entry.Unlock()
+	gameInterface := entry.Game
+
+	err = cfg.Service.CheckTurn(entry, cfg.seat(w, r))
+	if err != nil {
+		respondWithTurnError(w, r, err)
+		return
 	}
 
 	queries := r.URL.Query()
@@ -271,26 +539,14 @@ func (cfg *configdata) handleMove(w http.ResponseWriter, r *http.Request) {
 	var compName string
 	switch gameInterface.(type) {
 	case *tictactoe.TicTacToeGame:
-		game := gameInterface.(*tictactoe.TicTacToeGame)
-		game.MakeMove(move)
-
-		var winner int
-		data.Ended, winner = game.GameOver()
-
-		data.Active = utils.TTTPieces[game.State.Active]
-		data.Cells = utils.FillTTTCells(game, data)
-
-		if data.Ended {
-			if winner == 0 {
-				data.Status = "It's a tie!"
-			} else {
-				data.Status = fmt.Sprintf("%s Wins!", utils.TTTPieces[winner])
-			}
-
-			delete(cfg.GameData, data.ID)
-			delete(cfg.Games, data.ID)
-		} else {
-			data.Status = fmt.Sprintf("%s's Turn!", data.Active)
+		if limited := cfg.limitMove(r, cfg.seat(w, r), entry); limited != nil {
+			cfg.respondWithLimit(w, r, limited)
+			return
+		}
+		err = cfg.Service.Play(entry, cfg.seat(w, r), moveStr)
+		if err != nil {
+			respondWithPlayError(w, r, err)
+			return
 		}
 		compName = "tictactoe_gameboard.html"
 	case *chess.ChessGame:
@@ -314,36 +570,21 @@ func (cfg *configdata) handleMove(w http.ResponseWriter, r *http.Request) {
 
 		gameMove, valid := game.MoveFromLocations(src, move)
 		if !valid {
-			fmt.Printf("requested move is invalid: %d->%d\n", src, move)
+			requestLog(r).Info("rejected move", "start", src, "end", move)
 			w.WriteHeader(http.StatusBadRequest)
+			return
 		}
 		if promote {
+			// the pawn is only moved to show the choice of pieces, so the
+			// promotion is previewed on a copy of the game's data instead of
+			// being saved and sent to everyone watching
 			gameMove.Promotion = gameMove.Piece
-		}
-		game.MakeMove(gameMove)
-		if !promote {
-			data.Active = utils.ChessNames[game.EBE.Active]
-		}
-
-		data.Cells = utils.FillChessCells(game, data, -1, promote)
-		checkmate := len(game.GetLegalMoves()) == 0
-		draw := !checkmate && game.EBE.Halfmoves >= 100
-		data.Ended = checkmate || draw
-		if data.Ended {
-			data.Status = fmt.Sprintf("%s Wins!", utils.ChessNames[^(game.EBE.Active)&0b1])
-			if draw {
-				data.Status = "It's a tie!"
-			}
-
-			delete(cfg.GameData, data.ID)
-			delete(cfg.Games, data.ID)
-		} else {
-			data.Status = fmt.Sprintf("%s played %s, %s's Turn!", utils.ChessNames[^game.EBE.Active&0b1], gameMove, data.Active)
-		}
-		if promote {
-			compName = "promotion.html"
-			data.Status = fmt.Sprintf("%s, choose your promotion!", data.Active)
+			game.MakeMove(gameMove)
+			preview := cfg.view(w, r, entry)
+			preview.Cells = utils.FillChessCells(game, &preview, -1, true)
+			preview.Status = fmt.Sprintf("%s, choose your promotion!", preview.Active)
 			game.UnmakeMove(gameMove)
+
 			type promoteoption struct {
 				Piece   int
 				Picture string
@@ -356,7 +597,7 @@ func (cfg *configdata) handleMove(w http.ResponseWriter, r *http.Request) {
 			}
 			side := game.EBE.Active << 3
 			promoteData := promotedata{
-				*data,
+				preview,
 				src,
 				move,
 				[4]promoteoption{
@@ -367,78 +608,27 @@ func (cfg *configdata) handleMove(w http.ResponseWriter, r *http.Request) {
 				},
 			}
 
-			cfg.respondWithComponent(w, compName, promoteData)
+			cfg.respondWithComponent(w, r, "promotion.html", promoteData)
 			return
-		} else {
-			compName = "chess_gameboard.html"
 		}
-	default:
-		fmt.Printf("unhandled game type: %t\n", gameInterface)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	cfg.respondWithComponent(w, compName, *data)
-}
 
-func (cfg *configdata) handleBotTurn(w http.ResponseWriter, r *http.Request) {
-	gameInterface, data, err := cfg.getGameFromRequest(r)
-	if err != nil {
-		fmt.Printf("error retrieving game from id:\n%s\n", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
-	}
-
-	var compName string
-	switch gameInterface.(type) {
-	case *tictactoe.TicTacToeGame:
-		game := gameInterface.(*tictactoe.TicTacToeGame)
-		move := game.BestMove()
-		game.MakeMove(move)
-
-		var winner int
-		data.Ended, winner = game.GameOver()
-
-		data.Active = utils.TTTPieces[game.State.Active]
-		data.Cells = utils.FillTTTCells(game, data)
-
-		if data.Ended {
-			if winner == 0 {
-				data.Status = "It's a tie!"
-			} else {
-				data.Status = fmt.Sprintf("%s Wins!", utils.TTTPieces[winner])
-			}
-
-			delete(cfg.GameData, data.ID)
-			delete(cfg.Games, data.ID)
-		} else {
-			data.Status = fmt.Sprintf("%s's Turn!", data.Active)
+		if limited := cfg.limitMove(r, cfg.seat(w, r), entry); limited != nil {
+			cfg.respondWithLimit(w, r, limited)
+			return
 		}
-		compName = "tictactoe_gameboard.html"
-	case *chess.ChessGame:
-		game := gameInterface.(*chess.ChessGame)
-
-		move := game.BestMove()
-		game.MakeMove(move)
-		data.Active = utils.ChessNames[game.EBE.Active]
-
-		data.Cells = utils.FillChessCells(game, data, -1, false)
-		data.Ended = len(game.GetLegalMoves()) == 0
-		if data.Ended {
-			data.Status = fmt.Sprintf("%s Wins!", utils.ChessNames[^(game.EBE.Active)&0b1])
-
-			delete(cfg.GameData, data.ID)
-			delete(cfg.Games, data.ID)
-		} else {
-			data.Status = fmt.Sprintf("%s played %s, %s's Turn!", utils.ChessNames[^game.EBE.Active&0b1], move, data.Active)
+		err = cfg.Service.Play(entry, cfg.seat(w, r), gameMove.UCI())
+		if err != nil {
+			respondWithPlayError(w, r, err)
+			return
 		}
 		compName = "chess_gameboard.html"
 	default:
-		fmt.Printf("unhandled game type: %t\n", gameInterface)
+		requestLog(r).Error("unknown game type", "type", fmt.Sprintf("%T", gameInterface))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	cfg.respondWithComponent(w, compName, *data)
+	cfg.respondWithComponent(w, r, compName, cfg.view(w, r, entry))
 }
 
 func join(sep string, s ...string) string {
@@ -451,8 +641,11 @@ var tempFuncs = map[string]any{
 	"toString": fmt.Sprint,
 }
 
-func newBrowserRouter() *http.ServeMux {
-	pattern := filepath.Join("internal/routes/templates/components", "*.html")
+// newBrowserRouter serves the HTMX front end, with its templates read from
+// the templates directory.
+func newBrowserRouter(templates string, games *service.Service, seeks *lobby.Lobby, players *accounts.Accounts, finished *archive.Archive, limit *limits.Limits, console *admin.Admin) *http.ServeMux {
+	componentsDir := filepath.Join(templates, "components")
+	pattern := filepath.Join(componentsDir, "*.html")
 	components := make(map[string]*template.Template)
 
 	matches, _ := filepath.Glob(pattern)
@@ -461,18 +654,18 @@ func newBrowserRouter() *http.ServeMux {
 		name := filepath.Base(match)
 		var t *template.Template
 		if name == "promotion.html" {
-			t = template.Must(template.New(name).Funcs(tempFuncs).ParseFiles(match, "internal/routes/templates/components/chess_gameboard.html"))
+			t = template.Must(template.New(name).Funcs(tempFuncs).ParseFiles(match, filepath.Join(componentsDir, "chess_gameboard.html")))
 		} else {
 			t = template.Must(template.New(name).Funcs(tempFuncs).ParseFiles(match))
 		}
 
 		components[name] = t
-		fmt.Printf("added %s to components dict\n", name)
+		slog.Debug("parsed component", "name", name)
 	}
 
-	pattern = filepath.Join("internal/routes/templates", "*.html")
+	pattern = filepath.Join(templates, "*.html")
 	pages := make(map[string]*template.Template)
-	base := "internal/routes/templates/base.html"
+	base := filepath.Join(templates, "base.html")
 
 	matches, _ = filepath.Glob(pattern)
 	for _, match := range matches {
@@ -482,32 +675,62 @@ func newBrowserRouter() *http.ServeMux {
 
 		game := strings.Split(filepath.Base(match), ".")[0]
 
-		if game != "index" {
-			t := template.Must(template.New(base).Funcs(tempFuncs).ParseFiles(base, match, fmt.Sprintf("internal/routes/templates/components/%s_gameboard.html", game)))
-			fmt.Printf("created game template for %s\n", game)
+		switch game {
+		case "index":
+			t := template.Must(template.ParseFiles(base, match, filepath.Join(componentsDir, "seeks.html")))
 			pages[game] = t
-		} else {
-			t, _ := template.ParseFiles(base, match)
+		case "profile", "leaderboard", "archive", "replay", "limited", "admin", "join":
+			t := template.Must(template.ParseFiles(base, match))
+			pages[game] = t
+		default:
+			t := template.Must(template.New(base).Funcs(tempFuncs).ParseFiles(base, match, filepath.Join(componentsDir, game+"_gameboard.html")))
+			slog.Debug("parsed game page", "game", game)
 			pages[game] = t
 		}
 	}
 
-	config := configdata{
+	config := &configdata{
 		Components: components,
 		Pages:      pages,
-		Games:      make(map[string]interface{}),
-		GameData:   make(map[string]*utils.TwoPlayerGame),
+		Games:      games.Games,
+		Service:    games,
+		Lobby:      seeks,
+		Accounts:   players,
+		Archive:    finished,
+		Limits:     limit,
+		Admin:      console,
 	}
 
 	browserRouter := http.NewServeMux()
 	browserRouter.Handle("GET /css/styles.css", http.FileServer(http.FS(css)))
 	browserRouter.HandleFunc("GET /", config.handleIndex)
-	browserRouter.HandleFunc("GET /games/{game}", config.handleNewPage)
+	browserRouter.HandleFunc("GET /profile", config.handleProfile)
+	browserRouter.HandleFunc("POST /profile/link", config.handleLink)
+	browserRouter.HandleFunc("POST /profile/signout", config.handleSignOut)
+	browserRouter.HandleFunc("GET /players/{name}", config.handlePlayer)
+	browserRouter.HandleFunc("GET /leaderboard", config.handleLeaderboard)
+	browserRouter.HandleFunc("GET /archive", config.handleArchive)
+	browserRouter.HandleFunc("GET /archive/{id}", config.handleReplay)
+	browserRouter.HandleFunc("GET /archive/{id}/pgn", config.handleArchivePGN)
+	browserRouter.HandleFunc("GET /lobby/events", config.handleLobbyEvents)
+	browserRouter.HandleFunc("POST /lobby/seeks", config.handlePostSeek)
+	browserRouter.HandleFunc("POST /lobby/seeks/{id}/accept", config.handleAcceptSeek)
+	browserRouter.HandleFunc("DELETE /lobby/seeks/{id}", config.handleCancelSeek)
+	browserRouter.HandleFunc("GET /games/{game}", config.handleGamePage)
+	browserRouter.HandleFunc("GET /games/{id}/join", config.handleJoinPage)
+	browserRouter.HandleFunc("POST /games/{id}/join", config.handleJoin)
+	browserRouter.HandleFunc("GET /games/{id}/watch", config.handleWatch)
+	browserRouter.HandleFunc("GET /games/{id}/board", config.handleBoard)
+	browserRouter.HandleFunc("GET /games/{id}/events", config.handleEvents)
 	browserRouter.HandleFunc("POST /games/{id}/start", config.handleStartGame)
 	browserRouter.HandleFunc("POST /games/{id}", config.handleMove)
-	browserRouter.HandleFunc("POST /games/{id}/bot", config.handleBotTurn)
 	browserRouter.HandleFunc("POST /games/{id}/select", config.handleSelect)
 	browserRouter.HandleFunc("POST /games/{id}/promote", config.handlePromotion)
+	browserRouter.HandleFunc("GET /admin", config.handleAdmin)
+	browserRouter.HandleFunc("POST /admin/games/{id}/abort", config.handleAdminAbort)
+	browserRouter.HandleFunc("POST /admin/games/{id}/adjudicate", config.handleAdminAdjudicate)
+	browserRouter.HandleFunc("POST /admin/sessions/{session}/kick", config.handleAdminKick)
+	browserRouter.HandleFunc("POST /admin/settings", config.handleAdminSettings)
 
 	return browserRouter
 }
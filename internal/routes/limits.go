@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jfosburgh/gomes/internal/limits"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+// againstBot is whether a game is played against the bot, so that starting
+// it or moving in it has the bot search for a reply. The entry must be
+// locked.
+func againstBot(entry *store.Entry) bool {
+	return !entry.Data.Online && entry.Data.Player != ""
+}
+
+// limit checks the request's address and seat against limiters, returning
+// the first limit hit. The request only counts against the limiters if none
+// of them turns it away.
+func (cfg *configdata) limit(r *http.Request, seat string, limiters ...*limits.Limiter) *limits.Error {
+	if cfg.Limits == nil {
+		return nil
+	}
+
+	keys := []string{cfg.Limits.RequestIP(r), limits.Identity(seat)}
+	err := limits.Allow(keys, limiters...)
+	limited := &limits.Error{}
+	if errors.As(err, &limited) {
+		requestLog(r).Info("rate limited", "limit", limited.What, "retry", limited.Retry)
+		return limited
+	}
+
+	return nil
+}
+
+// limitGame checks starting a game, which is also limited as a bot search if
+// it's against the bot.
+func (cfg *configdata) limitGame(r *http.Request, seat string, bot bool) *limits.Error {
+	if cfg.Limits == nil {
+		return nil
+	}
+	if bot {
+		return cfg.limit(r, seat, cfg.Limits.Games, cfg.Limits.Bot)
+	}
+
+	return cfg.limit(r, seat, cfg.Limits.Games)
+}
+
+// limitMove checks moving in a game, which is also limited as a bot search if
+// it's against the bot. The entry must be locked.
+func (cfg *configdata) limitMove(r *http.Request, seat string, entry *store.Entry) *limits.Error {
+	if cfg.Limits == nil {
+		return nil
+	}
+	if againstBot(entry) {
+		return cfg.limit(r, seat, cfg.Limits.Moves, cfg.Limits.Bot)
+	}
+
+	return cfg.limit(r, seat, cfg.Limits.Moves)
+}
+
+func setRetryAfter(w http.ResponseWriter, err *limits.Error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(limits.RetryAfter(err.Retry).Seconds())))
+}
+
+// respondWithLimit tells the browser it's hit a limit. HTMX requests have the
+// message swapped into the page's notice, leaving the board as it was, and
+// anything else gets a page of its own.
+func (cfg *configdata) respondWithLimit(w http.ResponseWriter, r *http.Request, err *limits.Error) {
+	setRetryAfter(w, err)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Retarget", ".notice")
+		w.Header().Set("HX-Reswap", "innerHTML")
+		w.WriteHeader(http.StatusTooManyRequests)
+		cfg.respondWithComponent(w, r, "limit.html", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusTooManyRequests)
+	execErr := cfg.Pages["limited"].ExecuteTemplate(w, "base.html", err)
+	if execErr != nil {
+		requestLog(r).Error("error executing template", "template", "limited", "err", execErr)
+	}
+}
+
+// respondWithAPILimit is respondWithLimit for the JSON API.
+func respondWithAPILimit(w http.ResponseWriter, err *limits.Error) {
+	setRetryAfter(w, err)
+	respondWithJSONError(w, http.StatusTooManyRequests, err)
+}
+
+// limitBody caps the size of request bodies, so that a client can't have the
+// server read more than a form or a move's worth.
+func limitBody(next http.Handler, size int64) http.Handler {
+	if size <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, size)
+		next.ServeHTTP(w, r)
+	})
+}
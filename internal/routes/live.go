@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+)
+
+// keepAlive is how often an idle event stream sends a comment, so proxies
+// don't close it while a player is thinking.
+const keepAlive = 15 * time.Second
+
+// liftWriteDeadline lifts the server's write timeout for an event stream,
+// which is written to for as long as the client stays. Writers that can't
+// have their deadline set have no timeout to lift.
+func liftWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// gameShutdownNotice and lobbyShutdownNotice tell browser players the server
+// is going away, just before their event streams are closed.
+const (
+	gameShutdownNotice  = "The server is restarting. Your game has been saved, and this page will reconnect when it's back."
+	lobbyShutdownNotice = "The server is restarting. The lobby will reconnect when it's back."
+)
+
+// handleEvents streams the board to a browser as server-sent events, sending
+// it once straight away and again whenever the game changes, whoever changed
+// it. Every viewer gets the board as they should see it, and the watch page
+// asks for the board as a spectator sees it. Streams are listed for admins,
+// and closed with a notice if an admin kicks their player.
+func (cfg *configdata) handleEvents(w http.ResponseWriter, r *http.Request) {
+	// the session cookie has to be set before the stream starts
+	seat := cfg.seat(w, r)
+
+	entry, err := cfg.Games.Get(r.PathValue("id"))
+	if err != nil {
+		cfg.respondWithGameError(w, r, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		requestLog(r).Error("event streams aren't supported by this connection")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	watching := r.URL.Query().Has("watch")
+	render := func() utils.TwoPlayerGame {
+		if watching {
+			return service.Watch(entry)
+		}
+		return cfg.view(w, r, entry)
+	}
+
+	subscribe := entry.Subscribe
+	if watching {
+		subscribe = entry.Watch
+	}
+	updates, unsubscribe := subscribe()
+	defer unsubscribe()
+	kicked, closed := cfg.openSession(r, seat, entry.ID)
+	defer closed()
+
+	liftWriteDeadline(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	// a notice left from before a restart is cleared when the page reconnects
+	err = sendNotice(w, "")
+	if err == nil {
+		err = cfg.sendBoard(w, entry, render)
+	}
+	for err == nil {
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.Service.Closing():
+			sendNotice(w, gameShutdownNotice)
+			flusher.Flush()
+			return
+		case <-kicked:
+			sendNotice(w, kickedNotice)
+			flusher.Flush()
+			return
+		case _, open := <-updates:
+			if !open {
+				return
+			}
+			err = cfg.sendBoard(w, entry, render)
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+	}
+
+	requestLog(r).Info("stopped streaming game", "err", err)
+}
+
+// sendBoard writes the board as a single "board" event.
+func (cfg *configdata) sendBoard(w http.ResponseWriter, entry *store.Entry, render func() utils.TwoPlayerGame) error {
+	buf := bytes.Buffer{}
+
+	entry.Lock()
+	err := cfg.Components[service.GameName(entry.Game)+"_gameboard.html"].Execute(&buf, render())
+	entry.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return writeEvent(w, "board", buf.String())
+}
+
+// sendNotice writes a "notice" event, shown above the board or the lobby. An
+// empty notice clears it.
+func sendNotice(w http.ResponseWriter, notice string) error {
+	return writeEvent(w, "notice", html.EscapeString(notice))
+}
+
+// writeEvent writes a server-sent event, with each line of the rendered
+// component as a line of event data.
+func writeEvent(w http.ResponseWriter, name, component string) error {
+	event := strings.Builder{}
+	event.WriteString("event: " + name + "\n")
+	for _, line := range strings.Split(strings.TrimRight(component, "\n"), "\n") {
+		event.WriteString("data: " + line + "\n")
+	}
+	event.WriteString("\n")
+
+	_, err := fmt.Fprint(w, event.String())
+	return err
+}
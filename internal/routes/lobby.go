@@ -0,0 +1,217 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+)
+
+// lobbyView is the lobby as seen by one browser: the open seeks it can see,
+// marking the ones it posted, the bots it can challenge, and the game to go
+// to once its own seek has been matched.
+type lobbyView struct {
+	Seeks   []seekView
+	Bots    []lobby.Bot
+	Matched string
+}
+
+type seekView struct {
+	lobby.Seek
+	Own        bool
+	Acceptable bool
+}
+
+func (cfg *configdata) lobbyView(seat string) lobbyView {
+	view := lobbyView{}
+	view.Matched, _ = cfg.Lobby.Matched(seat)
+
+	for _, seek := range cfg.Lobby.Seeks() {
+		if !seek.For(seat) {
+			continue
+		}
+		view.Seeks = append(view.Seeks, seekView{
+			Seek:       seek,
+			Own:        seek.Seat == seat,
+			Acceptable: seek.Seat != seat && seek.Accepts(cfg.Lobby.Rating(seat, seek.Category())),
+		})
+	}
+	view.Bots = cfg.Lobby.Bots()
+
+	return view
+}
+
+// handleLobbyEvents streams the lobby's seeks to the index page whenever they
+// change, including when the browser's own seek is matched and it should
+// head to the new game.
+func (cfg *configdata) handleLobbyEvents(w http.ResponseWriter, r *http.Request) {
+	seat := cfg.seat(w, r)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		requestLog(r).Error("event streams aren't supported by this connection")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	updates, unsubscribe := cfg.Lobby.Subscribe()
+	defer unsubscribe()
+	kicked, closed := cfg.openSession(r, seat, "")
+	defer closed()
+
+	liftWriteDeadline(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	err := sendNotice(w, "")
+	if err == nil {
+		err = cfg.sendSeeks(w, seat)
+	}
+	for err == nil {
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.Service.Closing():
+			sendNotice(w, lobbyShutdownNotice)
+			flusher.Flush()
+			return
+		case <-kicked:
+			sendNotice(w, kickedNotice)
+			flusher.Flush()
+			return
+		case _, open := <-updates:
+			if !open {
+				return
+			}
+			err = cfg.sendSeeks(w, seat)
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+	}
+
+	requestLog(r).Info("stopped streaming the lobby", "err", err)
+}
+
+// sendSeeks writes the seeks as a single "lobby" event.
+func (cfg *configdata) sendSeeks(w http.ResponseWriter, seat string) error {
+	buf := bytes.Buffer{}
+	err := cfg.Components["seeks.html"].Execute(&buf, cfg.lobbyView(seat))
+	if err != nil {
+		return err
+	}
+
+	return writeEvent(w, "lobby", buf.String())
+}
+
+// handlePostSeek opens a seek from the index page's form, sending the browser
+// straight to the game if it matched one already waiting. A seek with an
+// opponent is a challenge to a bot from its profile page, and the browser
+// waits for the bot's answer in the lobby.
+func (cfg *configdata) handlePostSeek(w http.ResponseWriter, r *http.Request) {
+	seat := cfg.seat(w, r)
+	seek := lobby.Seek{
+		Seat:  seat,
+		Name:  cfg.Accounts.Name(seat),
+		Game:  r.FormValue("game"),
+		Clock: r.FormValue("clock"),
+		Rated: r.FormValue("rated") == "rated",
+	}
+	seek.Range, _ = strconv.Atoi(r.FormValue("range"))
+
+	// the form asks for the side that moves first or second, whatever the
+	// game calls it
+	switch r.FormValue("colour") {
+	case "first":
+		seek.Colour = lobby.Colours[seek.Game][0]
+	case "second":
+		seek.Colour = lobby.Colours[seek.Game][1]
+	}
+
+	// only chess is played against the clock
+	if seek.Game != "chess" {
+		seek.Clock = ""
+	}
+
+	if name := r.FormValue("opponent"); name != "" {
+		bot, ok := cfg.Accounts.ByName(name)
+		if !ok || !bot.Bot {
+			respondWithSeekError(w, r, lobby.ErrNotOnline)
+			return
+		}
+		seek.Opponent = bot.Seat()
+		seek.OpponentName = bot.Name
+	}
+
+	if limited := cfg.limitGame(r, seat, false); limited != nil {
+		cfg.respondWithLimit(w, r, limited)
+		return
+	}
+
+	id, err := cfg.Lobby.Post(seek)
+	if err != nil {
+		respondWithSeekError(w, r, err)
+		return
+	}
+
+	switch {
+	case id != "":
+		w.Header().Set("HX-Redirect", "/games/"+id)
+	case seek.Opponent != "":
+		w.Header().Set("HX-Redirect", "/")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *configdata) handleAcceptSeek(w http.ResponseWriter, r *http.Request) {
+	seat := cfg.seat(w, r)
+	if limited := cfg.limitGame(r, seat, false); limited != nil {
+		cfg.respondWithLimit(w, r, limited)
+		return
+	}
+
+	id, err := cfg.Lobby.Accept(r.PathValue("id"), seat, cfg.Accounts.Name(seat))
+	if err != nil {
+		respondWithSeekError(w, r, err)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/games/"+id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *configdata) handleCancelSeek(w http.ResponseWriter, r *http.Request) {
+	err := cfg.Lobby.Cancel(r.PathValue("id"), cfg.seat(w, r))
+	if err != nil {
+		respondWithSeekError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func respondWithSeekError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLog(r).Info("rejected seek", "err", err)
+	switch {
+	case errors.Is(err, lobby.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, lobby.ErrOwnSeek), errors.Is(err, lobby.ErrOutOfRange), errors.Is(err, lobby.ErrNotYours):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, lobby.ErrNotOnline):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, lobby.ErrUnknownGame):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
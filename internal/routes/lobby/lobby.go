@@ -0,0 +1,477 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/rating"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+)
+
+const (
+	// DefaultSeekTTL is how long a seek stays open without being matched,
+	// unless the lobby says otherwise.
+	DefaultSeekTTL = 30 * time.Minute
+
+	// DefaultRating is the rating of players who haven't played a rated game.
+	DefaultRating = rating.DefaultRating
+)
+
+var (
+	ErrNotFound    = errors.New("seek not found")
+	ErrOwnSeek     = errors.New("you can't accept your own seek")
+	ErrOutOfRange  = errors.New("your rating is outside this seek's range")
+	ErrNotYours    = errors.New("this challenge is for someone else")
+	ErrNotOnline   = errors.New("that bot isn't listening for challenges")
+	ErrUnknownGame = errors.New("unknown game")
+)
+
+// Colours lists the colours of each game in the lobby in the order they move.
+var Colours = map[string][2]string{
+	"chess":     {"White", "Black"},
+	"tictactoe": {"X", "O"},
+}
+
+// Titles are the names of the lobby's games as shown to players.
+var Titles = map[string]string{
+	"chess":     "Chess",
+	"tictactoe": "Tic-Tac-Toe",
+}
+
+// Ratings looks up a player's rating in a rating category.
+type Ratings interface {
+	Rating(seat, category string) int
+}
+
+// Seek is an open offer to play, posted by the player in Seat. Clock is a
+// time control such as 5+3, or empty for an untimed game, and Colour is the
+// colour they'd like to play, or empty if they don't mind. Range is how far
+// from their own rating an opponent's may be, with 0 for anyone. A seek with
+// an Opponent is a challenge to the player in that seat, which no one else
+// sees or can accept, and which is never matched automatically.
+type Seek struct {
+	ID           string
+	Seat         string
+	Name         string
+	Game         string
+	Clock        string
+	Colour       string
+	Rated        bool
+	Rating       int
+	Range        int
+	Opponent     string
+	OpponentName string
+
+	Posted time.Time
+}
+
+func (s Seek) Title() string {
+	return Titles[s.Game]
+}
+
+// TimeControl describes the seek's clock for players.
+func (s Seek) TimeControl() string {
+	if s.Clock == "" {
+		return "Untimed"
+	}
+
+	return s.Clock
+}
+
+// Category is the rating category the seek's game will be rated in.
+func (s Seek) Category() string {
+	initial, increment, _ := utils.ParseTimeControl(s.Clock)
+
+	return rating.Category(s.Game, initial, increment)
+}
+
+// Mode is whether the seek is for a rated or casual game.
+func (s Seek) Mode() string {
+	if s.Rated {
+		return "Rated"
+	}
+
+	return "Casual"
+}
+
+// Opponents describes the ratings the seek will play, or who it challenges.
+func (s Seek) Opponents() string {
+	if s.Opponent != "" {
+		return s.OpponentName
+	}
+	if s.Range == 0 {
+		return "Any"
+	}
+
+	return fmt.Sprintf("%d-%d", s.Rating-s.Range, s.Rating+s.Range)
+}
+
+// Side is the colour the seek's player will take, if they chose one.
+func (s Seek) Side() string {
+	if s.Colour == "" {
+		return "Random"
+	}
+
+	return s.Colour
+}
+
+// Accepts reports whether a player with the given rating is in the seek's
+// range.
+func (s Seek) Accepts(rating int) bool {
+	return s.Range == 0 || (rating >= s.Rating-s.Range && rating <= s.Rating+s.Range)
+}
+
+// For reports whether the player in a seat can see the seek: it's their own,
+// open to anyone, or a challenge to them.
+func (s Seek) For(seat string) bool {
+	return s.Opponent == "" || s.Opponent == seat || s.Seat == seat
+}
+
+// Matches reports whether two seeks are from different players looking for
+// the same game, and each is in the other's range. Challenges don't match.
+func (s Seek) Matches(other Seek) bool {
+	return s.Seat != other.Seat &&
+		s.Opponent == "" && other.Opponent == "" &&
+		s.Game == other.Game &&
+		s.Clock == other.Clock &&
+		s.Rated == other.Rated &&
+		(s.Colour == "" || other.Colour == "" || s.Colour != other.Colour) &&
+		s.Accepts(other.Rating) && other.Accepts(s.Rating)
+}
+
+// Bot is a bot account listening for challenges through the bot API.
+type Bot struct {
+	Seat string
+	Name string
+}
+
+// Lobby holds the open seeks for every front end, and starts an online game
+// through the service as soon as two of them match. Changes are announced to
+// its subscribers, and a player whose seek was matched while they waited can
+// pick up the game with Matched. It also keeps track of the bots that are
+// listening, so players know who they can challenge.
+type Lobby struct {
+	Service *service.Service
+
+	// Ratings is nil until players have ratings, and everyone is rated
+	// DefaultRating
+	Ratings Ratings
+
+	// SeekTTL is how long a seek stays open without being matched
+	SeekTTL time.Duration
+
+	mu      sync.Mutex
+	seeks   []Seek
+	matches map[string]string
+	next    int
+
+	// bots are the listening bots by seat, with how many streams each has
+	// open
+	bots      map[string]Bot
+	listeners map[string]int
+
+	subscribersMu sync.Mutex
+	subscribers   map[chan struct{}]struct{}
+}
+
+func New(games *service.Service) *Lobby {
+	return &Lobby{
+		Service:   games,
+		SeekTTL:   DefaultSeekTTL,
+		matches:   make(map[string]string),
+		bots:      make(map[string]Bot),
+		listeners: make(map[string]int),
+	}
+}
+
+// Rating is a player's rating in a rating category.
+func (l *Lobby) Rating(seat, category string) int {
+	if l.Ratings == nil {
+		return DefaultRating
+	}
+
+	return l.Ratings.Rating(seat, category)
+}
+
+// Post opens a seek, replacing any the player already had open. If it
+// matches a seek already in the lobby the game starts straight away and its
+// ID is returned, otherwise the ID is empty and the seek waits in the lobby.
+// Challenges can only be made to bots that are listening, and no seeks can
+// be posted once the server is shutting down.
+func (l *Lobby) Post(seek Seek) (string, error) {
+	select {
+	case <-l.Service.Closing():
+		return "", store.ErrClosed
+	default:
+	}
+
+	colours, ok := Colours[seek.Game]
+	if !ok {
+		return "", ErrUnknownGame
+	}
+	if !slices.Contains(colours[:], seek.Colour) {
+		seek.Colour = ""
+	}
+	if _, _, timed := utils.ParseTimeControl(seek.Clock); !timed {
+		seek.Clock = ""
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if seek.Opponent != "" {
+		if _, ok := l.bots[seek.Opponent]; !ok {
+			return "", ErrNotOnline
+		}
+		seek.Range = 0
+	}
+
+	l.prune(time.Now())
+	l.remove(func(s Seek) bool { return s.Seat == seek.Seat })
+
+	l.next++
+	seek.ID = strconv.Itoa(l.next)
+	seek.Rating = l.Rating(seek.Seat, seek.Category())
+	seek.Posted = time.Now()
+
+	for _, open := range l.seeks {
+		if open.Matches(seek) {
+			return l.pair(open, seek)
+		}
+	}
+
+	l.seeks = append(l.seeks, seek)
+	l.notify()
+
+	return "", nil
+}
+
+// Accept takes up a seek in the lobby, starting the game and returning its ID.
+func (l *Lobby) Accept(id, seat, name string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(time.Now())
+	i := slices.IndexFunc(l.seeks, func(s Seek) bool { return s.ID == id })
+	if i == -1 {
+		return "", ErrNotFound
+	}
+
+	open := l.seeks[i]
+	if open.Seat == seat {
+		return "", ErrOwnSeek
+	}
+	if !open.For(seat) {
+		return "", ErrNotYours
+	}
+
+	rating := l.Rating(seat, open.Category())
+	if !open.Accepts(rating) {
+		return "", ErrOutOfRange
+	}
+
+	return l.pair(open, Seek{Seat: seat, Name: name, Game: open.Game, Rating: rating})
+}
+
+// Cancel closes a player's own seek.
+func (l *Lobby) Cancel(id, seat string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.remove(func(s Seek) bool { return s.ID == id && s.Seat == seat }) {
+		return ErrNotFound
+	}
+	l.notify()
+
+	return nil
+}
+
+// Decline turns down a challenge to the player in a seat.
+func (l *Lobby) Decline(id, seat string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.remove(func(s Seek) bool { return s.ID == id && s.Opponent != "" && s.Opponent == seat }) {
+		return ErrNotFound
+	}
+	l.notify()
+
+	return nil
+}
+
+// Withdraw closes every seek a player has open and forgets any match they
+// haven't picked up, for when they leave.
+func (l *Lobby) Withdraw(seat string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.matches, seat)
+	if l.remove(func(s Seek) bool { return s.Seat == seat }) {
+		l.notify()
+	}
+}
+
+// Seeks lists the open seeks, oldest first.
+func (l *Lobby) Seeks() []Seek {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(time.Now())
+	return slices.Clone(l.seeks)
+}
+
+// Matched returns the game started for a player whose seek was matched while
+// it waited in the lobby. Each match is only returned once.
+func (l *Lobby) Matched(seat string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id, ok := l.matches[seat]
+	delete(l.matches, seat)
+
+	return id, ok
+}
+
+// Listen marks a bot as listening for challenges until the returned function
+// is called. A bot can listen more than once, and stays listed until it stops
+// every time.
+func (l *Lobby) Listen(bot Bot) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.listeners[bot.Seat]++
+	l.bots[bot.Seat] = bot
+	if l.listeners[bot.Seat] == 1 {
+		l.notify()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.listeners[bot.Seat]--
+			if l.listeners[bot.Seat] == 0 {
+				delete(l.listeners, bot.Seat)
+				delete(l.bots, bot.Seat)
+				l.notify()
+			}
+		})
+	}
+}
+
+// Bots lists the bots listening for challenges, by name.
+func (l *Lobby) Bots() []Bot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bots := make([]Bot, 0, len(l.bots))
+	for _, bot := range l.bots {
+		bots = append(bots, bot)
+	}
+	slices.SortFunc(bots, func(a, b Bot) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) })
+
+	return bots
+}
+
+// Listening reports whether the player in a seat is a bot listening for
+// challenges.
+func (l *Lobby) Listening(seat string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.bots[seat]
+	return ok
+}
+
+// pair starts the game between a seek waiting in the lobby and the player
+// who matched it, who is told the game's ID directly. The lobby must be
+// locked.
+func (l *Lobby) pair(open, seek Seek) (string, error) {
+	colours := Colours[open.Game]
+	seats := [2]string{open.Seat, seek.Seat}
+
+	switch {
+	case open.Colour == colours[1], open.Colour == "" && seek.Colour == colours[0]:
+		seats = [2]string{seek.Seat, open.Seat}
+	case open.Colour == "" && seek.Colour == "" && rand.Intn(2) == 1:
+		seats = [2]string{seek.Seat, open.Seat}
+	}
+
+	var clock *utils.Clock
+	if initial, increment, timed := utils.ParseTimeControl(open.Clock); timed {
+		clock = utils.NewClock(colours, initial, increment)
+	}
+
+	entry, err := l.Service.Pair(open.Game, seats, clock, open.Rated)
+	if err != nil {
+		return "", err
+	}
+
+	l.remove(func(s Seek) bool { return s.Seat == open.Seat || s.Seat == seek.Seat })
+	l.matches[open.Seat] = entry.ID
+	l.notify()
+
+	return entry.ID, nil
+}
+
+// remove drops the seeks that match, returning whether there were any. The
+// lobby must be locked.
+func (l *Lobby) remove(match func(Seek) bool) bool {
+	before := len(l.seeks)
+	l.seeks = slices.DeleteFunc(l.seeks, match)
+
+	return len(l.seeks) != before
+}
+
+// prune drops seeks that have been open longer than SeekTTL. The lobby must
+// be locked.
+func (l *Lobby) prune(now time.Time) {
+	if l.remove(func(s Seek) bool { return now.Sub(s.Posted) > l.SeekTTL }) {
+		l.notify()
+	}
+}
+
+// Subscribe returns a channel that is sent to whenever the lobby changes,
+// and a function to stop listening.
+func (l *Lobby) Subscribe() (<-chan struct{}, func()) {
+	l.subscribersMu.Lock()
+	defer l.subscribersMu.Unlock()
+
+	updates := make(chan struct{}, 1)
+	if l.subscribers == nil {
+		l.subscribers = make(map[chan struct{}]struct{})
+	}
+	l.subscribers[updates] = struct{}{}
+
+	return updates, func() {
+		l.subscribersMu.Lock()
+		defer l.subscribersMu.Unlock()
+
+		if _, ok := l.subscribers[updates]; ok {
+			delete(l.subscribers, updates)
+			close(updates)
+		}
+	}
+}
+
+func (l *Lobby) notify() {
+	l.subscribersMu.Lock()
+	defer l.subscribersMu.Unlock()
+
+	for updates := range l.subscribers {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package lobby
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+type fixedRatings map[string]int
+
+func (r fixedRatings) Rating(seat, category string) int {
+	return r[seat]
+}
+
+func newLobby(ratings Ratings) *Lobby {
+	l := New(service.New(store.New(time.Hour, 0)))
+	l.Ratings = ratings
+	return l
+}
+
+func TestMatches(t *testing.T) {
+	seek := Seek{Seat: "a", Game: "chess", Clock: "5+3", Rating: 1500, Range: 100}
+
+	tests := []struct {
+		name     string
+		other    Seek
+		expected bool
+	}{
+		{"same seek", Seek{Seat: "b", Game: "chess", Clock: "5+3", Rating: 1550}, true},
+		{"same player", Seek{Seat: "a", Game: "chess", Clock: "5+3", Rating: 1500}, false},
+		{"other game", Seek{Seat: "b", Game: "tictactoe", Clock: "5+3", Rating: 1500}, false},
+		{"other clock", Seek{Seat: "b", Game: "chess", Rating: 1500}, false},
+		{"rated", Seek{Seat: "b", Game: "chess", Clock: "5+3", Rated: true, Rating: 1500}, false},
+		{"out of range", Seek{Seat: "b", Game: "chess", Clock: "5+3", Rating: 1700}, false},
+		{"narrow range", Seek{Seat: "b", Game: "chess", Clock: "5+3", Rating: 1450, Range: 25}, false},
+	}
+
+	for _, test := range tests {
+		if actual := seek.Matches(test.other); actual != test.expected {
+			t.Errorf("%s: Expected match (%t) != actual match (%t)", test.name, test.expected, actual)
+		}
+	}
+
+	white := Seek{Seat: "a", Game: "chess", Colour: "White"}
+	if white.Matches(Seek{Seat: "b", Game: "chess", Colour: "White"}) {
+		t.Errorf("Expected two players wanting White not to match")
+	}
+	if !white.Matches(Seek{Seat: "b", Game: "chess", Colour: "Black"}) {
+		t.Errorf("Expected White and Black to match")
+	}
+}
+
+func TestPostMatches(t *testing.T) {
+	l := newLobby(nil)
+	updates, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+
+	id, err := l.Post(Seek{Seat: "a", Game: "chess", Clock: "3+2", Colour: "Black"})
+	if err != nil || id != "" {
+		t.Fatalf("Expected the first seek to wait, got game %q and error %v", id, err)
+	}
+	select {
+	case <-updates:
+	default:
+		t.Errorf("Expected subscribers to hear about the new seek")
+	}
+	if seeks := l.Seeks(); len(seeks) != 1 || seeks[0].Rating != DefaultRating {
+		t.Fatalf("Expected one seek at the default rating, got %v", seeks)
+	}
+
+	id, err = l.Post(Seek{Seat: "b", Game: "chess", Clock: "3+2"})
+	if err != nil || id == "" {
+		t.Fatalf("Expected the second seek to start a game, got error %v", err)
+	}
+	if len(l.Seeks()) != 0 {
+		t.Errorf("Expected matched seeks to leave the lobby")
+	}
+
+	matched, ok := l.Matched("a")
+	if !ok || matched != id {
+		t.Errorf("Expected matched game (%s) != actual matched game (%s)", id, matched)
+	}
+	if _, ok := l.Matched("a"); ok {
+		t.Errorf("Expected a match to be picked up only once")
+	}
+
+	entry, err := l.Service.Games.Get(id)
+	if err != nil {
+		t.Fatalf("Expected the game to be stored, got %s", err)
+	}
+	entry.Lock()
+	defer entry.Unlock()
+
+	data := entry.Data
+	if data.Seats["White"] != "b" || data.Seats["Black"] != "a" {
+		t.Errorf("Expected a to play Black as asked, got seats %v", data.Seats)
+	}
+	if data.Clock == nil || data.Clock.Running != 0 {
+		t.Errorf("Expected White's 3+2 clock to be running")
+	}
+	if data.Waiting() || !data.Started {
+		t.Errorf("Expected the game to be under way")
+	}
+}
+
+func TestPostRatingRange(t *testing.T) {
+	l := newLobby(fixedRatings{"a": 1500, "b": 1900, "c": 1550})
+
+	l.Post(Seek{Seat: "a", Game: "tictactoe", Rated: true, Range: 200})
+	id, _ := l.Post(Seek{Seat: "b", Game: "tictactoe", Rated: true})
+	if id != "" {
+		t.Errorf("Expected a 1900 player to be out of range")
+	}
+	if len(l.Seeks()) != 2 {
+		t.Fatalf("Expected both seeks to wait, got %d", len(l.Seeks()))
+	}
+
+	id, _ = l.Post(Seek{Seat: "c", Game: "tictactoe", Rated: true})
+	if id == "" {
+		t.Fatalf("Expected a 1550 player to match the 1500 player")
+	}
+	entry, _ := l.Service.Games.Get(id)
+	entry.Lock()
+	defer entry.Unlock()
+	if !entry.Data.Rated {
+		t.Errorf("Expected the game to be rated")
+	}
+	if seeks := l.Seeks(); len(seeks) != 1 || seeks[0].Seat != "b" {
+		t.Errorf("Expected only b's seek left, got %v", seeks)
+	}
+}
+
+func TestAcceptAndCancel(t *testing.T) {
+	l := newLobby(fixedRatings{"a": 1500, "b": 1500, "c": 1800})
+
+	l.Post(Seek{Seat: "a", Game: "chess", Range: 100})
+	seek := l.Seeks()[0]
+
+	if _, err := l.Accept(seek.ID, "a", ""); !errors.Is(err, ErrOwnSeek) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrOwnSeek, err)
+	}
+	if _, err := l.Accept(seek.ID, "c", ""); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrOutOfRange, err)
+	}
+	if err := l.Cancel(seek.ID, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotFound, err)
+	}
+
+	id, err := l.Accept(seek.ID, "b", "")
+	if err != nil {
+		t.Fatalf("Expected b to accept the seek, got %s", err)
+	}
+	if _, err := l.Accept(seek.ID, "c", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotFound, err)
+	}
+	if matched, _ := l.Matched("a"); matched != id {
+		t.Errorf("Expected matched game (%s) != actual matched game (%s)", id, matched)
+	}
+
+	l.Post(Seek{Seat: "a", Game: "chess"})
+	if err := l.Cancel(l.Seeks()[0].ID, "a"); err != nil {
+		t.Errorf("Expected no error cancelling a seek, got %s", err)
+	}
+	if len(l.Seeks()) != 0 {
+		t.Errorf("Expected the cancelled seek to leave the lobby")
+	}
+
+	l.Post(Seek{Seat: "a", Game: "tictactoe"})
+	l.Withdraw("a")
+	if len(l.Seeks()) != 0 {
+		t.Errorf("Expected a player's seeks to leave the lobby with them")
+	}
+}
+
+func TestPostReplacesAndExpires(t *testing.T) {
+	l := newLobby(nil)
+
+	l.Post(Seek{Seat: "a", Game: "chess"})
+	l.Post(Seek{Seat: "a", Game: "tictactoe"})
+	seeks := l.Seeks()
+	if len(seeks) != 1 || seeks[0].Game != "tictactoe" {
+		t.Fatalf("Expected the new seek to replace the old one, got %v", seeks)
+	}
+
+	if _, err := l.Post(Seek{Seat: "a", Game: "checkers"}); !errors.Is(err, ErrUnknownGame) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrUnknownGame, err)
+	}
+
+	l.mu.Lock()
+	l.seeks[0].Posted = time.Now().Add(-l.SeekTTL - time.Minute)
+	l.mu.Unlock()
+	if len(l.Seeks()) != 0 {
+		t.Errorf("Expected an old seek to expire")
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	l := newLobby(nil)
+
+	if _, err := l.Post(Seek{Seat: "a", Game: "chess", Opponent: "bot", OpponentName: "bot"}); !errors.Is(err, ErrNotOnline) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotOnline, err)
+	}
+
+	l.Listen(Bot{Seat: "bot", Name: "bot"})
+	l.Post(Seek{Seat: "a", Game: "chess", Opponent: "bot", OpponentName: "bot"})
+	if id, _ := l.Post(Seek{Seat: "c", Game: "chess"}); id != "" {
+		t.Errorf("Expected a challenge not to match an open seek")
+	}
+
+	challenge := l.Seeks()[0]
+	if challenge.For("c") || !challenge.For("bot") || !challenge.For("a") {
+		t.Errorf("Expected only the challenger and the challenged to see the challenge")
+	}
+	if _, err := l.Accept(challenge.ID, "c", ""); !errors.Is(err, ErrNotYours) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotYours, err)
+	}
+	if err := l.Decline(challenge.ID, "c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotFound, err)
+	}
+
+	id, err := l.Accept(challenge.ID, "bot", "bot")
+	if err != nil {
+		t.Fatalf("Expected the bot to accept the challenge, got %s", err)
+	}
+	if matched, _ := l.Matched("a"); matched != id {
+		t.Errorf("Expected matched game (%s) != actual matched game (%s)", id, matched)
+	}
+
+	l.Post(Seek{Seat: "a", Game: "tictactoe", Opponent: "bot", OpponentName: "bot"})
+	for _, seek := range l.Seeks() {
+		if seek.Seat == "a" {
+			challenge = seek
+		}
+	}
+	if err := l.Decline(challenge.ID, "bot"); err != nil {
+		t.Errorf("Expected no error declining a challenge, got %s", err)
+	}
+	if _, err := l.Accept(challenge.ID, "bot", "bot"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotFound, err)
+	}
+}
+
+func TestListen(t *testing.T) {
+	l := newLobby(nil)
+
+	stop := l.Listen(Bot{Seat: "bot", Name: "bot"})
+	again := l.Listen(Bot{Seat: "bot", Name: "bot"})
+	if !l.Listening("bot") || len(l.Bots()) != 1 {
+		t.Fatalf("Expected the bot to be listed once")
+	}
+
+	stop()
+	stop()
+	if !l.Listening("bot") {
+		t.Errorf("Expected the bot to be listed while it has a stream open")
+	}
+
+	again()
+	if l.Listening("bot") || len(l.Bots()) != 0 {
+		t.Errorf("Expected the bot to be unlisted once it stops listening")
+	}
+}
@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/jfosburgh/gomes/internal/metrics"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+var (
+	httpSeconds = metrics.Default.Histogram(
+		"gomes_http_request_seconds",
+		"How long HTTP requests took, by route and status code. Event streams count for as long as they were open.",
+		metrics.DefaultBuckets,
+		"route", "code",
+	)
+	sshSessions = metrics.Default.Gauge(
+		"gomes_ssh_sessions",
+		"SSH sessions open, by whether the player signed in with a key or as a guest.",
+		"auth",
+	)
+	sshSessionsTotal = metrics.Default.Counter(
+		"gomes_ssh_sessions_total",
+		"SSH sessions opened, by whether the player signed in with a key or as a guest.",
+		"auth",
+	)
+	sshSessionSeconds = metrics.Default.Histogram(
+		"gomes_ssh_session_seconds",
+		"How long SSH sessions were open.",
+		[]float64{1, 10, 60, 300, 900, 1800, 3600, 3 * 3600},
+	)
+)
+
+// registerGameMetrics adds the metrics that are read from the games as
+// they're served: the games going and the bot's searches.
+func registerGameMetrics(games *store.GameStore, gameService *service.Service) {
+	metrics.Default.GaugeFunc(
+		"gomes_store_games",
+		"Games in the store, finished or not, until they expire.",
+		nil,
+		func(set func(float64, ...string)) {
+			set(float64(games.Len()))
+		},
+	)
+	metrics.Default.GaugeFunc(
+		"gomes_games_active",
+		"Games in the store that haven't finished, by kind of game.",
+		[]string{"game"},
+		func(set func(float64, ...string)) {
+			active := map[string]int{"chess": 0, "tictactoe": 0}
+			for _, entry := range games.Entries() {
+				entry.Lock()
+				if !entry.Data.Ended {
+					active[service.GameName(entry.Game)]++
+				}
+				entry.Unlock()
+			}
+			for game, count := range active {
+				set(float64(count), game)
+			}
+		},
+	)
+	metrics.Default.GaugeFunc(
+		"gomes_bot_searches",
+		"The bot's searches, by whether they're waiting for a worker or running.",
+		[]string{"state"},
+		func(set func(float64, ...string)) {
+			queued, running := gameService.BotSearches()
+			set(float64(queued), "queued")
+			set(float64(running), "running")
+		},
+	)
+}
+
+// statusRecorder remembers the status code a handler responds with, and
+// still lets event streams flush.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// instrument times the requests served by router, labelled by the pattern
+// they matched, so that every game's page counts as the same route.
+func instrument(router *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := router.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		router.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		httpSeconds.Since(start, route, strconv.Itoa(recorder.status))
+	})
+}
+
+// handleHealthz reports that the server is up.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// handleReadyz reports whether the server is taking new games, which it
+// stops doing once it starts shutting down.
+func handleReadyz(gameService *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-gameService.Closing():
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ready\n"))
+		}
+	}
+}
+
+// sessionMetrics counts the SSH sessions open and times them.
+func sessionMetrics() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			auth := "guest"
+			if sess.PublicKey() != nil {
+				auth = "key"
+			}
+
+			start := time.Now()
+			sshSessionsTotal.Inc(auth)
+			sshSessions.Add(1, auth)
+			defer func() {
+				sshSessions.Add(-1, auth)
+				sshSessionSeconds.Since(start)
+			}()
+
+			next(sess)
+		}
+	}
+}
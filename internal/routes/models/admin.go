@@ -0,0 +1,264 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+)
+
+// searchTimeStep is how much the admin mode changes the bot's search time by
+// with each key press.
+const searchTimeStep = time.Second
+
+// adminRefreshMsg refreshes the admin mode that was opened at the given
+// time, so that refreshes left from an earlier visit are dropped. It has its
+// own message so that it isn't taken for a game's clock tick once a game has
+// been opened.
+type adminRefreshMsg struct {
+	opened time.Time
+}
+
+func refreshAdmin(opened time.Time) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return adminRefreshMsg{opened}
+	})
+}
+
+// ModelAdmin is the admin mode, for players whose keys are on the admin
+// allowlist: the bot's load and limits, and the games going and sessions
+// open, to watch, end or kick. It's refreshed every second.
+type ModelAdmin struct {
+	WindowParams
+	Client
+	cursor   int
+	sessions bool
+	games    []admin.Game
+	open     []admin.Session
+	load     admin.Load
+	notice   string
+	opened   time.Time
+}
+
+func NewAdmin(params WindowParams, client Client) ModelAdmin {
+	m := ModelAdmin{WindowParams: params, Client: client, opened: time.Now()}
+	m.refresh()
+
+	return m
+}
+
+func (m *ModelAdmin) refresh() {
+	m.games = m.Admin.Games()
+	m.open = m.Admin.Sessions.List()
+	m.load = m.Admin.Load()
+
+	rows := len(m.games)
+	if m.sessions {
+		rows = len(m.open)
+	}
+	m.cursor = min(m.cursor, max(rows-1, 0))
+}
+
+// name is the admin's name, for the log of what admins have done.
+func (m ModelAdmin) name() string {
+	return m.Accounts.Name(m.Seat)
+}
+
+func (m ModelAdmin) Init() tea.Cmd {
+	return refreshAdmin(m.opened)
+}
+
+func (m ModelAdmin) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case adminRefreshMsg:
+		if !msg.opened.Equal(m.opened) {
+			break
+		}
+		m.refresh()
+		return m, refreshAdmin(m.opened)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			m.cursor++
+		case "tab":
+			m.sessions = !m.sessions
+			m.cursor = 0
+		case "[", "]":
+			step := searchTimeStep
+			if msg.String() == "[" {
+				step = -step
+			}
+			m.setBotLimits(m.load.MaxDepth, max(m.load.MaxSearchTime+step, 0))
+		case "{", "}":
+			step := 1
+			if msg.String() == "{" {
+				step = -1
+			}
+			m.setBotLimits(max(m.load.MaxDepth+step, 0), m.load.MaxSearchTime)
+		case "q", "ctrl+c":
+			return NewHome(m.WindowParams, m.Client), nil
+		default:
+			if m.sessions {
+				m.updateSession(msg.String())
+			} else if next, cmd := m.updateGame(msg.String()); next != nil {
+				return next, cmd
+			}
+		}
+		m.refresh()
+	}
+
+	return m, nil
+}
+
+// updateGame acts on the selected game, returning the model to switch to if
+// the admin has opened it to watch.
+func (m *ModelAdmin) updateGame(key string) (tea.Model, tea.Cmd) {
+	if len(m.games) == 0 {
+		return nil, nil
+	}
+	game := m.games[m.cursor]
+
+	if key == "enter" || key == " " {
+		entry, err := m.Service.Games.Get(game.ID)
+		if err != nil {
+			m.notice = fmt.Sprintf("Can't watch game %s, %s", game.ID, err)
+			return nil, nil
+		}
+		entry.Lock()
+		defer entry.Unlock()
+
+		return openGame(m.WindowParams, m.Client, entry, true)
+	}
+
+	var err error
+	switch key {
+	case "a":
+		err = m.Admin.Abort(game.ID, m.name())
+		m.notice = fmt.Sprintf("Aborted game %s", game.ID)
+	case "1", "2":
+		winner := game.Sides[0]
+		if key == "2" {
+			winner = game.Sides[1]
+		}
+		err = m.Admin.Adjudicate(game.ID, winner, m.name())
+		m.notice = fmt.Sprintf("Adjudicated game %s as a win for %s", game.ID, winner)
+	case "d":
+		err = m.Admin.Adjudicate(game.ID, "", m.name())
+		m.notice = fmt.Sprintf("Adjudicated game %s as a draw", game.ID)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		m.notice = fmt.Sprintf("Can't end game %s, %s", game.ID, err)
+	}
+
+	return nil, nil
+}
+
+// updateSession acts on the selected session.
+func (m *ModelAdmin) updateSession(key string) {
+	if key != "x" || len(m.open) == 0 {
+		return
+	}
+	session := m.open[m.cursor]
+
+	kicked, err := m.Admin.Kick(session.ID, m.name())
+	if err != nil {
+		m.notice = fmt.Sprintf("Can't kick %s, %s", session.Name, err)
+		return
+	}
+	m.notice = fmt.Sprintf("Kicked %s, closing %d sessions", session.Name, kicked)
+}
+
+func (m *ModelAdmin) setBotLimits(depth int, searchTime time.Duration) {
+	err := m.Admin.SetBotLimits(depth, searchTime, m.name())
+	if err != nil {
+		m.notice = fmt.Sprintf("Can't change the bot's limits, %s", err)
+		return
+	}
+	m.notice = ""
+}
+
+// limitText describes a bot limit, where zero is no limit.
+func limitText[T comparable](limit T) string {
+	var none T
+	if limit == none {
+		return "none"
+	}
+
+	return fmt.Sprint(limit)
+}
+
+func (m ModelAdmin) View() string {
+	s := m.TxtStyle.Render("Admin")
+
+	load := fmt.Sprintf("Bot: %d of %d workers searching, %d queued", m.load.Running, m.load.Workers, m.load.Queued)
+	load += fmt.Sprintf("\nMax depth %s, max search time %s", limitText(m.load.MaxDepth), limitText(m.load.MaxSearchTime))
+	s += "\n\n" + m.TxtStyle.Render(load)
+
+	lines := []string{}
+	if m.sessions {
+		lines = append(lines, fmt.Sprintf("Sessions (%d)", len(m.open)))
+		for i, session := range m.open {
+			cursor := " "
+			if i == m.cursor {
+				cursor = ">"
+			}
+
+			where := "lobby"
+			if session.Game != "" {
+				where = "game " + session.Game
+			}
+			if session.Front == "ssh" {
+				where = "TUI"
+			}
+			lines = append(lines, fmt.Sprintf("%s %-7s %-16s %-15s %-13s %s", cursor, session.Front, session.Name, session.Addr, where, time.Since(session.Opened).Round(time.Second)))
+		}
+		if len(m.open) == 0 {
+			lines = append(lines, "No sessions open.")
+		}
+	} else {
+		lines = append(lines, fmt.Sprintf("Games (%d)", len(m.games)))
+		for i, game := range m.games {
+			cursor := " "
+			if i == m.cursor {
+				cursor = ">"
+			}
+
+			// enough of the ID to tell games apart, which enter opens
+			id := game.ID[:min(len(game.ID), 8)]
+			lines = append(lines, fmt.Sprintf("%s %-8s %-11s %-6s %-40s %3d moves %2d watching", cursor, id, game.Title, game.Mode, game.Players, game.Moves, game.Spectators))
+		}
+		if len(m.games) == 0 {
+			lines = append(lines, "No games going.")
+		}
+	}
+	s += "\n\n" + m.TxtStyle.Render(strings.Join(lines, "\n"))
+
+	if m.notice != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.notice)
+	}
+
+	optionText := ""
+	if m.sessions {
+		optionText += "Press 'x' to kick the player\n"
+	} else {
+		optionText += "Press '<enter>' to watch, 'a' to abort"
+		optionText += "\nPress '1' or '2' to adjudicate a win for the first or second side, 'd' for a draw\n"
+	}
+	optionText += "Press '[' or ']' to change the max search time, '{' or '}' the max depth"
+	optionText += "\nPress '<tab>' to switch between games and sessions"
+	optionText += "\nPress 'q' to go home\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/routes/archive"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+const (
+	// archiveSize is how many finished games the archive lists.
+	archiveSize = 15
+
+	// replayLines is how many lines of moves a replay shows at once.
+	replayLines = 8
+)
+
+// ModelArchive lists recently finished games to replay, either the player's
+// own or everyone's.
+type ModelArchive struct {
+	WindowParams
+	Client
+	cursor int
+	all    bool
+	games  []archive.Game
+}
+
+// NewArchive lists a signed in player's own games, and everyone's for a
+// guest.
+func NewArchive(params WindowParams, client Client) ModelArchive {
+	_, signedIn := client.Accounts.ForSeat(client.Seat)
+	m := ModelArchive{WindowParams: params, Client: client, all: !signedIn}
+	m.load()
+
+	return m
+}
+
+func (m *ModelArchive) load() {
+	var match func(archive.Game) bool
+	if !m.all {
+		seat := m.Seat
+		match = func(g archive.Game) bool { return g.Played(seat) }
+	}
+
+	m.games = m.Archive.Recent(archiveSize, match)
+	m.cursor = min(m.cursor, max(len(m.games)-1, 0))
+}
+
+func (m ModelArchive) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelArchive) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.games)-1 {
+				m.cursor++
+			}
+		case "tab":
+			m.all = !m.all
+			m.cursor = 0
+			m.load()
+		case "enter", " ":
+			if len(m.games) == 0 {
+				break
+			}
+			return NewReplay(m.WindowParams, m.Client, m.games[m.cursor], m), nil
+		case "q", "ctrl+c":
+			return NewHome(m.WindowParams, m.Client), nil
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelArchive) View() string {
+	title := "Your games"
+	if m.all {
+		title = "Everyone's games"
+	}
+	s := m.TxtStyle.Render(title)
+
+	lines := []string{}
+	for i, game := range m.games {
+		cursor := " "
+		if i == m.cursor {
+			cursor = ">"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %-11s %-48s %-28s %s", cursor, lobby.Titles[game.Game], game.Matchup(), game.Outcome(), game.Ended.Format("2006-01-02")))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No finished games yet.")
+	}
+	s += "\n\n" + m.TxtStyle.Render(strings.Join(lines, "\n"))
+
+	optionText := "Press '<enter>' to replay a game"
+	optionText += "\nPress '<tab>' to switch between your games and everyone's"
+	optionText += "\nPress 'q' to go home\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
+
+// ModelReplay steps through a finished game a move at a time, going back to
+// where it was opened from when it's done.
+type ModelReplay struct {
+	WindowParams
+	Client
+	game     archive.Game
+	notation []string
+	ply      int
+	frame    archive.Frame
+	back     tea.Model
+}
+
+// NewReplay replays an archived game from its final position. A nil back
+// returns home.
+func NewReplay(params WindowParams, client Client, game archive.Game, back tea.Model) ModelReplay {
+	m := ModelReplay{
+		WindowParams: params,
+		Client:       client,
+		game:         game,
+		notation:     game.Notation(),
+		back:         back,
+	}
+	m.seek(len(game.Moves))
+
+	return m
+}
+
+func (m *ModelReplay) seek(ply int) {
+	frame, err := m.game.Replay(ply)
+	if err != nil {
+		return
+	}
+
+	m.frame = frame
+	m.ply = frame.Ply
+}
+
+func (m ModelReplay) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelReplay) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "left", "h":
+			m.seek(m.ply - 1)
+		case "right", "l":
+			m.seek(m.ply + 1)
+		case "up", "k":
+			m.seek(m.ply - 2)
+		case "down", "j":
+			m.seek(m.ply + 2)
+		case "home", "g":
+			m.seek(0)
+		case "end", "G":
+			m.seek(len(m.game.Moves))
+		case "q", "ctrl+c":
+			if m.back == nil {
+				return NewHome(m.WindowParams, m.Client), nil
+			}
+			return m.back.Update(tea.WindowSizeMsg{Width: m.Width, Height: m.Height})
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelReplay) View() string {
+	t := m.board() + "\n"
+	t = lipgloss.JoinVertical(lipgloss.Center, t, m.TxtStyle.Render(m.game.Progress(m.ply)))
+	t = lipgloss.JoinVertical(lipgloss.Center, t, "", m.TxtStyle.Render(m.moves()))
+
+	s := m.TxtStyle.Render(lobby.Titles[m.game.Game]+" replay") + "\n" + m.TxtStyle.Render(m.game.Matchup())
+
+	optionText := "Press 'left' or 'right' to step a move, 'up' or 'down' for a whole turn"
+	optionText += "\nPress 'home' or 'end' to go to the start or the end"
+	optionText += "\nPress 'q' to go back\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+fmt.Sprintf("\n\n%+s\n", t)+m.QuitStyle.Render(optionText))
+}
+
+// board draws the replay's position, with the last move highlighted.
+func (m ModelReplay) board() string {
+	data := &utils.TwoPlayerGame{Started: true, Ended: true}
+	cells := []utils.Cell{}
+	width := 0
+	last := map[int]bool{}
+
+	switch game := m.frame.Game.(type) {
+	case *chess.ChessGame:
+		cells = utils.FillChessCells(game, data, -1, false)
+		width = 8
+		for _, square := range m.frame.Last {
+			last[utils.FlipRank(square)] = true
+		}
+	case *tictactoe.TicTacToeGame:
+		cells = utils.FillTTTCells(game, data)
+		width = 3
+		for _, cell := range m.frame.Last {
+			last[cell] = true
+		}
+	}
+
+	t := ""
+	row := ""
+	for i, cell := range cells {
+		content := cell.Content
+		if content == "_" {
+			content = ""
+		}
+		block := lipgloss.Place(2, 1, lipgloss.Center, lipgloss.Center, content)
+
+		bg := m.TxtStyle.GetForeground()
+		switch {
+		case last[i]:
+			bg = lipgloss.Color("58")
+		case width == 8 && strings.Contains(cell.Classes, "black"):
+			bg = m.QuitStyle.GetForeground()
+		case width == 3 && i%2 == 0:
+			bg = m.QuitStyle.GetForeground()
+		}
+
+		row = lipgloss.JoinHorizontal(lipgloss.Center, row, lipgloss.NewStyle().Background(bg).Foreground(lipgloss.Color("16")).Inherit(m.TxtStyle).Render(block))
+
+		if i%width == width-1 {
+			t = lipgloss.JoinVertical(lipgloss.Center, t, row)
+			row = ""
+		}
+	}
+
+	return t
+}
+
+// moves lists the moves a turn to a line, marking the last one played and
+// showing the lines around it.
+func (m ModelReplay) moves() string {
+	lines := []string{}
+	for i := 0; i < len(m.notation); i += 2 {
+		line := fmt.Sprintf("%3d.", i/2+1)
+		for j := i; j < i+2 && j < len(m.notation); j++ {
+			move := fmt.Sprintf(" %-8s", m.notation[j])
+			if j+1 == m.ply {
+				move = fmt.Sprintf("[%s]", m.notation[j])
+				move = fmt.Sprintf("%-9s", move)
+			}
+			line += move
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "No moves were played"
+	}
+
+	current := max(m.ply-1, 0) / 2
+	start := max(min(current-replayLines/2, len(lines)-replayLines), 0)
+	end := min(start+replayLines, len(lines))
+
+	return strings.Join(lines[start:end], "\n")
+}
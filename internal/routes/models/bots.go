@@ -0,0 +1,234 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+)
+
+// ModelBots lists the bot accounts a player owns, and registers new ones.
+// A bot's token is only shown when it's made, so it can be replaced with a
+// new one if it's lost.
+type ModelBots struct {
+	WindowParams
+	Client
+	owner  string
+	bots   []accounts.Account
+	cursor int
+
+	naming bool
+	name   string
+	status string
+}
+
+func NewBots(params WindowParams, client Client) ModelBots {
+	m := ModelBots{WindowParams: params, Client: client}
+	if account, ok := client.Accounts.ForSeat(client.Seat); ok {
+		m.owner = account.ID
+	}
+	m.load()
+
+	return m
+}
+
+func (m *ModelBots) load() {
+	m.bots = m.Accounts.Bots(m.owner)
+	m.cursor = min(m.cursor, max(len(m.bots)-1, 0))
+}
+
+func (m ModelBots) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelBots) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		if m.naming {
+			return m.updateName(msg)
+		}
+
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.bots)-1 {
+				m.cursor++
+			}
+		case "n":
+			m.naming = true
+			m.name = ""
+			m.status = ""
+		case "t":
+			if len(m.bots) == 0 {
+				break
+			}
+
+			bot := m.bots[m.cursor]
+			token, err := m.Accounts.NewToken(bot.ID, m.owner)
+			if err != nil {
+				m.status = err.Error()
+				break
+			}
+			m.status = tokenText(bot.Name, token)
+		case "q", "ctrl+c":
+			return ModelProfile{WindowParams: m.WindowParams, Client: m.Client}, nil
+		}
+	}
+
+	return m, nil
+}
+
+// updateName takes the name of a new bot a key at a time.
+func (m ModelBots) updateName(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		bot, token, err := m.Accounts.RegisterBot(strings.TrimSpace(m.name), m.owner)
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+
+		m.naming = false
+		m.load()
+		m.status = tokenText(bot.Name, token)
+	case tea.KeyBackspace:
+		if len(m.name) > 0 {
+			m.name = m.name[:len(m.name)-1]
+		}
+	case tea.KeyEsc:
+		m.naming = false
+		m.status = ""
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyRunes:
+		m.name += string(msg.Runes)
+	}
+
+	return m, nil
+}
+
+func tokenText(name, token string) string {
+	return fmt.Sprintf("%s's token is\n\n%s\n\nKeep it secret, it won't be shown again", name, token)
+}
+
+func (m ModelBots) View() string {
+	s := m.TxtStyle.Render("Your bots")
+
+	if m.naming {
+		s += "\n\n" + m.TxtStyle.Render("Pick a name for your bot:")
+		s += "\n\n" + m.TxtStyle.Render("> "+m.name+"_")
+		if m.status != "" {
+			s += "\n\n" + m.TxtStyle.Render(m.status)
+		}
+
+		optionText := "Press '<enter>' to create the bot"
+		optionText += "\nPress 'esc' to go back\n"
+
+		return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+	}
+
+	lines := []string{}
+	for i, bot := range m.bots {
+		cursor := " "
+		if i == m.cursor {
+			cursor = ">"
+		}
+
+		online := ""
+		if m.Lobby.Listening(bot.Seat()) {
+			online = "online"
+		}
+		lines = append(lines, fmt.Sprintf("%s %-20s %-6s %d games", cursor, bot.Name, online, len(bot.History)))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No bots yet.")
+	}
+	s += "\n\n" + m.TxtStyle.Render(strings.Join(lines, "\n"))
+
+	if m.status != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.status)
+	}
+
+	optionText := fmt.Sprintf("Press 'n' to create a bot, up to %d", accounts.MaxBots)
+	optionText += "\nPress 't' to replace the selected bot's token"
+	optionText += "\nPress 'q' to go back\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
+
+// ModelChallenge lists the bots taking challenges, and sets up a challenge
+// to the one picked.
+type ModelChallenge struct {
+	WindowParams
+	Client
+	bots   []lobby.Bot
+	cursor int
+}
+
+func NewChallenge(params WindowParams, client Client) ModelChallenge {
+	return ModelChallenge{WindowParams: params, Client: client, bots: client.Lobby.Bots()}
+}
+
+func (m ModelChallenge) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelChallenge) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.bots)-1 {
+				m.cursor++
+			}
+		case "enter", " ":
+			if len(m.bots) == 0 {
+				break
+			}
+			return ModelSeek{WindowParams: m.WindowParams, Client: m.Client, opponent: m.bots[m.cursor]}, nil
+		case "q", "ctrl+c":
+			return NewLobby(m.WindowParams, m.Client)
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelChallenge) View() string {
+	s := m.TxtStyle.Render("Challenge a bot")
+
+	lines := []string{}
+	for i, bot := range m.bots {
+		cursor := " "
+		if i == m.cursor {
+			cursor = ">"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %s", cursor, bot.Name))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No bots are taking challenges right now.")
+	}
+	s += "\n\n" + m.TxtStyle.Render(strings.Join(lines, "\n"))
+
+	optionText := "Press '<enter>' to challenge the selected bot"
+	optionText += "\nPress 'q' to go back to the lobby\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
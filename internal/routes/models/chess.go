@@ -7,24 +7,25 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/routes/service"
 	"github.com/jfosburgh/gomes/internal/routes/utils"
 	"github.com/jfosburgh/gomes/pkg/chess"
 )
 
 type ModelChess struct {
 	WindowParams
+	Client
+	subscription
 	game *chess.ChessGame
 	data *utils.TwoPlayerGame
 
 	boardCursorX int
 	boardCursorY int
 
-	botTurn bool
-	botChan chan struct{}
-
 	promoteCursor int
 	promote       bool
 	promoteData   []string
+	promoteCells  []utils.Cell
 
 	moveSrc int
 }
@@ -34,65 +35,69 @@ func (m ModelChess) Init() tea.Cmd {
 }
 
 func (m ModelChess) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.entry.Lock()
+	defer m.entry.Unlock()
+
+	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.Height = msg.Height
 		m.Width = msg.Width
-	case responseMsg:
-		move := m.game.BestMove()
-		m.game.MakeMove(move)
-		m.data.Active = utils.ChessNames[m.game.EBE.Active]
-
-		m.data.Cells = utils.FillChessCells(m.game, m.data, -1, false)
-		m.data.Ended = len(m.game.GetLegalMoves()) == 0 || m.game.EBE.Halfmoves >= 100
-		if m.data.Ended {
-			if m.game.EBE.Halfmoves >= 100 {
-				m.data.Status = "Enforcing 50-move rule, it's a tie"
-			} else {
-				m.data.Status = fmt.Sprintf("%s Wins!", utils.ChessNames[^(m.game.EBE.Active)&0b1])
-			}
-		} else {
-			m.data.Status = fmt.Sprintf("%s played %s, %s's Turn!", utils.ChessNames[^m.game.EBE.Active&0b1], move, m.data.Active)
+	case updateMsg:
+		cmd = waitForUpdate(m.updates)
+	case tickMsg:
+		if !m.data.Ended {
+			cmd = tick()
 		}
+	case tea.KeyMsg:
+		botTurn := service.BotTurn(m.data)
 
-		m.botTurn = m.data.Active != m.data.Player && m.data.Player != "" && !m.data.Ended
-		if m.botTurn {
-			go func() {
-				m.botChan <- responseMsg{}
-			}()
-			return m, tea.Batch(waitForActivity(m.botChan), nil)
+		key := msg.String()
+		if m.watching && key != "q" && key != "ctrl+c" && key != "v" {
+			break
 		}
-	case tea.KeyMsg:
-		switch msg.String() {
+
+		switch key {
 		case "r":
-			if !m.data.Ended {
+			if !m.data.Ended || m.data.Online {
 				break
 			}
 
+			// the finished game is kept with its result and the replay is
+			// stored as a new game
 			nextGame := chess.NewGame()
+			nextGame.MaxSearchDepth = m.game.MaxSearchDepth
 			nextGame.SearchTime = m.game.SearchTime
-			m.game = nextGame
+			nextData := *m.data
 
-			m.data.Ended = false
-			m.data.Active = "White"
-			m.data.Status = "White goes first!"
+			nextData.Ended = false
+			nextData.Result = ""
+			nextData.Termination = ""
+			nextData.Active = "White"
+			nextData.Status = "White goes first!"
 
-			m.data.Cells = utils.FillChessCells(m.game, m.data, -1, false)
+			nextData.Cells = utils.FillChessCells(nextGame, &nextData, -1, false)
 
-			m.promote = false
-			m.moveSrc = -1
-
-			m.botTurn = m.data.Active != m.data.Player && m.data.Player != "" && !m.data.Ended
-			if m.botTurn {
-				go func() {
-					m.botChan <- responseMsg{}
-				}()
-				return m, tea.Batch(waitForActivity(m.botChan), nil)
+			if err := m.allowGame(nextData.Player != ""); err != nil {
+				m.notice = err.Error()
+				break
+			}
+			entry, err := m.Service.Games.Add(nextGame, &nextData)
+			if err != nil {
+				m.data.Status = err.Error()
+				break
 			}
+			m.Service.Games.Remove(m.entry.ID)
+			m.unsubscribe()
+
+			entry.Lock()
+			defer entry.Unlock()
+			m.Service.StartBot(entry)
+			return openGame(m.WindowParams, m.Client, entry, false)
 		case "up", "k":
 			switch {
 			case m.promote:
-			case m.botTurn:
+			case botTurn:
 			default:
 				if m.boardCursorY > 0 {
 					m.boardCursorY--
@@ -101,7 +106,7 @@ func (m ModelChess) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "down", "j":
 			switch {
 			case m.promote:
-			case m.botTurn:
+			case botTurn:
 			default:
 				if m.boardCursorY < 7 {
 					m.boardCursorY++
@@ -109,7 +114,7 @@ func (m ModelChess) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "left", "h":
 			switch {
-			case m.botTurn:
+			case botTurn:
 			case m.promote:
 				if m.promoteCursor > 0 {
 					m.promoteCursor--
@@ -121,7 +126,7 @@ func (m ModelChess) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "right", "l":
 			switch {
-			case m.botTurn:
+			case botTurn:
 			case m.promote:
 				if m.promoteCursor < 3 {
 					m.promoteCursor++
@@ -132,11 +137,8 @@ func (m ModelChess) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		case "enter", " ":
-			// switch {
-			// case m.botTurn:
-			// default:
 			move := m.boardCursorY*8 + m.boardCursorX
-			if !m.data.Cells[move].Clickable && !m.promote {
+			if !m.view.Cells[move].Clickable && !m.promote {
 				return m, nil
 			}
 
@@ -153,36 +155,18 @@ func (m ModelChess) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 
 				if m.promote {
-					fmt.Println("applying promotion")
 					m.promote = false
 					side := m.game.EBE.Active << 3
 					gameMove.Promotion = side | []int{chess.KNIGHT, chess.ROOK, chess.BISHOP, chess.QUEEN}[m.promoteCursor]
 				} else if gameMove.Promotion != chess.EMPTY {
-					fmt.Println("promoting next choice")
+					// the pawn is only moved to show the choice of pieces,
+					// the game itself is left alone until one is picked
 					m.promote = true
 					gameMove.Promotion = gameMove.Piece
-				}
-				m.game.MakeMove(gameMove)
-				if !m.promote {
-					m.data.Active = utils.ChessNames[m.game.EBE.Active]
-				}
-
-				m.data.Cells = utils.FillChessCells(m.game, m.data, -1, m.promote)
-				checkmate := len(m.game.GetLegalMoves()) == 0
-				draw := !checkmate && m.game.EBE.Halfmoves >= 100
-				m.data.Ended = checkmate || draw
-				if m.data.Ended {
-					m.data.Status = fmt.Sprintf("%s Wins!", utils.ChessNames[^(m.game.EBE.Active)&0b1])
-					if draw {
-						m.data.Status = "Enforcing 50-move rule, it's a tie!"
-					}
-				} else {
-					m.data.Status = fmt.Sprintf("%s played %s, %s's Turn!", utils.ChessNames[^m.game.EBE.Active&0b1], gameMove, m.data.Active)
-				}
-
-				if m.promote {
-					m.data.Status = fmt.Sprintf("%s, choose your promotion!", m.data.Active)
+					m.game.MakeMove(gameMove)
+					m.promoteCells = utils.FillChessCells(m.game, m.data, -1, true)
 					m.game.UnmakeMove(gameMove)
+
 					side := m.game.EBE.Active << 3
 					m.promoteData = []string{
 						utils.ChessPieces[side|chess.KNIGHT],
@@ -190,35 +174,63 @@ func (m ModelChess) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						utils.ChessPieces[side|chess.BISHOP],
 						utils.ChessPieces[side|chess.QUEEN],
 					}
-				} else {
-					m.moveSrc = -1
+					break
 				}
+
+				m.moveSrc = -1
+				if m.Service.CheckTurn(m.entry, m.Seat) != nil {
+					break
+				}
+				if err := m.allowMove(m.data); err != nil {
+					m.notice = err.Error()
+					break
+				}
+				m.notice = ""
+				m.Service.PlayChess(m.entry, gameMove)
 			case m.moveSrc == -1:
 				m.moveSrc = move
 				location := utils.FlipRank(move)
 				m.data.Cells = utils.FillChessCells(m.game, m.data, location, false)
 			}
-			m.botTurn = m.data.Active != m.data.Player && m.data.Player != "" && !m.data.Ended
-			if m.botTurn {
-				go func() {
-					m.botChan <- responseMsg{}
-				}()
-				return m, tea.Batch(waitForActivity(m.botChan), nil)
+		case "v":
+			game, ok := m.Archive.Get(m.entry.ID)
+			if !m.data.Ended || !ok {
+				break
 			}
-			// }
+
+			m.unsubscribe()
+			if !m.data.Online && !m.watching {
+				m.Service.Games.Remove(m.entry.ID)
+			}
+			return NewReplay(m.WindowParams, m.Client, game, nil), nil
 		case "q", "ctrl+c":
-			return ModelHome{
-				WindowParams: m.WindowParams,
-				Games:        []string{"Chess", "Tic-Tac-Toe"},
-			}, nil
+			m.unsubscribe()
+			// online games carry on without this session, for the other
+			// player to finish or abandon, and spectators leave games as
+			// they found them
+			if !m.data.Online && !m.watching {
+				m.Service.Games.Remove(m.entry.ID)
+			}
+			return NewHome(m.WindowParams, m.Client), nil
 		}
 	}
 
-	return m, nil
+	m.refresh(m.Seat)
+	if m.promote {
+		if m.view.Ended {
+			m.promote = false
+			m.moveSrc = -1
+		} else {
+			m.view.Cells = m.promoteCells
+			m.view.Status = fmt.Sprintf("%s, choose your promotion!", m.view.Active)
+		}
+	}
+
+	return m, cmd
 }
 
 func (m ModelChess) View() string {
-	cells := m.data.Cells
+	cells := m.view.Cells
 	cursorIndex := m.boardCursorX + m.boardCursorY*8
 	t := ""
 	row := ""
@@ -272,15 +284,24 @@ func (m ModelChess) View() string {
 
 	t += "\n"
 
-	status := m.data.Status
-	if m.botTurn {
-		status += " Bot is thinking..."
+	status := m.view.Status
+	if service.BotTurn(&m.view) {
+		status += " Bot is thinking..." + queueText(m.view)
 	}
 	t = lipgloss.JoinVertical(lipgloss.Center, t, m.TxtStyle.Render(status))
+	if m.notice != "" {
+		t = lipgloss.JoinVertical(lipgloss.Center, t, m.TxtStyle.Render(m.notice))
+	}
+	if info := gameInfo(m.view, service.SeatNames(m.game)); info != "" {
+		t = lipgloss.JoinVertical(lipgloss.Center, t, "", m.TxtStyle.Render(info))
+	}
 
 	optionText := ""
-	if m.data.Ended {
-		optionText += "\nPress 'r' to replay"
+	if m.view.Ended && !m.view.Online && !m.watching {
+		optionText += "\nPress 'r' to play again"
+	}
+	if m.view.Ended {
+		optionText += "\nPress 'v' to view the replay"
 	}
 	optionText += "\nPress 'q' to return home\n"
 
@@ -289,7 +310,9 @@ func (m ModelChess) View() string {
 
 type ModelChessSettings struct {
 	WindowParams
-	page int
+	Client
+	status string
+	page   int
 
 	modes      []string
 	modeCursor int
@@ -357,81 +380,80 @@ func (m ModelChessSettings) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch m.page {
 			case 0:
 				if m.modes[m.modeCursor] == "Player vs. Player" {
-					next := ModelChess{
-						WindowParams: m.WindowParams,
-						game:         chess.NewGame(),
-						data:         &utils.TwoPlayerGame{},
-
-						moveSrc: -1,
-					}
-
-					next.data.Active = "White"
-
-					next.data.Started = true
-					next.data.Cells = utils.FillChessCells(next.game, next.data, -1, false)
-
-					next.data.Status = "White goes first!"
-
-					return next, nil
+					return m.newGame(chess.NewGame(), "")
 				}
 				m.page = 1
 			case 1:
+				if m.modes[m.modeCursor] == "Play Online" {
+					return m.newGame(chess.NewGame(), m.players[m.playerCursor])
+				}
 				m.page = 2
 			case 2:
 				m.page = 3
 			case 3:
-				next := ModelChess{
-					WindowParams: m.WindowParams,
-					game:         chess.NewGame(),
-					data:         &utils.TwoPlayerGame{},
-
-					moveSrc: -1,
-					botChan: make(chan struct{}),
-				}
-
-				next.data.Active = "White"
-				next.game.MaxSearchDepth = m.depths[m.depthCursor]
-				next.game.SearchTime = time.Duration(m.times[m.timeCursor]) * time.Second
+				game := chess.NewGame()
+				game.MaxSearchDepth = m.depths[m.depthCursor]
+				game.SearchTime = time.Duration(m.times[m.timeCursor]) * time.Second
 
+				player := "neither"
 				if m.modes[m.modeCursor] == "Player vs. Bot" {
-					next.data.Player = m.players[m.playerCursor]
-				} else {
-					next.data.Player = "neither"
+					player = m.players[m.playerCursor]
 				}
 
-				next.data.Started = true
-				next.data.Cells = utils.FillChessCells(next.game, next.data, -1, false)
-
-				next.data.Status = "White goes first!"
-				next.botTurn = next.data.Active != next.data.Player && next.data.Player != "" && !next.data.Ended
-
-				if next.botTurn {
-					go func() {
-						next.botChan <- responseMsg{}
-					}()
-				}
-
-				return next, waitForActivity(next.botChan)
+				return m.newGame(game, player)
 			}
 		case "N", "p":
 			if m.page == 0 {
-				return ModelHome{
-					WindowParams: m.WindowParams,
-					Games:        []string{"Chess", "Tic-Tac-Toe"},
-				}, nil
+				return NewHome(m.WindowParams, m.Client), nil
 			}
 			m.page = max(0, m.page-1)
 		case "q", "ctrl+c":
-			return ModelHome{
-				WindowParams: m.WindowParams,
-				Games:        []string{"Chess", "Tic-Tac-Toe"},
-			}, nil
+			return NewHome(m.WindowParams, m.Client), nil
 		}
 	}
 
 	return m, nil
 }
 
+// newGame stores a new game and starts playing it. The player is the colour
+// played against the bot, the colour taken in an online game, or empty when
+// both sides are played from this session.
+func (m ModelChessSettings) newGame(game *chess.ChessGame, player string) (tea.Model, tea.Cmd) {
+	data := &utils.TwoPlayerGame{
+		Active:  "White",
+		Started: true,
+		Status:  "White goes first!",
+	}
+
+	online := m.modes[m.modeCursor] == "Play Online"
+	if !online {
+		data.Player = player
+		data.Owner = m.Seat
+	}
+	data.Cells = utils.FillChessCells(game, data, -1, false)
+
+	if err := m.allowGame(data.Player != ""); err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	entry, err := m.Service.Games.Add(game, data)
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	if online {
+		service.Host(entry, player, m.Seat, nil)
+	}
+	m.Service.StartBot(entry)
+
+	return openGame(m.WindowParams, m.Client, entry, false)
+}
+
 func (m ModelChessSettings) View() string {
 	s := "Chess"
 	s += "\n\nChoose your settings:\n"
@@ -478,6 +500,8 @@ func (m ModelChessSettings) View() string {
 		s = lipgloss.JoinVertical(lipgloss.Left, s, m.QuitStyle.Render(playerString))
 	}
 
+	bot := m.modes[m.modeCursor] != "Player vs. Player" && m.modes[m.modeCursor] != "Play Online"
+
 	depthString := "\nSearch Depth:\n"
 	for i, depth := range m.depths {
 		cursor := " "
@@ -495,7 +519,7 @@ func (m ModelChessSettings) View() string {
 		depthString += fmt.Sprintf(" %s %d ply\n", cursor, depth)
 	}
 
-	if m.modes[m.modeCursor] != "Player vs. Player" {
+	if bot {
 		s = lipgloss.JoinVertical(lipgloss.Left, s, m.TxtStyle.Render(depthString))
 	} else {
 		s = lipgloss.JoinVertical(lipgloss.Left, s, m.QuitStyle.Render(depthString))
@@ -518,11 +542,15 @@ func (m ModelChessSettings) View() string {
 		timeString += fmt.Sprintf(" %s %ds\n", cursor, seconds)
 	}
 
-	if m.modes[m.modeCursor] != "Player vs. Player" {
+	if bot {
 		s = lipgloss.JoinVertical(lipgloss.Left, s, m.TxtStyle.Render(timeString))
 	} else {
 		s = lipgloss.JoinVertical(lipgloss.Left, s, m.QuitStyle.Render(timeString))
 	}
 
+	if m.status != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.status)
+	}
+
 	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render("Press 'q' to go home\n"))
 }
@@ -10,9 +10,32 @@ import (
 type ModelHome struct {
 	cursor int
 	Games  []string
+	Client
 	WindowParams
 }
 
+func NewHome(params WindowParams, client Client) ModelHome {
+	m := ModelHome{
+		WindowParams: params,
+		Games: []string{
+			"Chess",
+			"Tic-Tac-Toe",
+			"Lobby",
+			"Join a Game",
+			"Watch a Game",
+			"Archive",
+			"Leaderboards",
+			"Profile",
+		},
+		Client: client,
+	}
+	if client.Admin != nil && client.Admin.Allowed(client.Key) {
+		m.Games = append(m.Games, "Admin")
+	}
+
+	return m
+}
+
 func (m ModelHome) Init() tea.Cmd {
 	return nil
 }
@@ -37,9 +60,11 @@ func (m ModelHome) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "Tic-Tac-Toe":
 				next := ModelTTTSettings{
 					WindowParams: m.WindowParams,
+					Client:       m.Client,
 					modes: []string{
 						"Player vs. Player",
 						"Player vs. Bot",
+						"Play Online",
 					},
 					players: []string{
 						"X",
@@ -61,10 +86,12 @@ func (m ModelHome) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "Chess":
 				next := ModelChessSettings{
 					WindowParams: m.WindowParams,
+					Client:       m.Client,
 					modes: []string{
 						"Player vs. Player",
 						"Player vs. Bot",
 						"Bot vs. Bot",
+						"Play Online",
 					},
 					players: []string{
 						"White",
@@ -88,6 +115,21 @@ func (m ModelHome) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					},
 				}
 				return next, nil
+			case "Lobby":
+				return NewLobby(m.WindowParams, m.Client)
+			case "Join a Game":
+				return ModelJoin{WindowParams: m.WindowParams, Client: m.Client}, nil
+			case "Watch a Game":
+				return ModelJoin{WindowParams: m.WindowParams, Client: m.Client, watch: true}, nil
+			case "Archive":
+				return NewArchive(m.WindowParams, m.Client), nil
+			case "Leaderboards":
+				return ModelLeaderboard{WindowParams: m.WindowParams, Client: m.Client}, nil
+			case "Profile":
+				return ModelProfile{WindowParams: m.WindowParams, Client: m.Client}, nil
+			case "Admin":
+				next := NewAdmin(m.WindowParams, m.Client)
+				return next, next.Init()
 			}
 		case "q", "ctrl+c":
 			return m, tea.Quit
@@ -99,6 +141,9 @@ func (m ModelHome) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m ModelHome) View() string {
 	s := fmt.Sprintf("Welcome to gomes.sh, the best place to play games in the terminal")
+	if account, ok := m.Accounts.ForSeat(m.Seat); ok {
+		s += fmt.Sprintf("\n\nSigned in as %s", account.Name)
+	}
 	s += "\n\nWhat would you like to play?\n"
 
 	for i, game := range m.Games {
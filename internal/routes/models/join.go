@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+// ModelJoin takes a seat in an online game by its ID, whether it was started
+// in a browser or over SSH, or with watch set follows any game as a
+// spectator.
+type ModelJoin struct {
+	WindowParams
+	Client
+	watch  bool
+	id     string
+	status string
+}
+
+func (m ModelJoin) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelJoin) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyEnter:
+			return m.join()
+		case tea.KeyBackspace:
+			if len(m.id) > 0 {
+				m.id = m.id[:len(m.id)-1]
+			}
+		case tea.KeyEsc, tea.KeyCtrlC:
+			return NewHome(m.WindowParams, m.Client), nil
+		case tea.KeyRunes:
+			// game IDs are hex, so 'q' is free to go home as everywhere else
+			if msg.String() == "q" {
+				return NewHome(m.WindowParams, m.Client), nil
+			}
+			m.id += strings.ToLower(string(msg.Runes))
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelJoin) join() (tea.Model, tea.Cmd) {
+	entry, err := m.Service.Games.Get(strings.TrimSpace(m.id))
+	if err != nil {
+		m.status = "Couldn't find that game"
+		if !errors.Is(err, store.ErrNotFound) {
+			m.status = err.Error()
+		}
+		return m, nil
+	}
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	if !m.watch {
+		_, err = m.Service.Join(entry, m.Seat)
+		if err != nil {
+			m.status = fmt.Sprintf("Can't join, %s", err.Error())
+			return m, nil
+		}
+	}
+
+	return openGame(m.WindowParams, m.Client, entry, m.watch)
+}
+
+func (m ModelJoin) View() string {
+	title, prompt, action := "Join a Game", "Enter the ID of the game you were invited to:", "join"
+	if m.watch {
+		title, prompt, action = "Watch a Game", "Enter the ID of the game you want to watch:", "watch"
+	}
+
+	s := m.TxtStyle.Render(title)
+	s += "\n\n" + m.TxtStyle.Render(prompt)
+	s += "\n\n" + m.TxtStyle.Render("> "+m.id+"_")
+
+	if m.status != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.status)
+	}
+
+	optionText := fmt.Sprintf("Press '<enter>' to %s", action)
+	optionText += "\nPress 'q' to go home\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
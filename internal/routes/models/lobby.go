@@ -0,0 +1,353 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+)
+
+// ModelLobby lists the open seeks in the lobby shared with the browser, and
+// opens the game as soon as one of this session's seeks is matched.
+type ModelLobby struct {
+	WindowParams
+	Client
+	updates     <-chan struct{}
+	unsubscribe func()
+
+	seeks  []lobby.Seek
+	cursor int
+	status string
+}
+
+func NewLobby(params WindowParams, client Client) (tea.Model, tea.Cmd) {
+	updates, unsubscribe := client.Lobby.Subscribe()
+	m := ModelLobby{
+		WindowParams: params,
+		Client:       client,
+		updates:      updates,
+		unsubscribe:  unsubscribe,
+	}
+
+	return m.refresh()
+}
+
+func (m ModelLobby) Init() tea.Cmd {
+	return nil
+}
+
+// refresh picks up the latest seeks, or the game if this session's seek has
+// been matched.
+func (m ModelLobby) refresh() (tea.Model, tea.Cmd) {
+	if id, matched := m.Lobby.Matched(m.Seat); matched {
+		return m.openGame(id)
+	}
+
+	m.seeks = []lobby.Seek{}
+	for _, seek := range m.Lobby.Seeks() {
+		if seek.For(m.Seat) {
+			m.seeks = append(m.seeks, seek)
+		}
+	}
+	m.cursor = min(m.cursor, max(0, len(m.seeks)-1))
+
+	return m, waitForUpdate(m.updates)
+}
+
+func (m ModelLobby) openGame(id string) (tea.Model, tea.Cmd) {
+	next, cmd, err := openMatch(m.WindowParams, m.Client, id)
+	if err != nil {
+		m.status = err.Error()
+		return m, waitForUpdate(m.updates)
+	}
+
+	m.unsubscribe()
+	return next, cmd
+}
+
+// openMatch opens a game started for this session in the lobby.
+func openMatch(params WindowParams, client Client, id string) (tea.Model, tea.Cmd, error) {
+	entry, err := client.Service.Games.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	next, cmd := openGame(params, client, entry, false)
+	return next, cmd, nil
+}
+
+func (m ModelLobby) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case updateMsg:
+		return m.refresh()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.seeks)-1 {
+				m.cursor++
+			}
+		case "enter", " ":
+			if len(m.seeks) == 0 {
+				break
+			}
+
+			if err := m.allowGame(false); err != nil {
+				m.status = err.Error()
+				break
+			}
+			id, err := m.Lobby.Accept(m.seeks[m.cursor].ID, m.Seat, m.Accounts.Name(m.Seat))
+			if err != nil {
+				m.status = err.Error()
+				break
+			}
+			return m.openGame(id)
+		case "x":
+			if len(m.seeks) == 0 {
+				break
+			}
+
+			err := m.Lobby.Cancel(m.seeks[m.cursor].ID, m.Seat)
+			if err != nil {
+				m.status = "You can only cancel your own seek"
+			}
+		case "n":
+			m.unsubscribe()
+			return ModelSeek{WindowParams: m.WindowParams, Client: m.Client}, nil
+		case "c":
+			m.unsubscribe()
+			return NewChallenge(m.WindowParams, m.Client), nil
+		case "q", "ctrl+c":
+			m.unsubscribe()
+			return NewHome(m.WindowParams, m.Client), nil
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelLobby) View() string {
+	s := m.TxtStyle.Render("Lobby")
+
+	lines := []string{}
+	for i, seek := range m.seeks {
+		cursor := " "
+		if i == m.cursor {
+			cursor = ">"
+		}
+
+		line := fmt.Sprintf(" %s %-20s %-12s %-8s %-7s %-7s %5d  %s", cursor, seek.Name, seek.Title(), seek.TimeControl(), seek.Side(), seek.Mode(), seek.Rating, seek.Opponents())
+		switch {
+		case seek.Seat == m.Seat:
+			line += "  (yours)"
+		case !seek.Accepts(m.Lobby.Rating(m.Seat, seek.Category())):
+			line += "  (out of range)"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No one is looking for a game right now.")
+	}
+	s += "\n\n" + m.TxtStyle.Render(strings.Join(lines, "\n"))
+
+	if m.status != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.status)
+	}
+
+	optionText := "Press '<enter>' to play the selected seek"
+	optionText += "\nPress 'n' to post a seek, 'x' to cancel yours"
+	optionText += "\nPress 'c' to challenge a bot"
+	optionText += "\nPress 'q' to go home\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
+
+// seekOptions are the pages of the seek form, in order. The time control is
+// skipped for tic-tac-toe, which isn't played against the clock.
+var seekOptions = []struct {
+	title   string
+	choices []string
+}{
+	{"Game", []string{"Chess", "Tic-Tac-Toe"}},
+	{"Time Control", []string{"Untimed", "3+2", "5+0", "10+5", "15+10"}},
+	{"Play As", []string{"Random", "White / X", "Black / O"}},
+	{"Mode", []string{"Casual", "Rated"}},
+	{"Opponents", []string{"Any rating", "Rating +/-100", "Rating +/-200", "Rating +/-400"}},
+}
+
+const (
+	seekClockPage = 1
+	seekRangePage = 4
+)
+
+var (
+	seekGames  = []string{"chess", "tictactoe"}
+	seekClocks = []string{"", "3+2", "5+0", "10+5", "15+10"}
+	seekRanges = []int{0, 100, 200, 400}
+)
+
+// ModelSeek posts a seek to the lobby, going straight to the game if it
+// matches one that's already waiting. With an opponent it challenges that
+// bot instead, skipping the rating range.
+type ModelSeek struct {
+	WindowParams
+	Client
+	status   string
+	opponent lobby.Bot
+
+	page    int
+	cursors [5]int
+}
+
+func (m ModelSeek) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelSeek) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursors[m.page] > 0 {
+				m.cursors[m.page]--
+			}
+		case "down", "j":
+			if m.cursors[m.page] < len(seekOptions[m.page].choices)-1 {
+				m.cursors[m.page]++
+			}
+		case "enter", " ", "n":
+			m.page++
+			for m.page < len(seekOptions) && m.skipped(m.page) {
+				m.page++
+			}
+			if m.page == len(seekOptions) {
+				return m.post()
+			}
+		case "N", "p":
+			if m.page == 0 {
+				return NewLobby(m.WindowParams, m.Client)
+			}
+			m.page--
+			if m.skipped(m.page) {
+				m.page--
+			}
+		case "q", "ctrl+c":
+			return NewLobby(m.WindowParams, m.Client)
+		}
+	}
+
+	return m, nil
+}
+
+// timed reports whether the chosen game can be played against the clock.
+func (m ModelSeek) timed() bool {
+	return seekGames[m.cursors[0]] == "chess"
+}
+
+// skipped reports whether a page of the form doesn't apply to this seek.
+func (m ModelSeek) skipped(page int) bool {
+	return (page == seekClockPage && !m.timed()) || (page == seekRangePage && m.opponent.Seat != "")
+}
+
+func (m ModelSeek) post() (tea.Model, tea.Cmd) {
+	seek := lobby.Seek{
+		Seat:  m.Seat,
+		Name:  m.Accounts.Name(m.Seat),
+		Game:  seekGames[m.cursors[0]],
+		Rated: m.cursors[3] == 1,
+		Range: seekRanges[m.cursors[4]],
+	}
+	if m.timed() {
+		seek.Clock = seekClocks[m.cursors[1]]
+	}
+	if side := m.cursors[2]; side > 0 {
+		seek.Colour = lobby.Colours[seek.Game][side-1]
+	}
+	if m.opponent.Seat != "" {
+		seek.Opponent = m.opponent.Seat
+		seek.OpponentName = m.opponent.Name
+	}
+
+	if err := m.allowGame(false); err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	id, err := m.Lobby.Post(seek)
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	if id != "" {
+		next, cmd, err := openMatch(m.WindowParams, m.Client, id)
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		return next, cmd
+	}
+
+	next, cmd := NewLobby(m.WindowParams, m.Client)
+	if lobbyModel, ok := next.(ModelLobby); ok {
+		lobbyModel.status = "Your seek is posted, waiting for an opponent..."
+		if seek.Opponent != "" {
+			lobbyModel.status = fmt.Sprintf("Your challenge is sent, waiting for %s to answer...", seek.OpponentName)
+		}
+		return lobbyModel, cmd
+	}
+	return next, cmd
+}
+
+func (m ModelSeek) View() string {
+	title := "Post a Seek"
+	if m.opponent.Seat != "" {
+		title = "Challenge " + m.opponent.Name
+	}
+	s := m.TxtStyle.Render(title + "\n\nChoose your settings:\n")
+
+	for page, option := range seekOptions {
+		text := fmt.Sprintf("\n%s:\n", option.title)
+		for i, choice := range option.choices {
+			cursor := " "
+			if i == m.cursors[page] && page <= m.page {
+				cursor = "*"
+				if page == m.page {
+					cursor = ">"
+				}
+			}
+
+			text += fmt.Sprintf(" %s %s\n", cursor, choice)
+		}
+
+		style := m.TxtStyle
+		if m.skipped(page) {
+			style = m.QuitStyle
+		}
+		s = lipgloss.JoinVertical(lipgloss.Left, s, style.Render(text))
+	}
+
+	if m.status != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.status)
+	}
+
+	optionText := "Press '<enter>' or 'n' for next"
+	optionText += "\nPress 'N' or 'p' for previous"
+	optionText += "\nPress 'q' to go back to the lobby\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
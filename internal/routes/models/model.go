@@ -1,6 +1,24 @@
 package models
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/limits"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+	"github.com/jfosburgh/gomes/internal/routes/archive"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
 
 type WindowParams struct {
 	Width     int
@@ -8,3 +26,210 @@ type WindowParams struct {
 	TxtStyle  lipgloss.Style
 	QuitStyle lipgloss.Style
 }
+
+// Client is how an SSH session plays: the game service, lobby, accounts and
+// archive shared with the browser, the fingerprint of the session's public key, if it
+// has one, and the seat ID that binds the session to its colour in online
+// games. Games and moves are rate limited by Limits, keyed by the session's
+// address and seat, the same as the browser. Admin is the admin console,
+// shown to sessions whose keys are on its allowlist.
+type Client struct {
+	Service  *service.Service
+	Lobby    *lobby.Lobby
+	Accounts *accounts.Accounts
+	Archive  *archive.Archive
+	Limits   *limits.Limits
+	Admin    *admin.Admin
+	Key      string
+	Seat     string
+	// IP is the limits key for the session's address.
+	IP string
+}
+
+// allow checks the client against limiters, only counting the action
+// against them if none of them turns it away.
+func (c Client) allow(limiters ...*limits.Limiter) error {
+	return limits.Allow([]string{c.IP, limits.Identity(c.Seat)}, limiters...)
+}
+
+// allowGame checks starting a game, which is also limited as a bot search if
+// it's against the bot.
+func (c Client) allowGame(bot bool) error {
+	if c.Limits == nil {
+		return nil
+	}
+	if bot {
+		return c.allow(c.Limits.Games, c.Limits.Bot)
+	}
+
+	return c.allow(c.Limits.Games)
+}
+
+// allowMove checks moving in a game, which is also limited as a bot search if
+// it's against the bot.
+func (c Client) allowMove(data *utils.TwoPlayerGame) error {
+	if c.Limits == nil {
+		return nil
+	}
+	if !data.Online && data.Player != "" {
+		return c.allow(c.Limits.Moves, c.Limits.Bot)
+	}
+
+	return c.allow(c.Limits.Moves)
+}
+
+// updateMsg tells a game model that someone, a bot, the other player or the
+// clock, has changed its game, or the lobby that its seeks have changed.
+type updateMsg struct{}
+
+// tickMsg redraws a running clock.
+type tickMsg struct{}
+
+func waitForUpdate(updates <-chan struct{}) tea.Cmd {
+	return func() tea.Msg {
+		_, open := <-updates
+		if !open {
+			return nil
+		}
+
+		return updateMsg{}
+	}
+}
+
+// queueText shows that the bot is waiting for a worker to search with.
+func queueText(view utils.TwoPlayerGame) string {
+	switch {
+	case view.Queued == 0:
+		return ""
+	case view.Ahead() == 0:
+		return " (queued)"
+	}
+
+	return fmt.Sprintf(" (queued, %d ahead)", view.Ahead())
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return tickMsg{}
+	})
+}
+
+// subscription follows a stored game for a game model, keeping a copy of the
+// game as this session sees it so that it can be drawn without locking.
+// Spectators follow a game with watching set, and can't move in it.
+type subscription struct {
+	entry       *store.Entry
+	watching    bool
+	updates     <-chan struct{}
+	unsubscribe func()
+	view        utils.TwoPlayerGame
+	// notice is shown to this session only, such as a limit it's hit.
+	notice string
+}
+
+func subscribe(entry *store.Entry, watching bool) subscription {
+	follow := entry.Subscribe
+	if watching {
+		follow = entry.Watch
+	}
+
+	updates, unsubscribe := follow()
+	return subscription{
+		entry:       entry,
+		watching:    watching,
+		updates:     updates,
+		unsubscribe: unsubscribe,
+	}
+}
+
+// refresh copies the game as the given seat sees it. The entry must be
+// locked.
+func (s *subscription) refresh(seat string) {
+	s.view = service.View(s.entry, seat)
+	if s.watching {
+		s.view = service.Watch(s.entry)
+	}
+	s.view.Seats = maps.Clone(s.view.Seats)
+	s.view.Names = maps.Clone(s.view.Names)
+	if s.view.Clock != nil {
+		clock := *s.view.Clock
+		s.view.Clock = &clock
+	}
+}
+
+// follow returns the commands that keep a game model up to date: waiting for
+// the next change, and redrawing a running clock.
+func (s *subscription) follow() tea.Cmd {
+	cmds := []tea.Cmd{waitForUpdate(s.updates)}
+	if s.view.Clock != nil && !s.view.Ended {
+		cmds = append(cmds, tick())
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// openGame returns the model for playing or watching a stored game. The
+// entry must be locked.
+func openGame(params WindowParams, client Client, entry *store.Entry, watching bool) (tea.Model, tea.Cmd) {
+	sub := subscribe(entry, watching)
+	sub.refresh(client.Seat)
+
+	switch game := entry.Game.(type) {
+	case *chess.ChessGame:
+		return ModelChess{
+			WindowParams: params,
+			Client:       client,
+			subscription: sub,
+			game:         game,
+			data:         entry.Data,
+
+			moveSrc: -1,
+		}, sub.follow()
+	case *tictactoe.TicTacToeGame:
+		return ModelTTT{
+			WindowParams: params,
+			Client:       client,
+			subscription: sub,
+			game:         game,
+			data:         entry.Data,
+		}, sub.follow()
+	}
+
+	sub.unsubscribe()
+	return NewHome(params, client), nil
+}
+
+// gameInfo describes a game for the people following it: who's playing, who
+// they're playing as or how their opponent can join, the clock, and how many
+// are watching. Colours are the game's colours in the order they move.
+func gameInfo(view utils.TwoPlayerGame, colours []string) string {
+	lines := []string{}
+	if view.Online && !view.Waiting() && len(colours) == 2 {
+		lines = append(lines, fmt.Sprintf("%s (%s) vs. %s (%s)", view.Names[colours[0]], colours[0], view.Names[colours[1]], colours[1]))
+	}
+
+	switch {
+	case view.Watching:
+		lines = append(lines, "You are watching this game")
+	case view.Waiting():
+		lines = append(lines, fmt.Sprintf("Waiting for an opponent, they can join game %s from a browser at /games/%s/join or over ssh", view.ID, view.ID))
+	case view.Online && view.Player != "Spectator":
+		lines = append(lines, fmt.Sprintf("You are playing %s", view.Player))
+	}
+
+	if view.Clock != nil {
+		for _, face := range view.Clock.Display() {
+			line := fmt.Sprintf("%s %s", face.Side, face.Time())
+			if face.Running {
+				line += " <"
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if view.Spectators > 0 {
+		lines = append(lines, fmt.Sprintf("%d watching", view.Spectators))
+	}
+
+	return strings.Join(lines, "\n")
+}
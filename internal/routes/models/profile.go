@@ -0,0 +1,240 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/rating"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+)
+
+const (
+	// recentGames is how much of a player's history their profile shows.
+	recentGames = 10
+
+	// leaderboardSize is how many players a leaderboard shows.
+	leaderboardSize = 20
+)
+
+// ModelRegister asks a player connecting with a new SSH key for the display
+// name their account will go by.
+type ModelRegister struct {
+	WindowParams
+	Client
+	name   string
+	status string
+}
+
+func (m ModelRegister) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelRegister) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyEnter:
+			account, err := m.Accounts.Register(strings.TrimSpace(m.name), m.Key)
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+
+			m.Seat = account.Seat()
+			return NewHome(m.WindowParams, m.Client), nil
+		case tea.KeyBackspace:
+			if len(m.name) > 0 {
+				m.name = m.name[:len(m.name)-1]
+			}
+		case tea.KeyEsc:
+			// play as a guest this time, and be asked again next time
+			return NewHome(m.WindowParams, m.Client), nil
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyRunes:
+			m.name += string(msg.Runes)
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelRegister) View() string {
+	s := m.TxtStyle.Render("Welcome to gomes.sh!")
+	s += "\n\n" + m.TxtStyle.Render("Pick a name to play under, it'll be kept with your SSH key:")
+	s += "\n\n" + m.TxtStyle.Render("> "+m.name+"_")
+
+	if m.status != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.status)
+	}
+
+	optionText := "Press '<enter>' to create your profile"
+	optionText += "\nPress 'esc' to play as a guest\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
+
+// ModelProfile shows a player's ratings and recent games, gives them a
+// one-time code to sign in to the same account from a browser, and leads to
+// the bots they run.
+type ModelProfile struct {
+	WindowParams
+	Client
+	code string
+}
+
+func (m ModelProfile) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelProfile) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "l":
+			account, ok := m.Accounts.ForSeat(m.Seat)
+			if !ok {
+				break
+			}
+
+			code, err := m.Accounts.NewCode(account.ID)
+			if err != nil {
+				m.code = err.Error()
+				break
+			}
+			m.code = code
+		case "b":
+			if _, ok := m.Accounts.ForSeat(m.Seat); !ok {
+				break
+			}
+			return NewBots(m.WindowParams, m.Client), nil
+		case "q", "ctrl+c":
+			return NewHome(m.WindowParams, m.Client), nil
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelProfile) View() string {
+	account, ok := m.Accounts.ForSeat(m.Seat)
+	if !ok {
+		s := m.TxtStyle.Render("You're playing as a guest.\n\nConnect with an SSH key to keep a profile, with your ratings and games.")
+		return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render("Press 'q' to go home\n"))
+	}
+
+	s := m.TxtStyle.Render(account.Name) + "\n\n" + m.TxtStyle.Render(profileText(account))
+
+	if m.code != "" {
+		s += "\n\n" + m.TxtStyle.Render(fmt.Sprintf("Your code is %s, enter it at /profile in a browser within %d minutes", m.code, int(accounts.CodeTTL.Minutes())))
+	}
+
+	optionText := "Press 'l' to sign in from a browser"
+	optionText += "\nPress 'b' to manage your bots"
+	optionText += "\nPress 'q' to go home\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
+
+// profileText lists a player's ratings and most recent games.
+func profileText(account accounts.Account) string {
+	lines := []string{"Ratings:"}
+	for _, category := range rating.Categories {
+		r := account.Rating(category)
+		if r.Games == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %-18s %-6s %d games", rating.Title(category), r, r.Games))
+	}
+	if len(lines) == 1 {
+		lines = append(lines, "  No rated games yet")
+	}
+
+	lines = append(lines, "", "Recent games:")
+	if len(account.History) == 0 {
+		lines = append(lines, "  None yet")
+	}
+	for i := len(account.History) - 1; i >= 0 && i >= len(account.History)-recentGames; i-- {
+		game := account.History[i]
+		mode := "casual"
+		if game.Rated {
+			mode = fmt.Sprintf("rated %d (%+d)", game.Rating, game.Change)
+		}
+		lines = append(lines, fmt.Sprintf("  %-4s %-12s as %-5s vs. %-20s %s, %s", game.Result, lobby.Titles[game.Game], game.Colour, game.Opponent, mode, game.Ended.Format("2006-01-02")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ModelLeaderboard ranks the players in each rating category, switching
+// between categories with left and right.
+type ModelLeaderboard struct {
+	WindowParams
+	Client
+	category int
+}
+
+func (m ModelLeaderboard) Init() tea.Cmd {
+	return nil
+}
+
+func (m ModelLeaderboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Height = msg.Height
+		m.Width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "left", "h":
+			m.category = (m.category + len(rating.Categories) - 1) % len(rating.Categories)
+		case "right", "l":
+			m.category = (m.category + 1) % len(rating.Categories)
+		case "q", "ctrl+c":
+			return NewHome(m.WindowParams, m.Client), nil
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelLeaderboard) View() string {
+	category := rating.Categories[m.category]
+	own := m.Accounts.Name(m.Seat)
+	s := m.TxtStyle.Render(fmt.Sprintf("< %s >", rating.Title(category)))
+
+	lines := []string{}
+	standings := m.Accounts.Leaderboard(category, leaderboardSize)
+	if len(standings) > 0 {
+		lines = append(lines, fmt.Sprintf("  %3s  %-20s %-7s %-5s", "#", "Player", "Rating", "Games"))
+	}
+	for _, standing := range standings {
+		cursor := " "
+		if standing.Name == own {
+			cursor = ">"
+		}
+
+		r := fmt.Sprint(standing.Rating)
+		if standing.Provisional {
+			r += "?"
+		}
+		lines = append(lines, fmt.Sprintf("%s %3d  %-20s %-7s %-5d", cursor, standing.Rank, standing.Name, r, standing.Games))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody has played a rated game here yet.")
+	}
+	s += "\n\n" + m.TxtStyle.Render(strings.Join(lines, "\n"))
+
+	optionText := "Press 'left' or 'right' to change category"
+	optionText += "\nPress 'q' to go home\n"
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
+}
@@ -5,27 +5,19 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/jfosburgh/gomes/internal/routes/service"
 	"github.com/jfosburgh/gomes/internal/routes/utils"
 	"github.com/jfosburgh/gomes/pkg/tictactoe"
 )
 
 type ModelTTT struct {
 	WindowParams
+	Client
+	subscription
 	game         *tictactoe.TicTacToeGame
 	data         *utils.TwoPlayerGame
 	boardCursorX int
 	boardCursorY int
-	botTurn      bool
-	botChan      chan struct{}
-}
-
-type responseMsg struct{}
-
-func waitForActivity(sub chan struct{}) tea.Cmd {
-	return func() tea.Msg {
-		msg := responseMsg(<-sub)
-		return msg
-	}
 }
 
 func (m ModelTTT) Init() tea.Cmd {
@@ -33,51 +25,62 @@ func (m ModelTTT) Init() tea.Cmd {
 }
 
 func (m ModelTTT) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.entry.Lock()
+	defer m.entry.Unlock()
+
+	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.Height = msg.Height
 		m.Width = msg.Width
-	case responseMsg:
-		move := m.game.BestMove()
-		m.game.MakeMove(move)
-		var winner int
-		m.data.Ended, winner = m.game.GameOver()
-
-		m.data.Active = utils.TTTPieces[m.game.State.Active]
-		m.data.Cells = utils.FillTTTCells(m.game, m.data)
-
-		if m.data.Ended {
-			if winner == 0 {
-				m.data.Status = "It's a tie!"
-			} else {
-				m.data.Status = fmt.Sprintf("%s Wins!", utils.TTTPieces[winner])
-			}
-		} else {
-			m.data.Status = fmt.Sprintf("%s's Turn!", m.data.Active)
+	case updateMsg:
+		cmd = waitForUpdate(m.updates)
+	case tickMsg:
+		if !m.data.Ended {
+			cmd = tick()
 		}
-		m.botTurn = false
 	case tea.KeyMsg:
-		switch msg.String() {
+		key := msg.String()
+		if m.watching && key != "q" && key != "ctrl+c" && key != "v" {
+			break
+		}
+
+		switch key {
 		case "r":
-			if !m.data.Ended {
+			if !m.data.Ended || m.data.Online {
 				break
 			}
 
-			m.game = tictactoe.NewGame()
+			// the finished game is kept with its result and the replay is
+			// stored as a new game
+			nextGame := tictactoe.NewGame()
+			nextGame.SearchDepth = m.game.SearchDepth
+			nextData := *m.data
 
-			m.data.Ended = false
-			m.data.Active = "X"
-			m.data.Status = "X goes first!"
+			nextData.Ended = false
+			nextData.Result = ""
+			nextData.Termination = ""
+			nextData.Active = "X"
+			nextData.Status = "X goes first!"
 
-			m.data.Cells = utils.FillTTTCells(m.game, m.data)
+			nextData.Cells = utils.FillTTTCells(nextGame, &nextData)
 
-			m.botTurn = m.data.Active != m.data.Player && m.data.Player != "" && !m.data.Ended
-			if m.botTurn {
-				go func() {
-					m.botChan <- responseMsg{}
-				}()
-				return m, tea.Batch(waitForActivity(m.botChan), nil)
+			if err := m.allowGame(nextData.Player != ""); err != nil {
+				m.notice = err.Error()
+				break
 			}
+			entry, err := m.Service.Games.Add(nextGame, &nextData)
+			if err != nil {
+				m.data.Status = err.Error()
+				break
+			}
+			m.Service.Games.Remove(m.entry.ID)
+			m.unsubscribe()
+
+			entry.Lock()
+			defer entry.Unlock()
+			m.Service.StartBot(entry)
+			return openGame(m.WindowParams, m.Client, entry, false)
 		case "up", "k":
 			if m.boardCursorY > 0 {
 				m.boardCursorY--
@@ -95,54 +98,51 @@ func (m ModelTTT) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.boardCursorX++
 			}
 		case "enter", " ":
-			// switch {
-			// case m.botTurn:
-			// default:
 			move := m.boardCursorY*3 + m.boardCursorX
-			if !m.data.Cells[move].Clickable {
+			if !m.view.Cells[move].Clickable {
 				return m, nil
 			}
 
-			m.game.MakeMove(move)
-			// }
-
-			var winner int
-			m.data.Ended, winner = m.game.GameOver()
-
-			m.data.Active = utils.TTTPieces[m.game.State.Active]
-			m.data.Cells = utils.FillTTTCells(m.game, m.data)
-
-			if m.data.Ended {
-				if winner == 0 {
-					m.data.Status = "It's a tie!"
-				} else {
-					m.data.Status = fmt.Sprintf("%s Wins!", utils.TTTPieces[winner])
-				}
-			} else {
-				m.data.Status = fmt.Sprintf("%s's Turn!", m.data.Active)
+			if m.Service.CheckTurn(m.entry, m.Seat) != nil {
+				break
+			}
+			if err := m.allowMove(m.data); err != nil {
+				m.notice = err.Error()
+				break
+			}
+			m.notice = ""
+			m.Service.PlayTTT(m.entry, move)
+		case "v":
+			game, ok := m.Archive.Get(m.entry.ID)
+			if !m.data.Ended || !ok {
+				break
 			}
 
-			m.botTurn = m.data.Active != m.data.Player && m.data.Player != "" && !m.data.Ended
-			if m.botTurn {
-				go func() {
-					m.botChan <- responseMsg{}
-				}()
-				return m, tea.Batch(waitForActivity(m.botChan), nil)
+			m.unsubscribe()
+			if !m.data.Online && !m.watching {
+				m.Service.Games.Remove(m.entry.ID)
 			}
+			return NewReplay(m.WindowParams, m.Client, game, nil), nil
 		case "q", "ctrl+c":
-			return ModelHome{
-				WindowParams: m.WindowParams,
-				Games:        []string{"Chess", "Tic-Tac-Toe"},
-			}, nil
+			m.unsubscribe()
+			// online games carry on without this session, for the other
+			// player to finish or abandon, and spectators leave games as
+			// they found them
+			if !m.data.Online && !m.watching {
+				m.Service.Games.Remove(m.entry.ID)
+			}
+			return NewHome(m.WindowParams, m.Client), nil
 		}
 	}
 
-	return m, nil
+	m.refresh(m.Seat)
+
+	return m, cmd
 }
 
 func (m ModelTTT) View() string {
 	t := ""
-	cells := m.data.Cells
+	cells := m.view.Cells
 	cursorIndex := m.boardCursorX + m.boardCursorY*3
 	row := ""
 	for i, cell := range cells {
@@ -171,16 +171,25 @@ func (m ModelTTT) View() string {
 
 	t += "\n"
 
-	status := m.data.Status
-	if m.botTurn {
-		status += " Bot is thinking..."
+	status := m.view.Status
+	if service.BotTurn(&m.view) {
+		status += " Bot is thinking..." + queueText(m.view)
 	}
 
 	t = lipgloss.JoinVertical(lipgloss.Center, t, m.TxtStyle.Render(status))
+	if m.notice != "" {
+		t = lipgloss.JoinVertical(lipgloss.Center, t, m.TxtStyle.Render(m.notice))
+	}
+	if info := gameInfo(m.view, service.SeatNames(m.game)); info != "" {
+		t = lipgloss.JoinVertical(lipgloss.Center, t, "", m.TxtStyle.Render(info))
+	}
 
 	optionText := ""
-	if m.data.Ended {
-		optionText += "\nPress 'r' to replay"
+	if m.view.Ended && !m.view.Online && !m.watching {
+		optionText += "\nPress 'r' to play again"
+	}
+	if m.view.Ended {
+		optionText += "\nPress 'v' to view the replay"
 	}
 	optionText += "\nPress 'q' to return home\n"
 
@@ -189,7 +198,9 @@ func (m ModelTTT) View() string {
 
 type ModelTTTSettings struct {
 	WindowParams
-	page int
+	Client
+	status string
+	page   int
 
 	modes      []string
 	modeCursor int
@@ -246,70 +257,72 @@ func (m ModelTTTSettings) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch m.page {
 			case 0:
 				if m.modes[m.modeCursor] == "Player vs. Player" {
-					next := ModelTTT{
-						WindowParams: m.WindowParams,
-						game:         tictactoe.NewGame(),
-						data:         &utils.TwoPlayerGame{},
-					}
-
-					next.data.Active = "X"
-
-					next.data.Started = true
-					next.data.Cells = utils.FillTTTCells(next.game, next.data)
-
-					next.data.Status = "X goes first!"
-
-					return next, nil
+					return m.newGame(tictactoe.NewGame(), "")
 				}
 				m.page = 1
 			case 1:
+				if m.modes[m.modeCursor] == "Play Online" {
+					return m.newGame(tictactoe.NewGame(), m.players[m.playerCursor])
+				}
 				m.page = 2
 			case 2:
-				next := ModelTTT{
-					WindowParams: m.WindowParams,
-					game:         tictactoe.NewGame(),
-					data:         &utils.TwoPlayerGame{},
-					botChan:      make(chan struct{}),
-				}
+				game := tictactoe.NewGame()
+				game.SearchDepth = m.difficulties[m.difficultyCursor]
 
-				next.data.Active = "X"
-				if m.modes[m.modeCursor] == "Player vs. Bot" {
-					next.data.Player = m.players[m.playerCursor]
-					next.game.SearchDepth = m.difficulties[m.difficultyCursor]
-				}
-
-				next.data.Started = true
-				next.data.Cells = utils.FillTTTCells(next.game, next.data)
-
-				next.data.Status = "X goes first!"
-				next.botTurn = next.data.Active != next.data.Player && next.data.Player != "" && !next.data.Ended
-
-				if next.botTurn {
-					go func() {
-						next.botChan <- responseMsg{}
-					}()
-				}
-				return next, waitForActivity(next.botChan)
+				return m.newGame(game, m.players[m.playerCursor])
 			}
 		case "N", "p":
 			if m.page == 0 {
-				return ModelHome{
-					WindowParams: m.WindowParams,
-					Games:        []string{"Chess", "Tic-Tac-Toe"},
-				}, nil
+				return NewHome(m.WindowParams, m.Client), nil
 			}
 			m.page = max(0, m.page-1)
 		case "q", "ctrl+c":
-			return ModelHome{
-				WindowParams: m.WindowParams,
-				Games:        []string{"Chess", "Tic-Tac-Toe"},
-			}, nil
+			return NewHome(m.WindowParams, m.Client), nil
 		}
 	}
 
 	return m, nil
 }
 
+// newGame stores a new game and starts playing it. The player is the piece
+// played against the bot, the piece taken in an online game, or empty when
+// both sides are played from this session.
+func (m ModelTTTSettings) newGame(game *tictactoe.TicTacToeGame, player string) (tea.Model, tea.Cmd) {
+	data := &utils.TwoPlayerGame{
+		Active:  "X",
+		Started: true,
+		Status:  "X goes first!",
+	}
+
+	online := m.modes[m.modeCursor] == "Play Online"
+	if !online {
+		data.Player = player
+		data.Owner = m.Seat
+	}
+	data.Cells = utils.FillTTTCells(game, data)
+
+	if err := m.allowGame(data.Player != ""); err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	entry, err := m.Service.Games.Add(game, data)
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	if online {
+		service.Host(entry, player, m.Seat, nil)
+	}
+	m.Service.StartBot(entry)
+
+	return openGame(m.WindowParams, m.Client, entry, false)
+}
+
 func (m ModelTTTSettings) View() string {
 	s := "Tic-Tac-Toe"
 	s += "\n\nChoose your settings:\n"
@@ -350,7 +363,7 @@ func (m ModelTTTSettings) View() string {
 		playerString += fmt.Sprintf(" %s %s\n", cursor, player)
 	}
 
-	if m.modes[m.modeCursor] == "Player vs. Bot" {
+	if m.modes[m.modeCursor] != "Player vs. Player" {
 		s = lipgloss.JoinVertical(lipgloss.Left, s, m.TxtStyle.Render(playerString))
 	} else {
 		s = lipgloss.JoinVertical(lipgloss.Left, s, m.QuitStyle.Render(playerString))
@@ -383,5 +396,9 @@ func (m ModelTTTSettings) View() string {
 	optionText += "\nPress 'N', 'p' to go back"
 	optionText += "\nPress 'q' to go home\n"
 
+	if m.status != "" {
+		s += "\n\n" + m.TxtStyle.Render(m.status)
+	}
+
 	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, s+"\n\n"+m.QuitStyle.Render(optionText))
 }
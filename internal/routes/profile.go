@@ -0,0 +1,180 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jfosburgh/gomes/internal/rating"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+)
+
+// profileView is a player's profile page, or for a browser that hasn't been
+// linked to an account, the form to link it. A bot's page names its owner,
+// and has a form to challenge it while it's listening.
+type profileView struct {
+	Name    string
+	Own     bool
+	Linked  bool
+	Bot     bool
+	Owner   string
+	Online  bool
+	Error   string
+	Ratings []ratingView
+	History []historyView
+}
+
+type ratingView struct {
+	Category string
+	Title    string
+	Rating   string
+	Games    int
+}
+
+type historyView struct {
+	accounts.Game
+	Title string
+	// Player is set when the opponent has a profile to link to
+	Player bool
+	Change string
+}
+
+func newProfileView(account accounts.Account) profileView {
+	view := profileView{Name: account.Name, Linked: true}
+
+	for _, category := range rating.Categories {
+		r := account.Rating(category)
+		if r.Games == 0 {
+			continue
+		}
+		view.Ratings = append(view.Ratings, ratingView{category, rating.Title(category), r.String(), r.Games})
+	}
+
+	// newest first
+	for i := len(account.History) - 1; i >= 0; i-- {
+		game := account.History[i]
+		view.History = append(view.History, historyView{
+			Game:   game,
+			Title:  lobby.Titles[game.Game],
+			Player: game.Opponent != "Anonymous" && !strings.HasPrefix(game.Opponent, "Bot ("),
+			Change: fmt.Sprintf("%+d", game.Change),
+		})
+	}
+
+	return view
+}
+
+func (cfg *configdata) renderProfile(w http.ResponseWriter, r *http.Request, view profileView) {
+	err := cfg.Pages["profile"].ExecuteTemplate(w, "base.html", view)
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", "profile", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// handleProfile shows the browser's own profile, once it's been linked to an
+// account with a code from the TUI.
+func (cfg *configdata) handleProfile(w http.ResponseWriter, r *http.Request) {
+	account, ok := cfg.Accounts.ForSession(session(w, r))
+	if !ok {
+		cfg.renderProfile(w, r, profileView{})
+		return
+	}
+
+	view := newProfileView(account)
+	view.Own = true
+	cfg.renderProfile(w, r, view)
+}
+
+func (cfg *configdata) handlePlayer(w http.ResponseWriter, r *http.Request) {
+	account, ok := cfg.Accounts.ByName(r.PathValue("name"))
+	if !ok {
+		http.Error(w, accounts.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	view := newProfileView(account)
+	if own, ok := cfg.Accounts.ForSession(session(w, r)); ok {
+		view.Own = own.ID == account.ID
+	}
+	if account.Bot {
+		view.Bot = true
+		view.Online = cfg.Lobby.Listening(account.Seat())
+		if owner, ok := cfg.Accounts.Get(account.Owner); ok {
+			view.Owner = owner.Name
+		}
+	}
+	cfg.renderProfile(w, r, view)
+}
+
+// handleLink signs the browser in to the account a one-time code was made for.
+// Tries are limited by address alone, since a new session is free to make.
+func (cfg *configdata) handleLink(w http.ResponseWriter, r *http.Request) {
+	if cfg.Limits != nil {
+		if limited := cfg.limit(r, "", cfg.Limits.Links); limited != nil {
+			cfg.respondWithLimit(w, r, limited)
+			return
+		}
+	}
+
+	_, err := cfg.Accounts.Link(r.FormValue("code"), session(w, r))
+	if err != nil {
+		requestLog(r).Info("can't link session", "err", err)
+		w.WriteHeader(http.StatusForbidden)
+		cfg.renderProfile(w, r, profileView{Error: err.Error()})
+		return
+	}
+
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+func (cfg *configdata) handleSignOut(w http.ResponseWriter, r *http.Request) {
+	err := cfg.Accounts.Unlink(session(w, r))
+	if err != nil {
+		requestLog(r).Error("error signing out", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+// leaderboardSize is how many players a leaderboard shows.
+const leaderboardSize = 50
+
+type leaderboardView struct {
+	Title      string
+	Categories []categoryView
+	Standings  []accounts.Standing
+}
+
+type categoryView struct {
+	Category string
+	Title    string
+	Selected bool
+}
+
+// handleLeaderboard ranks the players in a rating category, chess blitz
+// unless another is asked for.
+func (cfg *configdata) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	category := r.URL.Query().Get("category")
+	if !slices.Contains(rating.Categories, category) {
+		category = "chess-blitz"
+	}
+
+	view := leaderboardView{
+		Title:     rating.Title(category),
+		Standings: cfg.Accounts.Leaderboard(category, leaderboardSize),
+	}
+	for _, c := range rating.Categories {
+		view.Categories = append(view.Categories, categoryView{c, rating.Title(c), c == category})
+	}
+
+	err := cfg.Pages["leaderboard"].ExecuteTemplate(w, "base.html", view)
+	if err != nil {
+		requestLog(r).Error("error executing template", "template", "leaderboard", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
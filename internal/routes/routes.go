@@ -1,19 +1,226 @@
 package routes
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/jfosburgh/gomes/internal/config"
+	"github.com/jfosburgh/gomes/internal/limits"
+	"github.com/jfosburgh/gomes/internal/metrics"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+	"github.com/jfosburgh/gomes/internal/routes/archive"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
 	"github.com/jfosburgh/gomes/pkg/chess"
 )
 
-func NewRouter() *http.ServeMux {
+// The web server's own limits, so that slow or idle clients can't hold
+// connections open. Event streams lift the write timeout for themselves,
+// since they're written to for as long as the page is open.
+const (
+	readHeaderTimeout = 10 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+	maxHeaderBytes    = 64 << 10
+)
+
+// Server is the web server and the SSH server, with the games, players and
+// archive they share.
+type Server struct {
+	web       *http.Server
+	redirect  *http.Server
+	ssh       *SSHServer
+	service   *service.Service
+	games     *store.GameStore
+	archive   *archive.Archive
+	stopSweep context.CancelFunc
+}
 
+// NewServer sets up the games, players and lobby as configured, and starts
+// the SSH server if it's enabled. The web server is started with
+// ListenAndServe.
+func NewServer(cfg config.Config) *Server {
+
+	chess.PGN_SOURCES = nil
+	if cfg.Chess.Book {
+		chess.PGN_SOURCES = cfg.Chess.Books
+	}
+	chess.WEIGHTS_SOURCE = cfg.Chess.Weights
+	chess.Logger = slog.Default()
 	chess.Init()
 
+	games := store.New(cfg.Games.TTL, cfg.Games.MaxGames)
+	journal, err := store.OpenJournal(cfg.Paths.Games)
+	if err != nil {
+		panic(err)
+	}
+	restored, err := games.Persist(journal)
+	if err != nil {
+		panic(err)
+	}
+	slog.Info("restored games", "games", restored, "path", cfg.Paths.Games)
+	sweep, stopSweep := context.WithCancel(context.Background())
+	go games.Run(sweep, cfg.Games.Sweep)
+
+	// both front ends play through the same service, so a game started in
+	// one can be joined from the other
+	players, err := accounts.Open(cfg.Paths.Accounts)
+	if err != nil {
+		panic(err)
+	}
+
+	finished, err := archive.Open(cfg.Paths.Archive)
+	if err != nil {
+		panic(err)
+	}
+	finished.Players = players
+
+	gameService := service.New(games)
+	gameService.Players = players
+	gameService.Archive = finished
+	gameService.SetBotLimits(cfg.Bots.MaxDepth, cfg.Bots.MaxSearchTime)
+	gameService.Workers = cfg.Bots.Workers
+	gameService.Threads = cfg.Bots.Threads
+	gameService.Resume()
+	seeks := lobby.New(gameService)
+	seeks.Ratings = players
+	seeks.SeekTTL = cfg.Lobby.SeekTTL
+
+	// each front end limits its clients the same way, so switching between
+	// them doesn't get around a limit
+	limit := &limits.Limits{
+		Games:    limits.NewLimiter("starting games", cfg.Limits.Games, time.Minute),
+		Moves:    limits.NewLimiter("moving", cfg.Limits.Moves, time.Minute),
+		Bot:      limits.NewLimiter("asking the bot to move", cfg.Limits.Bot, time.Minute),
+		Links:    limits.NewLimiter("trying sign-in codes", cfg.Limits.Links, time.Minute),
+		IPHeader: cfg.Limits.IPHeader,
+	}
+
+	// admins see and kick the sessions of both front ends
+	sessions := admin.NewSessions(cfg.Admin.KickFor)
+	console := admin.New(gameService, players, sessions, cfg.Admin.Keys)
+
 	router := http.NewServeMux()
 
-	router.Handle("/", newBrowserRouter())
-	ServeSSH()
+	router.Handle("/", refuseKicked(sessions, players, instrument(newBrowserRouter(cfg.Paths.Templates, gameService, seeks, players, finished, limit, console))))
+	if cfg.API.Enabled {
+		router.Handle("/api/v1/", refuseKicked(sessions, players, instrument(newAPIRouter(gameService, seeks, players, limit, cfg.API.Bots))))
+	}
+	if cfg.Metrics.Enabled {
+		registerGameMetrics(games, gameService)
+		router.Handle("GET /metrics", metrics.Default)
+	}
+	router.HandleFunc("GET /healthz", handleHealthz)
+	router.HandleFunc("GET /readyz", handleReadyz(gameService))
+
+	s := &Server{
+		web: &http.Server{
+			Addr:              cfg.HTTP.Addr,
+			Handler:           limitBody(router, int64(cfg.Limits.MaxBody)),
+			ReadHeaderTimeout: readHeaderTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
+			MaxHeaderBytes:    maxHeaderBytes,
+		},
+		service:   gameService,
+		games:     games,
+		archive:   finished,
+		stopSweep: stopSweep,
+	}
+	if cfg.HTTP.TLS {
+		s.web.TLSConfig, err = newTLSConfig(cfg.HTTP)
+		if err != nil {
+			panic(err)
+		}
+		if cfg.HTTP.RedirectAddr != "" {
+			s.redirect = &http.Server{
+				Addr:              cfg.HTTP.RedirectAddr,
+				Handler:           redirectToHTTPS(cfg.HTTP.Addr),
+				ReadHeaderTimeout: readHeaderTimeout,
+				WriteTimeout:      writeTimeout,
+				IdleTimeout:       idleTimeout,
+				MaxHeaderBytes:    maxHeaderBytes,
+			}
+		}
+	}
+	if cfg.SSH.Enabled {
+		s.ssh, err = ServeSSH(cfg.SSH, gameService, seeks, players, finished, limit, console)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return s
+}
+
+// ListenAndServe serves the web server until it's shut down, when it returns
+// http.ErrServerClosed. With TLS it serves HTTPS and HTTP/2, and redirects
+// plain HTTP in the background if that's configured.
+func (s *Server) ListenAndServe() error {
+	if s.web.TLSConfig == nil {
+		return s.web.ListenAndServe()
+	}
+
+	if s.redirect != nil {
+		slog.Info("redirecting HTTP to HTTPS", "addr", s.redirect.Addr)
+		go func() {
+			err := s.redirect.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("can't redirect HTTP to HTTPS", "err", err)
+			}
+		}()
+	}
+
+	return s.web.ListenAndServeTLS("", "")
+}
+
+// Shutdown stops both servers gracefully. No new games can be started from
+// the moment it's called, and players are told the server is going away: the
+// browser's event streams are sent a notice and closed, and TUI programs are
+// quit. Once the servers have let everyone go, the bot's searches under way
+// are given until ctx is done to finish, and the games journal and archive
+// are closed. Connections and searches still going when ctx is done are cut
+// off, which is only logged, since their games have been saved; an error is
+// only returned if the journal or archive can't be closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.service.Drain()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		err := s.web.Shutdown(ctx)
+		if err != nil {
+			slog.Warn("closing web connections still open", "err", err)
+			s.web.Close()
+		}
+		if s.redirect != nil {
+			s.redirect.Close()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if s.ssh == nil {
+			return
+		}
+		err := s.ssh.Shutdown(ctx)
+		if err != nil {
+			slog.Warn("closing SSH sessions still open", "err", err)
+		}
+	}()
+	wg.Wait()
+
+	err := s.service.Stop(ctx)
+	if err != nil {
+		slog.Warn("giving up on the bot's searches", "err", err)
+	}
+	s.stopSweep()
 
-	return router
+	return errors.Join(s.games.Close(), s.archive.Close())
 }
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/store"
+)
+
+// ErrUnknownSide is returned when adjudicating a game for a side it doesn't
+// have.
+var ErrUnknownSide = errors.New("this game has no such side")
+
+// SetBotLimits caps how deep the bot searches, in plies for chess, and how
+// long it thinks about each chess move, whatever a game asks for, with zero
+// for no limit. They can be changed while the bot is playing, and apply from
+// its next search.
+func (s *Service) SetBotLimits(depth int, searchTime time.Duration) {
+	s.maxDepth.Store(int64(depth))
+	s.maxSearchTime.Store(int64(searchTime))
+}
+
+// BotLimits are the limits set with SetBotLimits.
+func (s *Service) BotLimits() (depth int, searchTime time.Duration) {
+	return int(s.maxDepth.Load()), time.Duration(s.maxSearchTime.Load())
+}
+
+// BotWorkers is how many of the bot's searches run at once.
+func (s *Service) BotWorkers() int {
+	return s.pool().workers
+}
+
+// Abort ends a game without a result, for an admin. Aborted games aren't
+// archived or added to their players' histories.
+func (s *Service) Abort(entry *store.Entry) error {
+	if entry.Data.Ended {
+		return ErrGameOver
+	}
+
+	s.end(entry, "*", "Aborted", "Aborted by an admin.")
+	return nil
+}
+
+// Adjudicate ends a game with a win for the winner's side, or a draw if the
+// winner is empty, for an admin. The result counts like any other, so the
+// game is archived and rated if it would have been.
+func (s *Service) Adjudicate(entry *store.Entry, winner string) error {
+	if entry.Data.Ended {
+		return ErrGameOver
+	}
+
+	result, status := "1/2-1/2", "Adjudicated by an admin as a draw."
+	if winner != "" {
+		switch slices.Index(SeatNames(entry.Game), winner) {
+		case 0:
+			result = "1-0"
+		case 1:
+			result = "0-1"
+		default:
+			return ErrUnknownSide
+		}
+		status = fmt.Sprintf("Adjudicated by an admin, %s Wins!", winner)
+	}
+
+	s.end(entry, result, "Adjudication", status)
+	s.finish(entry)
+	return nil
+}
+
+// end finishes a game off the board, stopping its clock. The bot's search,
+// if it's thinking, finds the game over and doesn't play its move.
+func (s *Service) end(entry *store.Entry, result, termination, status string) {
+	data := entry.Data
+	if data.Clock != nil {
+		data.Clock.Stop(time.Now())
+	}
+	s.stopFlag(entry.ID)
+
+	data.Ended = true
+	data.Result = result
+	data.Termination = termination
+	data.Status = status
+	FillCells(entry.Game, data)
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+// recorder remembers the games it's told have finished.
+type recorder struct {
+	finished []string
+}
+
+func (r *recorder) Finished(entry *store.Entry) {
+	r.finished = append(r.finished, store.Result(entry.Game, entry.Data))
+}
+
+func TestAdjudicate(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	archive := &recorder{}
+	s.Archive = archive
+
+	tests := []struct {
+		winner string
+		result string
+		status string
+		err    error
+	}{
+		{"O", "0-1", "Adjudicated by an admin, O Wins!", nil},
+		{"", "1/2-1/2", "Adjudicated by an admin as a draw.", nil},
+		{"White", "", "", ErrUnknownSide},
+	}
+
+	for _, test := range tests {
+		entry := newTTT(t, s, &utils.TwoPlayerGame{})
+		entry.Lock()
+		Host(entry, "X", "browser", utils.NewClock([2]string{"X", "O"}, time.Minute, 0))
+		s.Join(entry, "ssh")
+
+		err := s.Adjudicate(entry, test.winner)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: Expected error (%v) != actual error (%v)", test.winner, test.err, err)
+		}
+		if test.err != nil {
+			entry.Unlock()
+			continue
+		}
+
+		if result := store.Result(entry.Game, entry.Data); result != test.result {
+			t.Errorf("%q: Expected result (%s) != actual result (%s)", test.winner, test.result, result)
+		}
+		if entry.Data.Status != test.status {
+			t.Errorf("%q: Expected status (%s) != actual status (%s)", test.winner, test.status, entry.Data.Status)
+		}
+		if entry.Data.Clock.Running != -1 {
+			t.Errorf("%q: Expected the clock to stop", test.winner)
+		}
+		if err := s.Adjudicate(entry, test.winner); !errors.Is(err, ErrGameOver) {
+			t.Errorf("%q: Expected error (%v) != actual error (%v)", test.winner, ErrGameOver, err)
+		}
+		entry.Unlock()
+	}
+
+	if len(archive.finished) != 2 {
+		t.Errorf("Expected both adjudicated games to be archived, got %v", archive.finished)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	archive := &recorder{}
+	s.Archive = archive
+
+	entry := newTTT(t, s, &utils.TwoPlayerGame{})
+	entry.Lock()
+	defer entry.Unlock()
+	s.PlayTTT(entry, 4)
+
+	if err := s.Abort(entry); err != nil {
+		t.Fatalf("Expected no error aborting, got %s", err)
+	}
+	if !entry.Data.Ended || store.Result(entry.Game, entry.Data) != "*" || store.Termination(entry.Game, entry.Data) != "Aborted" {
+		t.Errorf("Expected the game to end unfinished, got result %q", store.Result(entry.Game, entry.Data))
+	}
+	if len(archive.finished) != 0 {
+		t.Errorf("Expected aborted games not to be archived, got %v", archive.finished)
+	}
+	if err := s.Play(entry, "", "0"); !errors.Is(err, ErrGameOver) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrGameOver, err)
+	}
+}
+
+func TestBotLimits(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	s.SetBotLimits(4, time.Second)
+
+	if depth := s.limitDepth(9); depth != 4 {
+		t.Errorf("Expected depth (%d) != actual depth (%d)", 4, depth)
+	}
+
+	// the bot is named and rated by the depth it searched to, while the
+	// game keeps the depth it asked for
+	entry, _ := s.Games.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	updates, unsubscribe := entry.Subscribe()
+	defer unsubscribe()
+	entry.Lock()
+	s.Start(entry, "player", Settings{Mode: ModeBot, Colour: "O", Depth: 9})
+	entry.Unlock()
+	for moved := false; !moved; {
+		select {
+		case <-updates:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the bot to move")
+		}
+		entry.Lock()
+		moved = entry.Data.Active == "O"
+		entry.Unlock()
+	}
+
+	entry.Lock()
+	if depth := BotDepth(entry); depth != 4 {
+		t.Errorf("Expected bot depth (%d) != actual bot depth (%d)", 4, depth)
+	}
+	if depth := entry.Game.(*tictactoe.TicTacToeGame).SearchDepth; depth != 9 {
+		t.Errorf("Expected search depth (%d) != actual search depth (%d)", 9, depth)
+	}
+	entry.Unlock()
+
+	s.SetBotLimits(0, 0)
+	if depth := s.limitDepth(9); depth != 9 {
+		t.Errorf("Expected depth (%d) != actual depth (%d)", 9, depth)
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import "github.com/jfosburgh/gomes/internal/metrics"
+
+var (
+	movesPlayed = metrics.Default.Counter(
+		"gomes_moves_total",
+		"Moves played, by players and the bot, by kind of game.",
+		"game",
+	)
+	botSearchSeconds = metrics.Default.Histogram(
+		"gomes_bot_search_seconds",
+		"How long the bot's searches took once they had a worker, by kind of game.",
+		metrics.DefaultBuckets,
+		"game",
+	)
+	botQueueSeconds = metrics.Default.Histogram(
+		"gomes_bot_queue_seconds",
+		"How long the bot's searches waited for a worker, by kind of game.",
+		metrics.DefaultBuckets,
+		"game",
+	)
+	botNodes = metrics.Default.Counter(
+		"gomes_bot_nodes_searched_total",
+		"Positions evaluated by the bot's chess searches, counting only the depths they finished.",
+		"game",
+	)
+)
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+var (
+	ErrNotStarted  = errors.New("this game hasn't started")
+	ErrGameOver    = errors.New("this game is over")
+	ErrIllegalMove = errors.New("illegal move")
+)
+
+// Move is a move as front ends and scripts see it. Move is what to send to
+// play it, UCI for chess and the cell number for tic-tac-toe, and Notation is
+// how players write it, SAN for chess and the square, such as b2, for
+// tic-tac-toe.
+type Move struct {
+	Move     string `json:"move"`
+	Notation string `json:"notation"`
+}
+
+// TTTSquare names a tic-tac-toe cell with its column, a to c from the left,
+// and its row, 1 to 3 from the bottom.
+func TTTSquare(cell int) string {
+	return fmt.Sprintf("%c%d", 'a'+cell%3, 3-cell/3)
+}
+
+// ParseTTTMove finds the legal tic-tac-toe move given as a cell number or a
+// square.
+func ParseTTTMove(game *tictactoe.TicTacToeGame, notation string) (int, error) {
+	notation = strings.ToLower(strings.TrimSpace(notation))
+
+	cell, err := strconv.Atoi(notation)
+	if err != nil {
+		cell = -1
+		for i := range 9 {
+			if TTTSquare(i) == notation {
+				cell = i
+			}
+		}
+	}
+
+	if !slices.Contains(game.GenerateMoves(), cell) {
+		return 0, fmt.Errorf("%w: %q", ErrIllegalMove, notation)
+	}
+
+	return cell, nil
+}
+
+// ParseChessMove finds the legal chess move given in UCI or SAN.
+func ParseChessMove(game *chess.ChessGame, notation string) (chess.Move, error) {
+	move, err := game.MoveFromUCI(notation)
+	if err == nil {
+		return move, nil
+	}
+
+	move, err = game.MoveFromSAN(notation)
+	if err != nil {
+		return chess.Move{}, fmt.Errorf("%w: %q", ErrIllegalMove, notation)
+	}
+
+	return move, nil
+}
+
+// Play plays a move for the player in a seat, given in any notation
+// ParseChessMove or ParseTTTMove accepts.
+func (s *Service) Play(entry *store.Entry, seat, notation string) error {
+	data := entry.Data
+	switch {
+	case !data.Started:
+		return ErrNotStarted
+	case data.Ended:
+		return ErrGameOver
+	}
+
+	err := s.CheckTurn(entry, seat)
+	if err != nil {
+		return err
+	}
+
+	switch game := entry.Game.(type) {
+	case *chess.ChessGame:
+		move, err := ParseChessMove(game, notation)
+		if err != nil {
+			return err
+		}
+		s.PlayChess(entry, move)
+	case *tictactoe.TicTacToeGame:
+		move, err := ParseTTTMove(game, notation)
+		if err != nil {
+			return err
+		}
+		s.PlayTTT(entry, move)
+	default:
+		return ErrUnknownGame
+	}
+
+	return nil
+}
+
+// LegalMoves lists the moves that can be played next, none once the game is
+// over.
+func LegalMoves(entry *store.Entry) []Move {
+	moves := []Move{}
+	if entry.Data.Ended {
+		return moves
+	}
+
+	switch game := entry.Game.(type) {
+	case *chess.ChessGame:
+		for _, move := range game.GetLegalMoves() {
+			moves = append(moves, Move{move.UCI(), game.MoveToSAN(move)})
+		}
+	case *tictactoe.TicTacToeGame:
+		for _, cell := range game.GenerateMoves() {
+			moves = append(moves, Move{strconv.Itoa(cell), TTTSquare(cell)})
+		}
+	}
+
+	return moves
+}
+
+// History lists the moves played so far.
+func History(entry *store.Entry) []Move {
+	moves := []Move{}
+
+	switch game := entry.Game.(type) {
+	case *chess.ChessGame:
+		replay := chess.NewGame()
+		err := replay.SetStateFromFEN(entry.Start())
+		for _, move := range game.Moves {
+			notation := move.UCI()
+			if err == nil {
+				notation = replay.MoveToSAN(move)
+				replay.MakeMove(move)
+			}
+			moves = append(moves, Move{move.UCI(), notation})
+		}
+	case *tictactoe.TicTacToeGame:
+		for _, cell := range game.Moves {
+			moves = append(moves, Move{strconv.Itoa(cell), TTTSquare(cell)})
+		}
+	}
+
+	return moves
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+func TestPlay(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+
+	entry, err := s.Pair("chess", [2]string{"white", "black"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Lock()
+	defer entry.Unlock()
+
+	tests := []struct {
+		seat string
+		move string
+		err  error
+	}{
+		{"black", "e7e5", ErrNotYourTurn},
+		{"white", "e2e5", ErrIllegalMove},
+		{"white", "Nf6", ErrIllegalMove},
+		{"white", "e4", nil},
+		{"black", "e7e5", nil},
+		{"white", "G1F3", nil},
+	}
+
+	for _, test := range tests {
+		err := s.Play(entry, test.seat, test.move)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s plays %s: Expected error (%v) != actual error (%v)", test.seat, test.move, test.err, err)
+		}
+	}
+
+	expected := []Move{{"e2e4", "e4"}, {"e7e5", "e5"}, {"g1f3", "Nf3"}}
+	if history := History(entry); !slices.Equal(history, expected) {
+		t.Errorf("Expected history (%v) != actual history (%v)", expected, history)
+	}
+
+	legal := LegalMoves(entry)
+	if len(legal) != 29 || !slices.Contains(legal, Move{"b8c6", "Nc6"}) {
+		t.Errorf("Expected 29 legal moves including Nc6, got %v", legal)
+	}
+}
+
+func TestPlayTTT(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+
+	entry := newTTT(t, s, &utils.TwoPlayerGame{Owner: "me"})
+	entry.Lock()
+	defer entry.Unlock()
+
+	tests := []struct {
+		move string
+		err  error
+	}{
+		{"b2", nil},
+		{"4", ErrIllegalMove},
+		{"d1", ErrIllegalMove},
+		{"0", nil},
+		{"c2", nil},
+		{"A1", nil},
+		{"a2", nil},
+		{"c1", ErrGameOver},
+	}
+
+	for _, test := range tests {
+		err := s.Play(entry, "me", test.move)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: Expected error (%v) != actual error (%v)", test.move, test.err, err)
+		}
+	}
+
+	if !entry.Data.Ended || len(LegalMoves(entry)) != 0 {
+		t.Errorf("Expected X to have won with no moves left, got %s", entry.Data.Status)
+	}
+
+	expected := []Move{{"4", "b2"}, {"0", "a3"}, {"5", "c2"}, {"6", "a1"}, {"3", "a2"}}
+	if history := History(entry); !slices.Equal(history, expected) {
+		t.Errorf("Expected history (%v) != actual history (%v)", expected, history)
+	}
+}
+
+func TestStart(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+
+	game := tictactoe.NewGame()
+	entry, _ := s.Games.Add(game, &utils.TwoPlayerGame{Active: "X"})
+	entry.Lock()
+	defer entry.Unlock()
+
+	err := s.Start(entry, "me", Settings{Mode: "hotseat"})
+	if !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrUnknownMode, err)
+	}
+
+	err = s.Start(entry, "me", Settings{Mode: ModeBot, Colour: "X", Depth: 3})
+	if err != nil {
+		t.Fatalf("Expected no error starting the game, got %s", err)
+	}
+	if data := entry.Data; !data.Started || data.Player != "X" || data.Owner != "me" || game.SearchDepth != 3 {
+		t.Errorf("Expected me to play X against a depth 3 bot, got %+v (depth %d)", data, game.SearchDepth)
+	}
+
+	err = s.Start(entry, "me", Settings{Mode: ModeLocal})
+	if !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrStarted, err)
+	}
+
+	online, _ := s.Games.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{Active: "X"})
+	online.Lock()
+	defer online.Unlock()
+
+	clock := utils.NewClock([2]string{"X", "O"}, time.Minute, 0)
+	err = s.Start(online, "me", Settings{Mode: ModeOnline, Colour: "O", Clock: clock})
+	if err != nil {
+		t.Fatalf("Expected no error starting the game, got %s", err)
+	}
+	if data := online.Data; !data.Waiting() || data.Seats["O"] != "me" || data.Clock != clock {
+		t.Errorf("Expected an online game waiting for X with me as O, got %+v", data)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"slices"
+	"sync"
+)
+
+// pool runs the bot's searches on a fixed number of workers, so that many
+// games against the bot share the CPU rather than fight over it. Searches
+// wait in a queue per owner, and the workers serve the owners in turn, so a
+// player with many games waiting can't hold up everyone else.
+type pool struct {
+	mu      sync.Mutex
+	wake    *sync.Cond
+	queues  map[string][]*job
+	owners  []string
+	workers int
+	running int
+	closed  bool
+}
+
+// job is a search waiting for a worker. queued is told the job's place in
+// line whenever it changes, counting from one, and zero once it leaves the
+// queue. Jobs an idle worker is about to take aren't counted as waiting.
+// dropped is called instead of run if the pool closes first.
+type job struct {
+	owner   string
+	run     func()
+	queued  func(place int)
+	dropped func()
+	place   int
+}
+
+func newPool(workers int) *pool {
+	p := &pool{queues: make(map[string][]*job), workers: max(workers, 1)}
+	p.wake = sync.NewCond(&p.mu)
+
+	for range p.workers {
+		go p.work()
+	}
+
+	return p
+}
+
+// submit queues a job behind the owner's other jobs, and reports false if
+// the pool has closed.
+func (p *pool) submit(j *job) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+
+	if len(p.queues[j.owner]) == 0 {
+		p.owners = append(p.owners, j.owner)
+	}
+	p.queues[j.owner] = append(p.queues[j.owner], j)
+	p.reorder()
+	p.wake.Signal()
+
+	return true
+}
+
+// work runs jobs until the pool closes.
+func (p *pool) work() {
+	for {
+		p.mu.Lock()
+		for len(p.owners) == 0 && !p.closed {
+			p.wake.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+
+		j := p.next()
+		p.running++
+		p.reorder()
+		p.mu.Unlock()
+
+		j.run()
+
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}
+}
+
+// next takes the first job of the owner whose turn it is, sending the owner
+// to the back of the line if they have more waiting. The pool must be locked
+// and have jobs waiting.
+func (p *pool) next() *job {
+	owner := p.owners[0]
+	p.owners = p.owners[1:]
+
+	queue := p.queues[owner]
+	j := queue[0]
+	if len(queue) > 1 {
+		p.queues[owner] = queue[1:]
+		p.owners = append(p.owners, owner)
+	} else {
+		delete(p.queues, owner)
+	}
+
+	if j.place != 0 {
+		j.place = 0
+		if j.queued != nil {
+			j.queued(0)
+		}
+	}
+
+	return j
+}
+
+// reorder works out where every waiting job is in line, in the order the
+// workers will take them, and tells the ones that have moved. The pool must
+// be locked.
+func (p *pool) reorder() {
+	idle := p.workers - p.running
+	heads := make(map[string]int, len(p.owners))
+	owners := slices.Clone(p.owners)
+
+	place := 0
+	for len(owners) > 0 {
+		owner := owners[0]
+		owners = owners[1:]
+
+		queue := p.queues[owner]
+		j := queue[heads[owner]]
+		heads[owner]++
+		if heads[owner] < len(queue) {
+			owners = append(owners, owner)
+		}
+
+		place++
+		if ahead := max(place-idle, 0); j.place != ahead {
+			j.place = ahead
+			if j.queued != nil {
+				j.queued(ahead)
+			}
+		}
+	}
+}
+
+// stats is how many jobs are waiting and how many are running.
+func (p *pool) stats() (queued, running int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, queue := range p.queues {
+		queued += len(queue)
+	}
+
+	return queued, p.running
+}
+
+// close stops the workers once their jobs are done, and drops every job
+// still waiting.
+func (p *pool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+
+	dropped := []*job{}
+	for _, owner := range p.owners {
+		dropped = append(dropped, p.queues[owner]...)
+	}
+	p.owners = nil
+	p.queues = make(map[string][]*job)
+	p.wake.Broadcast()
+	p.mu.Unlock()
+
+	for _, j := range dropped {
+		if j.place != 0 && j.queued != nil {
+			j.queued(0)
+		}
+		if j.dropped != nil {
+			j.dropped()
+		}
+	}
+}
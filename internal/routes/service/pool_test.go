@@ -0,0 +1,168 @@
+package service
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+)
+
+func TestPoolFairness(t *testing.T) {
+	p := newPool(1)
+	defer p.close()
+
+	// the only worker is kept busy while the queue fills up
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.submit(&job{owner: "blocker", run: func() {
+		close(started)
+		<-block
+	}})
+	<-started
+
+	mu := sync.Mutex{}
+	order := []string{}
+	places := make(map[string]int)
+	done := sync.WaitGroup{}
+
+	submit := func(owner, name string) {
+		done.Add(1)
+		p.submit(&job{
+			owner: owner,
+			run: func() {
+				defer done.Done()
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+			},
+			queued: func(place int) {
+				mu.Lock()
+				places[name] = place
+				mu.Unlock()
+			},
+		})
+	}
+
+	submit("alice", "a1")
+	submit("alice", "a2")
+	submit("alice", "a3")
+	submit("bob", "b1")
+	submit("carol", "c1")
+	submit("bob", "b2")
+
+	expected := []string{"a1", "b1", "c1", "a2", "b2", "a3"}
+	mu.Lock()
+	for i, name := range expected {
+		if places[name] != i+1 {
+			t.Errorf("%s: Expected place (%d) != actual place (%d)", name, i+1, places[name])
+		}
+	}
+	mu.Unlock()
+
+	queued, running := p.stats()
+	if queued != 6 || running != 1 {
+		t.Errorf("Expected 6 queued and 1 running, got %d queued and %d running", queued, running)
+	}
+
+	close(block)
+	done.Wait()
+
+	if !slices.Equal(order, expected) {
+		t.Errorf("Expected order (%v) != actual order (%v)", expected, order)
+	}
+	for name, place := range places {
+		if place != 0 {
+			t.Errorf("%s: Expected to have left the queue, still at place %d", name, place)
+		}
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p := newPool(1)
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	p.submit(&job{owner: "alice", run: func() {
+		close(started)
+		<-block
+		close(finished)
+	}})
+	<-started
+
+	ran, dropped := false, false
+	p.submit(&job{owner: "bob", run: func() { ran = true }, dropped: func() { dropped = true }})
+	p.close()
+
+	if !dropped {
+		t.Errorf("Expected the waiting job to be dropped")
+	}
+	if p.submit(&job{owner: "carol", run: func() {}}) {
+		t.Errorf("Expected no jobs to be taken once closed")
+	}
+
+	// the running job is let finish
+	close(block)
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the running job to finish")
+	}
+	if ran {
+		t.Errorf("Expected the dropped job never to run")
+	}
+}
+
+func TestBotQueue(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	s.Workers = 1
+
+	// one worker is busy, so the bot's search waits its turn
+	block := make(chan struct{})
+	started := make(chan struct{})
+	s.pool().submit(&job{owner: "other", run: func() {
+		close(started)
+		<-block
+	}})
+	<-started
+
+	entry := newTTT(t, s, &utils.TwoPlayerGame{Player: "O", Owner: "alice"})
+	updates, unsubscribe := entry.Subscribe()
+	defer unsubscribe()
+
+	entry.Lock()
+	s.StartBot(entry)
+	view := View(entry, "alice")
+	entry.Unlock()
+
+	if view.Queued != 1 {
+		t.Errorf("Expected queued (%d) != actual queued (%d)", 1, view.Queued)
+	}
+	if queued, _ := s.BotSearches(); queued != 1 {
+		t.Errorf("Expected searches queued (%d) != actual searches queued (%d)", 1, queued)
+	}
+
+	close(block)
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case <-updates:
+		case <-deadline:
+			t.Fatalf("Expected the bot to move")
+		}
+
+		entry.Lock()
+		moved := entry.Data.Active == "O"
+		entry.Unlock()
+		if moved {
+			break
+		}
+	}
+
+	if entry.Queued() != 0 {
+		t.Errorf("Expected the search to have left the queue, still at place %d", entry.Queued())
+	}
+}
@@ -0,0 +1,772 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+var (
+	ErrWaiting     = errors.New("waiting for an opponent to join")
+	ErrNotYourTurn = errors.New("it's not your turn")
+	ErrOutOfTime   = errors.New("out of time")
+	ErrNotOnline   = errors.New("this game isn't open to other players")
+	ErrSeatsTaken  = errors.New("this game already has two players")
+	ErrNotInvited  = errors.New("this game is for someone else")
+	ErrNotYourGame = errors.New("only the player who started this game can move in it")
+	ErrUnknownGame = errors.New("unknown game")
+	ErrUnknownMode = errors.New("unknown game mode")
+	ErrStarted     = errors.New("this game has already started")
+)
+
+// The ways a game can be started.
+const (
+	ModeLocal  = "local"
+	ModeBot    = "bot"
+	ModeOnline = "online"
+)
+
+// Players keeps track of the people behind the seats, for a service whose
+// players have accounts.
+type Players interface {
+	// Name is the display name of the player in a seat.
+	Name(seat string) string
+	// Finished records a finished game in its players' profiles, rating
+	// them if it counts. The entry is locked.
+	Finished(entry *store.Entry)
+}
+
+// Archive keeps finished games for replaying.
+type Archive interface {
+	// Finished archives a finished game. The entry is locked.
+	Finished(entry *store.Entry)
+}
+
+// Service plays the games in a store for every front end, so that a move
+// made in the browser is the same as one made over SSH. Changes are announced
+// to anyone watching a game through the store entry's subscribers.
+//
+// Players are identified by a seat ID chosen by their front end, such as a
+// browser's session cookie. Unless noted otherwise, methods taking an entry
+// must be called with it locked.
+type Service struct {
+	Games *store.GameStore
+
+	// Players is nil when players are anonymous, and Archive is nil when
+	// finished games aren't kept
+	Players Players
+	Archive Archive
+
+	// maxDepth and maxSearchTime cap how hard the bot thinks, whatever a game
+	// asks for, and are set with SetBotLimits
+	maxDepth      atomic.Int64
+	maxSearchTime atomic.Int64
+
+	// Workers is how many of the bot's searches run at once, with zero for
+	// one per CPU, and Threads how many moves each chess search looks at in
+	// parallel, with zero for all of them. They must be set before the bot's
+	// first search.
+	Workers     int
+	Threads     int
+	workers     *pool
+	workersOnce sync.Once
+
+	// bots holds the IDs of games the bot is thinking about, and flags the
+	// timer that ends each timed game when the running clock runs out
+	bots  sync.Map
+	flags sync.Map
+
+	// searches counts the bot's searches under way, closing is closed once
+	// the service starts draining, and stopped is set once it has stopped
+	searches sync.WaitGroup
+	closing  chan struct{}
+	drain    sync.Once
+	stopped  atomic.Bool
+}
+
+func New(games *store.GameStore) *Service {
+	return &Service{Games: games, closing: make(chan struct{})}
+}
+
+// Drain gets the service ready to shut down: no new games can be started and
+// the bot starts no new searches, while games already under way can still be
+// played. Closing is closed, so that players can be told.
+func (s *Service) Drain() {
+	s.drain.Do(func() {
+		s.Games.Drain()
+		close(s.closing)
+	})
+}
+
+// Closing is closed when the service starts draining.
+func (s *Service) Closing() <-chan struct{} {
+	return s.closing
+}
+
+// Stop drains the service and waits for the bot's searches, both under way
+// and queued, to finish, giving up on them when ctx is done. Searches still
+// waiting for a worker by then are dropped. Clocks stop being enforced, and
+// the moves of searches given up on are never played, so games no longer
+// change once it returns. Resume picks them up again after a restart.
+func (s *Service) Stop(ctx context.Context) error {
+	s.Drain()
+
+	done := make(chan struct{})
+	go func() {
+		s.searches.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.stopped.Store(true)
+	s.pool().close()
+	s.flags.Range(func(id, timer any) bool {
+		timer.(*time.Timer).Stop()
+		s.flags.Delete(id)
+		return true
+	})
+
+	return err
+}
+
+func GameName(game interface{}) string {
+	switch game.(type) {
+	case *chess.ChessGame:
+		return "chess"
+	case *tictactoe.TicTacToeGame:
+		return "tictactoe"
+	}
+
+	return ""
+}
+
+// NewGame creates a game of the named kind.
+func NewGame(name string) (interface{}, error) {
+	switch name {
+	case "chess":
+		return chess.NewGame(), nil
+	case "tictactoe":
+		return tictactoe.NewGame(), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownGame, name)
+}
+
+// SeatNames lists the colours of a game in the order they move.
+func SeatNames(game interface{}) []string {
+	switch game.(type) {
+	case *chess.ChessGame:
+		return []string{"White", "Black"}
+	case *tictactoe.TicTacToeGame:
+		return []string{"X", "O"}
+	}
+
+	return nil
+}
+
+func FillCells(game interface{}, data *utils.TwoPlayerGame) {
+	switch game := game.(type) {
+	case *chess.ChessGame:
+		data.Cells = utils.FillChessCells(game, data, -1, false)
+	case *tictactoe.TicTacToeGame:
+		data.Cells = utils.FillTTTCells(game, data)
+	}
+}
+
+// View is the game as seen by the given seat. In an online game that is one
+// of the two seated players, who can only move on their turn, or someone
+// without a seat, who can only watch.
+func View(entry *store.Entry, seat string) utils.TwoPlayerGame {
+	data := *entry.Data
+	data.Spectators = entry.Watchers()
+	data.Queued = entry.Queued()
+	if !data.Online {
+		return data
+	}
+
+	data.Player = "Spectator"
+	for colour, id := range data.Seats {
+		if id == seat {
+			data.Player = colour
+		}
+	}
+
+	if data.Player != data.Active || data.Waiting() {
+		FillCells(entry.Game, &data)
+	}
+
+	return data
+}
+
+// Watch is the game as seen by a spectator, who can follow it but never move,
+// even in a game played from a single screen.
+func Watch(entry *store.Entry) utils.TwoPlayerGame {
+	data := *entry.Data
+	data.Spectators = entry.Watchers()
+	data.Queued = entry.Queued()
+	data.Watching = true
+
+	data.Cells = make([]utils.Cell, len(entry.Data.Cells))
+	for i, cell := range entry.Data.Cells {
+		cell.Clickable = false
+		cell.Classes = strings.ReplaceAll(cell.Classes, " enabled", "")
+		data.Cells[i] = cell
+	}
+
+	return data
+}
+
+// Host opens a game to a second player, seating its creator in the given
+// colour. The clock may be nil for an untimed game.
+func Host(entry *store.Entry, colour, seat string, clock *utils.Clock) {
+	data := entry.Data
+	if !slices.Contains(SeatNames(entry.Game), colour) {
+		colour = SeatNames(entry.Game)[0]
+	}
+
+	data.Online = true
+	data.Seats = map[string]string{colour: seat}
+	data.Clock = clock
+	data.Status = "Waiting for an opponent to join..."
+	FillCells(entry.Game, data)
+}
+
+// Join seats a player in an online game's open colour and returns the colour
+// they play. Players who already have a seat keep it, and a game offered to
+// one player can't be joined by anyone else.
+func (s *Service) Join(entry *store.Entry, seat string) (string, error) {
+	data := entry.Data
+	if !data.Online {
+		return "", ErrNotOnline
+	}
+
+	for colour, id := range data.Seats {
+		if id == seat {
+			return colour, nil
+		}
+	}
+
+	if data.Invitee != "" && data.Invitee != seat {
+		return "", ErrNotInvited
+	}
+
+	open := ""
+	for _, colour := range SeatNames(entry.Game) {
+		if _, taken := data.Seats[colour]; !taken {
+			open = colour
+			break
+		}
+	}
+	if open == "" {
+		return "", ErrSeatsTaken
+	}
+
+	data.Seats[open] = seat
+	data.Names = make(map[string]string)
+	for colour, id := range data.Seats {
+		data.Names[colour] = s.name(id)
+	}
+	data.Status = fmt.Sprintf("%s makes the first move!", SeatNames(entry.Game)[0])
+	FillCells(entry.Game, data)
+
+	// the first player's time starts once they have someone to play
+	if clock := data.Clock; clock != nil && !data.Waiting() {
+		clock.Start(slices.Index(SeatNames(entry.Game), data.Active), time.Now())
+		s.scheduleFlag(entry)
+	}
+
+	return open, nil
+}
+
+// Pair starts an online game of the named kind between two players, seating
+// the first in the colour that moves first. The clock may be nil for an
+// untimed game.
+func (s *Service) Pair(name string, seats [2]string, clock *utils.Clock, rated bool) (*store.Entry, error) {
+	game, err := NewGame(name)
+	if err != nil {
+		return nil, err
+	}
+
+	colours := SeatNames(game)
+	data := &utils.TwoPlayerGame{
+		Active:  colours[0],
+		Started: true,
+		Rated:   rated,
+	}
+	entry, err := s.Games.Add(game, data)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	Host(entry, colours[0], seats[0], clock)
+	_, err = s.Join(entry, seats[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Settings are how a game is started. Colour is the colour played against
+// the bot or taken in an online game, and defaults to the one that moves
+// first. Depth and SearchTime tune the bot, with zero keeping the game's
+// defaults; chess depths are in plies. Clock times an online game, and is nil
+// for an untimed one. Invitee is the seat of the only player who may join an
+// online game, or empty to let anyone with its invite link join.
+type Settings struct {
+	Mode       string
+	Colour     string
+	Depth      int
+	SearchTime time.Duration
+	Clock      *utils.Clock
+	Invitee    string
+}
+
+// Start starts a game for the player in a seat, who becomes its owner. The
+// bot moves first when the player takes the second colour.
+func (s *Service) Start(entry *store.Entry, seat string, settings Settings) error {
+	data := entry.Data
+	if data.Started {
+		return ErrStarted
+	}
+
+	colours := SeatNames(entry.Game)
+	colour := settings.Colour
+	if !slices.Contains(colours, colour) {
+		colour = colours[0]
+	}
+
+	switch settings.Mode {
+	case ModeLocal, ModeOnline:
+	case ModeBot:
+		data.Player = colour
+		switch game := entry.Game.(type) {
+		case *chess.ChessGame:
+			if settings.Depth > 0 {
+				game.MaxSearchDepth = settings.Depth
+			}
+			if settings.SearchTime > 0 {
+				game.SearchTime = settings.SearchTime
+			}
+		case *tictactoe.TicTacToeGame:
+			if settings.Depth > 0 {
+				game.SearchDepth = settings.Depth
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMode, settings.Mode)
+	}
+
+	data.Started = true
+	data.Owner = seat
+	data.Active = colours[0]
+	data.Status = fmt.Sprintf("%s makes the first move!", colours[0])
+	FillCells(entry.Game, data)
+
+	if settings.Mode == ModeOnline {
+		Host(entry, colour, seat, settings.Clock)
+		data.Invitee = settings.Invitee
+	}
+
+	s.StartBot(entry)
+
+	return nil
+}
+
+// CheckTurn makes sure a move in an online game comes from the player whose
+// turn it is, and that they haven't run out of time. Local games are played
+// from a single screen, so their owner may move for either side.
+func (s *Service) CheckTurn(entry *store.Entry, seat string) error {
+	data := entry.Data
+	if s.flagFall(entry) {
+		return ErrOutOfTime
+	}
+
+	if !data.Online {
+		if data.Owner != "" && data.Owner != seat {
+			return ErrNotYourGame
+		}
+		return nil
+	}
+
+	if data.Waiting() {
+		return ErrWaiting
+	}
+
+	if data.Seats[data.Active] != seat {
+		return ErrNotYourTurn
+	}
+
+	return nil
+}
+
+// PlayTTT plays a move in a tic-tac-toe game and keeps the game going.
+func (s *Service) PlayTTT(entry *store.Entry, move int) {
+	playTTTMove(entry.Game.(*tictactoe.TicTacToeGame), entry.Data, move)
+	s.afterMove(entry)
+}
+
+// PlayChess plays a move in a chess game and keeps the game going.
+func (s *Service) PlayChess(entry *store.Entry, move chess.Move) {
+	playChessMove(entry.Game.(*chess.ChessGame), entry.Data, move)
+	s.afterMove(entry)
+}
+
+func playTTTMove(game *tictactoe.TicTacToeGame, data *utils.TwoPlayerGame, move int) {
+	game.MakeMove(move)
+
+	var winner int
+	data.Ended, winner = game.GameOver()
+
+	data.Active = utils.TTTPieces[game.State.Active]
+	data.Cells = utils.FillTTTCells(game, data)
+
+	if data.Ended {
+		if winner == 0 {
+			data.Status = "It's a tie!"
+		} else {
+			data.Status = fmt.Sprintf("%s Wins!", utils.TTTPieces[winner])
+		}
+	} else {
+		data.Status = fmt.Sprintf("%s's Turn!", data.Active)
+	}
+}
+
+func playChessMove(game *chess.ChessGame, data *utils.TwoPlayerGame, move chess.Move) {
+	game.MakeMove(move)
+	data.Active = utils.ChessNames[game.EBE.Active]
+
+	data.Cells = utils.FillChessCells(game, data, -1, false)
+	checkmate := len(game.GetLegalMoves()) == 0
+	draw := !checkmate && game.EBE.Halfmoves >= 100
+	data.Ended = checkmate || draw
+	if data.Ended {
+		data.Status = fmt.Sprintf("%s Wins!", utils.ChessNames[^(game.EBE.Active)&0b1])
+		if draw {
+			data.Status = "It's a tie!"
+		}
+	} else {
+		data.Status = fmt.Sprintf("%s played %s, %s's Turn!", utils.ChessNames[^game.EBE.Active&0b1], move, data.Active)
+	}
+}
+
+// afterMove keeps a game going once a move has been played, counting the
+// move, pressing the clock and letting the bot reply.
+func (s *Service) afterMove(entry *store.Entry) {
+	data := entry.Data
+	movesPlayed.Inc(GameName(entry.Game))
+
+	if clock := data.Clock; clock != nil {
+		if data.Ended {
+			clock.Stop(time.Now())
+			s.stopFlag(entry.ID)
+		} else {
+			clock.Press(time.Now())
+			s.scheduleFlag(entry)
+		}
+	}
+
+	if data.Ended {
+		s.finish(entry)
+	}
+	s.StartBot(entry)
+}
+
+// name is the display name of the player in a seat.
+func (s *Service) name(seat string) string {
+	if s.Players == nil {
+		return "Anonymous"
+	}
+
+	return s.Players.Name(seat)
+}
+
+// finish archives a finished game and records it with its players.
+func (s *Service) finish(entry *store.Entry) {
+	if entry.Data.Waiting() {
+		return
+	}
+
+	if s.Archive != nil {
+		s.Archive.Finished(entry)
+	}
+	if s.Players != nil {
+		s.Players.Finished(entry)
+	}
+}
+
+// scheduleFlag ends the game when the running clock runs out, replacing any
+// timer set for an earlier move.
+func (s *Service) scheduleFlag(entry *store.Entry) {
+	clock := entry.Data.Clock
+	if clock == nil || clock.Running == -1 || s.stopped.Load() {
+		return
+	}
+
+	timer := time.AfterFunc(clock.Left(clock.Running, time.Now()), func() {
+		entry.Lock()
+		defer entry.Unlock()
+
+		s.flagFall(entry)
+	})
+
+	previous, ok := s.flags.Swap(entry.ID, timer)
+	if ok {
+		previous.(*time.Timer).Stop()
+	}
+}
+
+func (s *Service) stopFlag(id string) {
+	timer, ok := s.flags.LoadAndDelete(id)
+	if ok {
+		timer.(*time.Timer).Stop()
+	}
+}
+
+// flagFall ends the game if the side to move has run out of time, returning
+// whether it did.
+func (s *Service) flagFall(entry *store.Entry) bool {
+	data := entry.Data
+	if data.Clock == nil || data.Ended {
+		return false
+	}
+
+	now := time.Now()
+	side, flagged := data.Clock.Flagged(now)
+	if !flagged {
+		return false
+	}
+
+	data.Clock.Stop(now)
+	s.stopFlag(entry.ID)
+
+	data.Ended = true
+	data.Status = fmt.Sprintf("%s ran out of time, %s Wins!", data.Clock.Sides[side], data.Clock.Sides[1-side])
+	FillCells(entry.Game, data)
+	s.finish(entry)
+
+	return true
+}
+
+// BotDepth is how deep the bot searches in a game: as deep as the game asks,
+// unless it has been held shallower by its maximum depth.
+func BotDepth(entry *store.Entry) int {
+	if entry.Data.BotDepth > 0 {
+		return entry.Data.BotDepth
+	}
+
+	return gameDepth(entry.Game)
+}
+
+// gameDepth is how deep a game asks the bot to search.
+func gameDepth(game interface{}) int {
+	switch game := game.(type) {
+	case *chess.ChessGame:
+		return game.MaxSearchDepth
+	case *tictactoe.TicTacToeGame:
+		return game.SearchDepth
+	}
+
+	return 0
+}
+
+// BotName is how the bot playing a game is shown to players.
+func BotName(entry *store.Entry) string {
+	return fmt.Sprintf("Bot (depth %d)", BotDepth(entry))
+}
+
+// BotTurn reports whether a game is waiting on the bot.
+func BotTurn(data *utils.TwoPlayerGame) bool {
+	return data.Started && !data.Ended && !data.Online && data.Player != "" && data.Player != data.Active
+}
+
+// StartBot has the bot find its move in the background when it's the bot's
+// turn, so it keeps playing whether or not anyone is looking at the game. The
+// search runs on a copy of the game, and its move is only played if the game
+// hasn't changed in the meantime.
+func (s *Service) StartBot(entry *store.Entry) {
+	data := entry.Data
+	if !BotTurn(data) || s.draining() {
+		return
+	}
+
+	if _, thinking := s.bots.LoadOrStore(entry.ID, true); thinking {
+		return
+	}
+
+	position := store.Position(entry.Game)
+	depth := s.limitDepth(gameDepth(entry.Game))
+
+	var search func() func()
+	pooled := true
+	switch game := entry.Game.(type) {
+	case *chess.ChessGame:
+		clone := game.Clone()
+		clone.MaxSearchDepth = depth
+		clone.SearchTime = game.SearchTime
+		if _, maxSearchTime := s.BotLimits(); maxSearchTime > 0 {
+			clone.SearchTime = min(clone.SearchTime, maxSearchTime)
+		}
+		clone.Threads = s.Threads
+		clone.Logger = slog.With("game", entry.ID)
+
+		// book moves need no search, so they don't take up a worker while
+		// the bot makes a show of thinking
+		if move, ok := chess.ChooseFromCodebook(clone.EBE.Board, clone.EBE.Active); ok {
+			pooled = false
+			search = func() func() {
+				time.Sleep(clone.SearchTime)
+				return func() { playChessMove(game, data, move) }
+			}
+			break
+		}
+		nodes := 0
+		clone.OnSearchInfo = func(info chess.SearchInfo) { nodes = info.Nodes }
+		search = func() func() {
+			move := clone.BestMove()
+			botNodes.Add(float64(nodes), "chess")
+			return func() { playChessMove(game, data, move) }
+		}
+	case *tictactoe.TicTacToeGame:
+		clone := tictactoe.NewGame()
+		clone.FromString(game.ToGameString())
+		clone.SearchDepth = depth
+		search = func() func() {
+			move := clone.BestMove()
+			return func() { playTTTMove(game, data, move) }
+		}
+	default:
+		s.bots.Delete(entry.ID)
+		return
+	}
+
+	name := GameName(entry.Game)
+	submitted := time.Now()
+	run := func() {
+		defer s.searches.Done()
+		started := time.Now()
+		play := search()
+		if pooled {
+			botQueueSeconds.Observe(started.Sub(submitted).Seconds(), name)
+			botSearchSeconds.Since(started, name)
+		}
+
+		entry.Lock()
+		defer entry.Unlock()
+		s.bots.Delete(entry.ID)
+
+		if data.Ended || store.Position(entry.Game) != position || s.stopped.Load() {
+			return
+		}
+
+		play()
+		if data.BotDepth == 0 || depth < data.BotDepth {
+			data.BotDepth = depth
+		}
+		s.afterMove(entry)
+	}
+	abandon := func() {
+		s.bots.Delete(entry.ID)
+		s.searches.Done()
+	}
+
+	s.searches.Add(1)
+	if !pooled {
+		go run()
+		return
+	}
+
+	// searches are queued by whoever started the game, so each player gets
+	// their turn at the workers
+	owner := data.Owner
+	if owner == "" {
+		owner = entry.ID
+	}
+	queued := s.pool().submit(&job{
+		owner:   owner,
+		run:     run,
+		queued:  entry.SetQueued,
+		dropped: abandon,
+	})
+	if !queued {
+		abandon()
+	}
+}
+
+// pool is the workers the bot's searches run on, started with the first
+// search.
+func (s *Service) pool() *pool {
+	s.workersOnce.Do(func() {
+		workers := s.Workers
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		s.workers = newPool(workers)
+	})
+
+	return s.workers
+}
+
+// BotSearches is how many of the bot's searches are waiting for a worker and
+// how many are running.
+func (s *Service) BotSearches() (queued, running int) {
+	return s.pool().stats()
+}
+
+// draining reports whether the service has started draining.
+func (s *Service) draining() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// limitDepth caps a search depth at the bot's maximum depth.
+func (s *Service) limitDepth(depth int) int {
+	if maxDepth, _ := s.BotLimits(); maxDepth > 0 {
+		return min(depth, maxDepth)
+	}
+
+	return depth
+}
+
+// Resume picks up the games restored from the store's backend. Whoever's
+// clock was running starts their turn over, since the server being down
+// shouldn't cost them the game, and the bot finishes any game that was
+// waiting on it. The entries must not be locked.
+func (s *Service) Resume() {
+	for _, entry := range s.Games.Entries() {
+		entry.Lock()
+
+		if clock := entry.Data.Clock; clock != nil && clock.Running != -1 && !entry.Data.Ended {
+			clock.Since = time.Now()
+			s.scheduleFlag(entry)
+		}
+		s.StartBot(entry)
+
+		entry.Unlock()
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+func newTTT(t *testing.T, s *Service, data *utils.TwoPlayerGame) *store.Entry {
+	game := tictactoe.NewGame()
+	data.Active = "X"
+	data.Started = true
+	data.Cells = utils.FillTTTCells(game, data)
+
+	entry, err := s.Games.Add(game, data)
+	if err != nil {
+		t.Fatalf("Expected no error adding a game, got %s", err)
+	}
+
+	return entry
+}
+
+func TestJoin(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+
+	local := newTTT(t, s, &utils.TwoPlayerGame{})
+	local.Lock()
+	_, err := s.Join(local, "browser")
+	local.Unlock()
+	if !errors.Is(err, ErrNotOnline) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotOnline, err)
+	}
+
+	entry := newTTT(t, s, &utils.TwoPlayerGame{})
+	entry.Lock()
+	defer entry.Unlock()
+	Host(entry, "O", "browser", nil)
+
+	tests := []struct {
+		seat     string
+		expected string
+		err      error
+	}{
+		{"ssh", "X", nil},
+		{"browser", "O", nil},
+		{"ssh", "X", nil},
+		{"someone else", "", ErrSeatsTaken},
+	}
+
+	for _, test := range tests {
+		colour, err := s.Join(entry, test.seat)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: Expected error (%v) != actual error (%v)", test.seat, test.err, err)
+		}
+		if colour != test.expected {
+			t.Errorf("%s: Expected colour (%s) != actual colour (%s)", test.seat, test.expected, colour)
+		}
+	}
+}
+
+func TestCheckTurn(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	entry := newTTT(t, s, &utils.TwoPlayerGame{})
+	entry.Lock()
+	defer entry.Unlock()
+
+	Host(entry, "X", "browser", nil)
+	if err := s.CheckTurn(entry, "browser"); !errors.Is(err, ErrWaiting) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrWaiting, err)
+	}
+
+	s.Join(entry, "ssh")
+	if err := s.CheckTurn(entry, "ssh"); !errors.Is(err, ErrNotYourTurn) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotYourTurn, err)
+	}
+	if err := s.CheckTurn(entry, "browser"); err != nil {
+		t.Errorf("Expected no error for the player to move, got %s", err)
+	}
+
+	s.PlayTTT(entry, 4)
+	if err := s.CheckTurn(entry, "ssh"); err != nil {
+		t.Errorf("Expected no error for the player to move, got %s", err)
+	}
+	if view := View(entry, "browser"); view.Player != "X" || view.Cells[0].Clickable {
+		t.Errorf("Expected X to be waiting on O, got player %s with clickable cells", view.Player)
+	}
+}
+
+func TestOutOfTime(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	entry := newTTT(t, s, &utils.TwoPlayerGame{})
+	entry.Lock()
+	defer entry.Unlock()
+
+	Host(entry, "X", "browser", utils.NewClock([2]string{"X", "O"}, time.Minute, 0))
+	s.Join(entry, "ssh")
+	entry.Data.Clock.Since = time.Now().Add(-2 * time.Minute)
+
+	if err := s.CheckTurn(entry, "browser"); !errors.Is(err, ErrOutOfTime) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrOutOfTime, err)
+	}
+	if !entry.Data.Ended {
+		t.Errorf("Expected the game to end when X ran out of time")
+	}
+	if entry.Data.Status != "X ran out of time, O Wins!" {
+		t.Errorf("Expected status (%s) != actual status (%s)", "X ran out of time, O Wins!", entry.Data.Status)
+	}
+}
+
+func TestBotPlaysServerSide(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	entry := newTTT(t, s, &utils.TwoPlayerGame{Player: "O"})
+	updates, unsubscribe := entry.Subscribe()
+	defer unsubscribe()
+
+	entry.Lock()
+	s.StartBot(entry)
+	entry.Unlock()
+
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the bot to move")
+	}
+
+	entry.Lock()
+	defer entry.Unlock()
+	if entry.Data.Active != "O" {
+		t.Errorf("Expected active (%s) != actual active (%s)", "O", entry.Data.Active)
+	}
+	if BotTurn(entry.Data) {
+		t.Errorf("Expected the bot to wait for the player")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	entry := newTTT(t, s, &utils.TwoPlayerGame{})
+
+	_, stopWatching := entry.Watch()
+	defer stopWatching()
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	view := Watch(entry)
+	if !view.Watching || view.Spectators != 1 {
+		t.Errorf("Expected a spectator's view with 1 spectator, got watching %t with %d", view.Watching, view.Spectators)
+	}
+	for i, cell := range view.Cells {
+		if cell.Clickable || strings.Contains(cell.Classes, "enabled") {
+			t.Errorf("Expected cell %d to be read-only for spectators", i)
+		}
+	}
+
+	// the players' own view is untouched
+	if !entry.Data.Cells[0].Clickable {
+		t.Errorf("Expected cell 0 to stay clickable for the players")
+	}
+	if players := View(entry, ""); players.Spectators != 1 {
+		t.Errorf("Expected spectators (%d) != actual spectators (%d)", 1, players.Spectators)
+	}
+}
+
+func TestCheckOwner(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	entry := newTTT(t, s, &utils.TwoPlayerGame{Owner: "browser"})
+	entry.Lock()
+	defer entry.Unlock()
+
+	if err := s.CheckTurn(entry, "browser"); err != nil {
+		t.Errorf("Expected no error for the owner of a local game, got %s", err)
+	}
+	if err := s.CheckTurn(entry, "spectator"); !errors.Is(err, ErrNotYourGame) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotYourGame, err)
+	}
+}
+
+func TestStop(t *testing.T) {
+	s := New(store.New(time.Hour, 0))
+	entry := newTTT(t, s, &utils.TwoPlayerGame{Player: "O"})
+
+	// a search already under way is let finish
+	entry.Lock()
+	s.StartBot(entry)
+	entry.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.Stop(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error stopping, got %s", err)
+	}
+
+	select {
+	case <-s.Closing():
+	default:
+		t.Errorf("Expected closing to be closed")
+	}
+
+	entry.Lock()
+	if entry.Data.Active != "O" {
+		t.Errorf("Expected the bot's move to be played before stopping, active is %s", entry.Data.Active)
+	}
+
+	// but no new ones are started
+	entry.Data.Active = "X"
+	s.StartBot(entry)
+	entry.Unlock()
+	if _, thinking := s.bots.Load(entry.ID); thinking {
+		t.Errorf("Expected no search to start once stopped")
+	}
+
+	_, err = s.Games.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	if !errors.Is(err, store.ErrClosed) {
+		t.Errorf("Expected error (%v) != actual error (%v)", store.ErrClosed, err)
+	}
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const sessionCookie = "gomes_session"
+
+func generateSessionID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+
+	return fmt.Sprintf("%x", bytes)
+}
+
+// session returns the ID identifying the browser making the request, setting
+// a new session cookie if it doesn't have one yet. Anonymous players are
+// seated in online games by their session, so over HTTPS the cookie is only
+// ever sent over HTTPS.
+func session(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	id := generateSessionID()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// later calls during the same request should see the new session
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: id})
+
+	return id
+}
+
+// seat returns the seat the browser plays from: its account's, if the
+// session has been linked to one, or otherwise the session itself.
+func (cfg *configdata) seat(w http.ResponseWriter, r *http.Request) string {
+	id := session(w, r)
+	account, ok := cfg.Accounts.ForSession(id)
+	if !ok {
+		return id
+	}
+
+	return account.Seat()
+}
+
+// requestLog is the logger for a request, with the browser's session and the
+// game it's about, if it has them. Sessions seat their players, so they're
+// logged by a hash that can't be used to sit in someone else's seat.
+func requestLog(r *http.Request) *slog.Logger {
+	log := slog.Default()
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil && cookie.Value != "" {
+		log = log.With("session", fmt.Sprintf("%x", sha256.Sum256([]byte(cookie.Value)))[:12])
+	}
+	if id := r.PathValue("id"); id != "" {
+		log = log.With("game", id)
+	}
+
+	return log
+}
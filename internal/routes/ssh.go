@@ -1,91 +1,381 @@
 package routes
 
 import (
+	"context"
 	"errors"
-	"net"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/activeterm"
 	"github.com/charmbracelet/wish/bubbletea"
 	"github.com/charmbracelet/wish/elapsed"
-	"github.com/charmbracelet/wish/logging"
+	"github.com/jfosburgh/gomes/internal/config"
+	"github.com/jfosburgh/gomes/internal/limits"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+	"github.com/jfosburgh/gomes/internal/routes/archive"
+	"github.com/jfosburgh/gomes/internal/routes/lobby"
 	"github.com/jfosburgh/gomes/internal/routes/models"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/muesli/termenv"
+	gossh "golang.org/x/crypto/ssh"
 )
 
-const (
-	host = "localhost"
-	port = "23234"
-)
+// sshShutdownNotice is printed to TUI players when the server shuts down,
+// once their program has quit.
+const sshShutdownNotice = "The server is restarting. Your games have been saved, so reconnect in a moment to carry on."
+
+// sshBusyNotice is printed to players turned away for having too many
+// sessions open, or for the server having too many.
+const sshBusyNotice = "There are too many sessions open %s. Close one, or try again in a little while."
+
+// SSHServer serves the TUI, keeping track of the program running in each
+// session so that they can be closed when the server shuts down, or when an
+// admin kicks its player.
+type SSHServer struct {
+	server *ssh.Server
+	admin  *admin.Admin
+
+	mu       sync.Mutex
+	programs map[ssh.Session]*tea.Program
+	closing  bool
+
+	maxSessions      int
+	maxSessionsPerIP int
+	sessions         map[string]int
+	open             int
+}
+
+// ServeSSH serves the TUI in the background as configured. The host key is
+// read from the configured path, and created there if it doesn't exist yet.
+// Players are rate limited by limit, the same as in the browser, and their
+// sessions are listed in the admin console.
+func ServeSSH(cfg config.SSH, games *service.Service, seeks *lobby.Lobby, players *accounts.Accounts, finished *archive.Archive, limit *limits.Limits, console *admin.Admin) (*SSHServer, error) {
+	s := &SSHServer{
+		admin:            console,
+		programs:         make(map[ssh.Session]*tea.Program),
+		maxSessions:      cfg.MaxSessions,
+		maxSessionsPerIP: cfg.MaxSessionsPerIP,
+		sessions:         make(map[string]int),
+	}
 
-func ServeSSH() {
-	srv, err := wish.NewServer(
+	options := append(sshAuth(),
 		// The address the server will listen to.
-		wish.WithAddress(net.JoinHostPort(host, port)),
+		wish.WithAddress(cfg.Addr),
 
 		// The SSH server need its own keys, this will create a keypair in the
 		// given path if it doesn't exist yet.
 		// By default, it will create an ED25519 key.
-		wish.WithHostKeyPath(".ssh/id_ed25519"),
+		wish.WithHostKeyPath(cfg.HostKey),
 		// Middlewares do something on a ssh.Session, and then call the next
 		// middleware in the stack.
 		wish.WithMiddleware(
-			bubbletea.Middleware(teaHandler),
+			bubbletea.MiddlewareWithProgramHandler(s.programHandler(teaHandler(games, seeks, players, finished, limit, console)), termenv.Ascii),
+			s.middleware,
 			activeterm.Middleware(), // Bubble Tea apps usually require a PTY.
 
 			// The last item in the chain is the first to be called.
-			logging.Middleware(),
+			sessionLogging(),
 			elapsed.Middleware(),
+			sessionMetrics(),
+			s.limitSessions,
 		),
 	)
+	if cfg.IdleTimeout > 0 {
+		options = append(options, wish.WithIdleTimeout(cfg.IdleTimeout))
+	}
+	if cfg.MaxTimeout > 0 {
+		options = append(options, wish.WithMaxTimeout(cfg.MaxTimeout))
+	}
+
+	srv, err := wish.NewServer(options...)
 	if err != nil {
-		log.Error("Could not start server", "error", err)
+		return nil, err
 	}
+	s.server = srv
 
-	log.Info("Starting SSH server", "host", host, "port", port)
+	slog.Info("starting SSH server", "addr", cfg.Addr)
 	go func() {
 		if err = srv.ListenAndServe(); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
 			// We ignore ErrServerClosed because it is expected.
-			log.Error("Could not start server", "error", err)
+			slog.Error("can't serve the SSH server", "err", err)
 		}
 	}()
+
+	return s, nil
+}
+
+// sshAuth lets any public key in, to become the player's identity, while
+// clients without a key can still play as a guest. A client may ask about
+// keys it never signs with before the one it signs in with, so the keys seen
+// here aren't to be trusted: a session's key is read from the session.
+func sshAuth() []ssh.Option {
+	return []ssh.Option{
+		wish.WithPublicKeyAuth(func(ssh.Context, ssh.PublicKey) bool {
+			return true
+		}),
+		wish.WithKeyboardInteractiveAuth(func(ssh.Context, gossh.KeyboardInteractiveChallenge) bool {
+			return true
+		}),
+	}
+}
+
+// sessionKey is the fingerprint of the key a session authenticated with, or
+// empty for a guest without one.
+func sessionKey(sess ssh.Session) string {
+	if key := sess.PublicKey(); key != nil {
+		return gossh.FingerprintSHA256(key)
+	}
+
+	return ""
+}
+
+// programHandler makes each session's program like the bubbletea middleware
+// does, and keeps track of it. No programs are started once the server is
+// shutting down.
+func (s *SSHServer) programHandler(handler bubbletea.Handler) bubbletea.ProgramHandler {
+	return func(sess ssh.Session) *tea.Program {
+		m, opts := handler(sess)
+		if m == nil {
+			return nil
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closing {
+			return nil
+		}
+		program := tea.NewProgram(m, append(opts, bubbletea.MakeOptions(sess)...)...)
+		s.programs[sess] = program
+
+		return program
+	}
+}
+
+// middleware lists each session for admins while it's open, and forgets its
+// program once it has quit, telling the player why if it was quit for the
+// server shutting down or for an admin kicking them. Kicked players are
+// turned away until they're let back in.
+func (s *SSHServer) middleware(next ssh.Handler) ssh.Handler {
+	return func(sess ssh.Session) {
+		seat := sshSeat(sess, s.admin.Players)
+		if s.admin.Sessions.Kicked(seat) {
+			slog.Info("turned away kicked SSH session", "remote", sess.RemoteAddr().String())
+			wish.Println(sess, refusedNotice)
+			return
+		}
+
+		kicked := atomic.Bool{}
+		closed := s.admin.Sessions.Open(admin.Session{
+			Front: "ssh",
+			Seat:  seat,
+			Name:  s.admin.Players.Name(seat),
+			Addr:  sess.RemoteAddr().String(),
+		}, func() {
+			if s.quit(sess) {
+				kicked.Store(true)
+				return
+			}
+			wish.Println(sess, kickedNotice)
+			sess.Close()
+		})
+		defer closed()
+
+		next(sess)
+
+		s.mu.Lock()
+		delete(s.programs, sess)
+		closing := s.closing
+		s.mu.Unlock()
+
+		switch {
+		case closing:
+			wish.Println(sess, sshShutdownNotice)
+		case kicked.Load():
+			wish.Println(sess, kickedNotice)
+		}
+	}
+}
+
+// quit quits a session's program, reporting whether it had one to quit. A
+// session's program isn't started until its terminal has been set up.
+func (s *SSHServer) quit(sess ssh.Session) bool {
+	s.mu.Lock()
+	program, ok := s.programs[sess]
+	s.mu.Unlock()
+
+	if ok {
+		program.Quit()
+	}
+	return ok
+}
+
+// sshSeat is the seat a session plays from: its account's, if its key has
+// one, or otherwise the session itself.
+func sshSeat(sess ssh.Session, players *accounts.Accounts) string {
+	if key := sessionKey(sess); key != "" {
+		if account, ok := players.ForKey(key); ok {
+			return account.Seat()
+		}
+	}
+
+	return "ssh:" + sess.Context().SessionID()
+}
+
+// limitSessions turns sessions away once there are too many open, in all or
+// from the session's address.
+func (s *SSHServer) limitSessions(next ssh.Handler) ssh.Handler {
+	return func(sess ssh.Session) {
+		ip := limits.IP(sess.RemoteAddr().String())
+
+		s.mu.Lock()
+		busy := ""
+		switch {
+		case s.maxSessions > 0 && s.open >= s.maxSessions:
+			busy = "on the server"
+		case s.maxSessionsPerIP > 0 && s.sessions[ip] >= s.maxSessionsPerIP:
+			busy = "from your address"
+		default:
+			s.open++
+			s.sessions[ip]++
+		}
+		s.mu.Unlock()
+
+		if busy != "" {
+			slog.Info("turned away SSH session", "remote", sess.RemoteAddr().String(), "busy", busy)
+			wish.Printf(sess, sshBusyNotice+"\n", busy)
+			return
+		}
+
+		defer func() {
+			s.mu.Lock()
+			s.open--
+			s.sessions[ip]--
+			if s.sessions[ip] == 0 {
+				delete(s.sessions, ip)
+			}
+			s.mu.Unlock()
+		}()
+
+		next(sess)
+	}
+}
+
+// sessionLogging logs each session as it opens and closes, with the player's
+// key if they have one.
+func sessionLogging() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			log := slog.With("ssh_session", sess.Context().SessionID(), "user", sess.User(), "remote", sess.RemoteAddr().String())
+			if key := sessionKey(sess); key != "" {
+				log = log.With("key", key)
+			}
+
+			pty, _, ok := sess.Pty()
+			log.Info("SSH session opened", "pty", ok, "term", pty.Term, "width", pty.Window.Width, "height", pty.Window.Height)
+			start := time.Now()
+
+			next(sess)
+
+			log.Info("SSH session closed", "elapsed", time.Since(start))
+		}
+	}
+}
+
+// Shutdown stops accepting connections and quits every session's program,
+// then waits for the sessions to close. Once ctx is done, any that are left
+// are closed regardless.
+func (s *SSHServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	programs := make([]*tea.Program, 0, len(s.programs))
+	for _, program := range s.programs {
+		programs = append(programs, program)
+	}
+	s.mu.Unlock()
+
+	for _, program := range programs {
+		program.Quit()
+	}
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.server.Close()
+	}
+
+	return err
 }
 
 // You can wire any Bubble Tea model up to the middleware with a function that
 // handles the incoming ssh.Session. Here we just grab the terminal info and
 // pass it to the new model. You can also return tea.ProgramOptions (such as
-// tea.WithAltScreen) on a session by session basis.
-func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
-	// This should never fail, as we are using the activeterm middleware.
-	pty, _, _ := s.Pty()
-
-	// When running a Bubble Tea app over SSH, you shouldn't use the default
-	// lipgloss.NewStyle function.
-	// That function will use the color profile from the os.Stdin, which is the
-	// server, not the client.
-	// We provide a MakeRenderer function in the bubbletea middleware package,
-	// so you can easily get the correct renderer for the current session, and
-	// use it to create the styles.
-	// The recommended way to use these styles is to then pass them down to
-	// your Bubble Tea model.
-	renderer := bubbletea.MakeRenderer(s)
-	txtStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
-	quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
-
-	m := models.ModelHome{
-		WindowParams: models.WindowParams{
+// tea.WithAltScreen) on a session by session basis. Every session plays on
+// the same game service and lobby as the browser. Players are known by their
+// SSH public key, and seated in online games by their account, while
+// sessions without a key play as guests seated by their SSH session ID.
+func teaHandler(games *service.Service, seeks *lobby.Lobby, players *accounts.Accounts, finished *archive.Archive, limit *limits.Limits, console *admin.Admin) bubbletea.Handler {
+	return func(s ssh.Session) (tea.Model, []tea.ProgramOption) {
+		// This should never fail, as we are using the activeterm middleware.
+		pty, _, _ := s.Pty()
+
+		// When running a Bubble Tea app over SSH, you shouldn't use the default
+		// lipgloss.NewStyle function.
+		// That function will use the color profile from the os.Stdin, which is the
+		// server, not the client.
+		// We provide a MakeRenderer function in the bubbletea middleware package,
+		// so you can easily get the correct renderer for the current session, and
+		// use it to create the styles.
+		// The recommended way to use these styles is to then pass them down to
+		// your Bubble Tea model.
+		renderer := bubbletea.MakeRenderer(s)
+		txtStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
+		quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
+
+		params := models.WindowParams{
 			Width:     pty.Window.Width,
 			Height:    pty.Window.Height,
 			TxtStyle:  txtStyle,
 			QuitStyle: quitStyle,
-		},
-		Games: []string{
-			"Chess",
-			"Tic-Tac-Toe",
-		},
+		}
+		client := models.Client{
+			Service:  games,
+			Lobby:    seeks,
+			Accounts: players,
+			Archive:  finished,
+			Limits:   limit,
+			Admin:    console,
+			Seat:     "ssh:" + s.Context().SessionID(),
+			IP:       limits.IP(s.RemoteAddr().String()),
+		}
+
+		// a guest's seeks can't be played once they've gone, while players
+		// with an account can come back for theirs
+		guest := client.Seat
+		go func() {
+			<-s.Context().Done()
+			seeks.Withdraw(guest)
+		}()
+
+		client.Key = sessionKey(s)
+
+		var m tea.Model = models.NewHome(params, client)
+		if client.Key != "" {
+			account, ok := players.ForKey(client.Key)
+			if ok {
+				client.Seat = account.Seat()
+				m = models.NewHome(params, client)
+			} else {
+				m = models.ModelRegister{WindowParams: params, Client: client}
+			}
+		}
+
+		return m, []tea.ProgramOption{tea.WithAltScreen()}
 	}
-	return m, []tea.ProgramOption{tea.WithAltScreen()}
 }
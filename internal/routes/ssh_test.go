@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/jfosburgh/gomes/internal/routes/accounts"
+	"github.com/jfosburgh/gomes/internal/routes/admin"
+	"github.com/jfosburgh/gomes/internal/routes/service"
+	"github.com/jfosburgh/gomes/internal/routes/store"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// newSigner makes an SSH signer for a new ed25519 key.
+func newSigner(t *testing.T) gossh.Signer {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error making a key, got %s", err)
+	}
+	signer, err := gossh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("Expected no error making a signer, got %s", err)
+	}
+
+	return signer
+}
+
+// querySigner asks whether a public key would be accepted without signing
+// for it, sending a signature the server turns down.
+type querySigner struct {
+	key gossh.PublicKey
+}
+
+func (s querySigner) PublicKey() gossh.PublicKey {
+	return s.key
+}
+
+func (s querySigner) Sign(io.Reader, []byte) (*gossh.Signature, error) {
+	return &gossh.Signature{Format: "none"}, nil
+}
+
+// serveSSH serves SSH with the server's authentication, writing each session
+// what known says it's known by, and returns the address it listens on.
+func serveSSH(t *testing.T, known func(sess ssh.Session) string) string {
+	srv, err := wish.NewServer(append(sshAuth(),
+		wish.WithHostKeyPath(filepath.Join(t.TempDir(), "host_key")),
+		wish.WithMiddleware(func(next ssh.Handler) ssh.Handler {
+			return func(sess ssh.Session) {
+				io.WriteString(sess, known(sess))
+			}
+		}),
+	)...)
+	if err != nil {
+		t.Fatalf("Expected no error making the server, got %s", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error listening, got %s", err)
+	}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	return listener.Addr().String()
+}
+
+// dialSSH signs in with signers, tried in order, and returns what the session
+// is known by.
+func dialSSH(t *testing.T, addr string, signers ...gossh.Signer) string {
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "player",
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(signers...)},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error signing in, got %s", err)
+	}
+	defer client.Close()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("Expected no error opening a session, got %s", err)
+	}
+	defer sess.Close()
+	out, err := sess.Output("")
+	if err != nil {
+		t.Fatalf("Expected no error reading the session, got %s", err)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+func TestSessionKey(t *testing.T) {
+	addr := serveSSH(t, sessionKey)
+	alice, bob := newSigner(t), newSigner(t)
+
+	if key := dialSSH(t, addr, alice); key != gossh.FingerprintSHA256(alice.PublicKey()) {
+		t.Errorf("Expected key (%s) != actual key (%s)", gossh.FingerprintSHA256(alice.PublicKey()), key)
+	}
+
+	// the client asks whether alice's key and then bob's would be accepted,
+	// signing for neither, and then signs in with alice's: the session is
+	// alice's, not bob's, though his was the last key the server checked
+	key := dialSSH(t, addr, querySigner{alice.PublicKey()}, querySigner{bob.PublicKey()}, alice)
+	if key != gossh.FingerprintSHA256(alice.PublicKey()) {
+		t.Errorf("Expected key (%s) != actual key (%s)", gossh.FingerprintSHA256(alice.PublicKey()), key)
+	}
+}
+
+func TestSSHAdmin(t *testing.T) {
+	alice, mallory := newSigner(t), newSigner(t)
+	aliceKey := gossh.FingerprintSHA256(alice.PublicKey())
+	players, _ := accounts.Open("")
+	account, _ := players.Register("alice", aliceKey)
+	console := admin.New(service.New(store.New(time.Hour, 0)), players, admin.NewSessions(time.Minute), []string{aliceKey})
+
+	addr := serveSSH(t, func(sess ssh.Session) string {
+		return fmt.Sprintf("%s %t", sshSeat(sess, players), console.Allowed(sessionKey(sess)))
+	})
+
+	if known := dialSSH(t, addr, alice); known != account.Seat()+" true" {
+		t.Errorf("Expected alice to be seated as an admin, got %q", known)
+	}
+
+	// mallory asks whether her key and then alice's would be accepted, and
+	// signs in with her own: she's neither seated as alice nor an admin
+	known := dialSSH(t, addr, querySigner{mallory.PublicKey()}, querySigner{alice.PublicKey()}, mallory)
+	if strings.HasPrefix(known, account.Seat()) || strings.HasSuffix(known, "true") {
+		t.Errorf("Expected mallory to be seated as a guest, not an admin, got %q", known)
+	}
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Backend persists game records so games survive a restart.
+type Backend interface {
+	// Save records the latest state of a game, replacing any earlier record.
+	Save(record Record) error
+	// Delete forgets a game entirely.
+	Delete(id string) error
+	// Load returns every saved record, oldest first.
+	Load() ([]Record, error)
+	Close() error
+}
+
+type journalLine struct {
+	Op     string  `json:"op"`
+	ID     string  `json:"id,omitempty"`
+	Record *Record `json:"record,omitempty"`
+}
+
+// Journal is a Backend that appends every change to a JSON-lines file. The
+// file is replayed and compacted down to one line per game when it's opened,
+// and again whenever it grows to several times the number of games it holds.
+// Finished games are in the archive, so any saved as ended, as they once
+// were, are dropped when it's replayed.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records map[string]Record
+	lines   int
+}
+
+const compactAfter = 1000
+
+func OpenJournal(path string) (*Journal, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{
+		path:    path,
+		records: make(map[string]Record),
+	}
+
+	err = j.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	err = j.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (j *Journal) replay() error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := journalLine{}
+		err := json.Unmarshal(scanner.Bytes(), &line)
+		if err != nil {
+			// most likely the last line of a write that was cut short
+			slog.Warn("skipping unreadable line", "path", j.path, "line", lineNumber, "err", err)
+			continue
+		}
+
+		switch {
+		case line.Op == "save" && line.Record != nil && line.Record.Ended:
+			delete(j.records, line.Record.ID)
+		case line.Op == "save" && line.Record != nil:
+			j.records[line.Record.ID] = *line.Record
+		case line.Op == "delete":
+			delete(j.records, line.ID)
+		default:
+			slog.Warn("skipping unknown operation", "path", j.path, "line", lineNumber, "op", line.Op)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the journal with a single save per game and reopens it for
+// appending. The rewrite goes to a temporary file that replaces the journal in
+// one rename, so a crash part way through leaves the old journal intact.
+func (j *Journal) compact() error {
+	tmpPath := j.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, record := range j.sorted() {
+		err = writeLine(w, journalLine{Op: "save", Record: &record})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		j.file.Close()
+	}
+
+	err = os.Rename(tmpPath, j.path)
+	if err != nil {
+		return err
+	}
+
+	j.file, err = os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	j.lines = len(j.records)
+
+	return err
+}
+
+func writeLine(w io.Writer, line journalLine) error {
+	bytes, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(bytes, '\n'))
+	return err
+}
+
+func (j *Journal) append(line journalLine) error {
+	err := writeLine(j.file, line)
+	if err != nil {
+		return err
+	}
+
+	j.lines++
+	if j.lines > compactAfter && j.lines > 4*len(j.records) {
+		return j.compact()
+	}
+
+	return nil
+}
+
+func (j *Journal) Save(record Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.records[record.ID] = record
+	return j.append(journalLine{Op: "save", Record: &record})
+}
+
+func (j *Journal) Delete(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.records[id]; !ok {
+		return nil
+	}
+
+	delete(j.records, id)
+	return j.append(journalLine{Op: "delete", ID: id})
+}
+
+func (j *Journal) sorted() []Record {
+	records := make([]Record, 0, len(j.records))
+	for _, record := range j.records {
+		records = append(records, record)
+	}
+
+	slices.SortFunc(records, func(a, b Record) int {
+		return a.Updated.Compare(b.Updated)
+	})
+
+	return records
+}
+
+func (j *Journal) Load() ([]Record, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.sorted(), nil
+}
+
+// Close flushes the journal to disk and closes it.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err := j.file.Sync()
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
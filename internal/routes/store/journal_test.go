@@ -0,0 +1,267 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+func playChess(t *testing.T, game *chess.ChessGame, moves ...string) {
+	for _, played := range moves {
+		found := false
+		for _, move := range game.GetLegalMoves() {
+			if move.String() == played {
+				game.MakeMove(move)
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("Expected %s to be legal in %s", played, game.EBE.ToFEN())
+		}
+	}
+}
+
+func openStore(t *testing.T, path string) (*GameStore, *Journal, int) {
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening %s, got %s", path, err)
+	}
+
+	s := New(time.Hour, 0)
+	restored, err := s.Persist(journal)
+	if err != nil {
+		t.Fatalf("Expected no error restoring games, got %s", err)
+	}
+
+	return s, journal, restored
+}
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Save(Record{ID: "a", State: "first"})
+	journal.Save(Record{ID: "b", State: "kept"})
+	journal.Save(Record{ID: "a", State: "second"})
+	journal.Save(Record{ID: "c", State: "deleted"})
+	journal.Delete("c")
+	// finished games are in the archive, so they're dropped on replay
+	journal.Save(Record{ID: "e", State: "finished", Ended: true})
+	journal.Close()
+
+	// a write that was cut short shouldn't lose the rest of the journal
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"op":"save","record":{"id":"d"`)
+	f.Close()
+
+	journal, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	records, _ := journal.Load()
+	states := map[string]string{}
+	for _, record := range records {
+		states[record.ID] = record.State
+	}
+
+	expected := map[string]string{"a": "second", "b": "kept"}
+	if len(states) != len(expected) {
+		t.Fatalf("Expected records (%v) != actual records (%v)", expected, states)
+	}
+	for id, state := range expected {
+		if states[id] != state {
+			t.Errorf("Expected state of %s (%s) != actual state (%s)", id, state, states[id])
+		}
+	}
+
+	// replaying compacts the journal down to the games it still holds
+	bytes, _ := os.ReadFile(path)
+	if lines := strings.Count(string(bytes), "\n"); lines != len(expected) {
+		t.Errorf("Expected lines (%d) != actual lines (%d)", len(expected), lines)
+	}
+}
+
+func TestPersistRestoresGames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	s, journal, _ := openStore(t, path)
+
+	chessGame := chess.NewGame()
+	chessGame.MaxSearchDepth = 6
+	chessEntry, _ := s.Add(chessGame, &utils.TwoPlayerGame{Active: "White", Player: "Black"})
+
+	chessEntry.Lock()
+	playChess(t, chessGame, "e2e4", "d7d5", "e4d5", "g8f6")
+	chessEntry.Data.Started = true
+	chessEntry.Data.Active = "White"
+	chessEntry.Data.BotDepth = 4
+	chessEntry.Unlock()
+
+	tttGame := tictactoe.NewGame()
+	tttEntry, _ := s.Add(tttGame, &utils.TwoPlayerGame{Active: "X"})
+
+	tttEntry.Lock()
+	tttGame.MakeMove(4)
+	tttGame.MakeMove(0)
+	tttEntry.Unlock()
+
+	// finished games are in the archive, so they're deleted as soon as
+	// they end, before they're removed from the store
+	finished, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	finished.Lock()
+	finished.Data.Ended = true
+	finished.Unlock()
+
+	// abandoned games are forgotten
+	abandoned, _ := s.Add(chess.NewGame(), &utils.TwoPlayerGame{})
+	abandoned.Lock()
+	s.Remove(abandoned.ID)
+	abandoned.Unlock()
+
+	journal.Close()
+
+	s, journal, restored := openStore(t, path)
+	defer journal.Close()
+
+	if restored != 2 {
+		t.Errorf("Expected restored (%d) != actual restored (%d)", 2, restored)
+	}
+
+	entry, err := s.Get(chessEntry.ID)
+	if err != nil {
+		t.Fatalf("Expected chess game to be restored, got %s", err)
+	}
+	game := entry.Game.(*chess.ChessGame)
+	if game.EBE.ToFEN() != chessGame.EBE.ToFEN() {
+		t.Errorf("Expected FEN (%s) != actual FEN (%s)", chessGame.EBE.ToFEN(), game.EBE.ToFEN())
+	}
+	if len(game.Moves) != 4 {
+		t.Errorf("Expected moves (%d) != actual moves (%d)", 4, len(game.Moves))
+	}
+	if game.MaxSearchDepth != 6 {
+		t.Errorf("Expected search depth (%d) != actual search depth (%d)", 6, game.MaxSearchDepth)
+	}
+	if entry.Data.Player != "Black" || !entry.Data.Started || entry.Data.BotDepth != 4 || len(entry.Data.Cells) != 64 {
+		t.Errorf("Expected game data to be restored, got %+v", entry.Data)
+	}
+
+	entry, err = s.Get(tttEntry.ID)
+	if err != nil {
+		t.Fatalf("Expected tic-tac-toe game to be restored, got %s", err)
+	}
+	if entry.Game.(*tictactoe.TicTacToeGame).ToGameString() != tttGame.ToGameString() {
+		t.Errorf("Expected state (%s) != actual state (%s)", tttGame.ToGameString(), entry.Game.(*tictactoe.TicTacToeGame).ToGameString())
+	}
+	if moves := entry.Game.(*tictactoe.TicTacToeGame).Moves; len(moves) != 2 || moves[0] != 4 {
+		t.Errorf("Expected moves (%v) != actual moves (%v)", tttGame.Moves, moves)
+	}
+	if !entry.Created.Equal(tttEntry.Created) {
+		t.Errorf("Expected created (%s) != actual created (%s)", tttEntry.Created, entry.Created)
+	}
+
+	records, _ := journal.Load()
+	for _, record := range records {
+		switch record.ID {
+		case finished.ID:
+			t.Errorf("Expected finished game %s to be deleted", finished.ID)
+		case abandoned.ID:
+			t.Errorf("Expected abandoned game %s to be deleted", abandoned.ID)
+		}
+	}
+}
+
+func TestChessResult(t *testing.T) {
+	game := chess.NewGame()
+	playChess(t, game, "f2f3", "e7e5", "g2g4", "d8h4")
+
+	entry := &Entry{ID: "mate", Game: game, Data: &utils.TwoPlayerGame{Ended: true}}
+	record, err := snapshot(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Result != "0-1" {
+		t.Errorf("Expected result (%s) != actual result (%s)", "0-1", record.Result)
+	}
+	if termination := Termination(game, entry.Data); termination != "Checkmate" {
+		t.Errorf("Expected termination (%s) != actual termination (%s)", "Checkmate", termination)
+	}
+}
+
+func TestAdjudicatedResult(t *testing.T) {
+	game := chess.NewGame()
+	playChess(t, game, "e2e4")
+
+	// the position is a draw by the board's reckoning, but was adjudicated
+	data := &utils.TwoPlayerGame{Active: "Black", Started: true, Ended: true, Result: "1-0", Termination: "Adjudication"}
+	record, err := snapshot(&Entry{ID: "adjudicated", Game: game, Data: data, start: chess.NewGame().EBE.ToFEN()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, restored, err := restore(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := Result(game, restored); result != "1-0" {
+		t.Errorf("Expected result (%s) != actual result (%s)", "1-0", result)
+	}
+	if termination := Termination(game, restored); termination != "Adjudication" {
+		t.Errorf("Expected termination (%s) != actual termination (%s)", "Adjudication", termination)
+	}
+}
+
+func TestClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	s, _, _ := openStore(t, path)
+
+	game := tictactoe.NewGame()
+	entry, _ := s.Add(game, &utils.TwoPlayerGame{Active: "X"})
+	entry.Lock()
+	game.MakeMove(4)
+	entry.Unlock()
+
+	s.Drain()
+	_, err := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrClosed, err)
+	}
+
+	// games already in the store can still be played while it drains
+	entry.Lock()
+	game.MakeMove(0)
+	entry.Unlock()
+
+	err = s.Close()
+	if err != nil {
+		t.Fatalf("Expected no error closing, got %s", err)
+	}
+
+	// changes after closing aren't saved
+	entry.Lock()
+	game.MakeMove(8)
+	entry.Unlock()
+
+	s, journal, restored := openStore(t, path)
+	defer journal.Close()
+
+	if restored != 1 {
+		t.Fatalf("Expected restored (%d) != actual restored (%d)", 1, restored)
+	}
+	restoredEntry, _ := s.Get(entry.ID)
+	if moves := restoredEntry.Game.(*tictactoe.TicTacToeGame).Moves; len(moves) != 2 {
+		t.Errorf("Expected moves (%v) != actual moves (%v)", []int{4, 0}, moves)
+	}
+}
@@ -0,0 +1,282 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/chess"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+const (
+	KindChess     = "chess"
+	KindTicTacToe = "tictactoe"
+)
+
+// Record is the persisted form of a game. Games are rebuilt by playing Moves
+// from Start, and State is where they should end up: the FEN of a chess game
+// or the game string of a tic-tac-toe game. Chess moves are written as from
+// and to squares, and tic-tac-toe moves as cell numbers. Tic-tac-toe games
+// saved before their moves were kept are rebuilt from State alone.
+type Record struct {
+	ID    string   `json:"id"`
+	Kind  string   `json:"kind"`
+	Start string   `json:"start"`
+	State string   `json:"state"`
+	Moves []string `json:"moves,omitempty"`
+
+	// Result is 1-0, 0-1 or 1/2-1/2 once the game has ended, with X as the
+	// first player in tic-tac-toe.
+	Result string `json:"result,omitempty"`
+	// Termination is only kept for games ended off the board, whose result
+	// can't be worked out again from their moves.
+	Termination string `json:"termination,omitempty"`
+
+	Player  string `json:"player"`
+	Active  string `json:"active"`
+	Status  string `json:"status"`
+	Started bool   `json:"started"`
+	Ended   bool   `json:"ended"`
+
+	Online bool              `json:"online,omitempty"`
+	Seats  map[string]string `json:"seats,omitempty"`
+	Names  map[string]string `json:"names,omitempty"`
+	Owner  string            `json:"owner,omitempty"`
+	Clock  *utils.Clock      `json:"clock,omitempty"`
+	Rated  bool              `json:"rated,omitempty"`
+
+	Invitee string `json:"invitee,omitempty"`
+
+	SearchDepth int           `json:"search_depth"`
+	SearchTime  time.Duration `json:"search_time,omitempty"`
+	BotDepth    int           `json:"bot_depth,omitempty"`
+
+	Created time.Time `json:"created,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Position identifies where a game stands: the FEN of a chess game or the
+// game string of a tic-tac-toe game.
+func Position(game interface{}) string {
+	switch game := game.(type) {
+	case *chess.ChessGame:
+		return game.EBE.ToFEN()
+	case *tictactoe.TicTacToeGame:
+		return game.ToGameString()
+	}
+
+	return ""
+}
+
+func snapshot(e *Entry) (Record, error) {
+	record := Record{
+		ID:      e.ID,
+		Start:   e.start,
+		Player:  e.Data.Player,
+		Active:  e.Data.Active,
+		Status:  e.Data.Status,
+		Started: e.Data.Started,
+		Ended:   e.Data.Ended,
+		Online:  e.Data.Online,
+		Seats:   e.Data.Seats,
+		Names:   e.Data.Names,
+		Owner:   e.Data.Owner,
+		Clock:   e.Data.Clock,
+		Rated:   e.Data.Rated,
+		Invitee: e.Data.Invitee,
+		Created: e.Created,
+
+		BotDepth: e.Data.BotDepth,
+	}
+
+	switch game := e.Game.(type) {
+	case *chess.ChessGame:
+		record.Kind = KindChess
+		record.State = game.EBE.ToFEN()
+		record.SearchDepth = game.MaxSearchDepth
+		record.SearchTime = game.SearchTime
+		for _, move := range game.Moves {
+			record.Moves = append(record.Moves, move.String())
+		}
+	case *tictactoe.TicTacToeGame:
+		record.Kind = KindTicTacToe
+		record.State = game.ToGameString()
+		record.SearchDepth = game.SearchDepth
+		for _, move := range game.Moves {
+			record.Moves = append(record.Moves, strconv.Itoa(move))
+		}
+	default:
+		return record, fmt.Errorf("can't snapshot game of type %T", e.Game)
+	}
+
+	record.Result = Result(e.Game, e.Data)
+	record.Termination = e.Data.Termination
+
+	return record, nil
+}
+
+// Result is how a finished game ended: 1-0, 0-1 or 1/2-1/2, with X as the
+// first player in tic-tac-toe, or * if it was aborted. It's empty while the
+// game is in progress.
+func Result(game interface{}, data *utils.TwoPlayerGame) string {
+	if data.Ended && data.Result != "" {
+		return data.Result
+	}
+
+	// running out of time loses, whatever is left on the board
+	if clock := data.Clock; data.Ended && clock != nil {
+		if side, flagged := clock.Flagged(time.Now()); flagged {
+			return []string{"0-1", "1-0"}[side]
+		}
+	}
+
+	switch game := game.(type) {
+	case *chess.ChessGame:
+		if !data.Ended {
+			return ""
+		}
+		if len(game.GetLegalMoves()) == 0 && game.Bitboard.InCheck(game.EBE.Active<<3) {
+			return []string{"0-1", "1-0"}[game.EBE.Active]
+		}
+		return "1/2-1/2"
+	case *tictactoe.TicTacToeGame:
+		if ended, winner := game.GameOver(); ended {
+			return map[int]string{1: "1-0", 0: "1/2-1/2", -1: "0-1"}[winner]
+		}
+	}
+
+	return ""
+}
+
+// Termination is why a finished game ended, such as Checkmate or Time
+// forfeit. It's empty while the game is in progress.
+func Termination(game interface{}, data *utils.TwoPlayerGame) string {
+	if !data.Ended {
+		return ""
+	}
+	if data.Termination != "" {
+		return data.Termination
+	}
+	if clock := data.Clock; clock != nil {
+		if _, flagged := clock.Flagged(time.Now()); flagged {
+			return "Time forfeit"
+		}
+	}
+
+	switch game := game.(type) {
+	case *chess.ChessGame:
+		switch {
+		case len(game.GetLegalMoves()) > 0:
+			return "Fifty-move rule"
+		case game.Bitboard.InCheck(game.EBE.Active << 3):
+			return "Checkmate"
+		}
+		return "Stalemate"
+	case *tictactoe.TicTacToeGame:
+		if _, winner := game.GameOver(); winner != 0 {
+			return "Three in a row"
+		}
+		return "Board full"
+	}
+
+	return ""
+}
+
+func restore(record Record) (interface{}, *utils.TwoPlayerGame, error) {
+	data := &utils.TwoPlayerGame{
+		ID:      record.ID,
+		Player:  record.Player,
+		Active:  record.Active,
+		Status:  record.Status,
+		Started: record.Started,
+		Ended:   record.Ended,
+		Online:  record.Online,
+		Seats:   record.Seats,
+		Names:   record.Names,
+		Owner:   record.Owner,
+		Clock:   record.Clock,
+		Rated:   record.Rated,
+		Invitee: record.Invitee,
+
+		BotDepth: record.BotDepth,
+	}
+	if record.Termination != "" {
+		data.Result = record.Result
+		data.Termination = record.Termination
+	}
+
+	switch record.Kind {
+	case KindChess:
+		game := chess.NewGame()
+		err := game.SetStateFromFEN(record.Start)
+		if err != nil {
+			return nil, nil, err
+		}
+		game.MaxSearchDepth = record.SearchDepth
+		game.SearchTime = record.SearchTime
+
+		for _, played := range record.Moves {
+			found := false
+			for _, move := range game.GetLegalMoves() {
+				if move.String() == played {
+					game.MakeMove(move)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, nil, fmt.Errorf("game %s: %s is not a legal move in %s", record.ID, played, game.EBE.ToFEN())
+			}
+		}
+
+		if game.EBE.ToFEN() != record.State {
+			return nil, nil, fmt.Errorf("game %s: replayed moves reach %s, expected %s", record.ID, game.EBE.ToFEN(), record.State)
+		}
+
+		data.State = record.State
+		data.Cells = utils.FillChessCells(game, data, -1, false)
+		return game, data, nil
+	case KindTicTacToe:
+		game := tictactoe.NewGame()
+		err := game.FromString(record.State)
+		if len(record.Moves) > 0 {
+			err = replayTTT(game, record)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		game.SearchDepth = record.SearchDepth
+
+		data.State = record.State
+		data.Cells = utils.FillTTTCells(game, data)
+		return game, data, nil
+	}
+
+	return nil, nil, fmt.Errorf("game %s: unknown kind %q", record.ID, record.Kind)
+}
+
+// replayTTT sets up a tic-tac-toe game by playing a record's moves from its
+// start.
+func replayTTT(game *tictactoe.TicTacToeGame, record Record) error {
+	err := game.FromString(record.Start)
+	if err != nil {
+		return err
+	}
+
+	for _, played := range record.Moves {
+		cell, err := strconv.Atoi(played)
+		if err != nil || !slices.Contains(game.GenerateMoves(), cell) {
+			return fmt.Errorf("game %s: %s is not a legal move in %s", record.ID, played, game.ToGameString())
+		}
+		game.MakeMove(cell)
+	}
+
+	if game.ToGameString() != record.State {
+		return fmt.Errorf("game %s: replayed moves reach %s, expected %s", record.ID, game.ToGameString(), record.State)
+	}
+
+	return nil
+}
@@ -0,0 +1,479 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+)
+
+const (
+	DefaultTTL      = 30 * time.Minute
+	DefaultMaxGames = 1000
+
+	// FinishedTTL is how long a finished game stays around for the players to
+	// see the result, unless the store's TTL is shorter.
+	FinishedTTL = 5 * time.Minute
+)
+
+var (
+	ErrNotFound = errors.New("game not found")
+	ErrFull     = errors.New("too many games in progress")
+	ErrClosed   = errors.New("the server is shutting down")
+)
+
+// Entry is a single game in the store. Game is a *chess.ChessGame or a
+// *tictactoe.TicTacToeGame and, like Data, must only be read or written
+// while the entry is locked.
+type Entry struct {
+	ID   string
+	Game interface{}
+	Data *utils.TwoPlayerGame
+
+	// Created is when the game was first added to the store.
+	Created time.Time
+
+	mu         sync.Mutex
+	lastActive atomic.Int64
+
+	store   *GameStore
+	start   string
+	saved   []byte
+	removed bool
+
+	subscribersMu sync.Mutex
+	subscribers   map[chan struct{}]struct{}
+	watchers      atomic.Int32
+	queued        atomic.Int32
+}
+
+// Lock waits for exclusive access to the game and marks it as active.
+func (e *Entry) Lock() {
+	e.mu.Lock()
+	e.Touch()
+}
+
+// Unlock marks the game as active, saves and announces any changes made
+// while it was locked, and releases it.
+func (e *Entry) Unlock() {
+	e.Touch()
+	if !e.removed {
+		e.store.save(e)
+	}
+	e.mu.Unlock()
+}
+
+// Subscribe returns a channel that receives a value whenever the game
+// changes, and a function to stop listening. Changes that arrive while an
+// earlier one is still unread are merged into it. The channel is closed if
+// the game is removed from the store.
+func (e *Entry) Subscribe() (<-chan struct{}, func()) {
+	e.subscribersMu.Lock()
+	defer e.subscribersMu.Unlock()
+
+	updates := make(chan struct{}, 1)
+	if e.subscribers == nil {
+		e.subscribers = make(map[chan struct{}]struct{})
+	}
+	e.subscribers[updates] = struct{}{}
+
+	return updates, func() {
+		e.subscribersMu.Lock()
+		defer e.subscribersMu.Unlock()
+
+		if _, ok := e.subscribers[updates]; ok {
+			delete(e.subscribers, updates)
+			close(updates)
+		}
+	}
+}
+
+// Watch subscribes a spectator to the game. Spectators are counted, and
+// everyone following the game is told when one arrives or leaves.
+func (e *Entry) Watch() (<-chan struct{}, func()) {
+	updates, unsubscribe := e.Subscribe()
+	e.watchers.Add(1)
+	e.notify()
+
+	return updates, sync.OnceFunc(func() {
+		unsubscribe()
+		e.watchers.Add(-1)
+		e.notify()
+	})
+}
+
+// SetQueued records the bot's place in line for a worker to search this game
+// with, or zero once its search is running or done, and tells everyone
+// following the game.
+func (e *Entry) SetQueued(place int) {
+	if e.queued.Swap(int32(place)) != int32(place) {
+		e.notify()
+	}
+}
+
+// Queued is the bot's place in line for a worker, or zero if it isn't
+// waiting for one.
+func (e *Entry) Queued() int {
+	return int(e.queued.Load())
+}
+
+// Watchers is the number of spectators following the game.
+func (e *Entry) Watchers() int {
+	return int(e.watchers.Load())
+}
+
+func (e *Entry) Subscribers() int {
+	e.subscribersMu.Lock()
+	defer e.subscribersMu.Unlock()
+
+	return len(e.subscribers)
+}
+
+func (e *Entry) notify() {
+	e.subscribersMu.Lock()
+	defer e.subscribersMu.Unlock()
+
+	for updates := range e.subscribers {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *Entry) closeSubscribers() {
+	e.subscribersMu.Lock()
+	defer e.subscribersMu.Unlock()
+
+	for updates := range e.subscribers {
+		delete(e.subscribers, updates)
+		close(updates)
+	}
+}
+
+// Start is the position the game was set up from, as given by Position.
+func (e *Entry) Start() string {
+	return e.start
+}
+
+// Touch resets the game's idle timer without locking it.
+func (e *Entry) Touch() {
+	e.lastActive.Store(time.Now().UnixNano())
+}
+
+func (e *Entry) LastActive() time.Time {
+	return time.Unix(0, e.lastActive.Load())
+}
+
+// GameStore holds every game in progress, for browser and SSH players alike.
+// Games that see no activity for longer than the TTL are removed by Sweep,
+// and no more than maxGames can be in progress at once.
+type GameStore struct {
+	mu    sync.RWMutex
+	games map[string]*Entry
+
+	ttl      time.Duration
+	maxGames int
+
+	backend Backend
+
+	// draining stops new games being added, and closed stops games being
+	// saved once the backend has been closed
+	draining atomic.Bool
+	closed   atomic.Bool
+}
+
+// New creates a store. A ttl of zero keeps idle games forever and a maxGames
+// of zero allows any number of games.
+func New(ttl time.Duration, maxGames int) *GameStore {
+	return &GameStore{
+		games:    make(map[string]*Entry),
+		ttl:      ttl,
+		maxGames: maxGames,
+	}
+}
+
+func generateID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+
+	return fmt.Sprintf("%x", bytes)
+}
+
+// Add stores a new game under a fresh ID, which is also written to data.ID.
+// The game should be fully set up, since it's saved straight away.
+// When the store is full, expired games are swept first and ErrFull is
+// returned if there is still no room. Once the store is draining, ErrClosed
+// is returned instead.
+func (s *GameStore) Add(game interface{}, data *utils.TwoPlayerGame) (*Entry, error) {
+	if s.draining.Load() {
+		return nil, ErrClosed
+	}
+
+	if s.maxGames > 0 && s.Len() >= s.maxGames {
+		s.Sweep()
+	}
+
+	s.mu.Lock()
+	if s.maxGames > 0 && len(s.games) >= s.maxGames {
+		s.mu.Unlock()
+		return nil, ErrFull
+	}
+
+	id := generateID()
+	for s.games[id] != nil {
+		id = generateID()
+	}
+	data.ID = id
+
+	entry := &Entry{
+		ID:      id,
+		Game:    game,
+		Data:    data,
+		Created: time.Now(),
+		store:   s,
+		start:   Position(game),
+	}
+	entry.Lock()
+	s.games[id] = entry
+	s.mu.Unlock()
+
+	// unlocking saves the game, which records how it was set up
+	entry.Unlock()
+
+	return entry, nil
+}
+
+// Persist restores every unfinished game saved in the backend and saves all
+// changes to it from now on. It returns the number of games restored.
+func (s *GameStore) Persist(backend Backend) (int, error) {
+	records, err := backend.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restored := 0
+	for _, record := range records {
+		if record.Ended || s.games[record.ID] != nil {
+			continue
+		}
+
+		game, data, err := restore(record)
+		if err != nil {
+			slog.Warn("can't restore game", "game", record.ID, "err", err)
+			continue
+		}
+
+		entry := &Entry{
+			ID:      record.ID,
+			Game:    game,
+			Data:    data,
+			Created: record.Created,
+			store:   s,
+			start:   record.Start,
+		}
+		entry.lastActive.Store(record.Updated.UnixNano())
+		record.Updated = time.Time{}
+		entry.saved, _ = json.Marshal(record)
+		s.games[record.ID] = entry
+		restored++
+	}
+
+	s.backend = backend
+
+	return restored, nil
+}
+
+// save announces the entry to its subscribers and writes it to the backend if
+// it changed since it was last saved. The entry must be locked.
+func (s *GameStore) save(e *Entry) {
+	record, err := snapshot(e)
+	if err != nil {
+		slog.Error("error saving game", "game", e.ID, "err", err)
+		return
+	}
+
+	// the timestamp is left out of the comparison so that requests which only
+	// read the game don't count as a change
+	bytes, _ := json.Marshal(record)
+	if string(bytes) == string(e.saved) {
+		return
+	}
+	e.saved = bytes
+	e.notify()
+
+	if s.backend == nil || s.closed.Load() {
+		return
+	}
+
+	// finished games are kept by the archive, so only the games still going
+	// need to be saved to be resumed
+	if e.Data.Ended {
+		err = s.backend.Delete(e.ID)
+		if err != nil {
+			slog.Error("error deleting game", "game", e.ID, "err", err)
+		}
+		return
+	}
+
+	record.Updated = time.Unix(0, e.lastActive.Load())
+	err = s.backend.Save(record)
+	if err != nil {
+		slog.Error("error saving game", "game", e.ID, "err", err)
+	}
+}
+
+// Get returns the game with the given ID. The entry is not locked.
+func (s *GameStore) Get(id string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.games[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	return entry, nil
+}
+
+// Remove deletes the game with the given ID, if there is one, and must be
+// called while holding the entry's lock. The game is dropped from the backend
+// too, finished or not.
+func (s *GameStore) Remove(id string) {
+	s.mu.Lock()
+	entry, ok := s.games[id]
+	delete(s.games, id)
+	s.mu.Unlock()
+
+	if ok {
+		s.forget(entry)
+	}
+}
+
+func (s *GameStore) forget(e *Entry) {
+	if e.removed {
+		return
+	}
+
+	if s.backend != nil {
+		err := s.backend.Delete(e.ID)
+		if err != nil {
+			slog.Error("error deleting game", "game", e.ID, "err", err)
+		}
+	}
+	e.removed = true
+	e.closeSubscribers()
+}
+
+// Entries returns every game in the store, in no particular order.
+func (s *GameStore) Entries() []*Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]*Entry, 0, len(s.games))
+	for _, entry := range s.games {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func (s *GameStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.games)
+}
+
+// Sweep removes every game that has been idle for longer than the TTL, or
+// finished for longer than FinishedTTL, and returns how many were removed.
+// Games that are locked are in use, so they are left alone even if their
+// last activity is old.
+func (s *GameStore) Sweep() int {
+	if s.ttl <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	finishedTTL := min(s.ttl, FinishedTTL)
+
+	// expired games are taken out of the store under its lock, but dropped
+	// from the backend after, so that other games can be found meanwhile
+	expired := []*Entry{}
+	s.mu.Lock()
+	for id, entry := range s.games {
+		if !entry.LastActive().Before(now.Add(-finishedTTL)) || !entry.mu.TryLock() {
+			continue
+		}
+
+		ttl := s.ttl
+		if entry.Data.Ended {
+			ttl = finishedTTL
+		}
+		if !entry.LastActive().Before(now.Add(-ttl)) {
+			entry.mu.Unlock()
+			continue
+		}
+
+		delete(s.games, id)
+		expired = append(expired, entry)
+	}
+	s.mu.Unlock()
+
+	for _, entry := range expired {
+		s.forget(entry)
+		entry.mu.Unlock()
+	}
+
+	return len(expired)
+}
+
+// Drain stops new games being added, while the games already in the store
+// carry on as usual.
+func (s *GameStore) Drain() {
+	s.draining.Store(true)
+}
+
+// Close drains the store and closes its backend, once any saves under way
+// have finished. Every change has already been saved by then, so games that
+// change afterwards are only changed in memory.
+func (s *GameStore) Close() error {
+	s.Drain()
+	if s.closed.Swap(true) || s.backend == nil {
+		return nil
+	}
+
+	// games are saved while they're locked, so locking each one waits for
+	// its save to finish
+	for _, entry := range s.Entries() {
+		entry.mu.Lock()
+		entry.mu.Unlock()
+	}
+
+	return s.backend.Close()
+}
+
+// Run sweeps expired games every interval until ctx is cancelled.
+func (s *GameStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/routes/utils"
+	"github.com/jfosburgh/gomes/pkg/tictactoe"
+)
+
+func TestAddGet(t *testing.T) {
+	s := New(0, 0)
+
+	data := &utils.TwoPlayerGame{}
+	entry, err := s.Add(tictactoe.NewGame(), data)
+	if err != nil {
+		t.Fatalf("Expected no error adding a game, got %s", err)
+	}
+
+	if data.ID != entry.ID {
+		t.Errorf("Expected data ID (%s) != actual data ID (%s)", entry.ID, data.ID)
+	}
+
+	actual, err := s.Get(entry.ID)
+	if err != nil {
+		t.Fatalf("Expected no error getting %s, got %s", entry.ID, err)
+	}
+	if actual != entry {
+		t.Errorf("Expected entry (%p) != actual entry (%p)", entry, actual)
+	}
+
+	s.Remove(entry.ID)
+	_, err = s.Get(entry.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrNotFound, err)
+	}
+}
+
+func TestMaxGames(t *testing.T) {
+	s := New(0, 2)
+
+	for range 2 {
+		_, err := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+		if err != nil {
+			t.Fatalf("Expected no error adding a game, got %s", err)
+		}
+	}
+
+	_, err := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	if !errors.Is(err, ErrFull) {
+		t.Errorf("Expected error (%v) != actual error (%v)", ErrFull, err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	s := New(time.Hour, 2)
+
+	idle, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	busy, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	idle.lastActive.Store(time.Now().Add(-2 * time.Hour).UnixNano())
+
+	// a locked game is in use, however long ago it was last touched
+	busy.Lock()
+	busy.lastActive.Store(time.Now().Add(-2 * time.Hour).UnixNano())
+
+	removed := s.Sweep()
+	if removed != 1 {
+		t.Errorf("Expected removed (%d) != actual removed (%d)", 1, removed)
+	}
+	if _, err := s.Get(idle.ID); err == nil {
+		t.Errorf("Expected idle game %s to be swept", idle.ID)
+	}
+	if _, err := s.Get(busy.ID); err != nil {
+		t.Errorf("Expected locked game %s to survive the sweep, got %s", busy.ID, err)
+	}
+	busy.Unlock()
+
+	// a full store makes room by sweeping before it refuses a game
+	busy.lastActive.Store(time.Now().Add(-2 * time.Hour).UnixNano())
+	s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	_, err := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	if err != nil {
+		t.Errorf("Expected no error adding to a store with expired games, got %s", err)
+	}
+}
+
+// lookupBackend looks a game up in its store while it deletes one, as other
+// requests may while a sweep is dropping games from a slow backend.
+type lookupBackend struct {
+	t     *testing.T
+	store *GameStore
+	id    string
+}
+
+func (b lookupBackend) Save(Record) error       { return nil }
+func (b lookupBackend) Load() ([]Record, error) { return nil, nil }
+func (b lookupBackend) Close() error            { return nil }
+func (b lookupBackend) Delete(id string) error {
+	found := make(chan struct{})
+	go func() {
+		b.store.Get(b.id)
+		close(found)
+	}()
+
+	select {
+	case <-found:
+	case <-time.After(time.Second):
+		b.t.Errorf("Expected to look up %s while %s was deleted", b.id, id)
+	}
+
+	return nil
+}
+
+func TestSweepUnlocked(t *testing.T) {
+	s := New(time.Hour, 0)
+	idle, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	other, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	s.Persist(lookupBackend{t: t, store: s, id: other.ID})
+
+	idle.lastActive.Store(time.Now().Add(-2 * time.Hour).UnixNano())
+	if removed := s.Sweep(); removed != 1 {
+		t.Errorf("Expected removed (%d) != actual removed (%d)", 1, removed)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	s := New(time.Hour, 0)
+	entry, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+	initial := entry.Game.(*tictactoe.TicTacToeGame).SearchDepth
+
+	wg := sync.WaitGroup{}
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			e, err := s.Get(entry.ID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			e.Lock()
+			e.Game.(*tictactoe.TicTacToeGame).SearchDepth++
+			e.Unlock()
+
+			s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+			s.Sweep()
+		}()
+	}
+	wg.Wait()
+
+	if entry.Game.(*tictactoe.TicTacToeGame).SearchDepth != initial+50 {
+		t.Errorf("Expected search depth (%d) != actual search depth (%d)", initial+50, entry.Game.(*tictactoe.TicTacToeGame).SearchDepth)
+	}
+	if s.Len() != 51 {
+		t.Errorf("Expected games (%d) != actual games (%d)", 51, s.Len())
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := New(time.Hour, 0)
+	entry, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+
+	updates, unsubscribe := entry.Subscribe()
+	defer unsubscribe()
+
+	// only changes are announced, not every request that looks at the game
+	entry.Lock()
+	entry.Unlock()
+	select {
+	case <-updates:
+		t.Errorf("Expected no update for an unchanged game")
+	default:
+	}
+
+	entry.Lock()
+	entry.Game.(*tictactoe.TicTacToeGame).MakeMove(4)
+	entry.Unlock()
+	select {
+	case <-updates:
+	default:
+		t.Errorf("Expected an update after a move")
+	}
+
+	entry.Lock()
+	s.Remove(entry.ID)
+	entry.Unlock()
+	if _, open := <-updates; open {
+		t.Errorf("Expected updates to be closed when the game is removed")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s := New(time.Hour, 0)
+	entry, _ := s.Add(tictactoe.NewGame(), &utils.TwoPlayerGame{})
+
+	updates, unsubscribe := entry.Subscribe()
+	defer unsubscribe()
+
+	_, stopWatching := entry.Watch()
+	if entry.Watchers() != 1 {
+		t.Errorf("Expected watchers (%d) != actual watchers (%d)", 1, entry.Watchers())
+	}
+	select {
+	case <-updates:
+	default:
+		t.Errorf("Expected an update when a spectator arrives")
+	}
+
+	// leaving twice only counts once
+	stopWatching()
+	stopWatching()
+	if entry.Watchers() != 0 {
+		t.Errorf("Expected watchers (%d) != actual watchers (%d)", 0, entry.Watchers())
+	}
+	select {
+	case <-updates:
+	default:
+		t.Errorf("Expected an update when a spectator leaves")
+	}
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jfosburgh/gomes/internal/config"
+)
+
+// selfSignedFor is how long a self-signed certificate is valid, which only
+// has to outlast the server that made it.
+const selfSignedFor = 365 * 24 * time.Hour
+
+// newTLSConfig serves the configured certificate, or a self-signed one for
+// local use if there isn't one.
+func newTLSConfig(cfg config.HTTP) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if cfg.Cert != "" {
+		cert, err = tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	} else {
+		hosts := []string{"localhost", "127.0.0.1", "::1"}
+		if host, _, _ := net.SplitHostPort(cfg.Addr); host != "" {
+			hosts = append(hosts, host)
+		}
+		slog.Warn("serving HTTPS with a self-signed certificate, which browsers will warn about", "hosts", hosts)
+		cert, err = selfSignedCertificate(hosts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// selfSignedCertificate makes a certificate for hosts, which may be names or
+// IP addresses, signed by its own key. It's made anew each time the server
+// starts, and never written to disk.
+func selfSignedCertificate(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Gomes"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL over HTTPS, on
+// the port the HTTPS server listens on.
+func redirectToHTTPS(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		host     string
+		addr     string
+		location string
+	}{
+		{"example.com", ":443", "https://example.com/games/chess?x=1"},
+		{"example.com", ":8443", "https://example.com:8443/games/chess?x=1"},
+		{"example.com:80", ":443", "https://example.com/games/chess?x=1"},
+		{"example.com:80", ":8443", "https://example.com:8443/games/chess?x=1"},
+		{"[::1]:80", ":443", "https://[::1]/games/chess?x=1"},
+		{"[::1]:80", ":8443", "https://[::1]:8443/games/chess?x=1"},
+		{"::1", ":443", "https://[::1]/games/chess?x=1"},
+		{"::1", ":8443", "https://[::1]:8443/games/chess?x=1"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/games/chess?x=1", nil)
+		r.Host = test.host
+		w := httptest.NewRecorder()
+		redirectToHTTPS(test.addr).ServeHTTP(w, r)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s on %s: Expected status (%d) != actual status (%d)", test.host, test.addr, http.StatusPermanentRedirect, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s on %s: Expected location (%s) != actual location (%s)", test.host, test.addr, test.location, location)
+		}
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	hosts := []string{"localhost", "127.0.0.1", "::1", "example.com"}
+	cert, err := selfSignedCertificate(hosts...)
+	if err != nil {
+		t.Fatalf("Expected no error making a certificate, got %s", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Expected no error parsing the certificate, got %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	for _, host := range hosts {
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		if err != nil {
+			t.Errorf("%s: Expected the certificate to verify, got %s", host, err)
+		}
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "example.org", Roots: roots}); err == nil {
+		t.Errorf("Expected the certificate not to verify for another host")
+	}
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock is a game clock for the two sides of a game. A side's time only runs
+// down while it's their turn, and finishing a move adds the increment.
+type Clock struct {
+	Sides     [2]string        `json:"sides"`
+	Remaining [2]time.Duration `json:"remaining"`
+	Increment time.Duration    `json:"increment"`
+
+	// Initial is the time each side started with, which decides the rating
+	// category of the game.
+	Initial time.Duration `json:"initial,omitempty"`
+
+	// Running is the index of the side whose time is running down, or -1
+	// while the clock is stopped, and Since is when it started running.
+	Running int       `json:"running"`
+	Since   time.Time `json:"since"`
+}
+
+type ClockFace struct {
+	Side      string
+	Remaining time.Duration
+	Running   bool
+}
+
+func NewClock(sides [2]string, initial, increment time.Duration) *Clock {
+	return &Clock{
+		Sides:     sides,
+		Remaining: [2]time.Duration{initial, initial},
+		Increment: increment,
+		Initial:   initial,
+		Running:   -1,
+	}
+}
+
+// Left is the time a side has left at the given moment.
+func (c *Clock) Left(side int, now time.Time) time.Duration {
+	left := c.Remaining[side]
+	if side == c.Running {
+		left -= now.Sub(c.Since)
+	}
+
+	return max(0, left)
+}
+
+// Start runs down the given side's time from now on.
+func (c *Clock) Start(side int, now time.Time) {
+	c.Stop(now)
+	c.Running = side
+	c.Since = now
+}
+
+// Stop freezes both sides' time.
+func (c *Clock) Stop(now time.Time) {
+	if c.Running == -1 {
+		return
+	}
+
+	c.Remaining[c.Running] = c.Left(c.Running, now)
+	c.Running = -1
+}
+
+// Press ends the running side's turn, adding their increment and starting
+// the other side's time.
+func (c *Clock) Press(now time.Time) {
+	if c.Running == -1 {
+		return
+	}
+
+	side := c.Running
+	c.Stop(now)
+	c.Remaining[side] += c.Increment
+	c.Start(1-side, now)
+}
+
+// Flagged returns the side that has run out of time, if either has.
+func (c *Clock) Flagged(now time.Time) (int, bool) {
+	for side := range c.Sides {
+		if c.Left(side, now) <= 0 {
+			return side, true
+		}
+	}
+
+	return -1, false
+}
+
+// Display is the clock as it reads right now, one face per side.
+func (c *Clock) Display() []ClockFace {
+	now := time.Now()
+	faces := make([]ClockFace, len(c.Sides))
+	for side := range c.Sides {
+		faces[side] = ClockFace{
+			Side:      c.Sides[side],
+			Remaining: c.Left(side, now),
+			Running:   side == c.Running,
+		}
+	}
+
+	return faces
+}
+
+func (f ClockFace) Time() string {
+	seconds := int(f.Remaining.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// ParseTimeControl reads a time control written as minutes+increment, such as
+// 5+3 for five minutes with three seconds added after every move.
+func ParseTimeControl(control string) (time.Duration, time.Duration, bool) {
+	var minutes, increment int
+	_, err := fmt.Sscanf(control, "%d+%d", &minutes, &increment)
+	if err != nil || minutes <= 0 || increment < 0 {
+		return 0, 0, false
+	}
+
+	return time.Duration(minutes) * time.Minute, time.Duration(increment) * time.Second, true
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Now()
+	clock := NewClock([2]string{"White", "Black"}, time.Minute, 2*time.Second)
+
+	if left := clock.Left(0, start.Add(time.Hour)); left != time.Minute {
+		t.Errorf("Expected a stopped clock's time (%s) != actual time (%s)", time.Minute, left)
+	}
+
+	clock.Start(0, start)
+	clock.Press(start.Add(10 * time.Second))
+	if left := clock.Left(0, start.Add(20*time.Second)); left != 52*time.Second {
+		t.Errorf("Expected White's time (%s) != actual time (%s)", 52*time.Second, left)
+	}
+	if left := clock.Left(1, start.Add(20*time.Second)); left != 50*time.Second {
+		t.Errorf("Expected Black's time (%s) != actual time (%s)", 50*time.Second, left)
+	}
+
+	if _, flagged := clock.Flagged(start.Add(69 * time.Second)); flagged {
+		t.Errorf("Expected Black to have time left")
+	}
+	side, flagged := clock.Flagged(start.Add(71 * time.Second))
+	if !flagged || side != 1 {
+		t.Errorf("Expected flagged side (%d) != actual flagged side (%d)", 1, side)
+	}
+
+	clock.Stop(start.Add(2 * time.Minute))
+	if left := clock.Left(1, start.Add(time.Hour)); left != 0 {
+		t.Errorf("Expected Black's time (%s) != actual time (%s)", time.Duration(0), left)
+	}
+}
+
+func TestClockFaceTime(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                     "0:00",
+		9 * time.Second:                       "0:09",
+		3*time.Minute + 2*time.Second:         "3:02",
+		15 * time.Minute:                      "15:00",
+		59*time.Second + 600*time.Millisecond: "1:00",
+	}
+
+	for remaining, expected := range tests {
+		actual := ClockFace{Remaining: remaining}.Time()
+		if actual != expected {
+			t.Errorf("Expected time (%s) != actual time (%s)", expected, actual)
+		}
+	}
+}
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		control   string
+		initial   time.Duration
+		increment time.Duration
+		timed     bool
+	}{
+		{"5+3", 5 * time.Minute, 3 * time.Second, true},
+		{"15+0", 15 * time.Minute, 0, true},
+		{"", 0, 0, false},
+		{"0+5", 0, 0, false},
+		{"5", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		initial, increment, timed := ParseTimeControl(test.control)
+		if initial != test.initial || increment != test.increment || timed != test.timed {
+			t.Errorf("%q: Expected (%s, %s, %t) != actual (%s, %s, %t)", test.control, test.initial, test.increment, test.timed, initial, increment, timed)
+		}
+	}
+}
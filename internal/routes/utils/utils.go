@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"fmt"
+	"log/slog"
 	"slices"
 
 	"github.com/jfosburgh/gomes/pkg/chess"
@@ -16,10 +16,77 @@ type TwoPlayerGame struct {
 	Started bool
 	Ended   bool
 
+	// Online games are played between two players, with each side's seat ID
+	// and display name stored against its colour in Seats and Names. Other
+	// games are played from a single screen, the Owner's.
+	Online bool
+	Seats  map[string]string
+	Names  map[string]string
+	Owner  string
+
+	// Clock is nil for untimed games, and Rated is set for games matched
+	// in the lobby that count towards the players' ratings.
+	Clock *Clock
+	Rated bool
+
+	// Spectators is how many people are watching the game, and Watching is
+	// set when it's being shown to one of them.
+	Spectators int
+	Watching   bool
+
+	// Invite is the link a second player joins the game by, shown while it's
+	// waiting for them. Invitee is the seat of the only player who may join a
+	// game offered to one player, and empty for a game anyone with its link
+	// may join.
+	Invite  string
+	Invitee string
+
+	// Queued is the bot's place in line when its search for this game is
+	// waiting for a worker, and zero otherwise.
+	Queued int
+	// BotDepth is the shallowest depth the bot has searched to for a move it
+	// played, held within its maximum depth, and zero until it has moved.
+	BotDepth int
+
 	State string
 	Cells []Cell
 
 	Status string
+
+	// Result and Termination are set for games that were ended off the
+	// board, such as by an admin, and are otherwise worked out from the game.
+	Result      string
+	Termination string
+}
+
+// Ahead is how many searches are in line before the bot's.
+func (g TwoPlayerGame) Ahead() int {
+	return max(g.Queued-1, 0)
+}
+
+// Involves reports whether the player in a seat owns the game, plays in it or
+// is invited to it.
+func (g TwoPlayerGame) Involves(seat string) bool {
+	if seat == "" {
+		return false
+	}
+
+	if g.Owner == seat || g.Invitee == seat {
+		return true
+	}
+	for _, id := range g.Seats {
+		if id == seat {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Waiting reports whether an online game is still waiting for its second
+// player to join.
+func (g TwoPlayerGame) Waiting() bool {
+	return g.Online && len(g.Seats) < 2
 }
 
 type Cell struct {
@@ -67,12 +134,12 @@ func FlipRank(input int) int {
 func FillTTTCells(game *tictactoe.TicTacToeGame, gameState *TwoPlayerGame) []Cell {
 	cells := make([]Cell, 9)
 	currentTurn := TTTPieces[game.State.Active] == gameState.Player || gameState.Player == ""
+	running := gameState.Started && !gameState.Ended && !gameState.Waiting()
 
 	for i := range 9 {
 		cells[i].Content = TTTPieces[game.State.Board[i]]
 
 		empty := cells[i].Content == " "
-		running := gameState.Started && !gameState.Ended
 		cells[i].Clickable = currentTurn && running && empty
 
 		classes := "ttt-game-cell"
@@ -87,13 +154,13 @@ func FillTTTCells(game *tictactoe.TicTacToeGame, gameState *TwoPlayerGame) []Cel
 
 func FillChessCells(game *chess.ChessGame, gameState *TwoPlayerGame, selected int, promoting bool) []Cell {
 	cells := make([]Cell, 64)
-	gameActive := gameState.Started && !gameState.Ended
-	playerTurn := gameState.Player == "" || ChessPlayers[gameState.Active] == game.EBE.Active<<3
+	gameActive := gameState.Started && !gameState.Ended && !gameState.Waiting()
+	playerTurn := gameState.Player == "" || gameState.Player == gameState.Active
 
 	validTargets := []int{}
 	if selected != -1 {
 		validTargets = game.GetMoveTargets(selected)
-		fmt.Printf("valid moves for %d: %+v\n", selected, validTargets)
+		slog.Debug("selected a piece", "square", selected, "targets", validTargets)
 	}
 
 	side := game.EBE.Active << 3
@@ -116,10 +183,7 @@ func FillChessCells(game *chess.ChessGame, gameState *TwoPlayerGame, selected in
 			validTarget := slices.Contains(validTargets, i)
 			if validTarget {
 				classes += " target"
-
-				fmt.Printf("checking for promotion: %04b, %04b, %d\n", game.EBE.Board[selected]&0b0111, chess.PAWN, rank)
 				if game.EBE.Board[selected]&0b0111 == chess.PAWN && (rank == 7 || rank == 0) {
-					fmt.Printf("this was a valid promotion")
 					classes += " promote"
 				}
 			}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/jfosburgh/gomes/pkg/chess"
+)
+
+func clickable(cells []Cell) int {
+	count := 0
+	for _, cell := range cells {
+		if cell.Clickable {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestFillChessCellsOnline(t *testing.T) {
+	game := chess.NewGame()
+
+	tests := []struct {
+		name     string
+		data     TwoPlayerGame
+		expected int
+	}{
+		{"local game", TwoPlayerGame{Active: "White", Started: true}, 16},
+		{"player to move", TwoPlayerGame{Active: "White", Player: "White", Started: true, Online: true, Seats: map[string]string{"White": "a", "Black": "b"}}, 16},
+		{"opponent", TwoPlayerGame{Active: "White", Player: "Black", Started: true, Online: true, Seats: map[string]string{"White": "a", "Black": "b"}}, 0},
+		{"spectator", TwoPlayerGame{Active: "White", Player: "Spectator", Started: true, Online: true, Seats: map[string]string{"White": "a", "Black": "b"}}, 0},
+		{"waiting for opponent", TwoPlayerGame{Active: "White", Player: "White", Started: true, Online: true, Seats: map[string]string{"White": "a"}}, 0},
+	}
+
+	for _, test := range tests {
+		actual := clickable(FillChessCells(game, &test.data, -1, false))
+		if actual != test.expected {
+			t.Errorf("%s: Expected clickable cells (%d) != actual clickable cells (%d)", test.name, test.expected, actual)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package chess
 
 import (
-	"fmt"
 	"math"
 	"math/rand"
 	"os"
@@ -75,10 +74,25 @@ func ChooseFromCodebook(state EBEBoard, active int) (Move, bool) {
 }
 
 func ReadPGNToCodebook(filepath string, moveLimit int) error {
+	return ReadPGNGames(filepath, func(g *ChessGame, ply int, move Move, tags map[string]string) bool {
+		if ply >= moveLimit {
+			return false
+		}
+
+		addToCodebook(g.EBE.Board, move, g.EBE.Active)
+		return true
+	})
+}
+
+// ReadPGNGames replays every game in a PGN file, calling visit with the
+// position before each move is made. Returning false from visit skips the
+// rest of that game. Games that can't be parsed are skipped.
+func ReadPGNGames(filepath string, visit func(g *ChessGame, ply int, move Move, tags map[string]string) bool) error {
 	f, err := os.Open(filepath)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
 	ps := pgn.NewPGNScanner(f)
 	processed := 0
@@ -92,44 +106,55 @@ func ReadPGNToCodebook(filepath string, moveLimit int) error {
 			continue
 		}
 
+		if fen, ok := pgnGame.Tags["FEN"]; ok {
+			err = g.SetStateFromFEN(fen)
+			if err != nil {
+				skipped += 1
+				continue
+			}
+		}
+
 		processed += 1
 
 		for i, pgnMove := range pgnGame.Moves {
-			if i >= moveLimit {
+			move := g.moveFromPGN(pgnMove)
+			if !visit(g, i, move, pgnGame.Tags) {
 				break
 			}
 
-			start := algebraic2Int(pgnMove.From.String())
-			end := algebraic2Int(pgnMove.To.String())
-			piece := g.EBE.Board[start]
+			g.MakeMove(move)
+		}
+	}
 
-			capture := g.EBE.Board[end]
-			if piece&0b0111 == PAWN && start%8 != end%8 {
-				offset := 8 - (16 * g.EBE.Active)
-				capture = g.EBE.Board[start+offset]
-			}
+	Logger.Info("read PGN games", "path", filepath, "processed", processed, "skipped", skipped)
 
-			move := Move{
-				Piece: piece,
-				Start: start,
-				End:   end,
+	return nil
+}
 
-				Capture:   capture,
-				Castle:    piece&0b0111 == KING && math.Abs(float64(end)-float64(start)) == 2,
-				Promotion: PieceConverter[pgnMove.Promote],
+func (c *ChessGame) moveFromPGN(pgnMove pgn.Move) Move {
+	start := algebraic2Int(pgnMove.From.String())
+	end := algebraic2Int(pgnMove.To.String())
+	piece := c.EBE.Board[start]
+
+	// a diagonal pawn move onto an empty square is en passant, so the
+	// captured pawn sits behind the destination square
+	capture := c.EBE.Board[end]
+	if piece&0b0111 == PAWN && start%8 != end%8 && capture == EMPTY {
+		offset := 8 - (16 * c.EBE.Active)
+		capture = c.EBE.Board[end-offset]
+	}
 
-				Halfmoves:       g.EBE.Halfmoves,
-				CastlingRights:  g.EBE.CastlingRights,
-				EnPassantTarget: g.EBE.EnPassantTarget,
-			}
+	return Move{
+		Piece: piece,
+		Start: start,
+		End:   end,
 
-			addToCodebook(g.EBE.Board, move, g.EBE.Active)
+		Capture:   capture,
+		Castle:    piece&0b0111 == KING && math.Abs(float64(end)-float64(start)) == 2,
+		Promotion: PieceConverter[pgnMove.Promote],
 
-			g.MakeMove(move)
-		}
+		Halfmoves:       c.EBE.Halfmoves,
+		CastlingRights:  c.EBE.CastlingRights,
+		EnPassantTarget: c.EBE.EnPassantTarget,
 	}
-
-	fmt.Printf("processed %d games, skipped %d games\n", processed, skipped)
-
-	return nil
 }
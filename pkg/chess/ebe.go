@@ -1,6 +1,7 @@
 package chess
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -131,68 +132,211 @@ func castlingRightsToString(castlingRights int) string {
 	return s
 }
 
-func (b *EBE) FromFEN(fen string) {
-	fenParts := strings.Split(fen, " ")
-	rank := 7
-	file := 0
+var ErrInvalidFEN = errors.New("invalid FEN")
 
-	b.Board = [64]int{}
+func fenError(fen, format string, a ...any) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidFEN, fen, fmt.Sprintf(format, a...))
+}
 
-	placements := strings.Split(fenParts[0], "")
-	for i := range placements {
-		if placements[i] == "/" {
-			rank -= 1
-			file = 0
-			continue
-		}
+// FromFEN parses a FEN string into the board. The position is checked for
+// legality as well as syntax, and the board is left unchanged if it isn't
+// valid.
+func (b *EBE) FromFEN(fen string) error {
+	fenParts := strings.Fields(fen)
+	if len(fenParts) < 4 || len(fenParts) > 6 {
+		return fenError(fen, "expected 4 to 6 fields, found %d", len(fenParts))
+	}
 
-		empty, ok := strconv.Atoi(placements[i])
-		if ok == nil {
-			file += empty
-			continue
+	parsed := EBE{
+		Halfmoves: 0,
+		Moves:     1,
+	}
+
+	ranks := strings.Split(fenParts[0], "/")
+	if len(ranks) != 8 {
+		return fenError(fen, "expected 8 ranks, found %d", len(ranks))
+	}
+
+	for i, placements := range ranks {
+		rank := 7 - i
+		file := 0
+		for _, char := range placements {
+			if char >= '1' && char <= '8' {
+				file += int(char - '0')
+				continue
+			}
+
+			piece, ok := string2Piece[string(char)]
+			if !ok || piece == EMPTY {
+				return fenError(fen, "'%c' on rank %d is not a piece", char, rank+1)
+			}
+
+			if file < 8 {
+				parsed.Board[rank*8+file] = piece
+			}
+			file += 1
 		}
 
-		b.Board[rank*8+file] = string2Piece[placements[i]]
-		file += 1
+		if file != 8 {
+			return fenError(fen, "rank %d has %d squares, expected 8", rank+1, file)
+		}
 	}
 
-	if fenParts[1] == "w" {
-		b.Active = 0b0
-	} else {
-		b.Active = 0b1
+	switch fenParts[1] {
+	case "w":
+		parsed.Active = 0b0
+	case "b":
+		parsed.Active = 0b1
+	default:
+		return fenError(fen, "active color must be 'w' or 'b', found '%s'", fenParts[1])
 	}
 
-	castlingChars := strings.Split(fenParts[2], "")
-	b.CastlingRights = 0
-	for _, char := range castlingChars {
-		switch char {
-		case "-":
-			break
-		case "K":
-			b.CastlingRights = b.CastlingRights | (0b1 << 3)
-		case "Q":
-			b.CastlingRights = b.CastlingRights | (0b1 << 2)
-		case "k":
-			b.CastlingRights = b.CastlingRights | (0b1 << 1)
-		case "q":
-			b.CastlingRights = b.CastlingRights | 0b1
+	if fenParts[2] != "-" {
+		for _, char := range fenParts[2] {
+			right := 0
+			switch char {
+			case 'K':
+				right = 0b1 << 3
+			case 'Q':
+				right = 0b1 << 2
+			case 'k':
+				right = 0b1 << 1
+			case 'q':
+				right = 0b1
+			default:
+				return fenError(fen, "'%c' is not a castling right", char)
+			}
+
+			if parsed.CastlingRights&right != 0 {
+				return fenError(fen, "castling right '%c' is repeated", char)
+			}
+			parsed.CastlingRights = parsed.CastlingRights | right
 		}
 	}
 
-	enPassantPos := fenParts[3]
-	if enPassantPos == "-" {
-		b.EnPassantTarget = -1
+	if fenParts[3] == "-" {
+		parsed.EnPassantTarget = -1
 	} else {
-		b.EnPassantTarget = algebraic2Int(enPassantPos)
+		if !isSquare(fenParts[3]) {
+			return fenError(fen, "en passant target '%s' is not a square", fenParts[3])
+		}
+		parsed.EnPassantTarget = algebraic2Int(fenParts[3])
 	}
 
 	if len(fenParts) >= 5 {
-		halfmoves, _ := strconv.Atoi(fenParts[4])
-		b.Halfmoves = halfmoves
+		halfmoves, err := strconv.Atoi(fenParts[4])
+		if err != nil || halfmoves < 0 {
+			return fenError(fen, "halfmove clock '%s' is not a non-negative number", fenParts[4])
+		}
+		parsed.Halfmoves = halfmoves
 	}
 
 	if len(fenParts) >= 6 {
-		moves, _ := strconv.Atoi(fenParts[5])
-		b.Moves = moves
+		moves, err := strconv.Atoi(fenParts[5])
+		if err != nil || moves < 1 {
+			return fenError(fen, "fullmove number '%s' is not a positive number", fenParts[5])
+		}
+		parsed.Moves = moves
+	}
+
+	err := parsed.validate()
+	if err != nil {
+		return fenError(fen, "%s", err)
+	}
+
+	*b = parsed
+	return nil
+}
+
+// validate checks that a parsed position could occur in a game: one king per
+// side, no pawns on the back ranks, castling rights and the en passant target
+// that match the pieces, and the side that just moved not left in check.
+func (b *EBE) validate() error {
+	// a position may be parsed before any game has set up the move lookups,
+	// and it should be ready to generate moves from once it's valid
+	if !LOOKUPS_INITIALIZED {
+		InitLookups()
 	}
+
+	kings := map[int]int{}
+	for i, piece := range b.Board {
+		if piece&0b0111 == KING {
+			kings[piece&0b1000] += 1
+		}
+
+		if piece&0b0111 == PAWN && (i < 8 || i >= 56) {
+			return fmt.Errorf("%s pawn on back rank square %s", sideName(piece&0b1000), int2algebraic(i))
+		}
+	}
+
+	for _, side := range []int{WHITE, BLACK} {
+		switch kings[side] {
+		case 0:
+			return fmt.Errorf("%s has no king", sideName(side))
+		case 1:
+		default:
+			return fmt.Errorf("%s has %d kings", sideName(side), kings[side])
+		}
+	}
+
+	castling := []struct {
+		right  int
+		symbol string
+		king   int
+		rook   int
+		piece  int
+	}{
+		{0b1000, "K", 4, 7, WHITE},
+		{0b0100, "Q", 4, 0, WHITE},
+		{0b0010, "k", 60, 63, BLACK},
+		{0b0001, "q", 60, 56, BLACK},
+	}
+	for _, c := range castling {
+		if b.CastlingRights&c.right == 0 {
+			continue
+		}
+
+		if b.Board[c.king] != c.piece|KING || b.Board[c.rook] != c.piece|ROOK {
+			return fmt.Errorf("castling right '%s' needs a king on %s and a rook on %s", c.symbol, int2algebraic(c.king), int2algebraic(c.rook))
+		}
+	}
+
+	if b.EnPassantTarget != -1 {
+		// the target is the square the enemy pawn skipped over, so it has to
+		// be on the enemy's third rank with their pawn just in front of it
+		targetRank, pawnOffset, enemyPawn := 5, -8, BLACK|PAWN
+		if b.Active == 1 {
+			targetRank, pawnOffset, enemyPawn = 2, 8, WHITE|PAWN
+		}
+
+		target := b.EnPassantTarget
+		if target/8 != targetRank {
+			return fmt.Errorf("en passant target %s is not on rank %d", int2algebraic(target), targetRank+1)
+		}
+
+		if b.Board[target] != EMPTY || b.Board[target-pawnOffset] != EMPTY || b.Board[target+pawnOffset] != enemyPawn {
+			return fmt.Errorf("en passant target %s doesn't follow a double pawn push", int2algebraic(target))
+		}
+	}
+
+	bitboard := BitBoard{}
+	bitboard.FromEBE(b.Board)
+	waiting := enemy(b.Active << 3)
+	if bitboard.InCheck(waiting) {
+		return fmt.Errorf("%s is in check but it is %s's turn", sideName(waiting), sideName(b.Active<<3))
+	}
+
+	return nil
+}
+
+func sideName(side int) string {
+	if side == BLACK {
+		return "black"
+	}
+
+	return "white"
+}
+
+func isSquare(pos string) bool {
+	return len(pos) == 2 && pos[0] >= 'a' && pos[0] <= 'h' && pos[1] >= '1' && pos[1] <= '8'
 }
@@ -2,7 +2,10 @@ package chess
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"slices"
 	"testing"
 )
@@ -64,7 +67,10 @@ func TestStartingBoard(t *testing.T) {
 	expected := DefaultBoard()
 
 	actual := EBE{}
-	actual.FromFEN(StartingFEN)
+	err := actual.FromFEN(StartingFEN)
+	if err != nil {
+		t.Fatalf("Expected starting FEN to parse, got error: %s", err)
+	}
 
 	EBEEqual(t, expected, actual)
 }
@@ -82,10 +88,95 @@ func TestFENDecodeEncode(t *testing.T) {
 	expected := "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"
 
 	board := EBE{}
-	board.FromFEN(expected)
+	err := board.FromFEN(expected)
+	if err != nil {
+		t.Fatalf("Expected %s to parse, got error: %s", expected, err)
+	}
 
 	actual := board.ToFEN()
 	if expected != actual {
 		t.Errorf(fmt.Sprintf("Expected FEN string != actual FEN string\nExpected:\n%s\n\nActual:\n%s", expected, actual))
 	}
 }
+
+func TestFENValidation(t *testing.T) {
+	invalid := map[string]string{
+		"too few fields":            "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq",
+		"too few ranks":             "rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"too many ranks":            "rnbqkbnr/pppppppp/8/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"long rank":                 "rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"short rank":                "rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"bad piece letter":          "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1",
+		"bad active color":          "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+		"missing white king":        "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQQBNR w kq - 0 1",
+		"two black kings":           "rnbkkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1",
+		"pawn on back rank":         "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNP w Qkq - 0 1",
+		"bad castling letter":       "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkx - 0 1",
+		"castling without rook":     "rnbqkbn1/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"castling with moved king":  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w KQkq - 0 1",
+		"en passant off the board":  "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e9 0 1",
+		"en passant wrong rank":     "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e4 0 1",
+		"en passant without pawn":   "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq e3 0 1",
+		"bad halfmove clock":        "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1",
+		"bad fullmove number":       "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0",
+		"side not to move in check": "4k3/8/8/8/8/8/8/4RK2 w - - 0 1",
+	}
+
+	for name, fen := range invalid {
+		board := DefaultBoard()
+		err := board.FromFEN(fen)
+		if !errors.Is(err, ErrInvalidFEN) {
+			t.Errorf("Expected %s (%s) to be rejected, got error %v", name, fen, err)
+		}
+
+		EBEEqual(t, DefaultBoard(), board)
+	}
+
+	valid := []string{
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+		"4k3/8/8/8/8/8/8/4RK2 b - - 0 1",
+	}
+
+	for _, fen := range valid {
+		board := EBE{}
+		err := board.FromFEN(fen)
+		if err != nil {
+			t.Errorf("Expected %s to parse, got error: %s", fen, err)
+		}
+	}
+}
+
+func TestFENWithoutLookups(t *testing.T) {
+	// the lookups are package state that other tests set up, so the position
+	// is parsed again in a process of its own
+	if os.Getenv("GOMES_FRESH_PROCESS") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFENWithoutLookups$")
+		cmd.Env = append(os.Environ(), "GOMES_FRESH_PROCESS=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("Expected no error parsing in a fresh process, got %s:\n%s", err, out)
+		}
+		return
+	}
+
+	if LOOKUPS_INITIALIZED {
+		t.Fatal("Expected the lookups not to be set up in a fresh process")
+	}
+
+	board := EBE{}
+	err := board.FromFEN("4k3/8/3N4/8/8/8/8/4K3 w - - 0 1")
+	if !errors.Is(err, ErrInvalidFEN) {
+		t.Errorf("Expected black in check from a knight to be rejected, got error %v", err)
+	}
+
+	err = board.FromFEN("4k3/8/8/8/8/8/8/4K1N1 w - - 0 1")
+	if err != nil {
+		t.Errorf("Expected no error parsing, got %s", err)
+	}
+
+	if !LOOKUPS_INITIALIZED || KING_LOOKUP[4] != getKingMoves(1<<4) || KNIGHT_LOOKUP[6] != getKnightMoves(1<<6) {
+		t.Errorf("Expected the lookups to be set up once a position was parsed")
+	}
+}
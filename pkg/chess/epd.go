@@ -0,0 +1,185 @@
+package chess
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EPD is a single Extended Position Description record: a position plus the
+// opcodes attached to it (bm, am, id, ...).
+type EPD struct {
+	FEN        string
+	Operations map[string][]string
+}
+
+// ParseEPD parses one EPD line. The halfmove clock and fullmove number are
+// taken from the hmvc and fmvn opcodes when present.
+func ParseEPD(line string) (EPD, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return EPD{}, fmt.Errorf("epd %q: expected at least 4 position fields, found %d", line, len(fields))
+	}
+
+	epd := EPD{
+		Operations: make(map[string][]string),
+	}
+
+	// skip past the four position fields to find where the opcodes start
+	rest := strings.TrimSpace(line)
+	for range 4 {
+		rest = strings.TrimSpace(rest)
+		i := strings.IndexAny(rest, " \t")
+		if i == -1 {
+			rest = ""
+			break
+		}
+		rest = rest[i:]
+	}
+
+	for _, op := range splitOperations(rest) {
+		tokens := tokenizeOperation(op)
+		if len(tokens) == 0 {
+			continue
+		}
+		epd.Operations[tokens[0]] = tokens[1:]
+	}
+
+	halfmoves, fullmoves := "0", "1"
+	if hmvc := epd.Operations["hmvc"]; len(hmvc) > 0 {
+		halfmoves = hmvc[0]
+	}
+	if fmvn := epd.Operations["fmvn"]; len(fmvn) > 0 {
+		fullmoves = fmvn[0]
+	}
+	epd.FEN = strings.Join(append(fields[:4:4], halfmoves, fullmoves), " ")
+
+	return epd, nil
+}
+
+// ReadEPD parses every non-empty, non-comment line of r as an EPD record.
+func ReadEPD(r io.Reader) ([]EPD, error) {
+	records := []EPD{}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		epd, err := ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		records = append(records, epd)
+	}
+
+	return records, scanner.Err()
+}
+
+// ID returns the id opcode, or an empty string if the record has none.
+func (e EPD) ID() string {
+	id := e.Operations["id"]
+	if len(id) == 0 {
+		return ""
+	}
+
+	return id[0]
+}
+
+// BestMoves returns the SAN moves listed under the bm opcode.
+func (e EPD) BestMoves() []string {
+	return e.Operations["bm"]
+}
+
+// AvoidMoves returns the SAN moves listed under the am opcode.
+func (e EPD) AvoidMoves() []string {
+	return e.Operations["am"]
+}
+
+// Solved reports whether the engine's choice satisfies the record's bm and am
+// opcodes. The SAN strings are compared after removing check suffixes.
+func (e EPD) Solved(san string) bool {
+	san = normalizeSAN(san)
+
+	best := e.BestMoves()
+	if len(best) > 0 {
+		found := false
+		for _, bm := range best {
+			if normalizeSAN(bm) == san {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, am := range e.AvoidMoves() {
+		if normalizeSAN(am) == san {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splitOperations(s string) []string {
+	ops := []string{}
+
+	quoted := false
+	start := 0
+	for i, char := range s {
+		switch char {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				ops = append(ops, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		ops = append(ops, last)
+	}
+
+	return ops
+}
+
+func tokenizeOperation(op string) []string {
+	tokens := []string{}
+
+	current := strings.Builder{}
+	quoted := false
+	for _, char := range op {
+		switch {
+		case char == '"':
+			quoted = !quoted
+			if !quoted {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		case (char == ' ' || char == '\t') && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(char)
+		}
+	}
+
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens
+}
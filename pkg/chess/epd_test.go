@@ -0,0 +1,70 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEPD(t *testing.T) {
+	line := `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";`
+
+	epd, err := ParseEPD(line)
+	if err != nil {
+		t.Fatalf("Expected %s to parse, got error: %s", line, err)
+	}
+
+	expectedFEN := "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - 0 1"
+	if epd.FEN != expectedFEN {
+		t.Errorf("Expected FEN (%s) != actual FEN (%s)", expectedFEN, epd.FEN)
+	}
+
+	if epd.ID() != "WAC.001" {
+		t.Errorf("Expected id (WAC.001) != actual id (%s)", epd.ID())
+	}
+
+	if !ArrayEqual([]string{"Qg6"}, epd.BestMoves()) {
+		t.Errorf("Expected best moves ([Qg6]) != actual best moves (%+v)", epd.BestMoves())
+	}
+
+	if !epd.Solved("Qg6+") || epd.Solved("Qh3") {
+		t.Errorf("Expected only Qg6 to solve %s", line)
+	}
+}
+
+func TestParseEPDOpcodes(t *testing.T) {
+	line := `r1b2rk1/ppq1bppp/2n1pn2/3p4/2PP4/2N1PN2/PP2BPPP/R2QKB1R w KQ - am c5 a3; id "quoted; with separator"; hmvc 3; fmvn 12;`
+
+	epd, err := ParseEPD(line)
+	if err != nil {
+		t.Fatalf("Expected %s to parse, got error: %s", line, err)
+	}
+
+	if !strings.HasSuffix(epd.FEN, " 3 12") {
+		t.Errorf("Expected FEN (%s) to take its move counters from hmvc and fmvn", epd.FEN)
+	}
+
+	if epd.ID() != "quoted; with separator" {
+		t.Errorf("Expected id (quoted; with separator) != actual id (%s)", epd.ID())
+	}
+
+	if epd.Solved("a3") || !epd.Solved("O-O") {
+		t.Errorf("Expected am moves to be rejected and others accepted for %s", line)
+	}
+}
+
+func TestReadEPD(t *testing.T) {
+	input := "# comment\n\n" + StartingFEN[:strings.Index(StartingFEN, " - ")+2] + " bm e4;\n"
+
+	records, err := ReadEPD(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected input to parse, got error: %s", err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+
+	if records[0].FEN != StartingFEN {
+		t.Errorf("Expected FEN (%s) != actual FEN (%s)", StartingFEN, records[0].FEN)
+	}
+}
@@ -1,7 +1,8 @@
 package chess
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"math"
 	"sync"
 	"time"
@@ -9,6 +10,15 @@ import (
 
 const PARALLEL_SEARCH = true
 
+// SearchInfo describes a completed iteration of the iterative deepening search.
+type SearchInfo struct {
+	Depth   int
+	Nodes   int
+	Elapsed time.Duration
+	Best    Move
+	Value   float64
+}
+
 type searchMsg struct {
 	index     int
 	val       float64
@@ -18,20 +28,40 @@ type searchMsg struct {
 }
 
 func (c *ChessGame) Search() ([]Move, []float64) {
-	fmt.Printf("starting search with max depth %d\n", c.MaxSearchDepth)
+	log := c.logger()
+	log.Debug("starting search", "max_depth", c.MaxSearchDepth, "search_time", c.SearchTime)
 	options := c.GetLegalMoves()
 
 	vals := make([]float64, len(options))
+	if len(options) == 0 {
+		return options, vals
+	}
+
 	c.Transpositions = make(map[EBEBoard]TranspositionNode)
 
+	// every clone shares the done channel, so closing it stops all of them
+	// rather than only the goroutine that happens to read the timer
+	done := make(chan struct{})
+	c.SearchDone = done
 	c.SearchStart = time.Now()
-	c.SearchTimer = time.NewTimer(c.SearchTime)
+	c.SearchTimer = time.AfterFunc(c.SearchTime, func() {
+		close(done)
+	})
 	defer c.SearchTimer.Stop()
 
 	wg := sync.WaitGroup{}
+	var threads chan struct{}
+	if c.Threads > 0 {
+		threads = make(chan struct{}, c.Threads)
+	}
 
+	nodes := 0
 	depth := 0
 	for {
+		if c.MaxSearchDepth > 0 && depth >= c.MaxSearchDepth {
+			break
+		}
+
 		finished := true
 		evaluated := 0
 		skipped := 0
@@ -47,9 +77,15 @@ func (c *ChessGame) Search() ([]Move, []float64) {
 			if PARALLEL_SEARCH {
 				clone := c.Clone()
 				wg.Add(1)
+				if threads != nil {
+					threads <- struct{}{}
+				}
 
 				go func() {
 					defer wg.Done()
+					if threads != nil {
+						defer func() { <-threads }()
+					}
 
 					clone.MakeMove(move)
 					v, e, s := clone.Minimax(0, depth, math.Inf(-1), math.Inf(1))
@@ -70,7 +106,7 @@ func (c *ChessGame) Search() ([]Move, []float64) {
 				}()
 			} else {
 				select {
-				case <-c.SearchTimer.C:
+				case <-c.SearchDone:
 					finished = false
 					break
 				default:
@@ -112,27 +148,52 @@ func (c *ChessGame) Search() ([]Move, []float64) {
 		}
 
 		if !finished {
-			fmt.Printf("max search time (%dms) reached before search to depth %d finished, exited without updating vals\n", c.SearchTime.Milliseconds(), depth)
+			log.Debug("ran out of time before finishing the depth, keeping the last one's scores", "depth", depth+1, "search_time", c.SearchTime)
 			break
 		}
 
-		fmt.Printf("searched %d nodes, skipped %d to depth %d, %dms since search start\n", evaluated, skipped, depth, time.Since(c.SearchStart).Milliseconds())
+		log.Debug("searched depth", "depth", depth+1, "nodes", evaluated, "skipped", skipped, "elapsed", time.Since(c.SearchStart))
 		vals = searchVals
+		nodes += evaluated
+
+		if c.OnSearchInfo != nil {
+			best := bestIndex(vals, c.EBE.Active<<3 == WHITE)
+			c.OnSearchInfo(SearchInfo{
+				Depth:   depth + 1,
+				Nodes:   nodes,
+				Elapsed: time.Since(c.SearchStart),
+				Best:    options[best],
+				Value:   vals[best],
+			})
+		}
 
 		depth++
 	}
 
 	options, vals = sortMoves(options, vals, true)
 
-	fmt.Println("search results")
-	for i := range options {
-		fmt.Printf(" > %s == %f\n", options[i], vals[i])
+	if log.Enabled(context.Background(), slog.LevelDebug) {
+		results := make([]any, len(options))
+		for i := range options {
+			results[i] = slog.Float64(options[i].String(), vals[i])
+		}
+		log.Debug("finished search", "nodes", nodes, "elapsed", time.Since(c.SearchStart), slog.Group("scores", results...))
 	}
-	fmt.Printf("total search time: %dms\n", time.Since(c.SearchStart).Milliseconds())
 
 	return options, vals
 }
 
+func bestIndex(vals []float64, maximize bool) int {
+	best := 0
+	for i := range vals {
+		if (maximize && vals[i] > vals[best]) || (!maximize && vals[i] < vals[best]) {
+			best = i
+		}
+	}
+
+	return best
+}
+
 func sortMoves(options []Move, vals []float64, ascending bool) ([]Move, []float64) {
 	for i := range len(options) - 1 {
 		for j := 0; j < len(options)-i-1; j++ {
@@ -196,7 +257,9 @@ func (c *ChessGame) Minimax(depth, stopDepth int, alpha, beta float64) (float64,
 	skipped := 0
 	checked := 0
 
-	moves = c.PreOrder(moves)
+	if c.MoveOrdering {
+		moves = c.PreOrder(moves)
+	}
 
 	if c.EBE.Active<<3 == WHITE {
 		value := math.Inf(-1)
@@ -204,7 +267,7 @@ func (c *ChessGame) Minimax(depth, stopDepth int, alpha, beta float64) (float64,
 
 		for _, move := range moves {
 			select {
-			case <-c.SearchTimer.C:
+			case <-c.SearchDone:
 				return 0, -1, 0
 			default:
 				c.MakeMove(move)
@@ -239,7 +302,7 @@ func (c *ChessGame) Minimax(depth, stopDepth int, alpha, beta float64) (float64,
 
 		for _, move := range moves {
 			select {
-			case <-c.SearchTimer.C:
+			case <-c.SearchDone:
 				return 0, -1, 0
 			default:
 				c.MakeMove(move)
@@ -296,16 +359,18 @@ func (c *ChessGame) Evaluate(currentDepth int) float64 {
 }
 
 func (c *ChessGame) Material(side int) int {
-	score := 2000 * len(toPieceLocations(c.Bitboard[side|KING]))
-	score += 90 * len(toPieceLocations(c.Bitboard[side|QUEEN]))
-	score += 50 * len(toPieceLocations(c.Bitboard[side|ROOK]))
-	score += 30 * len(toPieceLocations(c.Bitboard[side|BISHOP]))
-	score += 30 * len(toPieceLocations(c.Bitboard[side|KNIGHT]))
+	w := c.Weights
+
+	score := w.King * len(toPieceLocations(c.Bitboard[side|KING]))
+	score += w.Queen * len(toPieceLocations(c.Bitboard[side|QUEEN]))
+	score += w.Rook * len(toPieceLocations(c.Bitboard[side|ROOK]))
+	score += w.Bishop * len(toPieceLocations(c.Bitboard[side|BISHOP]))
+	score += w.Knight * len(toPieceLocations(c.Bitboard[side|KNIGHT]))
 
 	pawnBoard := c.Bitboard[side|PAWN]
 	pawns := toPieceLocations(pawnBoard)
-	score += 10 * len(pawns)
-	score -= 5 * len(toPieceLocations(pawnBoard&(pawnBoard<<8)))
+	score += w.Pawn * len(pawns)
+	score -= w.DoubledPawn * len(toPieceLocations(pawnBoard&(pawnBoard<<8)))
 
 	blocked := 0
 	isolated := 0
@@ -343,7 +408,7 @@ func (c *ChessGame) Material(side int) int {
 		}
 	}
 
-	score -= 5 * (blocked + isolated)
+	score -= w.BlockedPawn*blocked + w.IsolatedPawn*isolated
 
 	flip := false
 	if side != c.EBE.Active<<3 {
@@ -351,7 +416,7 @@ func (c *ChessGame) Material(side int) int {
 		c.EBE.Active = (^c.EBE.Active) & 0b1
 	}
 
-	score += len(c.GetLegalMoves())
+	score += w.Mobility * len(c.GetLegalMoves())
 	if flip {
 		c.EBE.Active = (^c.EBE.Active) & 0b1
 	}
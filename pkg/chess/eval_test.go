@@ -1,6 +1,12 @@
 package chess
 
-import "testing"
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestMaterial(t *testing.T) {
 	game := NewGame()
@@ -11,3 +17,25 @@ func TestMaterial(t *testing.T) {
 		t.Errorf("White material (%d) should equal black material (%d)", whiteMaterial, blackMaterial)
 	}
 }
+
+func TestSearchLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	game := NewGame()
+	game.MaxSearchDepth = 2
+	game.SearchTime = 10 * time.Second
+	game.Logger = slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})).With("game", "abc")
+
+	game.Search()
+
+	logged := out.String()
+	for _, expected := range []string{"msg=\"starting search\"", "msg=\"searched depth\" game=abc depth=2", "msg=\"finished search\""} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Expected the log to contain (%s), got:\n%s", expected, logged)
+		}
+	}
+
+	// clones search with the same logger
+	if game.Clone().Logger != game.Logger {
+		t.Errorf("Expected clones to keep the game's logger")
+	}
+}
@@ -0,0 +1,28 @@
+package chess
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger is where games without a Logger of their own log to, along with
+// loading the opening book. It discards everything unless it's replaced, so
+// the engine is silent when used as a library.
+var Logger = slog.New(discardHandler{})
+
+// discardHandler is a handler that's never enabled.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logger is the game's Logger, or the package's if it hasn't got one.
+func (c *ChessGame) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+
+	return Logger
+}
@@ -2,8 +2,10 @@ package chess
 
 import (
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,6 +21,18 @@ type ChessGame struct {
 	SearchStart        time.Time
 	SearchTime         time.Duration
 	SearchTimer        *time.Timer
+	SearchDone         chan struct{}
+	OnSearchInfo       func(SearchInfo)
+	Weights            EvalWeights
+	MoveOrdering       bool
+
+	// Threads is how many root moves are searched at once, with zero for all
+	// of them
+	Threads int
+
+	// Logger is where the game logs its searches, at debug level. Without
+	// one, the package's Logger is used.
+	Logger *slog.Logger
 }
 
 type TranspositionNode struct {
@@ -29,6 +43,11 @@ type TranspositionNode struct {
 func Init() {
 	InitCodebook()
 	InitLookups()
+
+	err := LoadWeights()
+	if err != nil {
+		panic(err)
+	}
 }
 
 func NewGame() *ChessGame {
@@ -42,6 +61,8 @@ func NewGame() *ChessGame {
 		MaxSearchDepth:     4,
 		SearchTime:         2,
 		TranspositionMutex: &sync.RWMutex{},
+		Weights:            DefaultWeights,
+		MoveOrdering:       true,
 	}
 
 	c.Bitboard.FromEBE(c.EBE.Board)
@@ -63,16 +84,26 @@ func (c *ChessGame) Clone() *ChessGame {
 
 	clone.Transpositions = c.Transpositions
 	clone.SearchTimer = c.SearchTimer
+	clone.SearchDone = c.SearchDone
+	clone.Weights = c.Weights
+	clone.MoveOrdering = c.MoveOrdering
 	clone.TranspositionMutex = c.TranspositionMutex
+	clone.Logger = c.Logger
 
 	return clone
 }
 
-func (c *ChessGame) SetStateFromFEN(fen string) {
-	c.EBE.FromFEN(fen)
+func (c *ChessGame) SetStateFromFEN(fen string) error {
+	err := c.EBE.FromFEN(fen)
+	if err != nil {
+		return err
+	}
+
 	c.Bitboard.FromEBE(c.EBE.Board)
 	c.Moves = []Move{}
 	c.Captured = []int{}
+
+	return nil
 }
 
 func copyBitboard(source, dest *BitBoard) {
@@ -94,11 +125,11 @@ func (c *ChessGame) BestMove() Move {
 	codebookMove, ok := ChooseFromCodebook(c.EBE.Board, c.EBE.Active)
 	if ok {
 		time.Sleep(c.SearchTime)
-		fmt.Printf("selected move from codebook: %+v\n", codebookMove)
+		c.logger().Debug("chose a move from the opening book", "move", codebookMove.String())
 		return codebookMove
 	}
 
-	fmt.Printf("board not in codebook, searching\n")
+	c.logger().Debug("board not in the opening book, searching")
 
 	options, vals := c.Search()
 	if c.EBE.Active<<3 == WHITE {
@@ -118,7 +149,7 @@ func (c *ChessGame) BestMove() Move {
 		return options[0]
 	}
 
-	fmt.Printf("Selecting from top %d moves equivalent scores\n", topX)
+	c.logger().Debug("choosing between moves with equal scores", "moves", topX+1)
 
 	return options[rand.Intn(topX)]
 }
@@ -164,15 +195,12 @@ func (c *ChessGame) GetLegalMoves() []Move {
 }
 
 func (c *ChessGame) GetMoveTargets(pieceLocation int) []int {
-	fmt.Printf("getting moves for %d\n", pieceLocation)
 	pseudoLegal := c.GeneratePseudoLegal()
-	fmt.Printf("found %d pseudolegal moves\n", len(pseudoLegal))
 
 	moves := []int{}
 	active := c.EBE.Active << 3
 	for _, move := range pseudoLegal {
 		if move.Start != pieceLocation {
-			fmt.Printf("discarding %+v, %d->%d\n", move, move.Start, move.End)
 			continue
 		}
 
@@ -186,74 +214,126 @@ func (c *ChessGame) GetMoveTargets(pieceLocation int) []int {
 	return moves
 }
 
+// DivideEntry is the perft node count below a single root move.
+type DivideEntry struct {
+	Move  string
+	Nodes int
+	FEN   string
+}
+
+type perftMsg struct {
+	move  Move
+	count int
+	fen   string
+	legal bool
+}
+
 func (c *ChessGame) Perft(depth, startDepth int, debug bool) (int, string) {
 	if depth == 0 {
 		return 1, ""
 	}
 
-	start := time.Time{}
-	wg := sync.WaitGroup{}
+	start := time.Now()
+	count, divide := c.perft(depth, startDepth)
+
+	resultString := ""
+	for _, entry := range divide {
+		resultString += fmt.Sprintf("%s: %d %s\n", entry.Move, entry.Nodes, entry.FEN)
+	}
+
 	if depth == startDepth {
-		start = time.Now()
+		runTime := time.Since(start)
+		c.logger().Info("perft finished", "nodes", count, "depth", startDepth, "elapsed", runTime, "nodes_per_second", int(float32(count)/float32(runTime.Microseconds())*1e6))
 	}
 
-	resultString := ""
+	return count, resultString
+}
+
+// Divide runs perft to the given depth and returns the node count below each
+// legal root move, sorted by move so the output can be diffed against other
+// engines.
+func (c *ChessGame) Divide(depth int) ([]DivideEntry, int) {
+	if depth == 0 {
+		return []DivideEntry{}, 1
+	}
+
+	count, divide := c.perft(depth, depth)
+	return divide, count
+}
+
+func (c *ChessGame) perft(depth, startDepth int) (int, []DivideEntry) {
+	if depth == 0 {
+		return 1, nil
+	}
+
+	wg := sync.WaitGroup{}
 
 	count := 0
 	moves := c.GeneratePseudoLegal()
-	res := make(chan int, len(moves))
+	res := make(chan perftMsg, len(moves))
 
 	for _, move := range moves {
-		// if depth == startDepth {
-		// 	fmt.Printf("Searching after move %s on board state\n%s\n", move, c.EBE.Board)
-		// }
 		if (depth >= 5 || depth == startDepth) && PARALLEL_SEARCH {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
 				clone := c.Clone()
 				active := clone.EBE.Active << 3
-				childMoveCount := 0
+				msg := perftMsg{move: move}
 
 				clone.MakeMove(move)
 				if !clone.Bitboard.InCheck(active) {
-					childMoveCount, _ = clone.Perft(depth-1, startDepth, debug)
+					msg.legal = true
+					msg.count, _ = clone.perft(depth-1, startDepth)
 				}
 
-				if depth == startDepth && childMoveCount != 0 {
-					resultString += fmt.Sprintf("%s: %d %s\n", move, childMoveCount, clone.EBE.ToFEN())
+				if depth == startDepth {
+					msg.fen = clone.EBE.ToFEN()
 				}
 				clone.UnmakeMove(move)
 
-				res <- childMoveCount
+				res <- msg
 			}()
 		} else {
 			active := c.EBE.Active << 3
-			childMoveCount := 0
+			msg := perftMsg{move: move}
 
 			c.MakeMove(move)
 			if !c.Bitboard.InCheck(active) {
-				childMoveCount, _ = c.Perft(depth-1, startDepth, debug)
+				msg.legal = true
+				msg.count, _ = c.perft(depth-1, startDepth)
 			}
 
-			res <- childMoveCount
-			if depth == startDepth && childMoveCount != 0 {
-				resultString += fmt.Sprintf("%s: %d %s\n", move, childMoveCount, c.EBE.ToFEN())
+			if depth == startDepth {
+				msg.fen = c.EBE.ToFEN()
 			}
 			c.UnmakeMove(move)
+
+			res <- msg
 		}
 	}
 
 	wg.Wait()
 
+	// the root entries are only collected here, after every goroutine is done,
+	// so the divide output never races
+	divide := []DivideEntry{}
 	for range moves {
-		count += <-res
+		msg := <-res
+		count += msg.count
+
+		if depth == startDepth && msg.legal {
+			divide = append(divide, DivideEntry{
+				Move:  msg.move.String(),
+				Nodes: msg.count,
+				FEN:   msg.fen,
+			})
+		}
 	}
 
-	if depth == startDepth {
-		runTime := time.Since(start)
-		fmt.Printf("perft evaluated %d moves to depth of %d in %05dms, %08d moves/second\n", count, startDepth, runTime.Milliseconds(), int(float32(count)/float32(runTime.Microseconds())*1e6))
-	}
+	slices.SortFunc(divide, func(a, b DivideEntry) int {
+		return strings.Compare(a.Move, b.Move)
+	})
 
-	return count, resultString
+	return count, divide
 }
@@ -87,11 +87,7 @@ func (c *ChessGame) GeneratePseudoLegalKing(side int) []Move {
 
 	kingLocs := toPieceLocations(c.Bitboard[side|KING])
 	if len(kingLocs) == 0 {
-		fmt.Printf("generating king moves for\n%s\nin board\n%s\n", To2DString(c.Bitboard[side|KING]), c.EBE.Board)
-		for _, move := range c.Moves {
-			fmt.Printf(" %s", move)
-		}
-		fmt.Println()
+		c.logger().Error("no king to generate moves for", "side", side, "board", c.EBE.Board.String(), "moves", fmt.Sprint(c.Moves))
 	}
 	kingLoc := kingLocs[0]
 	moveLocs := toPieceLocations(KING_LOOKUP[kingLoc] & (^c.Bitboard[side]))
@@ -171,3 +171,29 @@ func BenchmarkSearch(b *testing.B) {
 
 	c.Search()
 }
+
+func TestDivide(t *testing.T) {
+	c := NewGame()
+	c.SetStateFromFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ")
+
+	entries, count := c.Divide(2)
+	if count != 2039 {
+		t.Errorf("Expected divide total (2039) != actual total (%d)", count)
+	}
+
+	if len(entries) != 48 {
+		t.Errorf("Expected 48 root moves, got %d", len(entries))
+	}
+
+	sum := 0
+	for i, entry := range entries {
+		sum += entry.Nodes
+		if i > 0 && entries[i-1].Move >= entry.Move {
+			t.Errorf("Expected divide entries to be sorted, %s came before %s", entries[i-1].Move, entry.Move)
+		}
+	}
+
+	if sum != count {
+		t.Errorf("Expected divide entries to sum to the total (%d), got %d", count, sum)
+	}
+}
@@ -0,0 +1,91 @@
+package chess
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// PGN is a finished or in-progress game ready to be written out in portable
+// game notation. Moves are in SAN, starting from the FEN tag if one is set.
+type PGN struct {
+	Tags   map[string]string
+	Moves  []string
+	Result string
+}
+
+func (p PGN) String() string {
+	s := ""
+
+	tags := map[string]string{}
+	for name, value := range p.Tags {
+		tags[name] = value
+	}
+	tags["Result"] = p.Result
+
+	for _, name := range sevenTagRoster {
+		value, ok := tags[name]
+		if !ok {
+			value = "?"
+		}
+		s += fmt.Sprintf("[%s \"%s\"]\n", name, escapeTag(value))
+	}
+
+	extra := []string{}
+	for name := range tags {
+		if !slices.Contains(sevenTagRoster, name) {
+			extra = append(extra, name)
+		}
+	}
+	slices.Sort(extra)
+	for _, name := range extra {
+		s += fmt.Sprintf("[%s \"%s\"]\n", name, escapeTag(tags[name]))
+	}
+	s += "\n"
+
+	moveNumber, active := 1, 0
+	if fen, ok := tags["FEN"]; ok {
+		start := EBE{}
+		if err := start.FromFEN(fen); err == nil {
+			moveNumber, active = start.Moves, start.Active
+		}
+	}
+
+	tokens := []string{}
+	for i, move := range p.Moves {
+		if active == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d.", moveNumber))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+		}
+		tokens = append(tokens, move)
+
+		if active == 1 {
+			moveNumber++
+		}
+		active = ^active & 0b1
+	}
+	tokens = append(tokens, p.Result)
+
+	line := ""
+	for _, token := range tokens {
+		if len(line)+len(token)+1 > 80 {
+			s += line + "\n"
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += token
+	}
+	s += line + "\n"
+
+	return s
+}
+
+func escapeTag(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	return strings.ReplaceAll(value, "\"", "\\\"")
+}
@@ -0,0 +1,51 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPGNString(t *testing.T) {
+	game := PGN{
+		Tags: map[string]string{
+			"White":       "base",
+			"Black":       "dev",
+			"Termination": "adjudication",
+		},
+		Moves:  []string{"e4", "e5", "Nf3"},
+		Result: "1/2-1/2",
+	}
+
+	expected := `[Event "?"]
+[Site "?"]
+[Date "?"]
+[Round "?"]
+[White "base"]
+[Black "dev"]
+[Result "1/2-1/2"]
+[Termination "adjudication"]
+
+1. e4 e5 2. Nf3 1/2-1/2
+`
+
+	actual := game.String()
+	if expected != actual {
+		t.Errorf("Expected PGN != actual PGN\nExpected:\n%s\nActual:\n%s", expected, actual)
+	}
+}
+
+func TestPGNStringFromFEN(t *testing.T) {
+	game := PGN{
+		Tags: map[string]string{
+			"FEN":   "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+			"SetUp": "1",
+		},
+		Moves:  []string{"c5", "Nf3"},
+		Result: "*",
+	}
+
+	actual := game.String()
+	if !strings.Contains(actual, "\n1... c5 2. Nf3 *\n") {
+		t.Errorf("Expected movetext to start with black's first move, got\n%s", actual)
+	}
+}
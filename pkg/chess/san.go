@@ -0,0 +1,97 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MoveToSAN returns the standard algebraic notation for a legal move in the
+// current position, including the check or mate suffix.
+func (c *ChessGame) MoveToSAN(move Move) string {
+	san := ""
+
+	switch {
+	case move.Castle && move.End > move.Start:
+		san = "O-O"
+	case move.Castle:
+		san = "O-O-O"
+	case move.Piece&0b0111 == PAWN:
+		if move.Start%8 != move.End%8 {
+			san = int2algebraic(move.Start)[:1] + "x"
+		}
+		san += int2algebraic(move.End)
+		if move.Promotion != EMPTY {
+			san += "=" + strings.ToUpper(piece2String[move.Promotion])
+		}
+	default:
+		san = strings.ToUpper(piece2String[move.Piece])
+
+		ambiguous, sameFile, sameRank := false, false, false
+		for _, other := range c.GetLegalMoves() {
+			if other.Piece != move.Piece || other.End != move.End || other.Start == move.Start {
+				continue
+			}
+
+			ambiguous = true
+			sameFile = sameFile || other.Start%8 == move.Start%8
+			sameRank = sameRank || other.Start/8 == move.Start/8
+		}
+
+		if ambiguous {
+			start := int2algebraic(move.Start)
+			switch {
+			case !sameFile:
+				san += start[:1]
+			case !sameRank:
+				san += start[1:]
+			default:
+				san += start
+			}
+		}
+
+		if move.Capture != EMPTY {
+			san += "x"
+		}
+		san += int2algebraic(move.End)
+	}
+
+	c.MakeMove(move)
+	if c.Bitboard.InCheck(c.EBE.Active << 3) {
+		if len(c.GetLegalMoves()) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	c.UnmakeMove(move)
+
+	return san
+}
+
+// MoveFromSAN finds the legal move in the current position matching the
+// given standard algebraic notation. Check, mate and annotation suffixes are
+// ignored.
+func (c *ChessGame) MoveFromSAN(san string) (Move, error) {
+	target := normalizeSAN(san)
+	if target == "" {
+		return Move{}, fmt.Errorf("empty move")
+	}
+
+	for _, move := range c.GetLegalMoves() {
+		if normalizeSAN(c.MoveToSAN(move)) == target {
+			return move, nil
+		}
+	}
+
+	return Move{}, fmt.Errorf("%s is not a legal move in %s", san, c.EBE.ToFEN())
+}
+
+func normalizeSAN(san string) string {
+	san = strings.TrimSpace(san)
+	san = strings.TrimRight(san, "+#!?")
+	san = strings.TrimSuffix(san, "e.p.")
+	san = strings.ReplaceAll(san, "0", "O")
+	san = strings.ReplaceAll(san, "=", "")
+
+	return san
+}
@@ -0,0 +1,56 @@
+package chess
+
+import "testing"
+
+func TestMoveToSAN(t *testing.T) {
+	cases := []struct {
+		fen      string
+		start    string
+		end      string
+		expected string
+	}{
+		{StartingFEN, "e2", "e4", "e4"},
+		{StartingFEN, "g1", "f3", "Nf3"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1", "g1", "O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1", "c1", "O-O-O"},
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "a1", "d1", "Rad1"},
+		{"4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "e4", "d5", "exd5"},
+		{"6k1/5ppp/8/8/8/8/8/R3K3 w Q - 0 1", "a1", "a8", "Ra8#"},
+		{"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", "a1", "a8", "Ra8+"},
+		{"4k3/8/8/1N6/8/8/8/1N2K3 w - - 0 1", "b1", "c3", "N1c3"},
+	}
+
+	for _, tc := range cases {
+		c := NewGame()
+		c.SetStateFromFEN(tc.fen)
+
+		move, legal := c.MoveFromLocations(algebraic2Int(tc.start), algebraic2Int(tc.end))
+		if !legal {
+			t.Errorf("Expected %s%s to be legal in %s", tc.start, tc.end, tc.fen)
+			continue
+		}
+
+		actual := c.MoveToSAN(move)
+		if tc.expected != actual {
+			t.Errorf("Expected SAN (%s) != actual SAN (%s) for %s%s in %s", tc.expected, actual, tc.start, tc.end, tc.fen)
+		}
+	}
+}
+
+func TestMoveFromSAN(t *testing.T) {
+	c := NewGame()
+	c.SetStateFromFEN("4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+
+	move, err := c.MoveFromSAN("b8=Q+")
+	if err != nil {
+		t.Fatalf("Expected b8=Q+ to parse, got error: %s", err)
+	}
+	if move.String() != "b7b8Q" {
+		t.Errorf("Expected b8=Q+ to be b7b8 promoting to a queen, got %s", move)
+	}
+
+	_, err = c.MoveFromSAN("Nf3")
+	if err == nil {
+		t.Errorf("Expected Nf3 to be rejected in %s", c.EBE.ToFEN())
+	}
+}
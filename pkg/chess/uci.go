@@ -0,0 +1,30 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UCI returns the move in the long algebraic notation used by the Universal
+// Chess Interface: the start and end squares followed by a lowercase
+// promotion piece, such as e7e8q.
+func (m Move) UCI() string {
+	return strings.ToLower(m.String())
+}
+
+// MoveFromUCI finds the legal move in the current position matching the
+// given UCI notation. The promotion piece may be given in either case.
+func (c *ChessGame) MoveFromUCI(uci string) (Move, error) {
+	target := strings.ToLower(strings.TrimSpace(uci))
+	if len(target) != 4 && len(target) != 5 {
+		return Move{}, fmt.Errorf("%q is not a UCI move", uci)
+	}
+
+	for _, move := range c.GetLegalMoves() {
+		if move.UCI() == target {
+			return move, nil
+		}
+	}
+
+	return Move{}, fmt.Errorf("%s is not a legal move in %s", uci, c.EBE.ToFEN())
+}
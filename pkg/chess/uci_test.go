@@ -0,0 +1,47 @@
+package chess
+
+import "testing"
+
+func TestMoveFromUCI(t *testing.T) {
+	cases := []struct {
+		fen      string
+		uci      string
+		expected string
+	}{
+		{StartingFEN, "e2e4", "e4"},
+		{StartingFEN, "G1F3", "Nf3"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8q", "b8=Q+"},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8N", "b8=N"},
+	}
+
+	for _, tc := range cases {
+		c := NewGame()
+		c.SetStateFromFEN(tc.fen)
+
+		move, err := c.MoveFromUCI(tc.uci)
+		if err != nil {
+			t.Errorf("Expected %s to parse in %s, got error: %s", tc.uci, tc.fen, err)
+			continue
+		}
+
+		actual := c.MoveToSAN(move)
+		if tc.expected != actual {
+			t.Errorf("Expected SAN (%s) != actual SAN (%s) for %s in %s", tc.expected, actual, tc.uci, tc.fen)
+		}
+	}
+
+	c := NewGame()
+	for _, uci := range []string{"e2e5", "e4", "e7e8q", ""} {
+		if _, err := c.MoveFromUCI(uci); err == nil {
+			t.Errorf("Expected %q to be rejected in %s", uci, c.EBE.ToFEN())
+		}
+	}
+}
+
+func TestMoveUCI(t *testing.T) {
+	move := Move{Start: algebraic2Int("b7"), End: algebraic2Int("b8"), Promotion: WHITE | QUEEN}
+	if move.UCI() != "b7b8q" {
+		t.Errorf("Expected UCI (%s) != actual UCI (%s)", "b7b8q", move.UCI())
+	}
+}
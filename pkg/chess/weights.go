@@ -0,0 +1,80 @@
+package chess
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// EvalWeights holds the coefficients used by Material. Piece values are in
+// the same units as the search scores, with a pawn worth 10.
+type EvalWeights struct {
+	King   int `json:"king"`
+	Queen  int `json:"queen"`
+	Rook   int `json:"rook"`
+	Bishop int `json:"bishop"`
+	Knight int `json:"knight"`
+	Pawn   int `json:"pawn"`
+
+	DoubledPawn  int `json:"doubled_pawn"`
+	BlockedPawn  int `json:"blocked_pawn"`
+	IsolatedPawn int `json:"isolated_pawn"`
+
+	Mobility int `json:"mobility"`
+}
+
+var DefaultWeights = EvalWeights{
+	King:   2000,
+	Queen:  90,
+	Rook:   50,
+	Bishop: 30,
+	Knight: 30,
+	Pawn:   10,
+
+	DoubledPawn:  5,
+	BlockedPawn:  5,
+	IsolatedPawn: 5,
+
+	Mobility: 1,
+}
+
+var WEIGHTS_SOURCE = "./pkg/chess/weights.json"
+
+// LoadWeights replaces DefaultWeights with the contents of WEIGHTS_SOURCE, so
+// tuned weights are used by every new game. A missing file keeps the built in
+// defaults.
+func LoadWeights() error {
+	weights, err := ReadWeights(WEIGHTS_SOURCE)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	DefaultWeights = weights
+	return nil
+}
+
+// ReadWeights loads a weights file. Fields missing from the file keep their
+// default values.
+func ReadWeights(path string) (EvalWeights, error) {
+	weights := DefaultWeights
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return weights, err
+	}
+
+	err = json.Unmarshal(data, &weights)
+	return weights, err
+}
+
+func WriteWeights(path string, weights EvalWeights) error {
+	data, err := json.MarshalIndent(weights, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -36,6 +36,9 @@ type TicTacToeGame struct {
 	State       TBT
 	SearchDepth int
 	TopK        int
+
+	// Moves are the cells played since the game was set up, in order.
+	Moves []int
 }
 
 type TBT struct {
@@ -109,6 +112,7 @@ func (t *TicTacToeGame) FromString(state string) error {
 	}
 
 	t.State.Active = player
+	t.Moves = nil
 
 	return nil
 }
@@ -138,11 +142,15 @@ func (t *TicTacToeGame) GenerateMoves() []int {
 func (t *TicTacToeGame) MakeMove(index int) {
 	t.State.Board[index] = t.State.Active
 	t.State.Active *= -1
+	t.Moves = append(t.Moves, index)
 }
 
 func (t *TicTacToeGame) UnmakeMove(index int) {
 	t.State.Board[index] = 0
 	t.State.Active *= -1
+	if len(t.Moves) > 0 {
+		t.Moves = t.Moves[:len(t.Moves)-1]
+	}
 }
 
 func (t *TicTacToeGame) Search() ([]int, []int) {
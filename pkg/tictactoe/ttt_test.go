@@ -79,6 +79,25 @@ func TestMakeMove(t *testing.T) {
 	GameEquals(t, expected, actual)
 }
 
+func TestMoves(t *testing.T) {
+	game := NewGame()
+	game.MakeMove(4)
+	game.MakeMove(0)
+	if len(game.Moves) != 2 || game.Moves[0] != 4 || game.Moves[1] != 0 {
+		t.Errorf("Expected moves ([4 0]) != actual moves (%v)", game.Moves)
+	}
+
+	game.UnmakeMove(0)
+	if len(game.Moves) != 1 || game.Moves[0] != 4 {
+		t.Errorf("Expected moves ([4]) != actual moves (%v)", game.Moves)
+	}
+
+	game.FromString("X        ,O")
+	if len(game.Moves) != 0 {
+		t.Errorf("Expected a game set up from a string to have no moves, got %v", game.Moves)
+	}
+}
+
 //
 // func TestEvaluate(t *testing.T) {
 // 	game := NewGame()